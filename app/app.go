@@ -0,0 +1,283 @@
+// app/app.go
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"strconv"
+
+	"github.com/ABDULS21985/test-portal/buildinfo"
+	"github.com/ABDULS21985/test-portal/config"
+	"github.com/ABDULS21985/test-portal/metrics"
+	"github.com/ABDULS21985/test-portal/middleware"
+	"github.com/ABDULS21985/test-portal/migrations"
+	"github.com/ABDULS21985/test-portal/openapi"
+	"github.com/ABDULS21985/test-portal/ratelimit"
+	"github.com/ABDULS21985/test-portal/repositories"
+	"github.com/ABDULS21985/test-portal/server"
+	"github.com/ABDULS21985/test-portal/tracing"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// App is the assembled application: the shared infrastructure, every repository, service and
+// middleware, and the router the modules registered their routes on
+type App struct {
+	Config     *config.Config
+	Logger     *slog.Logger
+	DB         *gorm.DB // nil when the repositories are overridden without a database
+	Metrics    *metrics.Metrics
+	Repos      repositories.Repositories
+	TxManager  repositories.TxManager
+	Services   Services
+	Middleware Middleware
+
+	// Router serves every route; API is its /api/v1 subrouter. Later versions are added with
+	// APIVersion and served side by side.
+	Router *mux.Router
+	API    *mux.Router
+	// OpenAPI describes the routes of the modules that document them
+	OpenAPI *openapi.Document
+
+	handler    http.Handler
+	modules    []Module
+	migrations []migrations.Migration
+	jobs       []Job
+	workers    *server.Workers
+	versions   map[int]*mux.Router
+	rateLimits map[string]ratelimit.Limit
+}
+
+// Middleware holds the route-level middleware modules attach to their subrouters
+type Middleware struct {
+	Auth          *middleware.AuthMiddleware
+	SCIMAuth      *middleware.SCIMAuthMiddleware
+	Impersonation *middleware.ImpersonationMiddleware
+	// RateLimiter enforces RATE_LIMITS; modules use it through App.RateLimit
+	RateLimiter *middleware.RateLimiter
+}
+
+// Options supplies the infrastructure New builds on and what tests replace
+type Options struct {
+	// DB backs the GORM repositories, the migrations and the database health check. It may be
+	// nil only when Repositories and TxManager are both set.
+	DB *gorm.DB
+	// Logger defaults to slog.Default()
+	Logger *slog.Logger
+	// Metrics defaults to a new registry protected by the configured bearer token
+	Metrics *metrics.Metrics
+
+	// Repositories and TxManager replace the GORM implementations, e.g. with repositories/memory
+	Repositories *repositories.Repositories
+	TxManager    repositories.TxManager
+	// Services set here are used instead of being built, including by the services that
+	// depend on them
+	Services Services
+	// RateLimitStore replaces the store RATE_LIMIT_STORE selects
+	RateLimitStore ratelimit.Store
+
+	// Modules are registered in order
+	Modules []Module
+}
+
+// New builds the application from cfg
+func New(cfg *config.Config, opts Options) (*App, error) {
+	if opts.DB == nil && (opts.Repositories == nil || opts.TxManager == nil) {
+		return nil, errors.New("app: a database or both repositories and a transaction manager are required")
+	}
+
+	a := &App{
+		Config:   cfg,
+		Logger:   opts.Logger,
+		DB:       opts.DB,
+		Metrics:  opts.Metrics,
+		modules:  opts.Modules,
+		workers:  server.NewWorkers(),
+		versions: map[int]*mux.Router{},
+	}
+	if a.Logger == nil {
+		a.Logger = slog.Default()
+	}
+	if a.Metrics == nil {
+		a.Metrics = metrics.New(cfg.MetricsBearerToken)
+	}
+
+	if opts.Repositories != nil {
+		a.Repos = *opts.Repositories
+	} else {
+		a.Repos = repositories.NewRepositories(opts.DB)
+	}
+	a.TxManager = opts.TxManager
+	if a.TxManager == nil {
+		a.TxManager = repositories.NewTxManager(opts.DB)
+	}
+
+	var err error
+	if a.migrations, err = ModuleMigrations(a.modules); err != nil {
+		return nil, err
+	}
+	if err := a.buildServices(opts.Services); err != nil {
+		return nil, err
+	}
+
+	a.Middleware = Middleware{
+		Auth:          middleware.NewAuthMiddleware(a.Services.Auth, a.Services.Sessions),
+		SCIMAuth:      middleware.NewSCIMAuthMiddleware(cfg.SCIMTenantTokens),
+		Impersonation: middleware.NewImpersonationMiddleware(a.Services.Audit, cfg.ImpersonationRestrictedPaths, a.Logger),
+	}
+	if err := a.buildRateLimiter(opts.RateLimitStore); err != nil {
+		return nil, err
+	}
+
+	a.Router = mux.NewRouter()
+	// Every matched request gets its route in the logging scope and a server span, and is counted
+	a.Router.Use(middleware.RequestScope, tracing.Middleware, a.Metrics.Instrument)
+	// Handlers and the queries they run share one deadline
+	a.Router.Use(middleware.Timeout(cfg.HTTPRequestTimeout))
+	a.API = a.APIVersion(1)
+	trustedProxies, err := middleware.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("TRUSTED_PROXIES: %w", err)
+	}
+	a.handler = a.middleware(trustedProxies)(a.Router)
+
+	// Modules document their routes once all of them are registered
+	a.OpenAPI = openapi.New(openapi.Info{
+		Title:   "Test Portal API",
+		Version: buildinfo.Get().Version,
+		Description: fmt.Sprintf("Routes are versioned under %[1]s/v1, %[1]s/v2 and so on. A request to %[1]s without a version "+
+			"is served by the version its Accept header names, as %[2]s.v2+json or application/json; version=2, "+
+			"and by version %[3]d otherwise. Deprecated routes answer with Deprecation and Sunset headers.",
+			APIPrefix, APIMediaType, cfg.APIDefaultVersion),
+	})
+	a.OpenAPI.Components.SecuritySchemes[BearerAuth] = &openapi.SecurityScheme{
+		Type: "http", Scheme: "bearer", BearerFormat: "JWT",
+		Description: "Token issued by /api/v1/auth/login or /api/v1/auth/webauthn/login/finish",
+	}
+
+	for _, module := range a.modules {
+		module.RegisterRoutes(a)
+		if m, ok := module.(JobsModule); ok {
+			jobs, err := m.Jobs(a)
+			if err != nil {
+				return nil, fmt.Errorf("%s module: %w", module.Name(), err)
+			}
+			a.jobs = append(a.jobs, jobs...)
+		}
+	}
+
+	if _, ok := a.versions[cfg.APIDefaultVersion]; !ok {
+		return nil, fmt.Errorf("app: API_DEFAULT_VERSION is %d, but no module serves that version", cfg.APIDefaultVersion)
+	}
+
+	for _, module := range a.modules {
+		if m, ok := module.(DocumentedModule); ok {
+			m.Document(a, a.OpenAPI)
+		}
+	}
+	undocumented, err := a.UndocumentedRoutes()
+	if err != nil {
+		return nil, err
+	}
+	if len(undocumented) > 0 {
+		a.Logger.Warn("routes missing from the OpenAPI document", slog.Any("routes", undocumented))
+	}
+	return a, nil
+}
+
+// BearerAuth names the security scheme of routes behind Middleware.Auth
+const BearerAuth = "bearerAuth"
+
+// APIPrefix is where the versioned API is mounted, and APIMediaType the vendor media type
+// clients name a version with
+const (
+	APIPrefix    = "/api"
+	APIMediaType = "application/vnd.testportal"
+)
+
+// APIVersion returns the subrouter of /api/v<version>, creating it on first use. Modules register
+// a version's handlers on it; versions are served side by side, and requests to /api without a
+// version are routed to one by the Accept header.
+func (a *App) APIVersion(version int) *mux.Router {
+	router, ok := a.versions[version]
+	if !ok {
+		router = a.Router.PathPrefix(APIPrefix + "/v" + strconv.Itoa(version)).Subrouter()
+		a.versions[version] = router
+	}
+	return router
+}
+
+// UndocumentedRoutes lists the registered routes the OpenAPI document doesn't describe
+func (a *App) UndocumentedRoutes() ([]string, error) {
+	return openapi.Undocumented(a.Router, a.OpenAPI)
+}
+
+// Handler is the HTTP handler serving every module's routes behind the middleware stack
+func (a *App) Handler() http.Handler {
+	return a.handler
+}
+
+// middleware is the stack wrapping the whole router, so it also covers requests no route
+// matches and CORS preflights. The client address is resolved first, for everything after it to
+// use. API version negotiation runs last, since it rewrites the path the router matches.
+func (a *App) middleware(trustedProxies []netip.Prefix) func(http.Handler) http.Handler {
+	cfg := a.Config
+	stack := []func(http.Handler) http.Handler{
+		middleware.ClientIP(trustedProxies),
+		middleware.RequestID,
+		middleware.AccessLog(a.Logger, cfg.AccessLogQuietPaths),
+		middleware.Recover(a.Logger),
+		middleware.SecurityHeaders(cfg.HSTSMaxAge),
+		middleware.CORS(middleware.CORSOptions{
+			AllowedOrigins:   cfg.CORSAllowedOrigins,
+			AllowedMethods:   cfg.CORSAllowedMethods,
+			AllowedHeaders:   cfg.CORSAllowedHeaders,
+			ExposedHeaders:   cfg.CORSExposedHeaders,
+			AllowCredentials: cfg.CORSAllowCredentials,
+			MaxAge:           cfg.CORSMaxAge,
+		}),
+	}
+	if cfg.CompressionEnabled {
+		stack = append(stack, middleware.Compress(cfg.CompressionMinSize))
+	}
+	stack = append(stack, middleware.APIVersion(middleware.VersionOptions{
+		Prefix:    APIPrefix,
+		MediaType: APIMediaType,
+		Default:   cfg.APIDefaultVersion,
+		Supported: func(version int) bool {
+			_, ok := a.versions[version]
+			return ok
+		},
+	}))
+	return middleware.Chain(stack...)
+}
+
+// Migrate applies pending migrations, the embedded ones and those of the modules
+func (a *App) Migrate(ctx context.Context) error {
+	if a.DB == nil {
+		return errors.New("app: no database to migrate")
+	}
+	return migrations.RunMigrations(ctx, a.DB, a.Logger, a.migrations...)
+}
+
+// Go runs fn as a background job alongside those of the modules
+func (a *App) Go(fn Job) {
+	a.workers.Go(fn)
+}
+
+// Start runs the modules' background jobs; Stop ends them
+func (a *App) Start() {
+	for _, job := range a.jobs {
+		a.workers.Go(job)
+	}
+}
+
+// Stop signals every background job and waits for them to return or for ctx to be done
+func (a *App) Stop(ctx context.Context) error {
+	return a.workers.Stop(ctx)
+}
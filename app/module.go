@@ -0,0 +1,57 @@
+// app/module.go
+package app
+
+import (
+	"fmt"
+
+	"github.com/ABDULS21985/test-portal/migrations"
+	"github.com/ABDULS21985/test-portal/openapi"
+)
+
+// Module is a feature area of the application. New calls RegisterRoutes once the repositories,
+// services and middleware are built, in the order the modules were given.
+type Module interface {
+	Name() string
+	RegisterRoutes(a *App)
+}
+
+// MigrationsModule is implemented by modules that ship their own schema migrations. They share
+// the version sequence of the embedded migrations, so a reused version is an error.
+type MigrationsModule interface {
+	Module
+	Migrations() ([]migrations.Migration, error)
+}
+
+// Job is a background loop that must return once stop is closed
+type Job func(stop <-chan struct{})
+
+// JobsModule is implemented by modules that run background loops while the application is up.
+// Jobs is called by New, so a misconfigured job fails the startup rather than the loop.
+type JobsModule interface {
+	Module
+	Jobs(a *App) ([]Job, error)
+}
+
+// DocumentedModule is implemented by modules that describe their routes in the OpenAPI
+// document. Document is called once every module has registered its routes.
+type DocumentedModule interface {
+	Module
+	Document(a *App, doc *openapi.Document)
+}
+
+// ModuleMigrations collects the migrations of every module that ships some
+func ModuleMigrations(modules []Module) ([]migrations.Migration, error) {
+	var all []migrations.Migration
+	for _, module := range modules {
+		m, ok := module.(MigrationsModule)
+		if !ok {
+			continue
+		}
+		own, err := m.Migrations()
+		if err != nil {
+			return nil, fmt.Errorf("%s module: %w", module.Name(), err)
+		}
+		all = append(all, own...)
+	}
+	return all, nil
+}
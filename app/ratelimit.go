@@ -0,0 +1,49 @@
+// app/ratelimit.go
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ABDULS21985/test-portal/middleware"
+	"github.com/ABDULS21985/test-portal/ratelimit"
+)
+
+// buildRateLimiter parses RATE_LIMITS and picks the store they are kept in, unless one is given
+func (a *App) buildRateLimiter(store ratelimit.Store) error {
+	a.rateLimits = make(map[string]ratelimit.Limit, len(a.Config.RateLimits))
+	for group, spec := range a.Config.RateLimits {
+		limit, err := ratelimit.ParseLimit(spec)
+		if err != nil {
+			return fmt.Errorf("RATE_LIMITS: %s: %w", group, err)
+		}
+		a.rateLimits[group] = limit
+	}
+
+	if store == nil {
+		switch a.Config.RateLimitStore {
+		case "postgres":
+			if a.DB == nil {
+				return errors.New("app: RATE_LIMIT_STORE=postgres requires a database")
+			}
+			postgres := ratelimit.NewPostgresStore(a.DB, a.Config.RateLimitCleanupInterval, a.Logger)
+			a.jobs = append(a.jobs, postgres.Start)
+			store = postgres
+		default:
+			store = ratelimit.NewMemoryStore()
+		}
+	}
+	a.Middleware.RateLimiter = middleware.NewRateLimiter(store, a.Logger)
+	return nil
+}
+
+// RateLimit limits the routes it wraps with the group's configured limit, counting requests
+// against key. Groups without a limit are not limited.
+func (a *App) RateLimit(group string, key middleware.RateLimitKey) func(http.Handler) http.Handler {
+	limit, ok := a.rateLimits[group]
+	if !ok {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return a.Middleware.RateLimiter.Limit(group, limit, key)
+}
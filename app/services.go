@@ -0,0 +1,128 @@
+// app/services.go
+package app
+
+import (
+	"fmt"
+
+	"github.com/ABDULS21985/test-portal/config"
+	"github.com/ABDULS21985/test-portal/migrations"
+	"github.com/ABDULS21985/test-portal/repositories"
+	"github.com/ABDULS21985/test-portal/services"
+)
+
+// Services holds one instance of every service
+type Services struct {
+	Users         services.UserService
+	Sessions      services.SessionService
+	Auth          services.AuthService
+	PasswordReset services.PasswordResetService
+	SCIM          services.SCIMService
+	Audit         services.AuditService
+	WebAuthn      services.WebAuthnService
+	Health        services.HealthService
+
+	// JWTKeys signs the tokens Auth issues; rotate it to change the signing key
+	JWTKeys *services.JWTKeyRing
+	// SMTP is the mail server login; set it to change the credentials
+	SMTP *services.SMTPCredentials
+	// LDAP authenticates and syncs directory users; nil unless LDAP_URL is set
+	LDAP services.CredentialProvider
+}
+
+// buildServices fills a.Services in dependency order, keeping any service given in overrides
+func (a *App) buildServices(overrides Services) error {
+	cfg, repos, s := a.Config, a.Repos, overrides
+
+	if s.Users == nil {
+		s.Users = services.NewUserService(repos.Users)
+	}
+	if s.Sessions == nil {
+		s.Sessions = services.NewSessionService(repos.Sessions, a.Logger)
+	}
+	if s.LDAP == nil && cfg.LDAPURL != "" {
+		provider, err := newLDAPProvider(cfg, repos.Users)
+		if err != nil {
+			return fmt.Errorf("invalid LDAP configuration: %w", err)
+		}
+		s.LDAP = provider
+	}
+	if s.JWTKeys == nil {
+		s.JWTKeys = services.NewJWTKeyRing([]byte(cfg.JWTSecret))
+	}
+	if s.SMTP == nil {
+		s.SMTP = services.NewSMTPCredentials(cfg.SMTPUser, cfg.SMTPPass)
+	}
+	if s.Auth == nil {
+		providers := []services.CredentialProvider{services.NewLocalCredentialProvider(repos.Users)}
+		if s.LDAP != nil {
+			providers = append(providers, s.LDAP)
+		}
+		s.Auth = services.NewAuthService(repos.Users, s.Sessions, s.JWTKeys, cfg.JWTExpiration, a.Logger, providers...)
+	}
+	if s.PasswordReset == nil {
+		s.PasswordReset = services.NewPasswordResetService(repos.Users, repos.PasswordResets, a.TxManager)
+	}
+	if s.SCIM == nil {
+		s.SCIM = services.NewSCIMService(repos.Users, repos.Roles, repos.Sessions, a.TxManager, cfg.SCIMDefaultRole)
+	}
+	if s.Audit == nil {
+		s.Audit = services.NewAuditService(repos.AuditLogs)
+	}
+	if s.WebAuthn == nil {
+		webAuthn, err := services.NewWebAuthnService(services.WebAuthnConfig{
+			RPID:          cfg.WebAuthnRPID,
+			RPDisplayName: cfg.WebAuthnRPName,
+			RPOrigins:     cfg.WebAuthnRPOrigins,
+			ChallengeTTL:  cfg.WebAuthnChallengeTTL,
+		}, repos.Users, repos.WebAuthn)
+		if err != nil {
+			return fmt.Errorf("invalid WebAuthn configuration: %w", err)
+		}
+		s.WebAuthn = webAuthn
+	}
+	if s.Health == nil {
+		health, err := a.newHealthService(s.SMTP)
+		if err != nil {
+			return err
+		}
+		s.Health = health
+	}
+
+	a.Services = s
+	return nil
+}
+
+// newHealthService checks the database and its migrations when there is one, and SMTP when asked
+func (a *App) newHealthService(smtpCredentials *services.SMTPCredentials) (services.HealthService, error) {
+	var checks []services.HealthCheck
+	if a.DB != nil {
+		migrator, err := migrations.NewMigrator(a.DB, a.migrations...)
+		if err != nil {
+			return nil, fmt.Errorf("could not load migrations: %w", err)
+		}
+		checks = append(checks, services.DatabaseHealthCheck(a.DB), services.MigrationsHealthCheck(migrator))
+	}
+	if a.Config.ReadinessCheckSMTP {
+		checks = append(checks, services.SMTPHealthCheck(a.Config.SMTPHost, a.Config.SMTPPort, smtpCredentials, a.Config.ReadinessSMTPLoginInterval))
+	}
+	return services.NewHealthService(a.Config.HealthCheckTimeout, a.Logger, checks...), nil
+}
+
+// newLDAPProvider builds the directory credential provider from the LDAP_* settings
+func newLDAPProvider(cfg *config.Config, userRepo repositories.UserRepository) (services.CredentialProvider, error) {
+	groupRoles, err := services.ParseLDAPGroupRoles(cfg.LDAPGroupRoleMap)
+	if err != nil {
+		return nil, fmt.Errorf("LDAP_GROUP_ROLE_MAP: %w", err)
+	}
+
+	return services.NewLDAPCredentialProvider(services.LDAPConfig{
+		URL:          cfg.LDAPURL,
+		BindDN:       cfg.LDAPBindDN,
+		BindPassword: cfg.LDAPBindPassword,
+		BaseDN:       cfg.LDAPBaseDN,
+		UserFilter:   cfg.LDAPUserFilter,
+		GroupRoles:   groupRoles,
+		DefaultRole:  cfg.LDAPDefaultRole,
+		StartTLS:     cfg.LDAPStartTLS,
+	}, userRepo), nil
+}
@@ -0,0 +1,152 @@
+// apperrors/apperrors.go
+package apperrors
+
+import (
+	"errors"
+	"time"
+)
+
+// Kind classifies a domain error; the HTTP layer derives the status code from it
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindNotFound
+	KindConflict
+	KindValidation
+	KindUnauthorized
+	KindForbidden
+	KindRateLimited
+	KindUnsupportedMediaType
+	KindTooLarge
+	KindNotAcceptable
+)
+
+// String returns the machine-readable code clients see in problem responses
+func (k Kind) String() string {
+	switch k {
+	case KindNotFound:
+		return "not_found"
+	case KindConflict:
+		return "conflict"
+	case KindValidation:
+		return "validation_failed"
+	case KindUnauthorized:
+		return "unauthorized"
+	case KindForbidden:
+		return "forbidden"
+	case KindRateLimited:
+		return "rate_limited"
+	case KindUnsupportedMediaType:
+		return "unsupported_media_type"
+	case KindTooLarge:
+		return "too_large"
+	case KindNotAcceptable:
+		return "not_acceptable"
+	default:
+		return "internal"
+	}
+}
+
+// Error is an error a client may be told about. Message is written for the client; the wrapped
+// cause is for logs only and never leaves the service.
+type Error struct {
+	Kind       Kind
+	Message    string
+	RetryAfter time.Duration // RateLimited only
+	Fields     []FieldError  // the invalid inputs, when a Validation error is about specific ones
+	Err        error
+}
+
+// FieldError describes one invalid input. Field is its path in the request body, such as
+// "email" or "members[2].value"; Code names the rule it broke.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NotFound reports a missing resource
+func NotFound(message string) *Error {
+	return &Error{Kind: KindNotFound, Message: message}
+}
+
+// Conflict reports a clash with existing state, such as a duplicate email
+func Conflict(message string) *Error {
+	return &Error{Kind: KindConflict, Message: message}
+}
+
+// Validation reports input that can't be accepted
+func Validation(message string) *Error {
+	return &Error{Kind: KindValidation, Message: message}
+}
+
+// InvalidFields reports input that can't be accepted because of the given fields
+func InvalidFields(message string, fields ...FieldError) *Error {
+	return &Error{Kind: KindValidation, Message: message, Fields: fields}
+}
+
+// Unauthorized reports missing or wrong credentials
+func Unauthorized(message string) *Error {
+	return &Error{Kind: KindUnauthorized, Message: message}
+}
+
+// Forbidden reports an authenticated caller who may not do this
+func Forbidden(message string) *Error {
+	return &Error{Kind: KindForbidden, Message: message}
+}
+
+// RateLimited reports a caller who must wait retryAfter before trying again
+func RateLimited(message string, retryAfter time.Duration) *Error {
+	return &Error{Kind: KindRateLimited, Message: message, RetryAfter: retryAfter}
+}
+
+// UnsupportedMediaType reports a request body in a format the endpoint doesn't accept
+func UnsupportedMediaType(message string) *Error {
+	return &Error{Kind: KindUnsupportedMediaType, Message: message}
+}
+
+// TooLarge reports a request body over the endpoint's size limit
+func TooLarge(message string) *Error {
+	return &Error{Kind: KindTooLarge, Message: message}
+}
+
+// NotAcceptable reports a request for a representation, such as an API version, that isn't served
+func NotAcceptable(message string) *Error {
+	return &Error{Kind: KindNotAcceptable, Message: message}
+}
+
+// WithCause returns a copy of e that wraps cause, for logs and errors.Is checks
+func (e *Error) WithCause(cause error) *Error {
+	clone := *e
+	clone.Err = cause
+	return &clone
+}
+
+// As returns the domain error in err's chain, if any
+func As(err error) (*Error, bool) {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr, true
+	}
+	return nil, false
+}
+
+// KindOf returns the kind of the domain error in err's chain, or KindInternal when there is none
+func KindOf(err error) Kind {
+	if appErr, ok := As(err); ok {
+		return appErr.Kind
+	}
+	return KindInternal
+}
@@ -1,102 +1,171 @@
 package config
 
 import (
+	"errors"
 	"fmt"
-	"log"
 	"os"
+	"slices"
+	"strings"
 	"time"
-
-	"github.com/ABDULS21985/test-portal/models"
-	"github.com/joho/godotenv"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
 )
 
-// Config holds all configuration for the application
+// Config holds all configuration for the application. See loader.go for the meaning of the tags.
 type Config struct {
-	Port            string
-	JWTSecret       string
-	JWTExpiration   time.Duration
-	EncryptionKey   string
-	DefaultUserPass string
-	DB              *gorm.DB
-	SMTPHost        string
-	SMTPPort        int
-	SMTPUser        string
-	SMTPPass        string
-	LogLevel        string
-	UploadDir       string
-	SLAInterval     time.Duration
-}
+	AppEnv        string        `env:"APP_ENV" default:"development" oneof:"development|test|staging|production" usage:"deployment environment; production enforces secret checks"`
+	Port          string        `env:"PORT" default:"8080"`
+	JWTSecret     string        `env:"JWT_SECRET" required:"true" secret:"true" min:"12"`
+	JWTExpiration time.Duration `env:"JWT_EXPIRATION" default:"72h"`
+	EncryptionKey string        `env:"ENCRYPTION_KEY" required:"true" secret:"true" min:"64" usage:"hex encoded 32 byte key for encrypted columns"`
+	SMTPHost      string        `env:"SMTP_HOST" default:"localhost"`
+	SMTPPort      int           `env:"SMTP_PORT" default:"587" min:"1"`
+	SMTPUser      string        `env:"SMTP_USER,SMTP_USERNAME"`
+	SMTPPass      string        `env:"SMTP_PASS,SMTP_PASSWORD"`
+	LogLevel      string        `env:"LOG_LEVEL" default:"info" oneof:"debug|info|warn|error"`
+	LogFormat     string        `env:"LOG_FORMAT" default:"json" oneof:"json|text"`
+	UploadDir     string        `env:"UPLOAD_DIR" default:"./uploads"`
+	SLAInterval   time.Duration `env:"SLA_CHECK_INTERVAL" default:"720m"`
 
-// LoadConfig loads environment variables and initializes the database connection
-func LoadConfig() *Config {
-	// Load environment variables from .env file
-	err := godotenv.Load()
-	if err != nil {
-		log.Printf("Warning: .env file not found. Using system environment variables.")
-	}
+	// HTTP server
+	HTTPReadTimeout       time.Duration `env:"HTTP_READ_TIMEOUT" default:"15s"`
+	HTTPReadHeaderTimeout time.Duration `env:"HTTP_READ_HEADER_TIMEOUT" default:"5s"`
+	HTTPWriteTimeout      time.Duration `env:"HTTP_WRITE_TIMEOUT" default:"30s"`
+	HTTPIdleTimeout       time.Duration `env:"HTTP_IDLE_TIMEOUT" default:"120s"`
+	HTTPRequestTimeout    time.Duration `env:"HTTP_REQUEST_TIMEOUT" default:"10s" usage:"deadline for handling a request, including its database work; 0 disables it"`
+	HTTPMaxHeaderBytes    int           `env:"HTTP_MAX_HEADER_BYTES" default:"1048576" min:"4096"`
+	ShutdownTimeout       time.Duration `env:"SHUTDOWN_TIMEOUT" default:"30s"`
+	TLSCertFile           string        `env:"TLS_CERT_FILE" usage:"serve HTTPS with this certificate; reloaded when it changes"`
+	TLSKeyFile            string        `env:"TLS_KEY_FILE"`
+	H2C                   bool          `env:"HTTP_H2C" default:"false" usage:"serve cleartext HTTP/2 (h2c) when TLS is off"`
 
-	// Parse required environment variables
-	jwtExpiration, err := time.ParseDuration(getEnv("JWT_EXPIRATION", "72h"))
-	if err != nil {
-		log.Fatalf("Invalid JWT_EXPIRATION format: %v", err)
-	}
+	// HTTP middleware: client address, access log, security headers, CORS and compression
+	TrustedProxies       []string      `env:"TRUSTED_PROXIES" usage:"addresses or CIDRs of reverse proxies whose X-Forwarded-For is believed"`
+	AccessLogQuietPaths  []string      `env:"ACCESS_LOG_QUIET_PATHS" default:"/healthz,/readyz,/metrics" usage:"paths whose access log lines are logged at debug level"`
+	HSTSMaxAge           time.Duration `env:"HSTS_MAX_AGE" default:"0s" usage:"send Strict-Transport-Security with this max-age; only enable when served over HTTPS"`
+	CORSAllowedOrigins   []string      `env:"CORS_ALLOWED_ORIGINS" usage:"browser origins allowed to call the API, or * without credentials; CORS is off when empty"`
+	CORSAllowedMethods   []string      `env:"CORS_ALLOWED_METHODS" default:"GET,POST,PUT,PATCH,DELETE"`
+	CORSAllowedHeaders   []string      `env:"CORS_ALLOWED_HEADERS" default:"Authorization,Content-Type,X-Request-ID"`
+	CORSExposedHeaders   []string      `env:"CORS_EXPOSED_HEADERS" default:"X-Request-ID,Retry-After,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,RateLimit-Policy,API-Version,Deprecation,Sunset,Link"`
+	CORSAllowCredentials bool          `env:"CORS_ALLOW_CREDENTIALS" default:"false"`
+	CORSMaxAge           time.Duration `env:"CORS_MAX_AGE" default:"10m" usage:"how long browsers may cache a preflight response"`
+	CompressionEnabled   bool          `env:"HTTP_COMPRESSION" default:"true" usage:"compress responses with brotli or gzip"`
+	CompressionMinSize   int           `env:"HTTP_COMPRESSION_MIN_SIZE" default:"1024" min:"0" usage:"responses smaller than this many bytes are sent uncompressed"`
 
-	// Construct the Data Source Name (DSN)
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		getEnv("DATABASE_HOST", "localhost"),
-		getEnv("DATABASE_PORT", "5432"),
-		getEnv("DATABASE_USER", "postgres"),
-		getEnv("DATABASE_PASSWORD", "password"),
-		getEnv("DATABASE_NAME", "testportal"),
-		getEnv("DATABASE_SSLMODE", "disable"),
-	)
-
-	// Connect to the database
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
-	}
+	// API versions live under /api/v1, /api/v2 and so on; requests to /api without one get the
+	// version their Accept header names, or this one
+	APIDefaultVersion int `env:"API_DEFAULT_VERSION" default:"1" min:"1" usage:"version serving /api requests that name none"`
 
-	// Automatic migration
-	log.Println("Running automatic migrations...")
-	err = db.AutoMigrate(&models.User{})
-	if err != nil {
-		log.Fatalf("Auto migration failed: %v", err)
+	// Rate limits per route group, as group:<requests>/<period>[ sliding_window] pairs; groups
+	// left out are unlimited. Postgres storage makes the limits hold across replicas.
+	RateLimits               map[string]string `env:"RATE_LIMITS" default:"auth:10/1m,register:5/1h,password_reset:5/1h,api:600/1m,scim:1200/1m" usage:"per route group limits: auth, register, password_reset, api, scim"`
+	RateLimitStore           string            `env:"RATE_LIMIT_STORE" default:"memory" oneof:"memory|postgres"`
+	RateLimitCleanupInterval time.Duration     `env:"RATE_LIMIT_CLEANUP_INTERVAL" default:"5m" min:"1" usage:"how often expired postgres rate limit rows are deleted"`
+
+	// Readiness probe
+	HealthCheckTimeout         time.Duration `env:"HEALTH_CHECK_TIMEOUT" default:"2s"`
+	ReadinessCheckSMTP         bool          `env:"READINESS_CHECK_SMTP" default:"false" usage:"include SMTP reachability in /readyz"`
+	ReadinessSMTPLoginInterval time.Duration `env:"READINESS_SMTP_LOGIN_INTERVAL" default:"10m" min:"1" usage:"how often the SMTP check logs in; every probe still connects"`
+
+	// Prometheus scrape endpoint; when a token is set, /metrics requires it as a bearer token
+	MetricsBearerToken string `env:"METRICS_BEARER_TOKEN" secret:"optional" usage:"bearer token required to scrape /metrics (optional)"`
+
+	// OpenTelemetry tracing, exported over OTLP/HTTP when an endpoint is set. The exporter also
+	// honours the standard OTEL_EXPORTER_OTLP_* variables, e.g. OTEL_EXPORTER_OTLP_HEADERS.
+	OTelEndpoint    string  `env:"OTEL_EXPORTER_OTLP_ENDPOINT" usage:"OTLP/HTTP collector URL, e.g. http://otel-collector:4318; tracing is off when empty"`
+	OTelServiceName string  `env:"OTEL_SERVICE_NAME" default:"test-portal"`
+	OTelSampleRatio float64 `env:"OTEL_TRACES_SAMPLER_ARG" default:"1" usage:"fraction of new traces sampled; a remote parent's decision is always honoured"`
+
+	// How often secrets files are re-read; SIGHUP also triggers a reload. 0 disables polling.
+	ReloadInterval time.Duration `env:"CONFIG_RELOAD_INTERVAL" default:"1m"`
+
+	// Field-level encryption. ENCRYPTION_KEY is the current key, numbered ENCRYPTION_KEY_VERSION;
+	// retired keys stay in ENCRYPTION_PREVIOUS_KEYS as version:hexkey pairs until "reencrypt" has
+	// run. BLIND_INDEX_KEY must not change once set, or encrypted columns can no longer be searched.
+	EncryptionKeyVersion   int               `env:"ENCRYPTION_KEY_VERSION" default:"1" min:"1"`
+	EncryptionPreviousKeys map[string]string `env:"ENCRYPTION_PREVIOUS_KEYS"`
+	BlindIndexKey          string            `env:"BLIND_INDEX_KEY" secret:"true" min:"32"`
+
+	// Database connection
+	DatabaseHost     string `env:"DATABASE_HOST" default:"localhost"`
+	DatabasePort     int    `env:"DATABASE_PORT" default:"5432" min:"1"`
+	DatabaseUser     string `env:"DATABASE_USER" default:"postgres"`
+	DatabasePassword string `env:"DATABASE_PASSWORD" default:"password" secret:"true"`
+	DatabaseName     string `env:"DATABASE_NAME" default:"testportal"`
+	DatabaseSSLMode  string `env:"DATABASE_SSLMODE" default:"disable" oneof:"disable|allow|prefer|require|verify-ca|verify-full"`
+
+	// Database connection pool and startup retry
+	DatabaseMaxOpenConns       int           `env:"DATABASE_MAX_OPEN_CONNS" default:"25" min:"1"`
+	DatabaseMaxIdleConns       int           `env:"DATABASE_MAX_IDLE_CONNS" default:"5" min:"0"`
+	DatabaseConnMaxLifetime    time.Duration `env:"DATABASE_CONN_MAX_LIFETIME" default:"30m"`
+	DatabaseConnMaxIdleTime    time.Duration `env:"DATABASE_CONN_MAX_IDLE_TIME" default:"5m"`
+	DatabaseConnectAttempts    int           `env:"DATABASE_CONNECT_ATTEMPTS" default:"10" min:"1"`
+	DatabaseConnectBackoff     time.Duration `env:"DATABASE_CONNECT_BACKOFF" default:"500ms"`
+	DatabaseSlowQueryThreshold time.Duration `env:"DATABASE_SLOW_QUERY_THRESHOLD" default:"200ms" usage:"queries slower than this are logged as warnings"`
+	MigrateOnStart             bool          `env:"MIGRATE_ON_START" default:"true" usage:"apply pending migrations when the server starts"`
+
+	// LDAP / Active Directory authentication, enabled when LDAPURL is set
+	LDAPURL          string        `env:"LDAP_URL"`
+	LDAPBindDN       string        `env:"LDAP_BIND_DN"`
+	LDAPBindPassword string        `env:"LDAP_BIND_PASSWORD" secret:"optional"`
+	LDAPBaseDN       string        `env:"LDAP_BASE_DN"`
+	LDAPUserFilter   string        `env:"LDAP_USER_FILTER" default:"(mail=%s)"`
+	LDAPGroupRoleMap string        `env:"LDAP_GROUP_ROLE_MAP"`
+	LDAPDefaultRole  string        `env:"LDAP_DEFAULT_ROLE" default:"user"`
+	LDAPStartTLS     bool          `env:"LDAP_START_TLS" default:"false"`
+	LDAPSyncInterval time.Duration `env:"LDAP_SYNC_INTERVAL" default:"1h"`
+
+	// SCIM provisioning: one bearer token per tenant, as tenant:token pairs
+	SCIMTenantTokens map[string]string `env:"SCIM_TENANT_TOKENS" secret:"optional"`
+	SCIMDefaultRole  string            `env:"SCIM_DEFAULT_ROLE" default:"user"`
+
+	// Impersonation ("log in as") for support staff
+	ImpersonationTTL             time.Duration `env:"IMPERSONATION_TTL" default:"30m"`
+	ImpersonationRestrictedPaths []string      `env:"IMPERSONATION_RESTRICTED_PATHS" default:"/api/users,/api/admin,/api/auth" usage:"path prefixes refusing writes while impersonating; API paths are matched without their version"`
+
+	// WebAuthn relying party
+	WebAuthnRPID         string        `env:"WEBAUTHN_RP_ID" default:"localhost"`
+	WebAuthnRPName       string        `env:"WEBAUTHN_RP_NAME" default:"Test Portal"`
+	WebAuthnRPOrigins    []string      `env:"WEBAUTHN_RP_ORIGINS" default:"http://localhost:8080"`
+	WebAuthnChallengeTTL time.Duration `env:"WEBAUTHN_CHALLENGE_TTL" default:"5m"`
+}
+
+// IsProduction reports whether APP_ENV is production
+func (c *Config) IsProduction() bool {
+	return strings.EqualFold(c.AppEnv, "production")
+}
+
+// Load builds the configuration from defaults, the config file, the environment and the given
+// command-line arguments, in increasing order of precedence, and returns the positional
+// arguments that follow the flags (a subcommand such as "migrate up"). All invalid or missing
+// values are reported together.
+func Load(args []string) (*Config, []string, error) {
+	cfg := &Config{}
+	rest, err := load(cfg, args, os.LookupEnv, cfg.IsProduction)
+	if err == nil {
+		err = cfg.check()
 	}
-	log.Println("Automatic migrations completed.")
-
-	// Return the loaded configuration
-	return &Config{
-		Port:            getEnv("PORT", "8080"),
-		JWTSecret:       getEnv("JWT_SECRET", "mysecretkey"),
-		JWTExpiration:   jwtExpiration,
-		EncryptionKey:   getEnv("ENCRYPTION_KEY", ""),
-		DefaultUserPass: getEnv("DEFAULT_USER_PASSWORD", "password"),
-		DB:              db,
-		SMTPHost:        getEnv("SMTP_HOST", "localhost"),
-		SMTPUser:        getEnv("SMTP_USER", ""),
-		SMTPPass:        getEnv("SMTP_PASS", ""),
-		LogLevel:        getEnv("LOG_LEVEL", "info"),
-		UploadDir:       getEnv("UPLOAD_DIR", "./uploads"),
+	if err != nil {
+		return nil, nil, err
 	}
+	return cfg, rest, nil
 }
 
-// getEnv reads an environment variable or returns a default value if not set
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
+// check reports settings that are valid on their own but not in combination
+func (c *Config) check() error {
+	var errs []error
+	// A wildcard with credentials would let any site make authenticated calls
+	if c.CORSAllowCredentials && slices.Contains(c.CORSAllowedOrigins, "*") {
+		errs = append(errs, errors.New("CORS_ALLOWED_ORIGINS: * can't be combined with CORS_ALLOW_CREDENTIALS=true; list the allowed origins instead"))
+	}
+	// The filter is a format string for the escaped email; without %s every login would search
+	// for the same entry
+	if c.LDAPURL != "" && strings.Count(c.LDAPUserFilter, "%s") != 1 {
+		errs = append(errs, fmt.Errorf("LDAP_USER_FILTER: must contain %%s exactly once, where the email goes, got %q", c.LDAPUserFilter))
 	}
-	return value
+	return errors.Join(errs...)
 }
 
-// parseEnvInt parses an environment variable as an integer, returning a default value if not set
-func parseEnvInt(key string, defaultValue int) (int, error) {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue, nil
-	}
-	return fmt.Sscanf(value, "%d")
+// LoadConfig loads the configuration from the process arguments and environment. It does not
+// touch the database; see the database package for that.
+func LoadConfig() (*Config, []string, error) {
+	return Load(os.Args[1:])
 }
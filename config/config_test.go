@@ -0,0 +1,80 @@
+// config/config_test.go
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadCORS(t *testing.T) {
+	tests := []struct {
+		name        string
+		origins     string
+		credentials string
+		wantErr     bool
+	}{
+		{name: "wildcard", origins: "*", credentials: "false"},
+		{name: "listed origins with credentials", origins: "https://app.example.com", credentials: "true"},
+		{name: "wildcard with credentials", origins: "https://app.example.com,*", credentials: "true", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("JWT_SECRET", "a-test-signing-secret")
+			t.Setenv("ENCRYPTION_KEY", strings.Repeat("ab", 32))
+			t.Setenv("CORS_ALLOWED_ORIGINS", tt.origins)
+			t.Setenv("CORS_ALLOW_CREDENTIALS", tt.credentials)
+			_, _, err := Load(nil)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "CORS_ALLOWED_ORIGINS") {
+					t.Fatalf("err = %v, want CORS_ALLOWED_ORIGINS rejected", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestLoadLDAPUserFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		filter  string
+		wantErr bool
+	}{
+		{name: "default", url: "ldaps://ldap.example.com"},
+		{name: "custom", url: "ldaps://ldap.example.com", filter: "(&(objectClass=person)(userPrincipalName=%s))"},
+		{name: "no placeholder", url: "ldaps://ldap.example.com", filter: "(mail=admin@example.com)", wantErr: true},
+		{name: "other verb", url: "ldaps://ldap.example.com", filter: "(mail=%v)", wantErr: true},
+		{name: "placeholder twice", url: "ldaps://ldap.example.com", filter: "(|(mail=%s)(uid=%s))", wantErr: true},
+		{name: "LDAP disabled", filter: "(mail=admin@example.com)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("JWT_SECRET", "a-test-signing-secret")
+			t.Setenv("ENCRYPTION_KEY", strings.Repeat("ab", 32))
+			t.Setenv("LDAP_URL", tt.url)
+			t.Setenv("LDAP_USER_FILTER", tt.filter)
+			_, _, err := Load(nil)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "LDAP_USER_FILTER") {
+					t.Fatalf("err = %v, want LDAP_USER_FILTER rejected", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestCheckReportsEveryProblem(t *testing.T) {
+	cfg := &Config{CORSAllowCredentials: true, CORSAllowedOrigins: []string{"*"}, LDAPURL: "ldap://ldap", LDAPUserFilter: "(mail=x)"}
+	err := cfg.check()
+	if err == nil || !strings.Contains(err.Error(), "CORS_ALLOWED_ORIGINS") || !strings.Contains(err.Error(), "LDAP_USER_FILTER") {
+		t.Fatalf("err = %v, want both settings reported", err)
+	}
+}
@@ -0,0 +1,472 @@
+// config/loader.go
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Struct tags understood by the loader:
+//
+//	env:"NAME,ALIAS"   environment / config file keys, first one found wins
+//	flag:"name"        command-line flag name (defaults to the lower-cased, dashed env name)
+//	default:"value"    value used when no layer sets the field
+//	required:"true"    the field must be set by some layer
+//	secret:"true"      in production the field must be set explicitly to a non-placeholder value
+//	secret:"optional"  as above, but the field may be left unset
+//	oneof:"a|b|c"      allowed values
+//	min:"n"            minimum for numbers, minimum length for strings
+//	usage:"text"       flag help text
+//
+// Values are layered defaults < config file < secrets file < environment < flags. In the files
+// and the environment NAME_FILE may name a file holding the value instead, and any value may be
+// ENC[...] encrypted with the master key (see secrets.go).
+
+// defaultConfigFile is read when neither -config nor CONFIG_FILE is given; it may be absent
+const defaultConfigFile = ".env"
+
+// insecureValues are placeholders that must never reach production as secrets
+var insecureValues = map[string]bool{
+	"":            true,
+	"password":    true,
+	"mysecretkey": true,
+	"secret":      true,
+	"changeme":    true,
+	"changeit":    true,
+}
+
+type fieldSpec struct {
+	value    reflect.Value
+	keys     []string
+	flagName string
+	def      string
+	hasDef   bool
+	required bool
+	secret   string // "true" must be set in production, "optional" only checked when set
+	oneOf    []string
+	min      string
+	usage    string
+}
+
+// source records which layer a field's value came from
+type source int
+
+const (
+	sourceNone source = iota
+	sourceDefault
+	sourceFile
+	sourceSecrets
+	sourceEnv
+	sourceFlag
+)
+
+// load populates the struct pointed to by dst from defaults, the config file, the environment
+// (looked up with lookupEnv) and args, and returns the positional arguments left after the
+// flags. Every problem found is reported in the returned error.
+func load(dst interface{}, args []string, lookupEnv func(string) (string, bool), production func() bool) ([]string, error) {
+	root := reflect.ValueOf(dst)
+	if root.Kind() != reflect.Ptr || root.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("config: destination must be a pointer to a struct")
+	}
+
+	var specs []fieldSpec
+	if err := collectFields(root.Elem(), &specs); err != nil {
+		return nil, err
+	}
+
+	flags, rest, err := parseFlags(specs, args)
+	if err != nil {
+		return nil, err
+	}
+
+	l := layers{flags: flags, lookupEnv: lookupEnv}
+	if l.file, err = readLayer(flags["config"], lookupEnv, "CONFIG_FILE", nil, defaultConfigFile); err != nil {
+		return nil, err
+	}
+	if l.secrets, err = readLayer(flags["secrets-file"], lookupEnv, "SECRETS_FILE", l.file, ""); err != nil {
+		return nil, err
+	}
+	if l.masterKey, err = loadMasterKey(lookupEnv); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	sources := make([]source, len(specs))
+	for i, spec := range specs {
+		raw, src, err := resolve(spec, l)
+		sources[i] = src
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", spec.keys[0], err))
+			continue
+		}
+
+		if src == sourceNone {
+			if spec.required {
+				errs = append(errs, fmt.Errorf("%s: required but not set", spec.keys[0]))
+			}
+			continue
+		}
+		if err := setValue(spec.value, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", spec.keys[0], err))
+			continue
+		}
+		if err := validate(spec, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", spec.keys[0], err))
+		}
+	}
+
+	// Secrets check only makes sense once APP_ENV itself has been resolved
+	if len(errs) == 0 && production() {
+		for i, spec := range specs {
+			if spec.secret == "" {
+				continue
+			}
+			unset := sources[i] == sourceNone || sources[i] == sourceDefault
+			switch {
+			case unset && spec.secret == "optional":
+			case unset:
+				errs = append(errs, fmt.Errorf("%s: must be set explicitly in production", spec.keys[0]))
+			case isInsecure(spec.value):
+				errs = append(errs, fmt.Errorf("%s: insecure placeholder value is not allowed in production", spec.keys[0]))
+			}
+		}
+	}
+
+	return rest, errors.Join(errs...)
+}
+
+// isInsecure reports whether a secret, or any value of a map of secrets, is a known placeholder
+func isInsecure(v reflect.Value) bool {
+	if v.Kind() == reflect.Map {
+		for _, key := range v.MapKeys() {
+			if insecureValues[strings.ToLower(fmt.Sprint(v.MapIndex(key).Interface()))] {
+				return true
+			}
+		}
+		return false
+	}
+	return insecureValues[strings.ToLower(fmt.Sprint(v.Interface()))]
+}
+
+func collectFields(v reflect.Value, specs *[]fieldSpec) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		envTag, ok := field.Tag.Lookup("env")
+		if !ok {
+			// Untagged nested structs are flattened; anything else is not configuration
+			if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Time{}) {
+				if err := collectFields(v.Field(i), specs); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if envTag == "-" {
+			continue
+		}
+
+		keys := strings.Split(envTag, ",")
+		spec := fieldSpec{
+			value:    v.Field(i),
+			keys:     keys,
+			flagName: field.Tag.Get("flag"),
+			required: field.Tag.Get("required") == "true",
+			secret:   field.Tag.Get("secret"),
+			min:      field.Tag.Get("min"),
+			usage:    field.Tag.Get("usage"),
+		}
+		spec.def, spec.hasDef = field.Tag.Lookup("default")
+		if oneOf := field.Tag.Get("oneof"); oneOf != "" {
+			spec.oneOf = strings.Split(oneOf, "|")
+		}
+		if spec.flagName == "" {
+			spec.flagName = strings.ReplaceAll(strings.ToLower(keys[0]), "_", "-")
+		}
+		if spec.secret != "" && spec.secret != "true" && spec.secret != "optional" {
+			return fmt.Errorf("config: field %s has invalid secret tag %q", field.Name, spec.secret)
+		}
+		if !isSupported(field.Type) {
+			return fmt.Errorf("config: field %s has unsupported type %s", field.Name, field.Type)
+		}
+		*specs = append(*specs, spec)
+	}
+	return nil
+}
+
+// parseFlags registers a string flag per field, plus -config and -secrets-file, and returns the
+// ones actually given and the remaining positional arguments
+func parseFlags(specs []fieldSpec, args []string) (map[string]string, []string, error) {
+	fs := flag.NewFlagSet("test-portal", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	fs.String("config", "", "path to a dotenv-style config file (default .env)")
+	fs.String("secrets-file", "", "path to a dotenv-style file of secrets, values may be ENC[...] encrypted")
+	for _, spec := range specs {
+		usage := spec.usage
+		if usage == "" {
+			usage = "overrides " + spec.keys[0]
+		}
+		fs.String(spec.flagName, "", usage)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			fs.SetOutput(os.Stderr)
+			fs.PrintDefaults()
+		}
+		return nil, nil, fmt.Errorf("config: %w", err)
+	}
+
+	set := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = f.Value.String()
+	})
+	return set, fs.Args(), nil
+}
+
+// layers holds every place a value can come from
+type layers struct {
+	flags     map[string]string
+	lookupEnv func(string) (string, bool)
+	secrets   map[string]string
+	file      map[string]string
+	masterKey []byte
+}
+
+// readLayer reads a dotenv-style file whose path is given by flagValue, the envKey variable or
+// the same key in parent, in that order. Only the fallback path may be missing.
+func readLayer(flagValue string, lookupEnv func(string) (string, bool), envKey string, parent map[string]string, fallback string) (map[string]string, error) {
+	path := flagValue
+	if path == "" {
+		if value, ok := lookupEnv(envKey); ok {
+			path = value
+		}
+	}
+	if path == "" {
+		path = parent[envKey]
+	}
+
+	optional := path == ""
+	if optional {
+		path = fallback
+	}
+	if path == "" {
+		return map[string]string{}, nil
+	}
+
+	values, err := godotenv.Read(path)
+	if err != nil {
+		if optional && errors.Is(err, os.ErrNotExist) {
+			return map[string]string{}, nil
+		}
+		return nil, fmt.Errorf("config: reading %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// resolve finds the raw value of a field. Within the environment and the files, NAME_FILE
+// points to a file holding the value (Docker and Kubernetes secrets). ENC[...] values are
+// decrypted with the master key.
+func resolve(spec fieldSpec, l layers) (string, source, error) {
+	if value, ok := l.flags[spec.flagName]; ok {
+		return l.decrypt(spec, value, sourceFlag)
+	}
+
+	lookups := []struct {
+		lookup func(string) (string, bool)
+		src    source
+	}{
+		{l.lookupEnv, sourceEnv},
+		{mapLookup(l.secrets), sourceSecrets},
+		{mapLookup(l.file), sourceFile},
+	}
+	for _, layer := range lookups {
+		for _, key := range spec.keys {
+			if value, ok := layer.lookup(key); ok && value != "" {
+				return l.decrypt(spec, value, layer.src)
+			}
+			if path, ok := layer.lookup(key + "_FILE"); ok && path != "" {
+				value, err := readSecretFile(path)
+				if err != nil {
+					return "", layer.src, err
+				}
+				return l.decrypt(spec, value, layer.src)
+			}
+		}
+	}
+
+	if spec.hasDef {
+		return spec.def, sourceDefault, nil
+	}
+	return "", sourceNone, nil
+}
+
+func (l layers) decrypt(spec fieldSpec, value string, src source) (string, source, error) {
+	if !IsEncrypted(value) {
+		return value, src, nil
+	}
+	if l.masterKey == nil {
+		return "", src, errors.New("value is encrypted but SECRETS_MASTER_KEY is not set")
+	}
+	plaintext, err := DecryptValue(l.masterKey, spec.keys[0], value)
+	return plaintext, src, err
+}
+
+func mapLookup(values map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}
+}
+
+// readSecretFile reads a mounted secret, dropping the trailing newline editors tend to add
+func readSecretFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading secret file: %w", err)
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func isSupported(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.String
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String && t.Elem().Kind() == reflect.String
+	}
+	return false
+}
+
+// setValue parses raw according to the field's type
+func setValue(v reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer %q", raw)
+		}
+		v.SetUint(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	case reflect.Map:
+		// key:value,key:value
+		items := make(map[string]string)
+		for _, pair := range strings.Split(raw, ",") {
+			pair = strings.TrimSpace(pair)
+			if pair == "" {
+				continue
+			}
+			key, value, ok := strings.Cut(pair, ":")
+			if !ok || strings.TrimSpace(key) == "" {
+				return fmt.Errorf("invalid key:value pair %q", pair)
+			}
+			items[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+		v.Set(reflect.ValueOf(items))
+	}
+	return nil
+}
+
+func validate(spec fieldSpec, raw string) error {
+	if len(spec.oneOf) > 0 {
+		valid := false
+		for _, allowed := range spec.oneOf {
+			if strings.EqualFold(strings.TrimSpace(raw), allowed) {
+				valid = true
+			}
+		}
+		if !valid {
+			return fmt.Errorf("must be one of %s, got %q", strings.Join(spec.oneOf, ", "), raw)
+		}
+	}
+
+	if spec.min != "" {
+		min, err := strconv.ParseFloat(spec.min, 64)
+		if err != nil {
+			return fmt.Errorf("invalid min tag %q", spec.min)
+		}
+		v := spec.value
+		switch {
+		case v.Kind() == reflect.String:
+			if float64(len(v.String())) < min {
+				return fmt.Errorf("must be at least %s characters long", spec.min)
+			}
+		case v.Type() == durationType:
+			// min is in seconds for durations
+			if time.Duration(v.Int()).Seconds() < min {
+				return fmt.Errorf("must be at least %ss", spec.min)
+			}
+		case v.CanInt():
+			if float64(v.Int()) < min {
+				return fmt.Errorf("must be at least %s", spec.min)
+			}
+		case v.CanUint():
+			if float64(v.Uint()) < min {
+				return fmt.Errorf("must be at least %s", spec.min)
+			}
+		case v.CanFloat():
+			if v.Float() < min {
+				return fmt.Errorf("must be at least %s", spec.min)
+			}
+		}
+	}
+	return nil
+}
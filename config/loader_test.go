@@ -0,0 +1,409 @@
+// config/loader_test.go
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type secretsConfig struct {
+	Token    string            `env:"TOKEN,API_TOKEN" secret:"true"`
+	Password string            `env:"PASSWORD" default:"password" secret:"true"`
+	Bind     string            `env:"BIND_PASSWORD" secret:"optional"`
+	Tenants  map[string]string `env:"TENANT_TOKENS" secret:"optional"`
+	Level    string            `env:"LEVEL" default:"info"`
+}
+
+// envOf returns a lookupEnv over values, so tests never see the real environment
+func envOf(values map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}
+}
+
+// writeFile writes content to name in a temporary directory and returns its path
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func notProduction() bool { return false }
+func inProduction() bool  { return true }
+
+func TestLoadSecretFiles(t *testing.T) {
+	tokenFile := writeFile(t, "token", "from-file\n")
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr string
+	}{
+		{name: "value", env: map[string]string{"TOKEN": "from-env"}, want: "from-env"},
+		{name: "file with trailing newline", env: map[string]string{"TOKEN_FILE": tokenFile}, want: "from-file"},
+		{name: "file for an alias", env: map[string]string{"API_TOKEN_FILE": tokenFile}, want: "from-file"},
+		{name: "value beats file", env: map[string]string{"TOKEN": "from-env", "TOKEN_FILE": tokenFile}, want: "from-env"},
+		{name: "empty value falls through to the file", env: map[string]string{"TOKEN": "", "TOKEN_FILE": tokenFile}, want: "from-file"},
+		{name: "missing file", env: map[string]string{"TOKEN_FILE": filepath.Join(t.TempDir(), "absent")}, wantErr: "TOKEN: reading secret file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg secretsConfig
+			_, err := load(&cfg, nil, envOf(tt.env), notProduction)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Token != tt.want {
+				t.Fatalf("Token = %q, want %q", cfg.Token, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadEncryptedValues(t *testing.T) {
+	masterKey, err := GenerateMasterKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ParseMasterKey(masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, _ := GenerateMasterKey()
+	sealed, err := EncryptValue(key, "TOKEN", "decrypted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealedForOther, _ := EncryptValue(key, "PASSWORD", "decrypted")
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr string
+	}{
+		{name: "env", env: map[string]string{"SECRETS_MASTER_KEY": masterKey, "TOKEN": sealed}, want: "decrypted"},
+		{name: "secrets file", env: map[string]string{
+			"SECRETS_MASTER_KEY": masterKey,
+			"SECRETS_FILE":       writeFile(t, "secrets.env", "TOKEN="+sealed+"\n"),
+		}, want: "decrypted"},
+		{name: "mounted file", env: map[string]string{
+			"SECRETS_MASTER_KEY": masterKey,
+			"TOKEN_FILE":         writeFile(t, "token", sealed+"\n"),
+		}, want: "decrypted"},
+		{name: "master key from a file", env: map[string]string{
+			"SECRETS_MASTER_KEY_FILE": writeFile(t, "master", masterKey+"\n"),
+			"TOKEN":                   sealed,
+		}, want: "decrypted"},
+		{name: "master key missing", env: map[string]string{"TOKEN": sealed}, wantErr: "SECRETS_MASTER_KEY is not set"},
+		{name: "wrong master key", env: map[string]string{"SECRETS_MASTER_KEY": otherKey, "TOKEN": sealed}, wantErr: "cannot decrypt"},
+		{name: "sealed for another variable", env: map[string]string{"SECRETS_MASTER_KEY": masterKey, "TOKEN": sealedForOther}, wantErr: "cannot decrypt"},
+		{name: "malformed master key", env: map[string]string{"SECRETS_MASTER_KEY": "short", "TOKEN": sealed}, wantErr: "SECRETS_MASTER_KEY: master key must be 32 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg secretsConfig
+			_, err := load(&cfg, nil, envOf(tt.env), notProduction)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Token != tt.want {
+				t.Fatalf("Token = %q, want %q", cfg.Token, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadSecretPrecedence(t *testing.T) {
+	configFile := writeFile(t, "app.env", "TOKEN=from-config\nSECRETS_FILE="+writeFile(t, "linked.env", "TOKEN=from-linked-secrets\n")+"\n")
+	secretsFile := writeFile(t, "secrets.env", "TOKEN=from-secrets\n")
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want string
+	}{
+		{name: "config file", env: map[string]string{"CONFIG_FILE": writeFile(t, "plain.env", "TOKEN=from-config\n")}, want: "from-config"},
+		{name: "secrets file named by the config file", env: map[string]string{"CONFIG_FILE": configFile}, want: "from-linked-secrets"},
+		{name: "secrets file beats the config file", env: map[string]string{"CONFIG_FILE": configFile, "SECRETS_FILE": secretsFile}, want: "from-secrets"},
+		{name: "env beats the secrets file", env: map[string]string{"CONFIG_FILE": configFile, "SECRETS_FILE": secretsFile, "TOKEN": "from-env"}, want: "from-env"},
+		{name: "flag beats env", args: []string{"-token", "from-flag", "-secrets-file", secretsFile}, env: map[string]string{"TOKEN": "from-env"}, want: "from-flag"},
+		{name: "secrets flag beats SECRETS_FILE", args: []string{"-secrets-file", secretsFile}, env: map[string]string{"SECRETS_FILE": writeFile(t, "ignored.env", "TOKEN=ignored\n")}, want: "from-secrets"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg secretsConfig
+			if _, err := load(&cfg, tt.args, envOf(tt.env), notProduction); err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Token != tt.want {
+				t.Fatalf("Token = %q, want %q", cfg.Token, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadOptionalSecrets(t *testing.T) {
+	base := map[string]string{"TOKEN": "a-real-token", "PASSWORD": "a-real-password"}
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{name: "optional secrets may be unset"},
+		{name: "optional secret set", env: map[string]string{"BIND_PASSWORD": "a-real-password"}},
+		{name: "optional secret placeholder", env: map[string]string{"BIND_PASSWORD": "changeme"}, wantErr: "BIND_PASSWORD: insecure placeholder"},
+		{name: "map of secrets", env: map[string]string{"TENANT_TOKENS": "acme:a-real-token,globex:another-token"}},
+		{name: "placeholder in a map of secrets", env: map[string]string{"TENANT_TOKENS": "acme:a-real-token,globex:secret"}, wantErr: "TENANT_TOKENS: insecure placeholder"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{}
+			for k, v := range base {
+				env[k] = v
+			}
+			for k, v := range tt.env {
+				env[k] = v
+			}
+			var cfg secretsConfig
+			_, err := load(&cfg, nil, envOf(env), inProduction)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+type typedConfig struct {
+	Port     int               `env:"PORT" default:"8080" min:"1"`
+	Ratio    float64           `env:"RATIO" default:"0.5"`
+	Debug    bool              `env:"DEBUG" default:"false"`
+	Timeout  time.Duration     `env:"TIMEOUT" default:"5s" min:"1"`
+	Origins  []string          `env:"ORIGINS" default:"a,b"`
+	Tokens   map[string]string `env:"TOKENS"`
+	Mode     string            `env:"MODE" default:"dev" oneof:"dev|prod"`
+	Name     string            `env:"NAME,APP_NAME" required:"true"`
+	Workers  uint8             `env:"WORKERS" default:"4"`
+	Internal string
+}
+
+func TestLoadTypes(t *testing.T) {
+	var cfg typedConfig
+	env := map[string]string{
+		"RATIO":    "0.25",
+		"DEBUG":    "true",
+		"TIMEOUT":  "1m30s",
+		"ORIGINS":  " https://a.example , ,https://b.example ",
+		"TOKENS":   "acme: t1 ,globex:t2",
+		"MODE":     "PROD",
+		"APP_NAME": "portal",
+		"WORKERS":  "16",
+	}
+	rest, err := load(&cfg, []string{"-port", "9090", "serve", "--verbose"}, envOf(env), notProduction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := typedConfig{
+		Port:    9090,
+		Ratio:   0.25,
+		Debug:   true,
+		Timeout: 90 * time.Second,
+		Origins: []string{"https://a.example", "https://b.example"},
+		Tokens:  map[string]string{"acme": "t1", "globex": "t2"},
+		Mode:    "PROD",
+		Name:    "portal",
+		Workers: 16,
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("got  %+v\nwant %+v", cfg, want)
+	}
+	if !reflect.DeepEqual(rest, []string{"serve", "--verbose"}) {
+		t.Fatalf("rest = %q", rest)
+	}
+}
+
+func TestLoadParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{name: "integer", env: map[string]string{"PORT": "eighty"}, want: `PORT: invalid integer "eighty"`},
+		{name: "number", env: map[string]string{"RATIO": "half"}, want: `RATIO: invalid number "half"`},
+		{name: "boolean", env: map[string]string{"DEBUG": "sometimes"}, want: `DEBUG: invalid boolean "sometimes"`},
+		{name: "duration", env: map[string]string{"TIMEOUT": "5"}, want: `TIMEOUT: invalid duration "5"`},
+		{name: "unsigned overflow", env: map[string]string{"WORKERS": "300"}, want: `WORKERS: invalid unsigned integer "300"`},
+		{name: "key:value pair", env: map[string]string{"TOKENS": "acme"}, want: `TOKENS: invalid key:value pair "acme"`},
+		{name: "oneof", env: map[string]string{"MODE": "staging"}, want: `MODE: must be one of dev, prod, got "staging"`},
+		{name: "minimum", env: map[string]string{"PORT": "0"}, want: "PORT: must be at least 1"},
+		{name: "minimum duration", env: map[string]string{"TIMEOUT": "500ms"}, want: "TIMEOUT: must be at least 1s"},
+		{name: "required", env: map[string]string{"NAME": ""}, want: "NAME: required but not set"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{"NAME": "portal"}
+			for k, v := range tt.env {
+				env[k] = v
+			}
+			var cfg typedConfig
+			_, err := load(&cfg, nil, envOf(env), notProduction)
+			if err == nil || err.Error() != tt.want {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadAggregatesErrors(t *testing.T) {
+	var cfg typedConfig
+	env := map[string]string{"PORT": "eighty", "DEBUG": "sometimes", "MODE": "staging"}
+	_, err := load(&cfg, nil, envOf(env), notProduction)
+	if err == nil {
+		t.Fatal("want an error")
+	}
+	for _, want := range []string{"PORT:", "DEBUG:", "MODE:", "NAME: required"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("%q is missing from %q", want, err)
+		}
+	}
+	if got := strings.Count(err.Error(), "\n") + 1; got != 4 {
+		t.Errorf("%d errors reported, want 4:\n%v", got, err)
+	}
+}
+
+func TestLoadFlagErrors(t *testing.T) {
+	var cfg typedConfig
+	if _, err := load(&cfg, []string{"-no-such-flag"}, envOf(map[string]string{"NAME": "portal"}), notProduction); err == nil {
+		t.Fatal("an unknown flag was accepted")
+	}
+	if _, err := load(&cfg, []string{"-port", "eighty"}, envOf(map[string]string{"NAME": "portal"}), notProduction); err == nil || !strings.Contains(err.Error(), "PORT") {
+		t.Fatalf("err = %v, want PORT rejected", err)
+	}
+	if _, err := load(cfg, nil, envOf(nil), notProduction); err == nil {
+		t.Fatal("a non-pointer destination was accepted")
+	}
+	if _, err := load(&cfg, nil, envOf(map[string]string{"NAME": "portal", "CONFIG_FILE": filepath.Join(t.TempDir(), "absent.env")}), notProduction); err == nil {
+		t.Fatal("a missing CONFIG_FILE was ignored")
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeFile(t, "app.env", "NAME=portal\nPORT=8081\nRATIO=0.1\nMODE=prod\n")
+	tests := []struct {
+		name      string
+		args      []string
+		env       map[string]string
+		wantPort  int
+		wantRatio float64
+		wantMode  string
+	}{
+		{name: "defaults", env: map[string]string{"NAME": "portal"}, wantPort: 8080, wantRatio: 0.5, wantMode: "dev"},
+		{name: "file beats defaults", env: map[string]string{"CONFIG_FILE": file}, wantPort: 8081, wantRatio: 0.1, wantMode: "prod"},
+		{name: "env beats file", env: map[string]string{"CONFIG_FILE": file, "PORT": "8082"}, wantPort: 8082, wantRatio: 0.1, wantMode: "prod"},
+		{name: "flag beats env", args: []string{"-port", "8083", "-mode", "dev"}, env: map[string]string{"CONFIG_FILE": file, "PORT": "8082"}, wantPort: 8083, wantRatio: 0.1, wantMode: "dev"},
+		{name: "config flag beats CONFIG_FILE", args: []string{"-config", file}, env: map[string]string{"CONFIG_FILE": filepath.Join(t.TempDir(), "absent.env")}, wantPort: 8081, wantRatio: 0.1, wantMode: "prod"},
+		{name: "empty env falls through", env: map[string]string{"CONFIG_FILE": file, "PORT": ""}, wantPort: 8081, wantRatio: 0.1, wantMode: "prod"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg typedConfig
+			if _, err := load(&cfg, tt.args, envOf(tt.env), notProduction); err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Port != tt.wantPort || cfg.Ratio != tt.wantRatio || cfg.Mode != tt.wantMode {
+				t.Fatalf("port %d, ratio %v, mode %q; want %d, %v, %q", cfg.Port, cfg.Ratio, cfg.Mode, tt.wantPort, tt.wantRatio, tt.wantMode)
+			}
+		})
+	}
+}
+
+func TestLoadProductionSecrets(t *testing.T) {
+	secretsFile := writeFile(t, "secrets.env", "TOKEN=a-real-token\nPASSWORD=a-real-password\n")
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr []string
+	}{
+		{name: "set explicitly", env: map[string]string{"TOKEN": "a-real-token", "PASSWORD": "a-real-password"}},
+		{name: "from a secrets file", env: map[string]string{"SECRETS_FILE": secretsFile}},
+		{name: "unset", wantErr: []string{"TOKEN: must be set explicitly", "PASSWORD: must be set explicitly"}},
+		{name: "left at its default", env: map[string]string{"TOKEN": "a-real-token"}, wantErr: []string{"PASSWORD: must be set explicitly"}},
+		{name: "placeholder", env: map[string]string{"TOKEN": "ChangeMe", "PASSWORD": "password"}, wantErr: []string{"TOKEN: insecure placeholder", "PASSWORD: insecure placeholder"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg secretsConfig
+			_, err := load(&cfg, nil, envOf(tt.env), inProduction)
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("want an error")
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("%q is missing from %q", want, err)
+				}
+			}
+		})
+	}
+
+	// Outside production the same placeholders are fine
+	var cfg secretsConfig
+	if _, err := load(&cfg, nil, envOf(map[string]string{"TOKEN": "changeme"}), notProduction); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadProductionConfig(t *testing.T) {
+	t.Setenv("APP_ENV", "production")
+	t.Setenv("JWT_SECRET", "changeme-changeme")
+	t.Setenv("ENCRYPTION_KEY", strings.Repeat("ab", 32))
+	t.Setenv("BLIND_INDEX_KEY", "")
+	t.Setenv("DATABASE_PASSWORD", "")
+	_, _, err := Load(nil)
+	if err == nil {
+		t.Fatal("a production configuration without its secrets was accepted")
+	}
+	for _, want := range []string{"BLIND_INDEX_KEY: must be set explicitly", "DATABASE_PASSWORD: must be set explicitly"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("%q is missing from %q", want, err)
+		}
+	}
+	// Optional integrations that aren't configured don't block production
+	for _, optional := range []string{"LDAP_BIND_PASSWORD", "METRICS_BEARER_TOKEN", "SCIM_TENANT_TOKENS"} {
+		if strings.Contains(err.Error(), optional) {
+			t.Errorf("unset %s was reported: %v", optional, err)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// config/reload.go
+package config
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Reloader re-reads the configuration so rotated secrets (a new mounted *_FILE, an updated
+// secrets file) take effect without a restart. Only valid configurations are published; a
+// broken reload is logged and the previous configuration stays in place.
+type Reloader struct {
+	args      []string
+	load      func(args []string) (*Config, []string, error)
+	mu        sync.RWMutex
+	current   *Config
+	listeners []func(*Config)
+	logger    *slog.Logger
+}
+
+// NewReloader creates a new instance of Reloader starting from cfg, which was loaded with args
+func NewReloader(cfg *Config, args []string, logger *slog.Logger) *Reloader {
+	return &Reloader{
+		args:    args,
+		load:    Load,
+		current: cfg,
+		logger:  logger,
+	}
+}
+
+// Current returns the latest valid configuration
+func (r *Reloader) Current() *Config {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current
+}
+
+// OnChange registers fn to be called with the new configuration after every reload that
+// changed something
+func (r *Reloader) OnChange(fn func(*Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listeners = append(r.listeners, fn)
+}
+
+// Reload loads the configuration again and notifies the listeners if it changed
+func (r *Reloader) Reload() error {
+	cfg, _, err := r.load(r.args)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	if reflect.DeepEqual(cfg, r.current) {
+		r.mu.Unlock()
+		return nil
+	}
+	r.current = cfg
+	listeners := append([]func(*Config){}, r.listeners...)
+	r.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(cfg)
+	}
+	return nil
+}
+
+// Watch reloads every interval and whenever the process receives SIGHUP, until stop is closed.
+// An interval of zero disables polling.
+func (r *Reloader) Watch(stop <-chan struct{}, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-hup:
+			r.logger.Info("SIGHUP received, reloading configuration")
+		case <-tick:
+		}
+		if err := r.Reload(); err != nil {
+			r.logger.Error("configuration reload failed, keeping the previous configuration", slog.Any("error", err))
+		}
+	}
+}
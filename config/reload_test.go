@@ -0,0 +1,58 @@
+// config/reload_test.go
+package config
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReload(t *testing.T) {
+	secretFile := writeFile(t, "jwt", "first-signing-secret\n")
+	t.Setenv("JWT_SECRET", "")
+	t.Setenv("JWT_SECRET_FILE", secretFile)
+	t.Setenv("ENCRYPTION_KEY", strings.Repeat("ab", 32))
+
+	cfg, _, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewReloader(cfg, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	var changes []string
+	r.OnChange(func(cfg *Config) { changes = append(changes, cfg.JWTSecret) })
+
+	// Nothing changed, nobody is told
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 || r.Current() != cfg {
+		t.Fatalf("an unchanged reload published %v", changes)
+	}
+
+	// A rotated mounted secret is picked up
+	if err := os.WriteFile(secretFile, []byte("second-signing-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0] != "second-signing-secret" {
+		t.Fatalf("changes = %v, want the rotated secret", changes)
+	}
+	if got := r.Current().JWTSecret; got != "second-signing-secret" {
+		t.Fatalf("Current().JWTSecret = %q", got)
+	}
+
+	// A broken configuration is refused and the last good one stays
+	if err := os.WriteFile(secretFile, []byte("short\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil || !strings.Contains(err.Error(), "JWT_SECRET") {
+		t.Fatalf("err = %v, want JWT_SECRET rejected", err)
+	}
+	if len(changes) != 1 || r.Current().JWTSecret != "second-signing-secret" {
+		t.Fatalf("a failed reload replaced the configuration: %v", changes)
+	}
+}
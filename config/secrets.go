@@ -0,0 +1,138 @@
+// config/secrets.go
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Encrypted values use a sops-like envelope so they can sit in a dotenv file next to plain ones:
+//
+//	ENC[AES256_GCM,data:<base64>,iv:<base64>,tag:<base64>]
+//
+// The variable name is bound in as additional data, so an encrypted value cannot be moved to
+// another key. The 32 byte master key is given in SECRETS_MASTER_KEY (or SECRETS_MASTER_KEY_FILE)
+// as hex or base64.
+const (
+	encPrefix    = "ENC[AES256_GCM,"
+	encSuffix    = "]"
+	masterKeyLen = 32
+)
+
+// IsEncrypted reports whether value is an ENC[...] envelope
+func IsEncrypted(value string) bool {
+	value = strings.TrimSpace(value)
+	return strings.HasPrefix(value, encPrefix) && strings.HasSuffix(value, encSuffix)
+}
+
+// EncryptValue seals plaintext for the variable name under key
+func EncryptValue(key []byte, name, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nil, iv, []byte(plaintext), []byte(name))
+	data, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+
+	enc := base64.StdEncoding.EncodeToString
+	return fmt.Sprintf("%sdata:%s,iv:%s,tag:%s%s", encPrefix, enc(data), enc(iv), enc(tag), encSuffix), nil
+}
+
+// DecryptValue opens an ENC[...] envelope that was sealed for the variable name
+func DecryptValue(key []byte, name, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if !IsEncrypted(value) {
+		return "", errors.New("not an encrypted value")
+	}
+
+	parts := make(map[string][]byte)
+	for _, field := range strings.Split(strings.TrimSuffix(strings.TrimPrefix(value, encPrefix), encSuffix), ",") {
+		k, v, ok := strings.Cut(field, ":")
+		if !ok {
+			return "", fmt.Errorf("malformed encrypted value field %q", field)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return "", fmt.Errorf("malformed encrypted value field %q", k)
+		}
+		parts[k] = decoded
+	}
+	if parts["iv"] == nil || parts["tag"] == nil {
+		return "", errors.New("malformed encrypted value: missing iv or tag")
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(parts["iv"]) != gcm.NonceSize() {
+		return "", errors.New("malformed encrypted value: bad iv")
+	}
+	plaintext, err := gcm.Open(nil, parts["iv"], append(parts["data"], parts["tag"]...), []byte(name))
+	if err != nil {
+		return "", errors.New("cannot decrypt value: wrong master key or value was encrypted for another key")
+	}
+	return string(plaintext), nil
+}
+
+// ParseMasterKey decodes a hex or base64 encoded 32 byte key
+func ParseMasterKey(encoded string) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
+	if key, err := hex.DecodeString(encoded); err == nil && len(key) == masterKeyLen {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(encoded); err == nil && len(key) == masterKeyLen {
+		return key, nil
+	}
+	return nil, fmt.Errorf("master key must be %d bytes, hex or base64 encoded", masterKeyLen)
+}
+
+// GenerateMasterKey returns a new random master key, hex encoded
+func GenerateMasterKey() (string, error) {
+	key := make([]byte, masterKeyLen)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// loadMasterKey reads the master key from the environment only; keeping it next to the values it
+// protects would defeat the point
+func loadMasterKey(lookupEnv func(string) (string, bool)) ([]byte, error) {
+	encoded, ok := lookupEnv("SECRETS_MASTER_KEY")
+	if !ok || encoded == "" {
+		path, ok := lookupEnv("SECRETS_MASTER_KEY_FILE")
+		if !ok || path == "" {
+			return nil, nil
+		}
+		var err error
+		if encoded, err = readSecretFile(path); err != nil {
+			return nil, fmt.Errorf("config: SECRETS_MASTER_KEY_FILE: %w", err)
+		}
+	}
+
+	key, err := ParseMasterKey(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("config: SECRETS_MASTER_KEY: %w", err)
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
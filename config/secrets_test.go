@@ -0,0 +1,100 @@
+// config/secrets_test.go
+package config
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestEncryptValueRoundTrip(t *testing.T) {
+	key := []byte(strings.Repeat("k", masterKeyLen))
+	for _, plaintext := range []string{"", "s3cret", "with,commas:and]brackets", strings.Repeat("long", 256)} {
+		sealed, err := EncryptValue(key, "JWT_SECRET", plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsEncrypted(sealed) {
+			t.Fatalf("%q is not recognised as encrypted", sealed)
+		}
+		if plaintext != "" && strings.Contains(sealed, plaintext) {
+			t.Fatalf("%q leaks the plaintext", sealed)
+		}
+		got, err := DecryptValue(key, "JWT_SECRET", sealed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != plaintext {
+			t.Fatalf("DecryptValue = %q, want %q", got, plaintext)
+		}
+	}
+
+	// A fresh IV per call means the same plaintext never encrypts the same way twice
+	a, _ := EncryptValue(key, "JWT_SECRET", "s3cret")
+	b, _ := EncryptValue(key, "JWT_SECRET", "s3cret")
+	if a == b {
+		t.Fatal("two encryptions of the same value are identical")
+	}
+}
+
+func TestDecryptValueErrors(t *testing.T) {
+	key := []byte(strings.Repeat("k", masterKeyLen))
+	sealed, err := EncryptValue(key, "JWT_SECRET", "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		key      []byte
+		variable string
+		value    string
+	}{
+		{name: "plain value", key: key, variable: "JWT_SECRET", value: "s3cret"},
+		{name: "wrong key", key: []byte(strings.Repeat("x", masterKeyLen)), variable: "JWT_SECRET", value: sealed},
+		{name: "other variable", key: key, variable: "DATABASE_PASSWORD", value: sealed},
+		{name: "tampered data", key: key, variable: "JWT_SECRET", value: strings.Replace(sealed, "data:", "data:AAAA", 1)},
+		{name: "missing tag", key: key, variable: "JWT_SECRET", value: sealed[:strings.Index(sealed, ",tag:")] + "]"},
+		{name: "bad base64", key: key, variable: "JWT_SECRET", value: "ENC[AES256_GCM,data:!!,iv:AAAA,tag:AAAA]"},
+		{name: "short key", key: []byte("short"), variable: "JWT_SECRET", value: sealed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := DecryptValue(tt.key, tt.variable, tt.value); err == nil {
+				t.Fatalf("DecryptValue = %q, want an error", got)
+			}
+		})
+	}
+}
+
+func TestParseMasterKey(t *testing.T) {
+	raw := []byte(strings.Repeat("m", masterKeyLen))
+	tests := []struct {
+		name    string
+		encoded string
+		wantErr bool
+	}{
+		{name: "hex", encoded: hex.EncodeToString(raw)},
+		{name: "base64", encoded: base64.StdEncoding.EncodeToString(raw)},
+		{name: "surrounding whitespace", encoded: " " + hex.EncodeToString(raw) + "\n"},
+		{name: "too short", encoded: hex.EncodeToString(raw[:16]), wantErr: true},
+		{name: "not encoded", encoded: string(raw), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParseMasterKey(tt.encoded)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(key) != string(raw) {
+				t.Fatalf("key = %x, want %x", key, raw)
+			}
+		})
+	}
+}
@@ -2,74 +2,83 @@
 package controllers
 
 import (
-	"encoding/json"
 	"net/http"
 
+	"github.com/ABDULS21985/test-portal/metrics"
 	"github.com/ABDULS21985/test-portal/services"
 	"github.com/ABDULS21985/test-portal/utils"
+
 	"github.com/google/uuid"
 )
 
 type AuthController struct {
-	authService services.AuthService
+	authService     services.AuthService
+	webAuthnService services.WebAuthnService
+	metrics         *metrics.Metrics
 }
 
 // NewAuthController creates a new instance of AuthController
-func NewAuthController(authService services.AuthService) *AuthController {
+func NewAuthController(authService services.AuthService, webAuthnService services.WebAuthnService, m *metrics.Metrics) *AuthController {
 	return &AuthController{
-		authService: authService,
+		authService:     authService,
+		webAuthnService: webAuthnService,
+		metrics:         m,
 	}
 }
 
-// LoginUser handles user authentication
-func (c *AuthController) LoginUser(w http.ResponseWriter, r *http.Request) {
-	var credentials struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
-	}
+// LoginRequest is the body of a password login
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
 
-	if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+// MFAChallengeResponse is returned instead of a token when the user must also present a passkey;
+// options is passed to navigator.credentials.get()
+type MFAChallengeResponse struct {
+	MFARequired bool        `json:"mfa_required"`
+	ChallengeID uuid.UUID   `json:"challenge_id"`
+	Options     interface{} `json:"options"`
+}
+
+// LoginUser handles user authentication. Users with a registered passkey get a WebAuthn challenge
+// instead of a token and finish at /api/v1/auth/webauthn/login/finish.
+func (c *AuthController) LoginUser(w http.ResponseWriter, r *http.Request) {
+	var credentials LoginRequest
+	if err := utils.DecodeJSON(w, r, &credentials); err != nil {
+		utils.RespondWithAppError(w, r, err)
 		return
 	}
 
-	user, token, err := c.authService.AuthenticateUser(credentials.Email, credentials.Password)
+	user, err := c.authService.VerifyCredentials(r.Context(), credentials.Email, credentials.Password)
 	if err != nil {
+		c.metrics.Login(metrics.LoginMethodPassword, false)
 		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
 
-	response := map[string]interface{}{
-		"user":  user,
-		"token": token,
-	}
-
-	utils.RespondWithJSON(w, http.StatusOK, response)
-}
-
-// Login handles user login and JWT generation
-func (c *AuthController) Login(w http.ResponseWriter, r *http.Request) {
-	var request struct {
-		UserID string `json:"user_id"`
-		Role   string `json:"role"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+	hasPasskey, err := c.webAuthnService.HasCredentials(r.Context(), user.ID)
+	if err != nil {
+		utils.RespondWithAppError(w, r, err)
 		return
 	}
-
-	userID, err := uuid.Parse(request.UserID)
-	if err != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID format")
+	if hasPasskey {
+		// The login is counted once the second factor is verified
+		options, challengeID, err := c.webAuthnService.BeginSecondFactor(r.Context(), user)
+		if err != nil {
+			utils.RespondWithAppError(w, r, err)
+			return
+		}
+		utils.RespondWithJSON(w, http.StatusOK, MFAChallengeResponse{MFARequired: true, ChallengeID: challengeID, Options: options})
 		return
 	}
 
-	token, err := c.authService.GenerateToken(userID, request.Role)
+	client := services.ClientInfo{IPAddress: utils.ClientIP(r), UserAgent: r.UserAgent()}
+	token, err := c.authService.IssueToken(r.Context(), user, client)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Could not generate token")
+		utils.RespondWithAppError(w, r, err)
 		return
 	}
+	c.metrics.Login(metrics.LoginMethodPassword, true)
 
-	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"token": token})
+	utils.RespondWithJSON(w, http.StatusOK, TokenResponse{User: user, Token: token})
 }
@@ -0,0 +1,91 @@
+// controllers/auth_controller_test.go
+package controllers
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories/memory"
+	"github.com/ABDULS21985/test-portal/services"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLoginUser(t *testing.T) {
+	ctx := context.Background()
+	repos := memory.NewStore().Repositories()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sessions := services.NewSessionService(repos.Sessions, logger)
+	auth := services.NewAuthService(repos.Users, sessions, services.NewJWTKeyRing([]byte("test-signing-key")), time.Hour, logger)
+	webAuthn, err := services.NewWebAuthnService(services.WebAuthnConfig{RPID: "localhost", RPDisplayName: "Test Portal", RPOrigins: []string{"http://localhost"}}, repos.Users, repos.WebAuthn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller := NewAuthController(auth, webAuthn, nil)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addUser := func(email string, disabled bool) *models.User {
+		user := &models.User{Email: email, Password: string(hash), Role: models.RoleUser, Disabled: disabled}
+		if err := repos.Users.CreateUser(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+		return user
+	}
+	addUser("ada@example.com", false)
+	addUser("gone@example.com", true)
+	withPasskey := addUser("passkey@example.com", false)
+	passkey := &models.WebAuthnCredential{UserID: withPasskey.ID, Name: "Laptop", CredentialID: []byte("credential"), PublicKey: []byte("key")}
+	if err := repos.WebAuthn.CreateCredential(ctx, passkey); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantToken  bool
+		wantMFA    bool
+	}{
+		{name: "valid", body: `{"email":"ada@example.com","password":"correct horse"}`, wantStatus: http.StatusOK, wantToken: true},
+		{name: "wrong password", body: `{"email":"ada@example.com","password":"battery staple"}`, wantStatus: http.StatusUnauthorized},
+		{name: "unknown user", body: `{"email":"nobody@example.com","password":"correct horse"}`, wantStatus: http.StatusUnauthorized},
+		{name: "disabled user", body: `{"email":"gone@example.com","password":"correct horse"}`, wantStatus: http.StatusUnauthorized},
+		{name: "missing password", body: `{"email":"ada@example.com"}`, wantStatus: http.StatusBadRequest},
+		{name: "passkey required", body: `{"email":"passkey@example.com","password":"correct horse"}`, wantStatus: http.StatusOK, wantMFA: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(http.HandlerFunc(controller.LoginUser), "POST", "/api/v1/auth/login", tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+
+			var body struct {
+				TokenResponse
+				MFAChallengeResponse
+			}
+			if rec.Code == http.StatusOK {
+				decodeBody(t, rec, &body)
+			}
+			if (body.Token != "") != tt.wantToken {
+				t.Fatalf("token issued = %v, want %v", body.Token != "", tt.wantToken)
+			}
+			if body.MFARequired != tt.wantMFA {
+				t.Fatalf("mfa_required = %v, want %v", body.MFARequired, tt.wantMFA)
+			}
+			if tt.wantToken {
+				if _, err := auth.GetClaimsFromToken(ctx, body.Token); err != nil {
+					t.Fatalf("the issued token doesn't validate: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+// controllers/health_controller.go
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/ABDULS21985/test-portal/buildinfo"
+	"github.com/ABDULS21985/test-portal/services"
+	"github.com/ABDULS21985/test-portal/utils"
+)
+
+type HealthController struct {
+	healthService services.HealthService
+}
+
+// NewHealthController creates a new instance of HealthController
+func NewHealthController(healthService services.HealthService) *HealthController {
+	return &HealthController{
+		healthService: healthService,
+	}
+}
+
+// Liveness reports that the process is up and serving; it checks no dependencies so a database
+// outage doesn't get every instance restarted
+func (c *HealthController) Liveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"status": services.HealthStatusOK})
+}
+
+// Readiness runs the dependency checks and answers 503 while a critical one fails
+func (c *HealthController) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := c.healthService.Readiness(r.Context())
+
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	utils.RespondWithJSON(w, status, report)
+}
+
+// Version returns the build information of the running binary
+func (c *HealthController) Version(w http.ResponseWriter, r *http.Request) {
+	utils.RespondWithJSON(w, http.StatusOK, buildinfo.Get())
+}
@@ -0,0 +1,95 @@
+// controllers/health_controller_test.go
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/buildinfo"
+	"github.com/ABDULS21985/test-portal/services"
+)
+
+func TestHealthController(t *testing.T) {
+	check := func(name string, critical, ok bool) services.HealthCheck {
+		return services.HealthCheck{Name: name, Critical: critical, Check: func(context.Context) error {
+			if !ok {
+				return errors.New("connection refused")
+			}
+			return nil
+		}}
+	}
+
+	tests := []struct {
+		name       string
+		checks     []services.HealthCheck
+		handler    func(c *HealthController) http.HandlerFunc
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "liveness ignores failing checks",
+			checks:     []services.HealthCheck{check("database", true, false)},
+			handler:    func(c *HealthController) http.HandlerFunc { return c.Liveness },
+			wantStatus: http.StatusOK,
+			wantBody:   services.HealthStatusOK,
+		},
+		{
+			name:       "ready",
+			checks:     []services.HealthCheck{check("database", true, true), check("smtp", false, true)},
+			handler:    func(c *HealthController) http.HandlerFunc { return c.Readiness },
+			wantStatus: http.StatusOK,
+			wantBody:   services.HealthStatusOK,
+		},
+		{
+			name:       "degraded is still ready",
+			checks:     []services.HealthCheck{check("database", true, true), check("smtp", false, false)},
+			handler:    func(c *HealthController) http.HandlerFunc { return c.Readiness },
+			wantStatus: http.StatusOK,
+			wantBody:   services.HealthStatusDegraded,
+		},
+		{
+			name:       "critical check failing",
+			checks:     []services.HealthCheck{check("database", true, false), check("smtp", false, true)},
+			handler:    func(c *HealthController) http.HandlerFunc { return c.Readiness },
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   services.HealthStatusFailing,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := NewHealthController(services.NewHealthService(time.Second, slog.New(slog.NewTextHandler(io.Discard, nil)), tt.checks...))
+			rec := serve(tt.handler(controller), "GET", "/healthz", "")
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+				t.Fatalf("Cache-Control = %q, want no-store", got)
+			}
+			var body struct {
+				Status string `json:"status"`
+			}
+			decodeBody(t, rec, &body)
+			if body.Status != tt.wantBody {
+				t.Fatalf("status = %q, want %q", body.Status, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHealthVersion(t *testing.T) {
+	controller := NewHealthController(services.NewHealthService(time.Second, slog.New(slog.NewTextHandler(io.Discard, nil))))
+	rec := serve(http.HandlerFunc(controller.Version), "GET", "/version", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var info buildinfo.Info
+	decodeBody(t, rec, &info)
+	if info != buildinfo.Get() {
+		t.Fatalf("version = %+v, want %+v", info, buildinfo.Get())
+	}
+}
@@ -0,0 +1,111 @@
+// controllers/impersonation_controller.go
+package controllers
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/middleware"
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/services"
+	"github.com/ABDULS21985/test-portal/utils"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type ImpersonationController struct {
+	authService  services.AuthService
+	auditService services.AuditService
+	tokenTTL     time.Duration
+	logger       *slog.Logger
+}
+
+// NewImpersonationController creates a new instance of ImpersonationController
+func NewImpersonationController(authService services.AuthService, auditService services.AuditService, tokenTTL time.Duration, logger *slog.Logger) *ImpersonationController {
+	return &ImpersonationController{
+		authService:  authService,
+		auditService: auditService,
+		tokenTTL:     tokenTTL,
+		logger:       logger,
+	}
+}
+
+// ImpersonationRequest explains why a user is being impersonated, for the audit log
+type ImpersonationRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// ImpersonationResponse carries a short-lived token acting as the subject
+type ImpersonationResponse struct {
+	Token         string       `json:"token"`
+	User          *models.User `json:"user"`
+	Impersonating bool         `json:"impersonating"`
+	ExpiresAt     time.Time    `json:"expires_at"`
+}
+
+// Impersonate issues a token that lets a support admin view the portal as another user
+func (c *ImpersonationController) Impersonate(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+	// An impersonation token must never be used to start another impersonation
+	if claims, ok := middleware.ClaimsFromContext(r.Context()); ok {
+		if _, impersonating := services.ImpersonatorFromClaims(claims); impersonating {
+			utils.RespondWithError(w, http.StatusForbidden, "This action is not allowed while impersonating a user")
+			return
+		}
+	}
+
+	subjectID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var request ImpersonationRequest
+	if err := utils.DecodeJSON(w, r, &request); err != nil {
+		utils.RespondWithAppError(w, r, err)
+		return
+	}
+	if strings.TrimSpace(request.Reason) == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "A reason is required to impersonate a user")
+		return
+	}
+
+	client := services.ClientInfo{IPAddress: utils.ClientIP(r), UserAgent: r.UserAgent()}
+	token, subject, err := c.authService.Impersonate(r.Context(), actorID, subjectID, c.tokenTTL, client)
+	if err != nil {
+		utils.RespondWithAppError(w, r, err)
+		return
+	}
+
+	entry := &models.AuditLog{
+		Action:    services.AuditImpersonationStart,
+		ActorID:   actorID,
+		SubjectID: &subject.ID,
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Status:    http.StatusOK,
+		IPAddress: utils.ClientIP(r),
+		UserAgent: r.UserAgent(),
+		Details:   request.Reason,
+	}
+	// Refuse to hand out an unaudited token
+	if err := c.auditService.Record(r.Context(), entry); err != nil {
+		c.logger.ErrorContext(r.Context(), "failed to write impersonation audit entry", slog.Any("error", err))
+		utils.RespondWithError(w, http.StatusInternalServerError, "Could not start impersonation")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, ImpersonationResponse{
+		Token:         token,
+		User:          subject,
+		Impersonating: true,
+		ExpiresAt:     time.Now().Add(c.tokenTTL),
+	})
+}
@@ -0,0 +1,138 @@
+// controllers/impersonation_controller_test.go
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/middleware"
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories"
+	"github.com/ABDULS21985/test-portal/repositories/memory"
+	"github.com/ABDULS21985/test-portal/services"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// failingAudit refuses every entry, as a database outage would
+type failingAudit struct{}
+
+func (failingAudit) Record(context.Context, *models.AuditLog) error {
+	return errors.New("connection refused")
+}
+
+func TestImpersonate(t *testing.T) {
+	ctx := context.Background()
+	repos := memory.NewStore().Repositories()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sessions := services.NewSessionService(repos.Sessions, logger)
+	auth := services.NewAuthService(repos.Users, sessions, services.NewJWTKeyRing([]byte("test-signing-key")), time.Hour, logger)
+	audit := services.NewAuditService(repos.AuditLogs)
+
+	addUser := func(email, role, tenant string) *models.User {
+		user := &models.User{Email: email, Role: role, Tenant: tenant}
+		if err := repos.Users.CreateUser(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+		return user
+	}
+	staff := addUser("staff@acme.example", models.RoleAdmin, "acme")
+	otherAdmin := addUser("boss@acme.example", models.RoleAdmin, "acme")
+	member := addUser("member@acme.example", models.RoleUser, "acme")
+	outsider := addUser("member@other.example", models.RoleUser, "other")
+
+	staffToken, err := auth.IssueToken(ctx, staff, services.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	impersonationToken, _, err := auth.Impersonate(ctx, staff.ID, member.ID, time.Hour, services.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newRouter := func(audit services.AuditService) http.Handler {
+		controller := NewImpersonationController(auth, audit, 15*time.Minute, logger)
+		router := mux.NewRouter()
+		router.Handle("/admin/impersonate/{id}", middleware.NewAuthMiddleware(auth, sessions).RequireAuth(http.HandlerFunc(controller.Impersonate))).Methods("POST")
+		return router
+	}
+	reason := `{"reason":"ticket #42"}`
+
+	tests := []struct {
+		name       string
+		audit      services.AuditService
+		token      string
+		target     string
+		body       string
+		wantStatus int
+	}{
+		{name: "member of the same tenant", token: staffToken, target: member.ID.String(), body: reason, wantStatus: http.StatusOK},
+		{name: "another tenant", token: staffToken, target: outsider.ID.String(), body: reason, wantStatus: http.StatusForbidden},
+		{name: "another admin", token: staffToken, target: otherAdmin.ID.String(), body: reason, wantStatus: http.StatusForbidden},
+		{name: "themselves", token: staffToken, target: staff.ID.String(), body: reason, wantStatus: http.StatusForbidden},
+		{name: "unknown user", token: staffToken, target: uuid.NewString(), body: reason, wantStatus: http.StatusNotFound},
+		{name: "invalid ID", token: staffToken, target: "42", body: reason, wantStatus: http.StatusBadRequest},
+		{name: "no reason", token: staffToken, target: member.ID.String(), body: `{"reason":"  "}`, wantStatus: http.StatusBadRequest},
+		{name: "while impersonating", token: impersonationToken, target: outsider.ID.String(), body: reason, wantStatus: http.StatusForbidden},
+		{name: "audit trail unavailable", audit: failingAudit{}, token: staffToken, target: member.ID.String(), body: reason, wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.audit == nil {
+				tt.audit = audit
+			}
+			before, err := repos.AuditLogs.ListBySubject(ctx, member.ID, repositories.ListOptions{Limit: 100})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/impersonate/"+tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			newRouter(tt.audit).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if rec.Code != http.StatusOK {
+				if strings.Contains(rec.Body.String(), "token") {
+					t.Fatalf("a refused request returned %s", rec.Body)
+				}
+				return
+			}
+
+			var body ImpersonationResponse
+			decodeBody(t, rec, &body)
+			if !body.Impersonating || body.User == nil || body.User.ID != member.ID || body.Token == "" {
+				t.Fatalf("response = %+v", body)
+			}
+			claims, err := auth.GetClaimsFromToken(ctx, body.Token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actor, ok := services.ImpersonatorFromClaims(claims); !ok || actor != staff.ID {
+				t.Fatalf("token actor = %v, %v; want %v", actor, ok, staff.ID)
+			}
+
+			after, err := repos.AuditLogs.ListBySubject(ctx, member.ID, repositories.ListOptions{Limit: 100})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(after) != len(before)+1 {
+				t.Fatalf("%d new audit entries, want 1", len(after)-len(before))
+			}
+			if entry := after[0]; entry.Action != services.AuditImpersonationStart || entry.ActorID != staff.ID || entry.Details != "ticket #42" {
+				t.Fatalf("audit entry = %+v", entry)
+			}
+		})
+	}
+}
@@ -2,73 +2,84 @@
 package controllers
 
 import (
-	"encoding/json"
 	"net/http"
 
+	"github.com/ABDULS21985/test-portal/metrics"
 	"github.com/ABDULS21985/test-portal/services"
 	"github.com/ABDULS21985/test-portal/utils"
 )
 
 type PasswordResetController struct {
 	passwordResetService services.PasswordResetService
+	metrics              *metrics.Metrics
 }
 
 // NewPasswordResetController creates a new instance of PasswordResetController
-func NewPasswordResetController(passwordResetService services.PasswordResetService) *PasswordResetController {
+func NewPasswordResetController(passwordResetService services.PasswordResetService, m *metrics.Metrics) *PasswordResetController {
 	return &PasswordResetController{
 		passwordResetService: passwordResetService,
+		metrics:              m,
 	}
 }
 
+// PasswordResetRequest names the account to reset
+type PasswordResetRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// PasswordResetResponse confirms a reset request; token is only set until reset emails are sent
+type PasswordResetResponse struct {
+	Message string `json:"message"`
+	Token   string `json:"token,omitempty"`
+}
+
+// ResetPasswordRequest sets a new password with a reset token
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required"`
+}
+
 // RequestPasswordReset handles password reset requests
 func (c *PasswordResetController) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
-	var request struct {
-		Email string `json:"email"`
-	}
+	var request PasswordResetRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+	if err := utils.DecodeJSON(w, r, &request); err != nil {
+		utils.RespondWithAppError(w, r, err)
 		return
 	}
+	// Counted before the lookup so the metric doesn't reveal which addresses exist
+	c.metrics.PasswordResetRequested()
 
-	user, err := c.passwordResetService.GetUserByEmail(request.Email)
+	user, err := c.passwordResetService.GetUserByEmail(r.Context(), request.Email)
 	if err != nil {
 		// For security, do not reveal whether the email exists
-		utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "If that email exists, a reset link has been sent."})
+		utils.RespondWithJSON(w, http.StatusOK, PasswordResetResponse{Message: "If that email exists, a reset link has been sent."})
 		return
 	}
 
-	token, err := c.passwordResetService.CreatePasswordResetToken(user.ID)
+	token, err := c.passwordResetService.CreatePasswordResetToken(r.Context(), user.ID)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Could not create reset token")
+		utils.RespondWithAppError(w, r, err)
 		return
 	}
 
 	// TODO: Send the token via email to the user
-	response := map[string]interface{}{
-		"message": "Password reset token generated.",
-		"token":   token,
-	}
-
-	utils.RespondWithJSON(w, http.StatusOK, response)
+	utils.RespondWithJSON(w, http.StatusOK, PasswordResetResponse{Message: "Password reset token generated.", Token: token})
 }
 
 // ResetPassword handles the actual password reset
 func (c *PasswordResetController) ResetPassword(w http.ResponseWriter, r *http.Request) {
-	var request struct {
-		Token       string `json:"token"`
-		NewPassword string `json:"new_password"`
-	}
+	var request ResetPasswordRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+	if err := utils.DecodeJSON(w, r, &request); err != nil {
+		utils.RespondWithAppError(w, r, err)
 		return
 	}
 
-	if err := c.passwordResetService.ResetPassword(request.Token, request.NewPassword); err != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+	if err := c.passwordResetService.ResetPassword(r.Context(), request.Token, request.NewPassword); err != nil {
+		utils.RespondWithAppError(w, r, err)
 		return
 	}
 
-	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Password has been reset successfully."})
+	utils.RespondWithJSON(w, http.StatusOK, MessageResponse{Message: "Password has been reset successfully."})
 }
@@ -0,0 +1,15 @@
+// controllers/responses.go
+package controllers
+
+import "github.com/ABDULS21985/test-portal/models"
+
+// MessageResponse is the body of a request that only needs confirming
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// TokenResponse is the body of a completed login
+type TokenResponse struct {
+	User  *models.User `json:"user"`
+	Token string       `json:"token"`
+}
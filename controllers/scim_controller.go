@@ -0,0 +1,307 @@
+// controllers/scim_controller.go
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ABDULS21985/test-portal/apperrors"
+	"github.com/ABDULS21985/test-portal/middleware"
+	"github.com/ABDULS21985/test-portal/scim"
+	"github.com/ABDULS21985/test-portal/services"
+	"github.com/ABDULS21985/test-portal/utils"
+
+	"github.com/gorilla/mux"
+)
+
+type SCIMController struct {
+	scimService services.SCIMService
+	logger      *slog.Logger
+}
+
+// NewSCIMController creates a new instance of SCIMController
+func NewSCIMController(scimService services.SCIMService, logger *slog.Logger) *SCIMController {
+	return &SCIMController{
+		scimService: scimService,
+		logger:      logger,
+	}
+}
+
+// ServiceProviderConfig advertises which optional SCIM features are supported
+func (c *SCIMController) ServiceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	supported := func(ok bool) map[string]bool { return map[string]bool{"supported": ok} }
+
+	scim.Respond(w, http.StatusOK, map[string]interface{}{
+		"schemas":        []string{scim.SchemaServiceProviderConfig},
+		"patch":          supported(true),
+		"bulk":           map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]interface{}{"supported": true, "maxResults": 200},
+		"changePassword": supported(true),
+		"sort":           supported(false),
+		"etag":           supported(false),
+		"authenticationSchemes": []map[string]string{{
+			"type":        "oauthbearertoken",
+			"name":        "OAuth Bearer Token",
+			"description": "Per-tenant bearer token issued by the portal administrators",
+		}},
+	})
+}
+
+// ListUsers handles GET /Users with filtering and pagination
+func (c *SCIMController) ListUsers(w http.ResponseWriter, r *http.Request) {
+	query, scimErr := parseSCIMQuery(r)
+	if scimErr != nil {
+		scim.RespondWithError(w, scimErr)
+		return
+	}
+
+	response, err := c.scimService.ListUsers(r.Context(), middleware.SCIMTenantFromContext(r.Context()), query)
+	if err != nil {
+		c.respondWithSCIMError(w, r, err)
+		return
+	}
+	scim.Respond(w, http.StatusOK, response)
+}
+
+// CreateUser handles POST /Users
+func (c *SCIMController) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var user scim.User
+	if scimErr := decodeSCIMBody(w, r, &user); scimErr != nil {
+		scim.RespondWithError(w, scimErr)
+		return
+	}
+
+	created, err := c.scimService.CreateUser(r.Context(), middleware.SCIMTenantFromContext(r.Context()), &user)
+	if err != nil {
+		c.respondWithSCIMError(w, r, err)
+		return
+	}
+	w.Header().Set("Location", created.Meta.Location)
+	scim.Respond(w, http.StatusCreated, created)
+}
+
+// GetUser handles GET /Users/{id}
+func (c *SCIMController) GetUser(w http.ResponseWriter, r *http.Request) {
+	user, err := c.scimService.GetUser(r.Context(), middleware.SCIMTenantFromContext(r.Context()), mux.Vars(r)["id"])
+	if err != nil {
+		c.respondWithSCIMError(w, r, err)
+		return
+	}
+	scim.Respond(w, http.StatusOK, user)
+}
+
+// ReplaceUser handles PUT /Users/{id}
+func (c *SCIMController) ReplaceUser(w http.ResponseWriter, r *http.Request) {
+	var user scim.User
+	if scimErr := decodeSCIMBody(w, r, &user); scimErr != nil {
+		scim.RespondWithError(w, scimErr)
+		return
+	}
+
+	updated, err := c.scimService.ReplaceUser(r.Context(), middleware.SCIMTenantFromContext(r.Context()), mux.Vars(r)["id"], &user)
+	if err != nil {
+		c.respondWithSCIMError(w, r, err)
+		return
+	}
+	scim.Respond(w, http.StatusOK, updated)
+}
+
+// PatchUser handles PATCH /Users/{id}
+func (c *SCIMController) PatchUser(w http.ResponseWriter, r *http.Request) {
+	patch, scimErr := decodePatch(w, r)
+	if scimErr != nil {
+		scim.RespondWithError(w, scimErr)
+		return
+	}
+
+	updated, err := c.scimService.PatchUser(r.Context(), middleware.SCIMTenantFromContext(r.Context()), mux.Vars(r)["id"], patch)
+	if err != nil {
+		c.respondWithSCIMError(w, r, err)
+		return
+	}
+	scim.Respond(w, http.StatusOK, updated)
+}
+
+// DeleteUser handles DELETE /Users/{id}
+func (c *SCIMController) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	if err := c.scimService.DeleteUser(r.Context(), middleware.SCIMTenantFromContext(r.Context()), mux.Vars(r)["id"]); err != nil {
+		c.respondWithSCIMError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListGroups handles GET /Groups with filtering and pagination
+func (c *SCIMController) ListGroups(w http.ResponseWriter, r *http.Request) {
+	query, scimErr := parseSCIMQuery(r)
+	if scimErr != nil {
+		scim.RespondWithError(w, scimErr)
+		return
+	}
+
+	response, err := c.scimService.ListGroups(r.Context(), middleware.SCIMTenantFromContext(r.Context()), query)
+	if err != nil {
+		c.respondWithSCIMError(w, r, err)
+		return
+	}
+	scim.Respond(w, http.StatusOK, response)
+}
+
+// CreateGroup handles POST /Groups
+func (c *SCIMController) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	var group scim.Group
+	if scimErr := decodeSCIMBody(w, r, &group); scimErr != nil {
+		scim.RespondWithError(w, scimErr)
+		return
+	}
+
+	created, err := c.scimService.CreateGroup(r.Context(), middleware.SCIMTenantFromContext(r.Context()), &group)
+	if err != nil {
+		c.respondWithSCIMError(w, r, err)
+		return
+	}
+	w.Header().Set("Location", created.Meta.Location)
+	scim.Respond(w, http.StatusCreated, created)
+}
+
+// GetGroup handles GET /Groups/{id}
+func (c *SCIMController) GetGroup(w http.ResponseWriter, r *http.Request) {
+	group, err := c.scimService.GetGroup(r.Context(), middleware.SCIMTenantFromContext(r.Context()), mux.Vars(r)["id"], excludesMembers(r))
+	if err != nil {
+		c.respondWithSCIMError(w, r, err)
+		return
+	}
+	scim.Respond(w, http.StatusOK, group)
+}
+
+// ReplaceGroup handles PUT /Groups/{id}
+func (c *SCIMController) ReplaceGroup(w http.ResponseWriter, r *http.Request) {
+	var group scim.Group
+	if scimErr := decodeSCIMBody(w, r, &group); scimErr != nil {
+		scim.RespondWithError(w, scimErr)
+		return
+	}
+
+	updated, err := c.scimService.ReplaceGroup(r.Context(), middleware.SCIMTenantFromContext(r.Context()), mux.Vars(r)["id"], &group)
+	if err != nil {
+		c.respondWithSCIMError(w, r, err)
+		return
+	}
+	scim.Respond(w, http.StatusOK, updated)
+}
+
+// PatchGroup handles PATCH /Groups/{id}
+func (c *SCIMController) PatchGroup(w http.ResponseWriter, r *http.Request) {
+	patch, scimErr := decodePatch(w, r)
+	if scimErr != nil {
+		scim.RespondWithError(w, scimErr)
+		return
+	}
+
+	updated, err := c.scimService.PatchGroup(r.Context(), middleware.SCIMTenantFromContext(r.Context()), mux.Vars(r)["id"], patch)
+	if err != nil {
+		c.respondWithSCIMError(w, r, err)
+		return
+	}
+	scim.Respond(w, http.StatusOK, updated)
+}
+
+// DeleteGroup handles DELETE /Groups/{id}
+func (c *SCIMController) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	if err := c.scimService.DeleteGroup(r.Context(), middleware.SCIMTenantFromContext(r.Context()), mux.Vars(r)["id"]); err != nil {
+		c.respondWithSCIMError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// respondWithSCIMError renders client errors as-is and hides the details of anything else
+func (c *SCIMController) respondWithSCIMError(w http.ResponseWriter, r *http.Request, err error) {
+	var scimErr *scim.Error
+	if errors.As(err, &scimErr) {
+		scim.RespondWithError(w, scimErr)
+		return
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		scim.RespondWithError(w, scim.NewError(http.StatusGatewayTimeout, "", "Request timed out"))
+		return
+	}
+	c.logger.ErrorContext(r.Context(), "SCIM request failed", slog.Any("error", err))
+	scim.RespondWithError(w, scim.NewError(http.StatusInternalServerError, "", "Internal Server Error"))
+}
+
+func parseSCIMQuery(r *http.Request) (services.SCIMQuery, *scim.Error) {
+	params := r.URL.Query()
+	query := services.SCIMQuery{
+		Filter:         params.Get("filter"),
+		StartIndex:     1,
+		ExcludeMembers: excludesMembers(r),
+	}
+
+	if raw := params.Get("startIndex"); raw != "" {
+		startIndex, err := strconv.Atoi(raw)
+		if err != nil {
+			return query, scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "startIndex must be an integer")
+		}
+		query.StartIndex = startIndex
+	}
+	if raw := params.Get("count"); raw != "" {
+		count, err := strconv.Atoi(raw)
+		if err != nil {
+			return query, scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "count must be an integer")
+		}
+		query.Count = &count
+	}
+	return query, nil
+}
+
+// decodeSCIMBody reads a SCIM request body, ignoring attributes the portal doesn't support as
+// RFC 7644 allows, and reports problems as SCIM errors
+func decodeSCIMBody(w http.ResponseWriter, r *http.Request, dst any) *scim.Error {
+	err := utils.DecodeJSONWithOptions(w, r, dst, utils.DecodeOptions{AllowUnknownFields: true})
+	if err == nil {
+		return nil
+	}
+	appErr, ok := apperrors.As(err)
+	if !ok {
+		return scim.NewError(http.StatusBadRequest, scim.ErrInvalidSyntax, "Invalid request payload")
+	}
+
+	scimType, detail := scim.ErrInvalidSyntax, appErr.Message
+	if len(appErr.Fields) > 0 {
+		scimType = scim.ErrInvalidValue
+		problems := make([]string, len(appErr.Fields))
+		for i, field := range appErr.Fields {
+			problems[i] = field.Field + " " + field.Message
+		}
+		detail += ": " + strings.Join(problems, "; ")
+	}
+	if appErr.Kind != apperrors.KindValidation {
+		scimType = ""
+	}
+	return scim.NewError(utils.StatusForKind(appErr.Kind), scimType, detail)
+}
+
+func excludesMembers(r *http.Request) bool {
+	for _, attr := range strings.Split(r.URL.Query().Get("excludedAttributes"), ",") {
+		if strings.EqualFold(strings.TrimSpace(attr), "members") {
+			return true
+		}
+	}
+	return false
+}
+
+func decodePatch(w http.ResponseWriter, r *http.Request) (*scim.PatchRequest, *scim.Error) {
+	var patch scim.PatchRequest
+	if scimErr := decodeSCIMBody(w, r, &patch); scimErr != nil {
+		return nil, scimErr
+	}
+	if len(patch.Operations) == 0 {
+		return nil, scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "Operations must not be empty")
+	}
+	return &patch, nil
+}
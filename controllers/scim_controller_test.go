@@ -0,0 +1,177 @@
+// controllers/scim_controller_test.go
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ABDULS21985/test-portal/middleware"
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories/memory"
+	"github.com/ABDULS21985/test-portal/scim"
+	"github.com/ABDULS21985/test-portal/services"
+
+	"github.com/gorilla/mux"
+)
+
+// brokenSCIM fails every lookup with err
+type brokenSCIM struct {
+	services.SCIMService
+	err error
+}
+
+func (s brokenSCIM) GetUser(context.Context, string, string) (*scim.User, error) {
+	return nil, s.err
+}
+
+// newSCIMRouter serves controller the way routes/scim.go does, with one token per tenant
+func newSCIMRouter(controller *SCIMController) http.Handler {
+	router := mux.NewRouter()
+	router.Use(middleware.NewSCIMAuthMiddleware(map[string]string{"acme": "acme-token", "globex": "globex-token"}).RequireTenantToken)
+	router.HandleFunc("/ServiceProviderConfig", controller.ServiceProviderConfig).Methods("GET")
+	router.HandleFunc("/Users", controller.ListUsers).Methods("GET")
+	router.HandleFunc("/Users", controller.CreateUser).Methods("POST")
+	router.HandleFunc("/Users/{id}", controller.GetUser).Methods("GET")
+	router.HandleFunc("/Users/{id}", controller.ReplaceUser).Methods("PUT")
+	router.HandleFunc("/Users/{id}", controller.PatchUser).Methods("PATCH")
+	router.HandleFunc("/Users/{id}", controller.DeleteUser).Methods("DELETE")
+	router.HandleFunc("/Groups", controller.ListGroups).Methods("GET")
+	router.HandleFunc("/Groups", controller.CreateGroup).Methods("POST")
+	router.HandleFunc("/Groups/{id}", controller.GetGroup).Methods("GET")
+	router.HandleFunc("/Groups/{id}", controller.PatchGroup).Methods("PATCH")
+	router.HandleFunc("/Groups/{id}", controller.DeleteGroup).Methods("DELETE")
+	return router
+}
+
+// serveSCIM sends a SCIM request authenticated with token
+func serveSCIM(handler http.Handler, token, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", scim.ContentType)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSCIMController(t *testing.T) {
+	store := memory.NewStore()
+	repos := store.Repositories()
+	service := services.NewSCIMService(repos.Users, repos.Roles, repos.Sessions, memory.NewTxManager(store), models.RoleUser)
+	router := newSCIMRouter(NewSCIMController(service, slog.New(slog.NewTextHandler(io.Discard, nil))))
+
+	rec := serveSCIM(router, "acme-token", "POST", "/Users", `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"userName":"ada@acme.example","title":"ignored"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("creating a user: %d %s", rec.Code, rec.Body)
+	}
+	var ada scim.User
+	decodeBody(t, rec, &ada)
+	if rec.Header().Get("Location") != ada.Meta.Location || ada.Meta.Location == "" {
+		t.Fatalf("Location = %q, meta.location = %q", rec.Header().Get("Location"), ada.Meta.Location)
+	}
+	rec = serveSCIM(router, "acme-token", "POST", "/Groups", `{"displayName":"Engineering"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("creating a group: %d %s", rec.Code, rec.Body)
+	}
+	var group scim.Group
+	decodeBody(t, rec, &group)
+
+	tests := []struct {
+		name         string
+		token        string
+		method       string
+		target       string
+		body         string
+		wantStatus   int
+		wantSCIMType string
+		wantBody     string
+		wantAbsent   string
+	}{
+		{name: "no token", method: "GET", target: "/Users", wantStatus: http.StatusUnauthorized},
+		{name: "unknown token", token: "guessed", method: "GET", target: "/Users", wantStatus: http.StatusUnauthorized},
+		{name: "service provider config", token: "acme-token", method: "GET", target: "/ServiceProviderConfig", wantStatus: http.StatusOK, wantBody: `"patch":{"supported":true}`},
+		{name: "list users", token: "acme-token", method: "GET", target: "/Users", wantStatus: http.StatusOK, wantBody: `"totalResults":1`},
+		{name: "list users filtered", token: "acme-token", method: "GET", target: `/Users?filter=userName+eq+%22nobody%40acme.example%22`, wantStatus: http.StatusOK, wantBody: `"totalResults":0`},
+		{name: "another tenant sees none", token: "globex-token", method: "GET", target: "/Users", wantStatus: http.StatusOK, wantBody: `"totalResults":0`},
+		{name: "invalid filter", token: "acme-token", method: "GET", target: "/Users?filter=userName+eq", wantStatus: http.StatusBadRequest, wantSCIMType: scim.ErrInvalidFilter},
+		{name: "invalid startIndex", token: "acme-token", method: "GET", target: "/Users?startIndex=first", wantStatus: http.StatusBadRequest, wantSCIMType: scim.ErrInvalidValue},
+		{name: "invalid count", token: "acme-token", method: "GET", target: "/Groups?count=all", wantStatus: http.StatusBadRequest, wantSCIMType: scim.ErrInvalidValue},
+		{name: "get user", token: "acme-token", method: "GET", target: "/Users/" + ada.ID, wantStatus: http.StatusOK, wantBody: `"userName":"ada@acme.example"`},
+		{name: "get user of another tenant", token: "globex-token", method: "GET", target: "/Users/" + ada.ID, wantStatus: http.StatusNotFound},
+		{name: "malformed body", token: "acme-token", method: "POST", target: "/Users", body: `{"userName":`, wantStatus: http.StatusBadRequest, wantSCIMType: scim.ErrInvalidSyntax},
+		{name: "duplicate userName", token: "acme-token", method: "POST", target: "/Users", body: `{"userName":"ada@acme.example"}`, wantStatus: http.StatusConflict, wantSCIMType: scim.ErrUniqueness},
+		{name: "replace user", token: "acme-token", method: "PUT", target: "/Users/" + ada.ID, body: `{"userName":"ada@acme.example","displayName":"Ada L."}`, wantStatus: http.StatusOK, wantBody: `"displayName":"Ada L."`},
+		{name: "patch without operations", token: "acme-token", method: "PATCH", target: "/Users/" + ada.ID, body: `{"Operations":[]}`, wantStatus: http.StatusBadRequest, wantSCIMType: scim.ErrInvalidValue},
+		{name: "patch operation without op", token: "acme-token", method: "PATCH", target: "/Users/" + ada.ID, body: `{"Operations":[{"path":"active","value":false}]}`, wantStatus: http.StatusBadRequest, wantSCIMType: scim.ErrInvalidValue},
+		{name: "deactivate user", token: "acme-token", method: "PATCH", target: "/Users/" + ada.ID, body: `{"Operations":[{"op":"replace","path":"active","value":false}]}`, wantStatus: http.StatusOK, wantBody: `"active":false`},
+		{name: "add group member", token: "acme-token", method: "PATCH", target: "/Groups/" + group.ID, body: fmt.Sprintf(`{"Operations":[{"op":"add","path":"members","value":[{"value":%q}]}]}`, ada.ID), wantStatus: http.StatusOK, wantBody: ada.ID},
+		{name: "group without members", token: "acme-token", method: "GET", target: "/Groups/" + group.ID + "?excludedAttributes=members", wantStatus: http.StatusOK, wantAbsent: `"members"`},
+		{name: "delete group of another tenant", token: "globex-token", method: "DELETE", target: "/Groups/" + group.ID, wantStatus: http.StatusNotFound},
+		{name: "delete user", token: "acme-token", method: "DELETE", target: "/Users/" + ada.ID, wantStatus: http.StatusNoContent},
+		{name: "deleted user is gone", token: "acme-token", method: "GET", target: "/Users/" + ada.ID, wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveSCIM(router, tt.token, tt.method, tt.target, tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if rec.Code == http.StatusNoContent {
+				return
+			}
+			if got := rec.Header().Get("Content-Type"); got != scim.ContentType {
+				t.Fatalf("Content-Type = %q", got)
+			}
+			if rec.Code >= 400 {
+				var body scim.Error
+				decodeBody(t, rec, &body)
+				if body.Status != fmt.Sprint(tt.wantStatus) || body.ScimType != tt.wantSCIMType {
+					t.Fatalf("error = %+v, want status %d and scimType %q", body, tt.wantStatus, tt.wantSCIMType)
+				}
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Fatalf("body %s doesn't contain %s", rec.Body, tt.wantBody)
+			}
+			if tt.wantAbsent != "" && strings.Contains(rec.Body.String(), tt.wantAbsent) {
+				t.Fatalf("body %s contains %s", rec.Body, tt.wantAbsent)
+			}
+		})
+	}
+}
+
+func TestSCIMControllerErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantDetail string
+	}{
+		{name: "SCIM error", err: scim.NewError(http.StatusNotFound, "", "User 42 not found"), wantStatus: http.StatusNotFound, wantDetail: "User 42 not found"},
+		{name: "timeout", err: fmt.Errorf("query: %w", context.DeadlineExceeded), wantStatus: http.StatusGatewayTimeout, wantDetail: "Request timed out"},
+		{name: "internal details stay hidden", err: errors.New("dial tcp db.internal:5432: connection refused"), wantStatus: http.StatusInternalServerError, wantDetail: "Internal Server Error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newSCIMRouter(NewSCIMController(brokenSCIM{err: tt.err}, slog.New(slog.NewTextHandler(io.Discard, nil))))
+			rec := serveSCIM(router, "acme-token", "GET", "/Users/42", "")
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			var body scim.Error
+			decodeBody(t, rec, &body)
+			if body.Detail != tt.wantDetail {
+				t.Fatalf("detail = %q, want %q", body.Detail, tt.wantDetail)
+			}
+		})
+	}
+}
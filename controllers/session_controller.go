@@ -0,0 +1,89 @@
+// controllers/session_controller.go
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/middleware"
+	"github.com/ABDULS21985/test-portal/services"
+	"github.com/ABDULS21985/test-portal/utils"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type SessionController struct {
+	sessionService services.SessionService
+}
+
+// NewSessionController creates a new instance of SessionController
+func NewSessionController(sessionService services.SessionService) *SessionController {
+	return &SessionController{
+		sessionService: sessionService,
+	}
+}
+
+// SessionResponse is a session as shown to its owner
+type SessionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	IPAddress  string    `json:"ip_address"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// ListMySessions lists the caller's active sessions
+func (c *SessionController) ListMySessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+	currentID, _ := middleware.SessionIDFromContext(r.Context())
+
+	sessions, err := c.sessionService.ListSessions(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithAppError(w, r, err)
+		return
+	}
+
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionResponse{
+			ID:         session.ID,
+			IPAddress:  session.IPAddress,
+			UserAgent:  session.UserAgent,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.ID == currentID,
+		})
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, response)
+}
+
+// RevokeMySession logs the caller out of one of their sessions
+func (c *SessionController) RevokeMySession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+
+	sessionID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	if err := c.sessionService.RevokeSession(r.Context(), userID, sessionID); err != nil {
+		utils.RespondWithAppError(w, r, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, MessageResponse{Message: "Session terminated"})
+}
@@ -0,0 +1,121 @@
+// controllers/session_controller_test.go
+package controllers
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/middleware"
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories/memory"
+	"github.com/ABDULS21985/test-portal/services"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+func TestSessionController(t *testing.T) {
+	ctx := context.Background()
+	repos := memory.NewStore().Repositories()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sessions := services.NewSessionService(repos.Sessions, logger)
+	auth := services.NewAuthService(repos.Users, sessions, services.NewJWTKeyRing([]byte("test-signing-key")), time.Hour, logger)
+	controller := NewSessionController(sessions)
+
+	requireAuth := middleware.NewAuthMiddleware(auth, sessions).RequireAuth
+	router := mux.NewRouter()
+	router.Handle("/me/sessions", requireAuth(http.HandlerFunc(controller.ListMySessions))).Methods("GET")
+	router.Handle("/me/sessions/{id}", requireAuth(http.HandlerFunc(controller.RevokeMySession))).Methods("DELETE")
+
+	login := func(user *models.User, userAgent string) (string, uuid.UUID) {
+		token, err := auth.IssueToken(ctx, user, services.ClientInfo{IPAddress: "203.0.113.7", UserAgent: userAgent})
+		if err != nil {
+			t.Fatal(err)
+		}
+		active, err := sessions.ListSessions(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, session := range active {
+			if session.UserAgent == userAgent {
+				return token, session.ID
+			}
+		}
+		t.Fatalf("no session for %s", userAgent)
+		return "", uuid.Nil
+	}
+	ada := &models.User{Email: "ada@example.com", Role: models.RoleUser}
+	bob := &models.User{Email: "bob@example.com", Role: models.RoleUser}
+	for _, user := range []*models.User{ada, bob} {
+		if err := repos.Users.CreateUser(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	laptop, laptopSession := login(ada, "laptop")
+	phone, phoneSession := login(ada, "phone")
+	_, bobSession := login(bob, "bob's laptop")
+
+	// The steps share the sessions, so each sees the revocations before it
+	steps := []struct {
+		name         string
+		token        string
+		method       string
+		target       string
+		wantStatus   int
+		wantSessions []string // user agents, current first
+	}{
+		{name: "list", token: laptop, method: "GET", target: "/me/sessions", wantStatus: http.StatusOK, wantSessions: []string{"laptop", "phone"}},
+		{name: "list from another session", token: phone, method: "GET", target: "/me/sessions", wantStatus: http.StatusOK, wantSessions: []string{"phone", "laptop"}},
+		{name: "no token", method: "GET", target: "/me/sessions", wantStatus: http.StatusUnauthorized},
+		{name: "invalid session ID", token: laptop, method: "DELETE", target: "/me/sessions/42", wantStatus: http.StatusBadRequest},
+		{name: "unknown session", token: laptop, method: "DELETE", target: "/me/sessions/" + uuid.NewString(), wantStatus: http.StatusNotFound},
+		{name: "another user's session", token: laptop, method: "DELETE", target: "/me/sessions/" + bobSession.String(), wantStatus: http.StatusNotFound},
+		{name: "revoke another own session", token: laptop, method: "DELETE", target: "/me/sessions/" + phoneSession.String(), wantStatus: http.StatusOK},
+		{name: "revoked session is signed out", token: phone, method: "GET", target: "/me/sessions", wantStatus: http.StatusUnauthorized},
+		{name: "revoking twice is fine", token: laptop, method: "DELETE", target: "/me/sessions/" + phoneSession.String(), wantStatus: http.StatusOK},
+		{name: "list after revoking", token: laptop, method: "GET", target: "/me/sessions", wantStatus: http.StatusOK, wantSessions: []string{"laptop"}},
+		{name: "revoke the current session", token: laptop, method: "DELETE", target: "/me/sessions/" + laptopSession.String(), wantStatus: http.StatusOK},
+		{name: "current session is signed out", token: laptop, method: "GET", target: "/me/sessions", wantStatus: http.StatusUnauthorized},
+	}
+	for _, step := range steps {
+		req := httptest.NewRequest(step.method, step.target, nil)
+		if step.token != "" {
+			req.Header.Set("Authorization", "Bearer "+step.token)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != step.wantStatus {
+			t.Fatalf("%s: status = %d, want %d: %s", step.name, rec.Code, step.wantStatus, rec.Body)
+		}
+		if step.wantSessions == nil {
+			continue
+		}
+
+		var body []SessionResponse
+		decodeBody(t, rec, &body)
+		if len(body) != len(step.wantSessions) {
+			t.Fatalf("%s: %d sessions, want %d", step.name, len(body), len(step.wantSessions))
+		}
+		for _, session := range body {
+			current := session.UserAgent == step.wantSessions[0]
+			if session.Current != current || session.IPAddress != "203.0.113.7" {
+				t.Fatalf("%s: session %+v, want current = %v", step.name, session, current)
+			}
+		}
+	}
+}
+
+func TestSessionControllerUnauthenticated(t *testing.T) {
+	repos := memory.NewStore().Repositories()
+	controller := NewSessionController(services.NewSessionService(repos.Sessions, slog.New(slog.NewTextHandler(io.Discard, nil))))
+	for name, handler := range map[string]http.HandlerFunc{"list": controller.ListMySessions, "revoke": controller.RevokeMySession} {
+		if rec := serve(handler, "GET", "/me/sessions", ""); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s without a user: status = %d, want 401", name, rec.Code)
+		}
+	}
+}
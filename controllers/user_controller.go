@@ -2,8 +2,8 @@
 package controllers
 
 import (
-	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/ABDULS21985/test-portal/models"
 	"github.com/ABDULS21985/test-portal/services"
@@ -24,16 +24,34 @@ func NewUserController(userService services.UserService) *UserController {
 	}
 }
 
+// RegisterRequest is the body of a self-registration. The role isn't the caller's to choose.
+type RegisterRequest struct {
+	Name     string `json:"name" validate:"max=100"`
+	Email    string `json:"email" validate:"required,email,max=100"`
+	Password string `json:"password" validate:"required,min=8"`
+}
+
+// UpdateProfileRequest is the body of a profile update. Members left out keep their stored
+// values; the role, tenant, account state and auth source can't be changed this way.
+type UpdateProfileRequest struct {
+	Name        *string    `json:"name,omitempty" validate:"max=100"`
+	Email       *string    `json:"email,omitempty" validate:"email,max=100"`
+	Password    *string    `json:"password,omitempty" validate:"min=8"`
+	Phone       *string    `json:"phone,omitempty" validate:"max=32"`
+	DateOfBirth *time.Time `json:"date_of_birth,omitempty"`
+}
+
 // RegisterUser handles user registration
 func (c *UserController) RegisterUser(w http.ResponseWriter, r *http.Request) {
-	var user models.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+	var req RegisterRequest
+	if err := utils.DecodeJSON(w, r, &req); err != nil {
+		utils.RespondWithAppError(w, r, err)
 		return
 	}
 
-	if err := c.userService.RegisterUser(&user); err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+	user := models.User{Name: req.Name, Email: req.Email, Password: req.Password, Role: models.RoleUser}
+	if err := c.userService.RegisterUser(r.Context(), &user); err != nil {
+		utils.RespondWithAppError(w, r, err)
 		return
 	}
 
@@ -49,9 +67,9 @@ func (c *UserController) GetUserProfile(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	user, err := c.userService.GetUserProfile(userID)
+	user, err := c.userService.GetUserProfile(r.Context(), userID)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		utils.RespondWithAppError(w, r, err)
 		return
 	}
 
@@ -67,15 +85,21 @@ func (c *UserController) UpdateUserProfile(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	var user models.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+	var req UpdateProfileRequest
+	if err := utils.DecodeJSON(w, r, &req); err != nil {
+		utils.RespondWithAppError(w, r, err)
 		return
 	}
 
-	user.ID = userID
-	if err := c.userService.UpdateUserProfile(&user); err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+	user, err := c.userService.UpdateUserProfile(r.Context(), userID, services.ProfileUpdate{
+		Name:        req.Name,
+		Email:       req.Email,
+		Password:    req.Password,
+		Phone:       req.Phone,
+		DateOfBirth: req.DateOfBirth,
+	})
+	if err != nil {
+		utils.RespondWithAppError(w, r, err)
 		return
 	}
 
@@ -91,10 +115,10 @@ func (c *UserController) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := c.userService.DeleteUser(userID); err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+	if err := c.userService.DeleteUser(r.Context(), userID); err != nil {
+		utils.RespondWithAppError(w, r, err)
 		return
 	}
 
-	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "User deleted successfully"})
+	utils.RespondWithJSON(w, http.StatusOK, MessageResponse{Message: "User deleted successfully"})
 }
@@ -0,0 +1,248 @@
+// controllers/user_controller_test.go
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ABDULS21985/test-portal/encryption/encryptiontest"
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories"
+	"github.com/ABDULS21985/test-portal/repositories/memory"
+	"github.com/ABDULS21985/test-portal/services"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// serve sends a request with a JSON body, when there is one, to handler and records the response
+func serve(handler http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	var req *http.Request
+	if body == "" {
+		req = httptest.NewRequest(method, target, nil)
+	} else {
+		req = httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// decodeBody unmarshals the recorded JSON response into dst
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder, dst any) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), dst); err != nil {
+		t.Fatalf("response %q: %v", rec.Body.String(), err)
+	}
+}
+
+func newUserRouter(t *testing.T) (*mux.Router, repositories.Repositories) {
+	t.Helper()
+	repos := memory.NewStore().Repositories()
+	controller := NewUserController(services.NewUserService(repos.Users))
+
+	router := mux.NewRouter()
+	router.HandleFunc("/users", controller.RegisterUser).Methods("POST")
+	router.HandleFunc("/users/{id}", controller.GetUserProfile).Methods("GET")
+	router.HandleFunc("/users/{id}", controller.UpdateUserProfile).Methods("PUT")
+	router.HandleFunc("/users/{id}", controller.DeleteUser).Methods("DELETE")
+	return router, repos
+}
+
+func TestRegisterUser(t *testing.T) {
+	router, repos := newUserRouter(t)
+	if rec := serve(router, "POST", "/users", `{"email":"taken@example.com","password":"long enough"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("seeding a user: %d %s", rec.Code, rec.Body)
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "valid", body: `{"name":"Ada","email":"ada@example.com","password":"correct horse"}`, wantStatus: http.StatusCreated},
+		{name: "missing password", body: `{"email":"ada2@example.com"}`, wantStatus: http.StatusBadRequest},
+		{name: "short password", body: `{"email":"ada3@example.com","password":"short"}`, wantStatus: http.StatusBadRequest},
+		{name: "invalid email", body: `{"email":"not-an-email","password":"correct horse"}`, wantStatus: http.StatusBadRequest},
+		{name: "role is not the caller's to choose", body: `{"email":"ada4@example.com","password":"correct horse","role":"admin"}`, wantStatus: http.StatusBadRequest},
+		{name: "duplicate email", body: `{"email":"taken@example.com","password":"correct horse"}`, wantStatus: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(router, "POST", "/users", tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if rec.Code != http.StatusCreated {
+				return
+			}
+			if strings.Contains(rec.Body.String(), "correct horse") || strings.Contains(rec.Body.String(), "password") {
+				t.Fatalf("the response exposes the password: %s", rec.Body)
+			}
+
+			var created models.User
+			decodeBody(t, rec, &created)
+			stored, err := repos.Users.GetUserByID(context.Background(), created.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Role != models.RoleUser {
+				t.Fatalf("role = %q, want %q", stored.Role, models.RoleUser)
+			}
+			if err := bcrypt.CompareHashAndPassword([]byte(stored.Password), []byte("correct horse")); err != nil {
+				t.Fatal("the password wasn't stored as a bcrypt hash")
+			}
+		})
+	}
+
+	t.Run("wrong content type", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/users", strings.NewReader(`{"email":"ada5@example.com","password":"correct horse"}`))
+		req.Header.Set("Content-Type", "text/plain")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnsupportedMediaType {
+			t.Fatalf("status = %d, want 415", rec.Code)
+		}
+	})
+}
+
+func TestGetUserProfile(t *testing.T) {
+	router, repos := newUserRouter(t)
+	user := &models.User{Email: "ada@example.com", Password: "hash", Role: models.RoleUser}
+	if err := repos.Users.CreateUser(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		id         string
+		wantStatus int
+	}{
+		{name: "existing", id: user.ID.String(), wantStatus: http.StatusOK},
+		{name: "unknown", id: uuid.NewString(), wantStatus: http.StatusNotFound},
+		{name: "malformed ID", id: "42", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(router, "GET", "/users/"+tt.id, "")
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+			var got models.User
+			decodeBody(t, rec, &got)
+			if got.ID != user.ID || got.Email != user.Email {
+				t.Fatalf("got %+v", got)
+			}
+		})
+	}
+}
+
+func TestUpdateUserProfile(t *testing.T) {
+	encryptiontest.Use(t)
+	ctx := context.Background()
+	router, repos := newUserRouter(t)
+	if err := repos.Users.CreateUser(ctx, &models.User{Email: "taken@example.com", Password: "hash", Role: models.RoleUser}); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantName   string
+	}{
+		{name: "name", body: `{"name":"Ada Lovelace"}`, wantStatus: http.StatusOK, wantName: "Ada Lovelace"},
+		{name: "tenant", body: `{"tenant":"other"}`, wantStatus: http.StatusBadRequest},
+		{name: "disabled", body: `{"disabled":false}`, wantStatus: http.StatusBadRequest},
+		{name: "role", body: `{"role":"admin"}`, wantStatus: http.StatusBadRequest},
+		{name: "auth source", body: `{"auth_source":"ldap"}`, wantStatus: http.StatusBadRequest},
+		{name: "alongside an allowed field", body: `{"name":"Mallory","role":"admin","tenant":"other"}`, wantStatus: http.StatusBadRequest},
+		{name: "blank email", body: `{"email":""}`, wantStatus: http.StatusBadRequest},
+		{name: "short password", body: `{"password":"short"}`, wantStatus: http.StatusBadRequest},
+		{name: "duplicate email", body: `{"email":"taken@example.com"}`, wantStatus: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A user SCIM disabled in its tenant
+			user := &models.User{Name: "Ada", Email: uuid.NewString() + "@example.com", Password: string(hash), Role: models.RoleUser, Tenant: "acme", Disabled: true, Phone: "+44 20 7946 0000"}
+			if err := repos.Users.CreateUser(ctx, user); err != nil {
+				t.Fatal(err)
+			}
+
+			rec := serve(router, "PUT", "/users/"+user.ID.String(), tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+
+			stored, err := repos.Users.GetUserByID(ctx, user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Tenant != "acme" || !stored.Disabled || stored.Role != models.RoleUser || stored.AuthSource != models.AuthSourceLocal {
+				t.Fatalf("the account changed: %+v", stored)
+			}
+			// Fields the body leaves out keep their values
+			if stored.Password != string(hash) || stored.Phone != user.Phone || stored.Email != user.Email {
+				t.Fatalf("unmentioned fields changed: %+v", stored)
+			}
+			wantName := "Ada"
+			if tt.wantName != "" {
+				wantName = tt.wantName
+			}
+			if stored.Name != wantName {
+				t.Fatalf("name = %q, want %q", stored.Name, wantName)
+			}
+		})
+	}
+
+	t.Run("password", func(t *testing.T) {
+		user := &models.User{Email: "grace@example.com", Password: string(hash), Role: models.RoleUser}
+		if err := repos.Users.CreateUser(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+		if rec := serve(router, "PUT", "/users/"+user.ID.String(), `{"password":"battery staple"}`); rec.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+		}
+		stored, err := repos.Users.GetUserByID(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(stored.Password), []byte("battery staple")); err != nil {
+			t.Fatal("the new password wasn't stored as a bcrypt hash")
+		}
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		if rec := serve(router, "PUT", "/users/"+uuid.NewString(), `{"name":"Nobody"}`); rec.Code != http.StatusNotFound {
+			t.Fatalf("status = %d, want 404", rec.Code)
+		}
+	})
+}
+
+func TestDeleteUser(t *testing.T) {
+	router, repos := newUserRouter(t)
+	user := &models.User{Email: "ada@example.com", Password: "hash", Role: models.RoleUser}
+	if err := repos.Users.CreateUser(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := serve(router, "DELETE", "/users/"+user.ID.String(), ""); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(router, "GET", "/users/"+user.ID.String(), ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("the deleted user is still served: %d", rec.Code)
+	}
+}
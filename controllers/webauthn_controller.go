@@ -0,0 +1,181 @@
+// controllers/webauthn_controller.go
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ABDULS21985/test-portal/metrics"
+	"github.com/ABDULS21985/test-portal/middleware"
+	"github.com/ABDULS21985/test-portal/services"
+	"github.com/ABDULS21985/test-portal/utils"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+type WebAuthnController struct {
+	webAuthnService services.WebAuthnService
+	authService     services.AuthService
+	metrics         *metrics.Metrics
+}
+
+// NewWebAuthnController creates a new instance of WebAuthnController
+func NewWebAuthnController(webAuthnService services.WebAuthnService, authService services.AuthService, m *metrics.Metrics) *WebAuthnController {
+	return &WebAuthnController{
+		webAuthnService: webAuthnService,
+		authService:     authService,
+		metrics:         m,
+	}
+}
+
+// CeremonyResponse is the body returned to the browser when a ceremony starts; options is passed
+// to navigator.credentials.create() or .get() and challenge_id is echoed back on finish
+type CeremonyResponse struct {
+	ChallengeID uuid.UUID   `json:"challenge_id"`
+	Options     interface{} `json:"options"`
+}
+
+// FinishRegistrationRequest carries the authenticator's attestation for a new passkey
+type FinishRegistrationRequest struct {
+	ChallengeID uuid.UUID       `json:"challenge_id" validate:"required"`
+	Name        string          `json:"name" validate:"max=100"`
+	Credential  json.RawMessage `json:"credential" validate:"required"`
+}
+
+// BeginLoginRequest optionally names the account to log in to
+type BeginLoginRequest struct {
+	Email string `json:"email" validate:"omitempty,email"`
+}
+
+// FinishLoginRequest carries the authenticator's assertion
+type FinishLoginRequest struct {
+	ChallengeID uuid.UUID       `json:"challenge_id" validate:"required"`
+	Credential  json.RawMessage `json:"credential" validate:"required"`
+}
+
+// BeginRegistration starts registering a new passkey for the authenticated user
+func (c *WebAuthnController) BeginRegistration(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+
+	options, challengeID, err := c.webAuthnService.BeginRegistration(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithAppError(w, r, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, CeremonyResponse{ChallengeID: challengeID, Options: options})
+}
+
+// FinishRegistration verifies the authenticator's attestation and stores the new passkey
+func (c *WebAuthnController) FinishRegistration(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+
+	var request FinishRegistrationRequest
+	if err := utils.DecodeJSON(w, r, &request); err != nil {
+		utils.RespondWithAppError(w, r, err)
+		return
+	}
+
+	credential, err := c.webAuthnService.FinishRegistration(r.Context(), userID, request.ChallengeID, request.Name, request.Credential)
+	if err != nil {
+		utils.RespondWithAppError(w, r, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, credential)
+}
+
+// ListCredentials lists the authenticated user's passkeys
+func (c *WebAuthnController) ListCredentials(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+
+	credentials, err := c.webAuthnService.ListCredentials(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithAppError(w, r, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, credentials)
+}
+
+// DeleteCredential removes one of the authenticated user's passkeys
+func (c *WebAuthnController) DeleteCredential(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+
+	credentialID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid credential ID")
+		return
+	}
+
+	if err := c.webAuthnService.DeleteCredential(r.Context(), userID, credentialID); err != nil {
+		utils.RespondWithAppError(w, r, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, MessageResponse{Message: "Passkey deleted successfully"})
+}
+
+// BeginLogin starts a passwordless passkey login
+func (c *WebAuthnController) BeginLogin(w http.ResponseWriter, r *http.Request) {
+	var request BeginLoginRequest
+	// The body is optional; without an email the browser offers any discoverable passkey
+	if r.ContentLength != 0 {
+		if err := utils.DecodeJSON(w, r, &request); err != nil {
+			utils.RespondWithAppError(w, r, err)
+			return
+		}
+	}
+
+	options, challengeID, err := c.webAuthnService.BeginLogin(r.Context(), request.Email)
+	if err != nil {
+		utils.RespondWithAppError(w, r, err)
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, CeremonyResponse{ChallengeID: challengeID, Options: options})
+}
+
+// FinishLogin verifies a passkey assertion, either for a passwordless login or as the second
+// factor of /api/v1/auth/login, and issues a token
+func (c *WebAuthnController) FinishLogin(w http.ResponseWriter, r *http.Request) {
+	var request FinishLoginRequest
+	if err := utils.DecodeJSON(w, r, &request); err != nil {
+		utils.RespondWithAppError(w, r, err)
+		return
+	}
+
+	user, err := c.webAuthnService.FinishLogin(r.Context(), request.ChallengeID, request.Credential)
+	if err != nil {
+		c.metrics.Login(metrics.LoginMethodPasskey, false)
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid credentials")
+		return
+	}
+
+	client := services.ClientInfo{IPAddress: utils.ClientIP(r), UserAgent: r.UserAgent()}
+	token, err := c.authService.IssueToken(r.Context(), user, client)
+	if err != nil {
+		utils.RespondWithAppError(w, r, err)
+		return
+	}
+	c.metrics.Login(metrics.LoginMethodPasskey, true)
+
+	utils.RespondWithJSON(w, http.StatusOK, TokenResponse{User: user, Token: token})
+}
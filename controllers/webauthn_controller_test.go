@@ -0,0 +1,154 @@
+// controllers/webauthn_controller_test.go
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/middleware"
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories/memory"
+	"github.com/ABDULS21985/test-portal/services"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// acceptingWebAuthn accepts every login assertion as user's; signing real assertions is covered
+// by the service tests
+type acceptingWebAuthn struct {
+	services.WebAuthnService
+	user *models.User
+}
+
+func (s acceptingWebAuthn) FinishLogin(context.Context, uuid.UUID, []byte) (*models.User, error) {
+	return s.user, nil
+}
+
+func TestWebAuthnController(t *testing.T) {
+	ctx := context.Background()
+	repos := memory.NewStore().Repositories()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sessions := services.NewSessionService(repos.Sessions, logger)
+	auth := services.NewAuthService(repos.Users, sessions, services.NewJWTKeyRing([]byte("test-signing-key")), time.Hour, logger)
+	webAuthn, err := services.NewWebAuthnService(services.WebAuthnConfig{RPID: "localhost", RPDisplayName: "Test Portal", RPOrigins: []string{"http://localhost"}}, repos.Users, repos.WebAuthn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ada := &models.User{Email: "ada@example.com", Role: models.RoleUser}
+	bob := &models.User{Email: "bob@example.com", Role: models.RoleUser}
+	for _, user := range []*models.User{ada, bob} {
+		if err := repos.Users.CreateUser(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	adaKey := &models.WebAuthnCredential{UserID: ada.ID, Name: "Laptop", CredentialID: []byte("ada-credential"), PublicKey: []byte("key")}
+	bobKey := &models.WebAuthnCredential{UserID: bob.ID, Name: "Phone", CredentialID: []byte("bob-credential"), PublicKey: []byte("key")}
+	for _, credential := range []*models.WebAuthnCredential{adaKey, bobKey} {
+		if err := repos.WebAuthn.CreateCredential(ctx, credential); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, loginChallenge, err := webAuthn.BeginLogin(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newRouter := func(service services.WebAuthnService) http.Handler {
+		controller := NewWebAuthnController(service, auth, nil)
+		// Stands in for RequireAuth: the X-User header names the authenticated user
+		asUser := func(next http.HandlerFunc) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if id, err := uuid.Parse(r.Header.Get("X-User")); err == nil {
+					r = r.WithContext(context.WithValue(r.Context(), middleware.UserContext, id))
+				}
+				next(w, r)
+			})
+		}
+		router := mux.NewRouter()
+		router.Handle("/passkeys/register/begin", asUser(controller.BeginRegistration)).Methods("POST")
+		router.Handle("/passkeys/register/finish", asUser(controller.FinishRegistration)).Methods("POST")
+		router.Handle("/passkeys", asUser(controller.ListCredentials)).Methods("GET")
+		router.Handle("/passkeys/{id}", asUser(controller.DeleteCredential)).Methods("DELETE")
+		router.HandleFunc("/passkeys/login/begin", controller.BeginLogin).Methods("POST")
+		router.HandleFunc("/passkeys/login/finish", controller.FinishLogin).Methods("POST")
+		return router
+	}
+
+	finishLogin := fmt.Sprintf(`{"challenge_id":%q,"credential":{"id":"x"}}`, loginChallenge)
+	steps := []struct {
+		name       string
+		service    services.WebAuthnService
+		user       *models.User
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		wantBody   string
+		wantToken  bool
+	}{
+		{name: "begin registration", user: ada, method: "POST", target: "/passkeys/register/begin", wantStatus: http.StatusOK, wantBody: `"name":"ada@example.com"`},
+		{name: "registration excludes existing passkeys", user: ada, method: "POST", target: "/passkeys/register/begin", wantStatus: http.StatusOK, wantBody: `"excludeCredentials"`},
+		{name: "begin registration unauthenticated", method: "POST", target: "/passkeys/register/begin", wantStatus: http.StatusUnauthorized},
+		{name: "finish registration without a credential", user: ada, method: "POST", target: "/passkeys/register/finish", body: fmt.Sprintf(`{"challenge_id":%q}`, uuid.New()), wantStatus: http.StatusBadRequest},
+		{name: "finish registration with an unknown challenge", user: ada, method: "POST", target: "/passkeys/register/finish", body: fmt.Sprintf(`{"challenge_id":%q,"credential":{}}`, uuid.New()), wantStatus: http.StatusBadRequest},
+		{name: "finish registration unauthenticated", method: "POST", target: "/passkeys/register/finish", body: `{}`, wantStatus: http.StatusUnauthorized},
+		{name: "list", user: ada, method: "GET", target: "/passkeys", wantStatus: http.StatusOK, wantBody: `"name":"Laptop"`},
+		{name: "list unauthenticated", method: "GET", target: "/passkeys", wantStatus: http.StatusUnauthorized},
+		{name: "delete with an invalid ID", user: ada, method: "DELETE", target: "/passkeys/42", wantStatus: http.StatusBadRequest},
+		{name: "delete another user's passkey", user: ada, method: "DELETE", target: "/passkeys/" + bobKey.ID.String(), wantStatus: http.StatusNotFound},
+		{name: "delete", user: ada, method: "DELETE", target: "/passkeys/" + adaKey.ID.String(), wantStatus: http.StatusOK},
+		{name: "deleted passkey is gone", user: ada, method: "GET", target: "/passkeys", wantStatus: http.StatusOK, wantBody: `[]`},
+		{name: "begin login without a body", method: "POST", target: "/passkeys/login/begin", wantStatus: http.StatusOK, wantBody: `"challenge_id"`},
+		{name: "begin login for an account", method: "POST", target: "/passkeys/login/begin", body: `{"email":"bob@example.com"}`, wantStatus: http.StatusOK, wantBody: `"allowCredentials"`},
+		{name: "begin login for an unknown account", method: "POST", target: "/passkeys/login/begin", body: `{"email":"nobody@example.com"}`, wantStatus: http.StatusOK, wantBody: `"challenge_id"`},
+		{name: "begin login with an invalid email", method: "POST", target: "/passkeys/login/begin", body: `{"email":"bob"}`, wantStatus: http.StatusBadRequest},
+		{name: "finish login with a bad assertion", method: "POST", target: "/passkeys/login/finish", body: finishLogin, wantStatus: http.StatusUnauthorized},
+		{name: "finish login without a challenge", method: "POST", target: "/passkeys/login/finish", body: `{"credential":{}}`, wantStatus: http.StatusBadRequest},
+		{name: "finish login", service: acceptingWebAuthn{WebAuthnService: webAuthn, user: bob}, method: "POST", target: "/passkeys/login/finish", body: finishLogin, wantStatus: http.StatusOK, wantToken: true},
+	}
+	for _, step := range steps {
+		service := step.service
+		if service == nil {
+			service = webAuthn
+		}
+		req := httptest.NewRequest(step.method, step.target, strings.NewReader(step.body))
+		if step.body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if step.user != nil {
+			req.Header.Set("X-User", step.user.ID.String())
+		}
+		rec := httptest.NewRecorder()
+		newRouter(service).ServeHTTP(rec, req)
+
+		if rec.Code != step.wantStatus {
+			t.Fatalf("%s: status = %d, want %d: %s", step.name, rec.Code, step.wantStatus, rec.Body)
+		}
+		if !strings.Contains(rec.Body.String(), step.wantBody) {
+			t.Fatalf("%s: body %s doesn't contain %s", step.name, rec.Body, step.wantBody)
+		}
+		if rec.Code == http.StatusUnauthorized && strings.Contains(step.target, "login") && !strings.Contains(rec.Body.String(), "Invalid credentials") {
+			t.Fatalf("%s: a failed login says more than it should: %s", step.name, rec.Body)
+		}
+		if step.wantToken {
+			var body TokenResponse
+			decodeBody(t, rec, &body)
+			claims, err := auth.GetClaimsFromToken(ctx, body.Token)
+			if err != nil {
+				t.Fatalf("%s: the issued token doesn't validate: %v", step.name, err)
+			}
+			if claims["user_id"] != bob.ID.String() {
+				t.Fatalf("%s: token for %v, want %v", step.name, claims["user_id"], bob.ID)
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// database/database.go
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/logging"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// maxBackoff caps the delay between connection attempts
+const maxBackoff = 30 * time.Second
+
+// Options describes how to reach Postgres and how to size the connection pool
+type Options struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	Name     string
+	SSLMode  string
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// ConnectAttempts is how many times Connect tries before giving up; Backoff is the first
+	// delay between attempts and doubles after every failure
+	ConnectAttempts int
+	Backoff         time.Duration
+
+	// Logger receives GORM's query log; queries slower than SlowQueryThreshold are warnings
+	Logger             *slog.Logger
+	SlowQueryThreshold time.Duration
+}
+
+// DSN returns the libpq connection string for the options
+func (o Options) DSN() string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		o.Host, o.Port, o.User, o.Password, o.Name, o.SSLMode)
+}
+
+// Open builds a *gorm.DB with the configured pool settings and checks it is reachable
+func Open(ctx context.Context, opts Options) (*gorm.DB, error) {
+	logger := opts.Logger
+	if logger == nil {
+		logger = logging.Discard()
+	}
+	db, err := gorm.Open(postgres.Open(opts.DSN()), &gorm.Config{
+		Logger: logging.NewGormLogger(logger, opts.SlowQueryThreshold),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(opts.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(opts.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
+
+	if err := Ping(ctx, db); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Connect opens the database, retrying with exponential backoff so the application can start
+// before Postgres is ready. It gives up after opts.ConnectAttempts or when ctx is done.
+func Connect(ctx context.Context, opts Options) (*gorm.DB, error) {
+	attempts := opts.ConnectAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := opts.Backoff
+	logger := opts.Logger
+	if logger == nil {
+		logger = logging.Discard()
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		db, err := Open(ctx, opts)
+		if err == nil {
+			return db, nil
+		}
+		lastErr = err
+		if attempt == attempts {
+			break
+		}
+
+		logger.Warn("database not reachable, retrying",
+			slog.Int("attempt", attempt), slog.Int("attempts", attempts), slog.Duration("backoff", backoff), slog.Any("error", err))
+		select {
+		case <-ctx.Done():
+			return nil, errors.Join(ctx.Err(), lastErr)
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+	return nil, fmt.Errorf("failed to connect to database after %d attempts: %w", attempts, lastErr)
+}
+
+// Ping checks that the database answers within the deadline of ctx
+func Ping(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// Close releases the connection pool
+func Close(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
@@ -0,0 +1,25 @@
+// encryption/encryptiontest/encryptiontest.go
+package encryptiontest
+
+import (
+	"testing"
+
+	"github.com/ABDULS21985/test-portal/encryption"
+)
+
+// Key is the version 1 data key of the keyring Use installs
+const Key = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+// Use makes a test keyring active, as main does at startup, until the test ends. The encrypted
+// serializer and BlindIndex read the active keyring, so tests storing users' PII call it first.
+func Use(t testing.TB) *encryption.Keyring {
+	t.Helper()
+	keyring, err := encryption.NewKeyring(1, map[int]string{1: Key}, []byte("blind-index"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := encryption.Active()
+	encryption.Use(keyring)
+	t.Cleanup(func() { encryption.Use(previous) })
+	return keyring
+}
@@ -0,0 +1,151 @@
+// encryption/keyring.go
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Ciphertexts are stored as "v<version>:<base64(nonce || sealed)>" so that a value says which key
+// it was written with and old keys can be retired by re-encrypting.
+
+// ErrNoKeyring is returned when encrypted columns are used before Use was called
+var ErrNoKeyring = errors.New("encryption: no keyring configured")
+
+// Keyring holds the data keys by version. New values are always sealed with the current version;
+// older versions are only used to decrypt until the re-encrypt job has rewritten everything.
+type Keyring struct {
+	current    int
+	keys       map[int]cipher.AEAD
+	blindIndex []byte
+}
+
+// NewKeyring creates a new instance of Keyring. keys maps versions to hex encoded 32 byte keys
+// and must contain current. blindIndexKey is kept separate from the data keys so search hashes
+// survive key rotation.
+func NewKeyring(current int, keys map[int]string, blindIndexKey []byte) (*Keyring, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("encryption: no key for current version %d", current)
+	}
+	if len(blindIndexKey) == 0 {
+		return nil, errors.New("encryption: blind index key is required")
+	}
+
+	k := &Keyring{current: current, keys: make(map[int]cipher.AEAD, len(keys)), blindIndex: blindIndexKey}
+	for version, encoded := range keys {
+		raw, err := hex.DecodeString(strings.TrimSpace(encoded))
+		if err != nil || len(raw) != 32 {
+			return nil, fmt.Errorf("encryption: key version %d must be 32 bytes, hex encoded", version)
+		}
+		block, err := aes.NewCipher(raw)
+		if err != nil {
+			return nil, err
+		}
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[version] = gcm
+	}
+	return k, nil
+}
+
+// CurrentVersion returns the key version new values are encrypted with
+func (k *Keyring) CurrentVersion() int {
+	return k.current
+}
+
+// Encrypt seals plaintext with the current key. context (the column name) is bound in as
+// additional data so a ciphertext cannot be copied into another column.
+func (k *Keyring) Encrypt(plaintext []byte, context string) (string, error) {
+	gcm := k.keys[k.current]
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, plaintext, []byte(context))
+	return fmt.Sprintf("v%d:%s", k.current, base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// Decrypt opens a value produced by Encrypt with whichever key version it names
+func (k *Keyring) Decrypt(ciphertext, context string) ([]byte, error) {
+	version, payload, err := parseCiphertext(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	gcm, ok := k.keys[version]
+	if !ok {
+		return nil, fmt.Errorf("encryption: unknown key version %d", version)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("encryption: malformed ciphertext")
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(context))
+	if err != nil {
+		return nil, errors.New("encryption: ciphertext could not be authenticated")
+	}
+	return plaintext, nil
+}
+
+// NeedsRotation reports whether ciphertext was written with an older key version
+func (k *Keyring) NeedsRotation(ciphertext string) bool {
+	version, _, err := parseCiphertext(ciphertext)
+	return err == nil && version != k.current
+}
+
+// BlindIndex returns a deterministic keyed hash of value for equality lookups on an encrypted
+// column. Values are trimmed and lower-cased first, so lookups are case-insensitive.
+func (k *Keyring) BlindIndex(value string) string {
+	mac := hmac.New(sha256.New, k.blindIndex)
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(value))))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func parseCiphertext(ciphertext string) (int, string, error) {
+	prefix, payload, ok := strings.Cut(ciphertext, ":")
+	if !ok || !strings.HasPrefix(prefix, "v") {
+		return 0, "", errors.New("encryption: value is not encrypted")
+	}
+	version, err := strconv.Atoi(prefix[1:])
+	if err != nil {
+		return 0, "", errors.New("encryption: value is not encrypted")
+	}
+	return version, payload, nil
+}
+
+var active atomic.Pointer[Keyring]
+
+// Use makes k the keyring used by the "encrypted" GORM serializer and BlindIndex
+func Use(k *Keyring) {
+	active.Store(k)
+}
+
+// Active returns the keyring set with Use, or nil
+func Active() *Keyring {
+	return active.Load()
+}
+
+// BlindIndex hashes value with the active keyring. It returns "" for empty values, so that
+// optional columns don't all share one index entry.
+func BlindIndex(value string) (string, error) {
+	if strings.TrimSpace(value) == "" {
+		return "", nil
+	}
+	k := Active()
+	if k == nil {
+		return "", ErrNoKeyring
+	}
+	return k.BlindIndex(value), nil
+}
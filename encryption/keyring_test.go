@@ -0,0 +1,172 @@
+// encryption/keyring_test.go
+package encryption
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	keyV1 = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+	keyV2 = "1f1e1d1c1b1a191817161514131211100f0e0d0c0b0a09080706050403020100"
+)
+
+func newTestKeyring(t *testing.T, current int, keys map[int]string) *Keyring {
+	t.Helper()
+	k, err := NewKeyring(current, keys, []byte("blind-index"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestNewKeyringErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		current    int
+		keys       map[int]string
+		blindIndex string
+	}{
+		{name: "no current key", current: 2, keys: map[int]string{1: keyV1}, blindIndex: "b"},
+		{name: "short key", current: 1, keys: map[int]string{1: keyV1[:32]}, blindIndex: "b"},
+		{name: "not hex", current: 1, keys: map[int]string{1: strings.Repeat("zz", 32)}, blindIndex: "b"},
+		{name: "bad previous key", current: 2, keys: map[int]string{1: "nope", 2: keyV2}, blindIndex: "b"},
+		{name: "no blind index key", current: 1, keys: map[int]string{1: keyV1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewKeyring(tt.current, tt.keys, []byte(tt.blindIndex)); err == nil {
+				t.Fatal("want an error")
+			}
+		})
+	}
+}
+
+func TestKeyringRoundTrip(t *testing.T) {
+	k := newTestKeyring(t, 1, map[int]string{1: keyV1})
+	for _, plaintext := range []string{"", "+44 20 7946 0958", strings.Repeat("x", 4096)} {
+		ciphertext, err := k.Encrypt([]byte(plaintext), "phone")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(ciphertext, "v1:") {
+			t.Fatalf("ciphertext %q does not name its key version", ciphertext)
+		}
+		got, err := k.Decrypt(ciphertext, "phone")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != plaintext {
+			t.Fatalf("Decrypt = %q, want %q", got, plaintext)
+		}
+	}
+
+	a, _ := k.Encrypt([]byte("same"), "phone")
+	b, _ := k.Encrypt([]byte("same"), "phone")
+	if a == b {
+		t.Fatal("equal plaintexts produced equal ciphertexts")
+	}
+}
+
+func TestKeyringDecryptErrors(t *testing.T) {
+	k := newTestKeyring(t, 1, map[int]string{1: keyV1})
+	ciphertext, err := k.Encrypt([]byte("+44 20 7946 0958"), "phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := strings.TrimPrefix(ciphertext, "v1:")
+
+	tests := []struct {
+		name       string
+		ciphertext string
+		context    string
+		want       string
+	}{
+		{name: "other column", ciphertext: ciphertext, context: "date_of_birth", want: "could not be authenticated"},
+		{name: "unknown version", ciphertext: "v7:" + payload, context: "phone", want: "unknown key version 7"},
+		{name: "tampered", ciphertext: "v1:AAAA" + payload[4:], context: "phone", want: "could not be authenticated"},
+		{name: "plaintext", ciphertext: "+44 20 7946 0958", context: "phone", want: "not encrypted"},
+		{name: "bad version", ciphertext: "vX:" + payload, context: "phone", want: "not encrypted"},
+		{name: "not base64", ciphertext: "v1:***", context: "phone", want: "malformed"},
+		{name: "truncated", ciphertext: "v1:AAAA", context: "phone", want: "malformed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := k.Decrypt(tt.ciphertext, tt.context)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	old := newTestKeyring(t, 1, map[int]string{1: keyV1})
+	ciphertext, err := old.Encrypt([]byte("1990-01-02"), "date_of_birth")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := newTestKeyring(t, 2, map[int]string{1: keyV1, 2: keyV2})
+	if !rotated.NeedsRotation(ciphertext) {
+		t.Fatal("a v1 value does not need rotation under a v2 keyring")
+	}
+	if got, err := rotated.Decrypt(ciphertext, "date_of_birth"); err != nil || string(got) != "1990-01-02" {
+		t.Fatalf("Decrypt = %q, %v", got, err)
+	}
+
+	fresh, err := rotated.Encrypt([]byte("1990-01-02"), "date_of_birth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(fresh, "v2:") || rotated.NeedsRotation(fresh) {
+		t.Fatalf("new values are not written with the current key: %q", fresh)
+	}
+	if rotated.NeedsRotation("plaintext") {
+		t.Fatal("a plaintext value needs rotation")
+	}
+
+	// Once the old key is retired, values the re-encrypt job missed can't be read
+	retired := newTestKeyring(t, 2, map[int]string{2: keyV2})
+	if _, err := retired.Decrypt(ciphertext, "date_of_birth"); err == nil {
+		t.Fatal("a value sealed with a retired key was decrypted")
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	k := newTestKeyring(t, 1, map[int]string{1: keyV1})
+	if k.BlindIndex("+44 20 7946 0958") != k.BlindIndex("  +44 20 7946 0958 ") {
+		t.Fatal("surrounding whitespace changes the blind index")
+	}
+	if k.BlindIndex("Ada") != k.BlindIndex("ada") {
+		t.Fatal("case changes the blind index")
+	}
+	if k.BlindIndex("+44 20 7946 0958") == k.BlindIndex("+44 20 7946 0959") {
+		t.Fatal("different values share a blind index")
+	}
+
+	// The blind index key is independent of the data keys, so rotation keeps lookups working
+	rotated := newTestKeyring(t, 2, map[int]string{1: keyV1, 2: keyV2})
+	if k.BlindIndex("ada") != rotated.BlindIndex("ada") {
+		t.Fatal("rotating the data key changed the blind index")
+	}
+	other, err := NewKeyring(1, map[int]string{1: keyV1}, []byte("another-blind-index"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.BlindIndex("ada") == other.BlindIndex("ada") {
+		t.Fatal("the blind index does not depend on its key")
+	}
+
+	useKeyring(t, nil)
+	if _, err := BlindIndex("ada"); err != ErrNoKeyring {
+		t.Fatalf("err = %v, want ErrNoKeyring", err)
+	}
+	if index, err := BlindIndex("  "); err != nil || index != "" {
+		t.Fatalf("BlindIndex of a blank value = %q, %v; want empty", index, err)
+	}
+	Use(k)
+	if index, err := BlindIndex("ada"); err != nil || index != k.BlindIndex("ada") {
+		t.Fatalf("BlindIndex = %q, %v", index, err)
+	}
+}
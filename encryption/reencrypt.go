@@ -0,0 +1,129 @@
+// encryption/reencrypt.go
+package encryption
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Reencrypt rewrites every encrypted column of the given models that was sealed with an older key
+// version, batchSize rows at a time, and returns how many rows were updated. Once it has run,
+// the old key versions can be removed from the configuration.
+func Reencrypt(ctx context.Context, db *gorm.DB, k *Keyring, batchSize int, models ...interface{}) (int, error) {
+	if batchSize < 1 {
+		batchSize = 500
+	}
+
+	updated := 0
+	for _, model := range models {
+		s, err := schema.Parse(model, &sync.Map{}, db.NamingStrategy)
+		if err != nil {
+			return updated, err
+		}
+		if s.PrioritizedPrimaryField == nil {
+			return updated, fmt.Errorf("encryption: %s has no primary key", s.Table)
+		}
+
+		var columns []string
+		for _, field := range s.Fields {
+			if strings.EqualFold(field.TagSettings["SERIALIZER"], SerializerName) {
+				columns = append(columns, field.DBName)
+			}
+		}
+		if len(columns) == 0 {
+			continue
+		}
+
+		n, err := reencryptTable(ctx, db, k, batchSize, s.Table, s.PrioritizedPrimaryField.DBName, columns)
+		updated += n
+		if err != nil {
+			return updated, fmt.Errorf("encryption: re-encrypting %s: %w", s.Table, err)
+		}
+	}
+	return updated, nil
+}
+
+func reencryptTable(ctx context.Context, db *gorm.DB, k *Keyring, batchSize int, table, pk string, columns []string) (int, error) {
+	updated := 0
+	var last interface{}
+	for {
+		query := db.WithContext(ctx).Table(table).Select(append([]string{pk}, columns...)).Order(pk).Limit(batchSize)
+		if last != nil {
+			query = query.Where(pk+" > ?", last)
+		}
+		var rows []map[string]interface{}
+		if err := query.Find(&rows).Error; err != nil {
+			return updated, err
+		}
+		if len(rows) == 0 {
+			return updated, nil
+		}
+
+		for _, row := range rows {
+			last = row[pk]
+			rotated, err := reencryptRow(ctx, db, k, table, pk, last, row, columns)
+			if err != nil {
+				return updated, err
+			}
+			if rotated {
+				updated++
+			}
+		}
+	}
+}
+
+// maxRowAttempts bounds how often a row that keeps changing underneath reencryptRow is retried
+const maxRowAttempts = 5
+
+// reencryptRow rewrites the columns of one row that were sealed with an older key version. The
+// update only matches while the columns still hold the ciphertexts that were read, so a
+// concurrent write is never overwritten with stale data; the row is read again and retried.
+func reencryptRow(ctx context.Context, db *gorm.DB, k *Keyring, table, pk string, id interface{}, row map[string]interface{}, columns []string) (bool, error) {
+	for attempt := 1; ; attempt++ {
+		changes := make(map[string]interface{})
+		update := db.WithContext(ctx).Table(table).Where(pk+" = ?", id)
+		for _, column := range columns {
+			ciphertext, _ := row[column].(string)
+			if ciphertext == "" || !k.NeedsRotation(ciphertext) {
+				continue
+			}
+			plaintext, err := k.Decrypt(ciphertext, column)
+			if err != nil {
+				return false, fmt.Errorf("%s %v: %w", column, id, err)
+			}
+			if changes[column], err = k.Encrypt(plaintext, column); err != nil {
+				return false, err
+			}
+			update = update.Where(column+" = ?", ciphertext)
+		}
+		if len(changes) == 0 {
+			return false, nil
+		}
+
+		result := update.UpdateColumns(changes)
+		if result.Error != nil {
+			return false, result.Error
+		}
+		if result.RowsAffected > 0 {
+			return true, nil
+		}
+		if attempt == maxRowAttempts {
+			return false, fmt.Errorf("%s %v kept changing while it was re-encrypted", pk, id)
+		}
+
+		var fresh []map[string]interface{}
+		if err := db.WithContext(ctx).Table(table).Select(columns).Where(pk+" = ?", id).Limit(1).Find(&fresh).Error; err != nil {
+			return false, err
+		}
+		if len(fresh) == 0 {
+			// Deleted in the meantime
+			return false, nil
+		}
+		row = fresh[0]
+	}
+}
@@ -0,0 +1,217 @@
+// encryption/reencrypt_test.go
+package encryption
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type secretRow struct {
+	ID    int    `gorm:"primaryKey"`
+	Phone string `gorm:"type:text;serializer:encrypted"`
+	Note  string `gorm:"type:text;serializer:encrypted"`
+	Label string
+}
+
+// nopConnPool satisfies gorm.ConnPool for a dry-run session, which never touches it
+type nopConnPool struct{}
+
+var errNoDatabase = errors.New("no database")
+
+func (nopConnPool) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	return nil, errNoDatabase
+}
+
+func (nopConnPool) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	return nil, errNoDatabase
+}
+
+func (nopConnPool) QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error) {
+	return nil, errNoDatabase
+}
+
+func (nopConnPool) QueryRowContext(context.Context, string, ...interface{}) *sql.Row {
+	return nil
+}
+
+func TestReencryptModels(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: nopConnPool{}}), &gorm.Config{DryRun: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := newTestKeyring(t, 1, map[int]string{1: keyV1})
+
+	type plain struct {
+		ID   int
+		Name string
+	}
+	if n, err := Reencrypt(context.Background(), db, k, 10, &plain{}); err != nil || n != 0 {
+		t.Fatalf("a model without encrypted columns: %d, %v", n, err)
+	}
+
+	type keyless struct {
+		Phone string `gorm:"serializer:encrypted"`
+	}
+	if _, err := Reencrypt(context.Background(), db, k, 10, &keyless{}); err == nil || !strings.Contains(err.Error(), "no primary key") {
+		t.Fatalf("err = %v, want a missing primary key refused", err)
+	}
+}
+
+// openTestDB connects to TEST_DATABASE_URL and creates an empty secret_rows table, skipping
+// the test when the variable isn't set
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Migrator().DropTable(&secretRow{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&secretRow{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Migrator().DropTable(&secretRow{})
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// rawColumns returns the stored ciphertexts of a row
+func rawColumns(t *testing.T, db *gorm.DB, id int) (phone, note string) {
+	t.Helper()
+	var row struct {
+		Phone sql.NullString
+		Note  sql.NullString
+	}
+	if err := db.Table("secret_rows").Select("phone, note").Where("id = ?", id).Take(&row).Error; err != nil {
+		t.Fatal(err)
+	}
+	return row.Phone.String, row.Note.String
+}
+
+func TestReencryptPostgres(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	old := newTestKeyring(t, 1, map[int]string{1: keyV1})
+	rotated := newTestKeyring(t, 2, map[int]string{1: keyV1, 2: keyV2})
+
+	useKeyring(t, old)
+	rows := []secretRow{
+		{ID: 1, Phone: "+44 1", Note: "first", Label: "a"},
+		{ID: 2, Phone: "+44 2", Label: "no note"},
+		{ID: 3, Phone: "+44 3", Note: "third"},
+		{ID: 4, Phone: "+44 4", Note: "fourth"},
+		{ID: 5, Phone: "+44 5", Note: "fifth"},
+	}
+	if err := db.Create(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Rows are walked in batches smaller than the table
+	Use(rotated)
+	updated, err := Reencrypt(ctx, db, rotated, 2, &secretRow{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated != len(rows) {
+		t.Fatalf("updated %d rows, want %d", updated, len(rows))
+	}
+	for _, want := range rows {
+		phone, note := rawColumns(t, db, want.ID)
+		if !strings.HasPrefix(phone, "v2:") || (want.Note != "" && !strings.HasPrefix(note, "v2:")) || (want.Note == "" && note != "") {
+			t.Fatalf("row %d stored %q, %q after re-encryption", want.ID, phone, note)
+		}
+		var got secretRow
+		if err := db.First(&got, want.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("row %d = %+v, want %+v", want.ID, got, want)
+		}
+	}
+
+	// Nothing is left to do
+	if updated, err := Reencrypt(ctx, db, rotated, 2, &secretRow{}); err != nil || updated != 0 {
+		t.Fatalf("second run updated %d rows, %v", updated, err)
+	}
+}
+
+func TestReencryptConcurrentUpdate(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	old := newTestKeyring(t, 1, map[int]string{1: keyV1})
+	rotated := newTestKeyring(t, 2, map[int]string{1: keyV1, 2: keyV2})
+
+	useKeyring(t, old)
+	if err := db.Create(&secretRow{ID: 1, Phone: "+44 1", Note: "before"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// The application writes a new phone number between the job's read and its update
+	concurrent, err := rotated.Encrypt([]byte("+44 2"), "phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	interfered := false
+	err = db.Callback().Update().Before("gorm:update").Register("test:interfere", func(tx *gorm.DB) {
+		if interfered || tx.Statement.Table != "secret_rows" {
+			return
+		}
+		interfered = true
+		if err := tx.Session(&gorm.Session{NewDB: true}).Exec("UPDATE secret_rows SET phone = ? WHERE id = 1", concurrent).Error; err != nil {
+			t.Error(err)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Use(rotated)
+	updated, err := Reencrypt(ctx, db, rotated, 10, &secretRow{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !interfered || updated != 1 {
+		t.Fatalf("interfered = %v, updated = %d", interfered, updated)
+	}
+
+	var got secretRow
+	if err := db.First(&got, 1).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got.Phone != "+44 2" {
+		t.Fatalf("the concurrent write was overwritten: phone = %q", got.Phone)
+	}
+	if _, note := rawColumns(t, db, 1); !strings.HasPrefix(note, "v2:") || got.Note != "before" {
+		t.Fatalf("note = %q stored as %q, want it re-encrypted", got.Note, note)
+	}
+}
+
+func TestReencryptUnknownVersion(t *testing.T) {
+	db := openTestDB(t)
+	rotated := newTestKeyring(t, 2, map[int]string{2: keyV2})
+	useKeyring(t, rotated)
+
+	if err := db.Exec("INSERT INTO secret_rows (id, phone) VALUES (1, 'v9:AAAA')").Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Reencrypt(context.Background(), db, rotated, 10, &secretRow{}); err == nil || !strings.Contains(err.Error(), "unknown key version 9") {
+		t.Fatalf("err = %v, want the unknown key version reported", err)
+	}
+}
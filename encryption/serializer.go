@@ -0,0 +1,81 @@
+// encryption/serializer.go
+package encryption
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+// SerializerName is used in model tags: `gorm:"serializer:encrypted;type:text"`
+const SerializerName = "encrypted"
+
+func init() {
+	schema.RegisterSerializer(SerializerName, Serializer{})
+}
+
+// Serializer transparently encrypts a column with the active keyring. Strings are encrypted as
+// is, anything else as JSON. Zero values are stored as NULL rather than encrypted.
+type Serializer struct{}
+
+// Scan decrypts the column into the field
+func (Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	fieldValue := reflect.New(field.FieldType)
+
+	var ciphertext string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		ciphertext = v
+	case []byte:
+		ciphertext = string(v)
+	default:
+		return fmt.Errorf("encryption: unsupported column value %T for %s", dbValue, field.Name)
+	}
+
+	if ciphertext != "" {
+		k := Active()
+		if k == nil {
+			return ErrNoKeyring
+		}
+		plaintext, err := k.Decrypt(ciphertext, field.DBName)
+		if err != nil {
+			return fmt.Errorf("%s: %w", field.Name, err)
+		}
+		if field.FieldType.Kind() == reflect.String {
+			fieldValue.Elem().SetString(string(plaintext))
+		} else if err := json.Unmarshal(plaintext, fieldValue.Interface()); err != nil {
+			return fmt.Errorf("%s: %w", field.Name, err)
+		}
+	}
+
+	field.ReflectValueOf(ctx, dst).Set(fieldValue.Elem())
+	return nil
+}
+
+// Value encrypts the field for storage
+func (Serializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(fieldValue)
+	if !rv.IsValid() || rv.IsZero() {
+		return nil, nil
+	}
+
+	var plaintext []byte
+	if rv.Kind() == reflect.String {
+		plaintext = []byte(rv.String())
+	} else {
+		var err error
+		if plaintext, err = json.Marshal(fieldValue); err != nil {
+			return nil, err
+		}
+	}
+
+	k := Active()
+	if k == nil {
+		return nil, ErrNoKeyring
+	}
+	return k.Encrypt(plaintext, field.DBName)
+}
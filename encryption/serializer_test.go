@@ -0,0 +1,128 @@
+// encryption/serializer_test.go
+package encryption
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm/schema"
+)
+
+type record struct {
+	ID          int
+	Phone       string     `gorm:"serializer:encrypted"`
+	DateOfBirth *time.Time `gorm:"serializer:encrypted"`
+	Tags        []string   `gorm:"serializer:encrypted"`
+}
+
+// fields parses record and returns its encrypted fields by name
+func fields(t *testing.T) map[string]*schema.Field {
+	t.Helper()
+	s, err := schema.Parse(&record{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]*schema.Field{"Phone": s.FieldsByName["Phone"], "DateOfBirth": s.FieldsByName["DateOfBirth"], "Tags": s.FieldsByName["Tags"]}
+}
+
+// useKeyring makes k active until the test ends
+func useKeyring(t *testing.T, k *Keyring) {
+	t.Helper()
+	previous := Active()
+	Use(k)
+	t.Cleanup(func() { Use(previous) })
+}
+
+func TestSerializerRoundTrip(t *testing.T) {
+	useKeyring(t, newTestKeyring(t, 1, map[int]string{1: keyV1}))
+	ctx := context.Background()
+	born := time.Date(1990, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		field string
+		value interface{}
+	}{
+		{name: "string", field: "Phone", value: "+44 20 7946 0958"},
+		{name: "time as JSON", field: "DateOfBirth", value: &born},
+		{name: "slice as JSON", field: "Tags", value: []string{"vip", "beta"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			field := fields(t)[tt.field]
+			stored, err := Serializer{}.Value(ctx, field, reflect.Value{}, tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ciphertext, ok := stored.(string)
+			if !ok || !strings.HasPrefix(ciphertext, "v1:") {
+				t.Fatalf("stored %#v, want a v1 ciphertext", stored)
+			}
+
+			for _, dbValue := range []interface{}{ciphertext, []byte(ciphertext)} {
+				var got record
+				if err := (Serializer{}).Scan(ctx, field, reflect.ValueOf(&got), dbValue); err != nil {
+					t.Fatal(err)
+				}
+				if v := reflect.ValueOf(got).FieldByName(tt.field).Interface(); !reflect.DeepEqual(v, tt.value) {
+					t.Fatalf("scanned %#v, want %#v", v, tt.value)
+				}
+			}
+		})
+	}
+}
+
+func TestSerializerZeroValues(t *testing.T) {
+	useKeyring(t, newTestKeyring(t, 1, map[int]string{1: keyV1}))
+	ctx := context.Background()
+	f := fields(t)
+
+	for name, value := range map[string]interface{}{"Phone": "", "DateOfBirth": (*time.Time)(nil), "Tags": []string(nil)} {
+		if stored, err := (Serializer{}).Value(ctx, f[name], reflect.Value{}, value); err != nil || stored != nil {
+			t.Fatalf("%s: stored %#v, %v; want NULL", name, stored, err)
+		}
+	}
+
+	got := record{Phone: "stale"}
+	if err := (Serializer{}).Scan(ctx, f["Phone"], reflect.ValueOf(&got), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got.Phone != "" {
+		t.Fatalf("scanning NULL left %q", got.Phone)
+	}
+}
+
+func TestSerializerErrors(t *testing.T) {
+	ctx := context.Background()
+	f := fields(t)
+	k := newTestKeyring(t, 1, map[int]string{1: keyV1})
+	useKeyring(t, k)
+	phone, err := (Serializer{}).Value(ctx, f["Phone"], reflect.Value{}, "+44 20 7946 0958")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A ciphertext copied into another column doesn't decrypt there
+	var got record
+	if err := (Serializer{}).Scan(ctx, f["Tags"], reflect.ValueOf(&got), phone); err == nil {
+		t.Fatal("a phone ciphertext decrypted as tags")
+	}
+	if err := (Serializer{}).Scan(ctx, f["Phone"], reflect.ValueOf(&got), 42); err == nil {
+		t.Fatal("an integer column value was accepted")
+	}
+	if err := (Serializer{}).Scan(ctx, f["Phone"], reflect.ValueOf(&got), "+44 20 7946 0958"); err == nil {
+		t.Fatal("a plaintext column value was accepted")
+	}
+
+	Use(nil)
+	if _, err := (Serializer{}).Value(ctx, f["Phone"], reflect.Value{}, "+44 20 7946 0958"); err != ErrNoKeyring {
+		t.Fatalf("Value err = %v, want ErrNoKeyring", err)
+	}
+	if err := (Serializer{}).Scan(ctx, f["Phone"], reflect.ValueOf(&got), phone); err != ErrNoKeyring {
+		t.Fatalf("Scan err = %v, want ErrNoKeyring", err)
+	}
+}
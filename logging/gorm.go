@@ -0,0 +1,88 @@
+// logging/gorm.go
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// GormLogger sends GORM's logging through slog. Failed queries are errors, queries slower than
+// the threshold are warnings and everything else is debug. Bind parameters are never logged,
+// since they routinely carry passwords, tokens and personal data.
+type GormLogger struct {
+	logger        *slog.Logger
+	slowThreshold time.Duration
+	level         gormlogger.LogLevel
+}
+
+// NewGormLogger creates a new instance of GormLogger
+func NewGormLogger(logger *slog.Logger, slowThreshold time.Duration) *GormLogger {
+	return &GormLogger{
+		logger:        logger.With(slog.String("component", "gorm")),
+		slowThreshold: slowThreshold,
+		level:         gormlogger.Info,
+	}
+}
+
+// LogMode implements gormlogger.Interface
+func (l *GormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	clone := *l
+	clone.level = level
+	return &clone
+}
+
+// Info implements gormlogger.Interface
+func (l *GormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Info {
+		l.logger.InfoContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+// Warn implements gormlogger.Interface
+func (l *GormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		l.logger.WarnContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+// Error implements gormlogger.Interface
+func (l *GormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Error {
+		l.logger.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+// Trace implements gormlogger.Interface
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	sql, rows := fc()
+	attrs := []slog.Attr{
+		slog.String("sql", sql),
+		slog.Int64("rows", rows),
+		slog.Duration("elapsed", elapsed),
+	}
+
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= gormlogger.Error:
+		l.logger.LogAttrs(ctx, slog.LevelError, "query failed", append(attrs, slog.Any("error", err))...)
+	case l.slowThreshold > 0 && elapsed > l.slowThreshold && l.level >= gormlogger.Warn:
+		l.logger.LogAttrs(ctx, slog.LevelWarn, "slow query", append(attrs, slog.Duration("threshold", l.slowThreshold))...)
+	case l.level >= gormlogger.Info:
+		l.logger.LogAttrs(ctx, slog.LevelDebug, "query", attrs...)
+	}
+}
+
+// ParamsFilter keeps bind parameters out of the logged SQL
+func (l *GormLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	return sql, nil
+}
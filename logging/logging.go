@@ -0,0 +1,92 @@
+// logging/logging.go
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Options configures the application logger
+type Options struct {
+	Level  string // debug, info, warn or error
+	Format string // json or text
+}
+
+// New creates the application logger. Every record passes through redaction and picks up the
+// request-scoped attributes stored in its context.
+func New(w io.Writer, opts Options) (*slog.Logger, error) {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+	var handler slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "", "json":
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	case "text":
+		handler = slog.NewTextHandler(w, handlerOpts)
+	default:
+		return nil, fmt.Errorf("logging: unknown format %q", opts.Format)
+	}
+	return slog.New(&contextHandler{Handler: handler}), nil
+}
+
+// ParseLevel converts a LOG_LEVEL value into a slog.Level
+func ParseLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return 0, fmt.Errorf("logging: unknown level %q", level)
+	}
+	return l, nil
+}
+
+// Discard returns a logger that drops everything, for constructors used outside the server
+func Discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// scope holds the attributes collected for one request. It is shared by pointer so that
+// handlers further down the chain (e.g. authentication adding the user ID) can contribute.
+type scope struct {
+	attrs []slog.Attr
+}
+
+type scopeKey struct{}
+
+// WithScope returns a context that collects request-scoped attributes
+func WithScope(ctx context.Context, attrs ...slog.Attr) context.Context {
+	return context.WithValue(ctx, scopeKey{}, &scope{attrs: attrs})
+}
+
+// AddAttrs adds attributes to the request scope of ctx, if there is one. Callers run on the
+// request goroutine, so no locking is needed.
+func AddAttrs(ctx context.Context, attrs ...slog.Attr) {
+	if s, ok := ctx.Value(scopeKey{}).(*scope); ok {
+		s.attrs = append(s.attrs, attrs...)
+	}
+}
+
+// contextHandler adds the request-scoped attributes to records logged with a context
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if s, ok := ctx.Value(scopeKey{}).(*scope); ok {
+		record.AddAttrs(s.attrs...)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
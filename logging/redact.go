@@ -0,0 +1,52 @@
+// logging/redact.go
+package logging
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute names (or parts of names) whose values are never logged
+var sensitiveKeys = []string{
+	"password", "passwd", "secret", "token", "authorization", "cookie", "api_key", "apikey",
+	"private_key", "credential", "session_data", "otp",
+}
+
+var emailPattern = regexp.MustCompile(`([A-Za-z0-9._%+\-])[A-Za-z0-9._%+\-]*@([A-Za-z0-9.\-]+\.[A-Za-z]{2,})`)
+
+// redact is the slog ReplaceAttr hook: secrets are dropped by key, and e-mail addresses are
+// masked wherever they appear in string values (j***@example.com)
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	if len(groups) == 0 && (attr.Key == slog.TimeKey || attr.Key == slog.LevelKey) {
+		return attr
+	}
+
+	key := strings.ToLower(attr.Key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return slog.String(attr.Key, redacted)
+		}
+	}
+
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, MaskEmails(value.String()))
+	case slog.KindAny:
+		if err, ok := value.Any().(error); ok {
+			return slog.String(attr.Key, MaskEmails(err.Error()))
+		}
+	}
+	return attr
+}
+
+// MaskEmails replaces the local part of every e-mail address in s but its first character
+func MaskEmails(s string) string {
+	if !strings.Contains(s, "@") {
+		return s
+	}
+	return emailPattern.ReplaceAllString(s, "$1***@$2")
+}
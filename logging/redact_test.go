@@ -0,0 +1,129 @@
+// logging/redact_test.go
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestMaskEmails(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "no address here", want: "no address here"},
+		{in: "ada@example.com", want: "a***@example.com"},
+		{in: "a@example.com", want: "a***@example.com"},
+		{in: "login failed for ada.lovelace+work@mail.example.co.uk", want: "login failed for a***@mail.example.co.uk"},
+		{in: "ada@example.com, bob@example.org", want: "a***@example.com, b***@example.org"},
+		{in: `user "ada@example.com" not found`, want: `user "a***@example.com" not found`},
+		{in: "ssh git@host", want: "ssh git@host"},
+		{in: "@example.com", want: "@example.com"},
+	}
+	for _, tt := range tests {
+		if got := MaskEmails(tt.in); got != tt.want {
+			t.Errorf("MaskEmails(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// logLine logs one record through a JSON logger from New and returns it decoded
+func logLine(t *testing.T, log func(logger *slog.Logger)) map[string]any {
+	t.Helper()
+	var buf bytes.Buffer
+	logger, err := New(&buf, Options{Level: "debug", Format: "json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	log(logger)
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("%s: %v", buf.Bytes(), err)
+	}
+	return line
+}
+
+func TestRedact(t *testing.T) {
+	line := logLine(t, func(logger *slog.Logger) {
+		logger.Info("password reset requested by ada@example.com",
+			slog.String("password", "hunter2"),
+			slog.String("new_Password", "hunter3"),
+			slog.String("Authorization", "Bearer eyJhbGciOi"),
+			slog.String("refresh_token", "r-123"),
+			slog.Any("session_data", map[string]string{"challenge": "c"}),
+			slog.Int("otp", 123456),
+			slog.Group("request", slog.String("cookie", "sid=1"), slog.String("path", "/login")),
+			slog.String("email", "ada@example.com"),
+			slog.Any("error", errors.New(`user "bob@example.org" is disabled`)),
+			slog.Int("status", 401),
+			slog.Bool("mfa", true),
+		)
+	})
+
+	want := map[string]any{
+		"msg":           "password reset requested by a***@example.com",
+		"password":      redacted,
+		"new_Password":  redacted,
+		"Authorization": redacted,
+		"refresh_token": redacted,
+		"session_data":  redacted,
+		"otp":           redacted,
+		"request":       map[string]any{"cookie": redacted, "path": "/login"},
+		"email":         "a***@example.com",
+		"error":         `user "b***@example.org" is disabled`,
+		"status":        float64(401),
+		"mfa":           true,
+		"level":         "INFO",
+	}
+	delete(line, "time")
+	if !reflect.DeepEqual(line, want) {
+		t.Fatalf("logged %v\nwant   %v", line, want)
+	}
+}
+
+func TestRequestScope(t *testing.T) {
+	ctx := WithScope(context.Background(), slog.String("request_id", "req-1"))
+	AddAttrs(ctx, slog.String("user_id", "u-1"), slog.String("api_token", "t-1"))
+	// Without a scope it is a no-op
+	AddAttrs(context.Background(), slog.String("ignored", "x"))
+
+	line := logLine(t, func(logger *slog.Logger) {
+		logger.With(slog.String("component", "auth")).InfoContext(ctx, "login")
+	})
+	for key, want := range map[string]any{"request_id": "req-1", "user_id": "u-1", "api_token": redacted, "component": "auth"} {
+		if line[key] != want {
+			t.Errorf("%s = %v, want %v", key, line[key], want)
+		}
+	}
+}
+
+func TestNewOptions(t *testing.T) {
+	tests := []struct {
+		opts    Options
+		wantErr bool
+	}{
+		{opts: Options{Level: "info"}},
+		{opts: Options{Level: "DEBUG", Format: "text"}},
+		{opts: Options{Level: "warn", Format: "JSON"}},
+		{opts: Options{Level: "verbose"}, wantErr: true},
+		{opts: Options{Level: "info", Format: "xml"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		_, err := New(&bytes.Buffer{}, tt.opts)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("New(%+v) err = %v, want error %v", tt.opts, err, tt.wantErr)
+		}
+	}
+
+	var buf bytes.Buffer
+	logger, _ := New(&buf, Options{Level: "warn"})
+	logger.Info("dropped")
+	if buf.Len() != 0 {
+		t.Fatalf("an info line got past a warn logger: %s", buf.Bytes())
+	}
+}
@@ -1,49 +1,456 @@
 package main
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
-	"net/http"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/ABDULS21985/test-portal/app"
 	"github.com/ABDULS21985/test-portal/config"
-	"github.com/ABDULS21985/test-portal/controllers"
-	"github.com/ABDULS21985/test-portal/middleware"
+	"github.com/ABDULS21985/test-portal/database"
+	"github.com/ABDULS21985/test-portal/encryption"
+	"github.com/ABDULS21985/test-portal/logging"
+	"github.com/ABDULS21985/test-portal/metrics"
 	"github.com/ABDULS21985/test-portal/migrations"
-	"github.com/ABDULS21985/test-portal/repositories"
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories/memory"
 	"github.com/ABDULS21985/test-portal/routes"
-	"github.com/ABDULS21985/test-portal/services"
-
-	"github.com/gorilla/mux"
+	"github.com/ABDULS21985/test-portal/server"
+	"github.com/ABDULS21985/test-portal/tracing"
 )
 
+// migrationsDir is where "migrate create" writes new migration files
+const migrationsDir = "migrations/sql"
+
 func main() {
+	// Secrets tooling runs before the configuration is loaded, since it helps produce it
+	if len(os.Args) > 1 && os.Args[1] == "secrets" {
+		if err := runSecrets(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Load configurations
-	cfg := config.LoadConfig()
-
-	// Run migrations (optional)
-	migrations.RunMigrations(cfg.DB)
-
-	// Initialize repositories
-	userRepo := repositories.NewUserRepository(cfg.DB)
-	passwordResetRepo := repositories.NewPasswordResetRepository(cfg.DB)
-
-	// Initialize services
-	userService := services.NewUserService(userRepo)
-	authService := services.NewAuthService(userRepo, []byte(cfg.JWTSecret))
-	passwordResetService := services.NewPasswordResetService(userRepo, passwordResetRepo)
-
-	// Initialize controllers
-	userController := controllers.NewUserController(userService)
-	authController := controllers.NewAuthController(authService)
-	passwordResetController := controllers.NewPasswordResetController(passwordResetService)
-
-	// Setup router
-	router := mux.NewRouter()
-	authMiddleware := middleware.NewAuthMiddleware(authService)
-	routes.SetupRoutes(router, authController, userController, passwordResetController, authMiddleware)
-
-	// Start server
-	log.Printf("Server running on port %s", cfg.Port)
-	if err := http.ListenAndServe(":"+cfg.Port, router); err != nil {
-		log.Fatalf("Could not start server: %v", err)
+	cfg, args, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+
+	logger, err := logging.New(os.Stderr, logging.Options{Level: cfg.LogLevel, Format: cfg.LogFormat})
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Anything still using the log package ends up in the same stream
+	slog.SetDefault(logger)
+
+	keyring, err := newKeyring(cfg, logger)
+	if err != nil {
+		fatal(logger, "invalid encryption configuration", err)
+	}
+	encryption.Use(keyring)
+
+	if len(args) > 0 {
+		if err := runCommand(cfg, args, logger); err != nil {
+			fatal(logger, "command failed", err, slog.String("command", strings.Join(args, " ")))
+		}
+		return
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Endpoint:    cfg.OTelEndpoint,
+		ServiceName: cfg.OTelServiceName,
+		SampleRatio: cfg.OTelSampleRatio,
+	})
+	if err != nil {
+		fatal(logger, "invalid tracing configuration", err)
+	}
+
+	// Connect to the database
+	db, err := database.Connect(context.Background(), databaseOptions(cfg, logger))
+	if err != nil {
+		fatal(logger, "could not connect to database", err)
+	}
+	defer database.Close(db)
+	if err := tracing.InstrumentDB(db); err != nil {
+		fatal(logger, "could not instrument database", err)
+	}
+
+	appMetrics := metrics.New(cfg.MetricsBearerToken)
+	if err := appMetrics.InstrumentDB(db); err != nil {
+		fatal(logger, "could not instrument database", err)
+	}
+
+	application, err := app.New(cfg, app.Options{
+		DB:      db,
+		Logger:  logger,
+		Metrics: appMetrics,
+		Modules: routes.Modules(),
+	})
+	if err != nil {
+		fatal(logger, "could not build application", err)
+	}
+
+	// Run migrations
+	if cfg.MigrateOnStart {
+		if err := application.Migrate(context.Background()); err != nil {
+			fatal(logger, "database migration failed", err)
+		}
+	}
+
+	// Pick up rotated secrets without a restart
+	reloader := config.NewReloader(cfg, os.Args[1:], logger)
+	applied := cfg
+	reloader.OnChange(func(next *config.Config) {
+		if application.Services.JWTKeys.Rotate([]byte(next.JWTSecret)) {
+			logger.Info("JWT signing key rotated")
+		}
+		if application.Services.SMTP.Set(next.SMTPUser, next.SMTPPass) {
+			logger.Info("SMTP credentials updated")
+		}
+		keyring, err := rotatedKeyring(applied, next, logger)
+		switch {
+		case err != nil:
+			logger.Error("encryption key not rotated", slog.Any("error", err))
+		case keyring != nil:
+			encryption.Use(keyring)
+			applied = next
+			logger.Info("encryption key rotated", slog.Int("version", next.EncryptionKeyVersion))
+		}
+	})
+	application.Go(func(stop <-chan struct{}) { reloader.Watch(stop, cfg.ReloadInterval) })
+	application.Start()
+
+	// Start server; SIGINT or SIGTERM drains in-flight requests and stops the workers
+	srv, err := server.New(application.Handler(), serverOptions(cfg), logger)
+	if err != nil {
+		fatal(logger, "invalid server configuration", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger.Info("server running", slog.String("port", cfg.Port), slog.String("env", cfg.AppEnv))
+	if err := srv.Run(ctx); err != nil {
+		logger.Error("server stopped with error", slog.Any("error", err))
+	}
+
+	// One deadline covers draining requests, stopping the workers and flushing traces
+	logger.Info("shutting down", slog.Duration("timeout", cfg.ShutdownTimeout))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Warn("in-flight requests did not finish in time", slog.Any("error", err))
+	}
+	if err := application.Stop(shutdownCtx); err != nil {
+		logger.Warn("background workers did not stop in time", slog.Any("error", err))
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Warn("could not flush traces", slog.Any("error", err))
+	}
+	logger.Info("server stopped")
+}
+
+// fatal logs err and exits
+func fatal(logger *slog.Logger, msg string, err error, attrs ...any) {
+	logger.Error(msg, append([]any{slog.Any("error", err)}, attrs...)...)
+	os.Exit(1)
+}
+
+// runCommand executes a subcommand given after the flags instead of starting the server
+func runCommand(cfg *config.Config, args []string, logger *slog.Logger) error {
+	switch args[0] {
+	case "migrate":
+		return runMigrate(cfg, args[1:], logger)
+	case "reencrypt":
+		return runReencrypt(cfg, logger)
+	case "openapi":
+		return runOpenAPI(cfg, args[1:], logger)
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// runMigrate implements migrate up|down [steps]|status|create <name>
+func runMigrate(cfg *config.Config, args []string, logger *slog.Logger) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up|down [steps]|status|create <name>")
+	}
+
+	if args[0] == "create" {
+		if len(args) < 2 {
+			return errors.New("usage: migrate create <name>")
+		}
+		up, down, err := migrations.Create(migrationsDir, strings.Join(args[1:], " "))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created %s\nCreated %s\n", up, down)
+		return nil
+	}
+
+	ctx := context.Background()
+	db, err := database.Connect(ctx, databaseOptions(cfg, logger))
+	if err != nil {
+		return err
+	}
+	defer database.Close(db)
+
+	extra, err := app.ModuleMigrations(routes.Modules())
+	if err != nil {
+		return err
+	}
+	migrator, err := migrations.NewMigrator(db, extra...)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("Applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("Nothing to apply")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		rolledBack, err := migrator.Down(ctx, steps)
+		for _, m := range rolledBack {
+			fmt.Printf("Rolled back %04d_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			if s.ChecksumMismatch {
+				state += " (modified since applied)"
+			}
+			fmt.Printf("%04d_%-40s %s\n", s.Version, s.Name, state)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
+
+// runSecrets implements secrets keygen|encrypt <NAME>. encrypt reads the plaintext from stdin
+// and prints a NAME=ENC[...] line for the secrets file.
+func runSecrets(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: secrets keygen|encrypt <NAME>")
+	}
+
+	switch args[0] {
+	case "keygen":
+		key, err := config.GenerateMasterKey()
+		if err != nil {
+			return err
+		}
+		fmt.Println(key)
+		return nil
+	case "encrypt":
+		if len(args) < 2 {
+			return errors.New("usage: secrets encrypt <NAME> < plaintext")
+		}
+		key, err := config.ParseMasterKey(os.Getenv("SECRETS_MASTER_KEY"))
+		if err != nil {
+			return fmt.Errorf("SECRETS_MASTER_KEY: %w", err)
+		}
+		plaintext, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		value, err := config.EncryptValue(key, args[1], strings.TrimRight(string(plaintext), "\r\n"))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s=%s\n", args[1], value)
+		return nil
+	default:
+		return fmt.Errorf("unknown secrets command %q", args[0])
+	}
+}
+
+// runOpenAPI implements openapi [check]: it prints the OpenAPI document, or with check fails when
+// a registered route is missing from it. The application is built on in-memory repositories, so
+// no database is needed.
+func runOpenAPI(cfg *config.Config, args []string, logger *slog.Logger) error {
+	if len(args) > 1 || (len(args) == 1 && args[0] != "check") {
+		return errors.New("usage: openapi [check]")
+	}
+
+	store := memory.NewStore()
+	repos := store.Repositories()
+	application, err := app.New(cfg, app.Options{
+		Logger:       logger,
+		Repositories: &repos,
+		TxManager:    memory.NewTxManager(store),
+		Modules:      routes.Modules(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(application.OpenAPI)
+	}
+	undocumented, err := application.UndocumentedRoutes()
+	if err != nil {
+		return err
+	}
+	if len(undocumented) > 0 {
+		return fmt.Errorf("routes missing from the OpenAPI document:\n  %s", strings.Join(undocumented, "\n  "))
+	}
+	fmt.Println("Every route is documented")
+	return nil
+}
+
+// runReencrypt rewrites encrypted columns still sealed with a previous key version
+func runReencrypt(cfg *config.Config, logger *slog.Logger) error {
+	ctx := context.Background()
+	db, err := database.Connect(ctx, databaseOptions(cfg, logger))
+	if err != nil {
+		return err
+	}
+	defer database.Close(db)
+
+	updated, err := encryption.Reencrypt(ctx, db, encryption.Active(), 500, &models.User{})
+	fmt.Printf("Re-encrypted %d rows with key version %d\n", updated, cfg.EncryptionKeyVersion)
+	return err
+}
+
+// newKeyring builds the field encryption keyring from the ENCRYPTION_* settings
+func newKeyring(cfg *config.Config, logger *slog.Logger) (*encryption.Keyring, error) {
+	keys, err := encryptionKeys(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return encryption.NewKeyring(cfg.EncryptionKeyVersion, keys, blindIndexKey(cfg, logger))
+}
+
+// rotatedKeyring builds the keyring for reloaded ENCRYPTION_* settings, or returns nil if they
+// didn't change. Keys are only added at run time: every key in use stays in the keyring so rows
+// it encrypted remain readable, and a changed key needs a new version.
+func rotatedKeyring(applied, next *config.Config, logger *slog.Logger) (*encryption.Keyring, error) {
+	if next.EncryptionKey == applied.EncryptionKey &&
+		next.EncryptionKeyVersion == applied.EncryptionKeyVersion &&
+		maps.Equal(next.EncryptionPreviousKeys, applied.EncryptionPreviousKeys) &&
+		next.BlindIndexKey == applied.BlindIndexKey {
+		return nil, nil
+	}
+	if next.BlindIndexKey != applied.BlindIndexKey {
+		return nil, errors.New("BLIND_INDEX_KEY can't change while running")
+	}
+
+	keys, err := encryptionKeys(next)
+	if err != nil {
+		return nil, err
+	}
+	inUse, err := encryptionKeys(applied)
+	if err != nil {
+		return nil, err
+	}
+	for version, key := range inUse {
+		if existing, ok := keys[version]; !ok {
+			keys[version] = key
+		} else if existing != key {
+			return nil, fmt.Errorf("key version %d changed; a new key needs a new ENCRYPTION_KEY_VERSION", version)
+		}
+	}
+	// Keep the blind index the running keyring uses, even when it was derived from the old key
+	return encryption.NewKeyring(next.EncryptionKeyVersion, keys, blindIndexKey(applied, logger))
+}
+
+// encryptionKeys maps ENCRYPTION_KEY and ENCRYPTION_PREVIOUS_KEYS by version
+func encryptionKeys(cfg *config.Config) (map[int]string, error) {
+	keys := map[int]string{cfg.EncryptionKeyVersion: cfg.EncryptionKey}
+	for version, key := range cfg.EncryptionPreviousKeys {
+		v, err := strconv.Atoi(strings.TrimPrefix(version, "v"))
+		if err != nil || v < 1 {
+			return nil, fmt.Errorf("ENCRYPTION_PREVIOUS_KEYS: invalid version %q", version)
+		}
+		if v == cfg.EncryptionKeyVersion {
+			return nil, fmt.Errorf("ENCRYPTION_PREVIOUS_KEYS: version %d is the current version", v)
+		}
+		keys[v] = key
+	}
+	return keys, nil
+}
+
+// blindIndexKey returns BLIND_INDEX_KEY, or outside production a key derived from ENCRYPTION_KEY
+func blindIndexKey(cfg *config.Config, logger *slog.Logger) []byte {
+	if cfg.BlindIndexKey != "" {
+		return []byte(cfg.BlindIndexKey)
+	}
+	// Only reachable outside production, where BLIND_INDEX_KEY is mandatory
+	logger.Warn("BLIND_INDEX_KEY is not set; deriving it from ENCRYPTION_KEY, which breaks phone lookups after a key rotation")
+	mac := hmac.New(sha256.New, []byte(cfg.EncryptionKey))
+	mac.Write([]byte("blind-index"))
+	return mac.Sum(nil)
+}
+
+// serverOptions maps the HTTP_* and TLS_* settings onto the server package options
+func serverOptions(cfg *config.Config) server.Options {
+	return server.Options{
+		Addr:              ":" + cfg.Port,
+		ReadTimeout:       cfg.HTTPReadTimeout,
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		WriteTimeout:      cfg.HTTPWriteTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+		MaxHeaderBytes:    cfg.HTTPMaxHeaderBytes,
+		TLSCertFile:       cfg.TLSCertFile,
+		TLSKeyFile:        cfg.TLSKeyFile,
+		CertCheckInterval: cfg.ReloadInterval,
+		H2C:               cfg.H2C,
+	}
+}
+
+// databaseOptions maps the DATABASE_* settings onto the database package options
+func databaseOptions(cfg *config.Config, logger *slog.Logger) database.Options {
+	return database.Options{
+		Host:            cfg.DatabaseHost,
+		Port:            cfg.DatabasePort,
+		User:            cfg.DatabaseUser,
+		Password:        cfg.DatabasePassword,
+		Name:            cfg.DatabaseName,
+		SSLMode:         cfg.DatabaseSSLMode,
+		MaxOpenConns:    cfg.DatabaseMaxOpenConns,
+		MaxIdleConns:    cfg.DatabaseMaxIdleConns,
+		ConnMaxLifetime: cfg.DatabaseConnMaxLifetime,
+		ConnMaxIdleTime: cfg.DatabaseConnMaxIdleTime,
+		ConnectAttempts: cfg.DatabaseConnectAttempts,
+		Backoff:         cfg.DatabaseConnectBackoff,
+
+		Logger:             logger,
+		SlowQueryThreshold: cfg.DatabaseSlowQueryThreshold,
 	}
 }
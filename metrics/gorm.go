@@ -0,0 +1,99 @@
+// metrics/gorm.go
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"gorm.io/gorm"
+)
+
+const startTimeKey = "metrics:start_time"
+
+// InstrumentDB times every GORM operation and exports the connection pool statistics
+func (m *Metrics) InstrumentDB(db *gorm.DB) error {
+	if m == nil {
+		return nil
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	if err := m.Register(collectors.NewDBStatsCollector(sqlDB, namespace)); err != nil {
+		return err
+	}
+
+	before := func(tx *gorm.DB) {
+		tx.InstanceSet(startTimeKey, time.Now())
+	}
+	after := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			value, ok := tx.InstanceGet(startTimeKey)
+			if !ok {
+				return
+			}
+			start, _ := value.(time.Time)
+
+			status := "ok"
+			if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+				status = "error"
+			}
+			table := tx.Statement.Table
+			if table == "" {
+				table = "unknown"
+			}
+			m.dbQueries.WithLabelValues(operation, table, status).Observe(time.Since(start).Seconds())
+		}
+	}
+
+	cb := db.Callback()
+	hooks := []struct {
+		operation string
+		register  func(name string, before, after func(*gorm.DB)) error
+	}{
+		{"create", func(name string, b, a func(*gorm.DB)) error {
+			if err := cb.Create().Before("gorm:create").Register(name+":before", b); err != nil {
+				return err
+			}
+			return cb.Create().After("gorm:create").Register(name+":after", a)
+		}},
+		{"query", func(name string, b, a func(*gorm.DB)) error {
+			if err := cb.Query().Before("gorm:query").Register(name+":before", b); err != nil {
+				return err
+			}
+			return cb.Query().After("gorm:query").Register(name+":after", a)
+		}},
+		{"update", func(name string, b, a func(*gorm.DB)) error {
+			if err := cb.Update().Before("gorm:update").Register(name+":before", b); err != nil {
+				return err
+			}
+			return cb.Update().After("gorm:update").Register(name+":after", a)
+		}},
+		{"delete", func(name string, b, a func(*gorm.DB)) error {
+			if err := cb.Delete().Before("gorm:delete").Register(name+":before", b); err != nil {
+				return err
+			}
+			return cb.Delete().After("gorm:delete").Register(name+":after", a)
+		}},
+		{"row", func(name string, b, a func(*gorm.DB)) error {
+			if err := cb.Row().Before("gorm:row").Register(name+":before", b); err != nil {
+				return err
+			}
+			return cb.Row().After("gorm:row").Register(name+":after", a)
+		}},
+		{"raw", func(name string, b, a func(*gorm.DB)) error {
+			if err := cb.Raw().Before("gorm:raw").Register(name+":before", b); err != nil {
+				return err
+			}
+			return cb.Raw().After("gorm:raw").Register(name+":after", a)
+		}},
+	}
+	for _, hook := range hooks {
+		if err := hook.register("metrics:"+hook.operation, before, after(hook.operation)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
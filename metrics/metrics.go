@@ -0,0 +1,173 @@
+// metrics/metrics.go
+package metrics
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "testportal"
+
+// Login methods and results used as label values
+const (
+	LoginMethodPassword = "password"
+	LoginMethodPasskey  = "passkey"
+)
+
+// Metrics owns the Prometheus registry and every collector of the portal. All methods are safe
+// to call on a nil *Metrics, so components work unchanged when metrics are not wired in.
+type Metrics struct {
+	registry    *prometheus.Registry
+	bearerToken string
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+	httpInFlight prometheus.Gauge
+	dbQueries    *prometheus.HistogramVec
+
+	logins        *prometheus.CounterVec
+	resetsRequest prometheus.Counter
+}
+
+// New creates a new instance of Metrics. When bearerToken is set, scrapes must present it.
+func New(bearerToken string) *Metrics {
+	m := &Metrics{
+		registry:    prometheus.NewRegistry(),
+		bearerToken: bearerToken,
+
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "http", Name: "requests_total",
+			Help: "HTTP requests by route template, method and status code.",
+		}, []string{"route", "method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "http", Name: "request_duration_seconds",
+			Help:    "HTTP request latency by route template and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		httpInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "http", Name: "requests_in_flight",
+			Help: "HTTP requests currently being served.",
+		}),
+		dbQueries: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "db", Name: "query_duration_seconds",
+			Help:    "Database query latency by operation, table and outcome.",
+			Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"operation", "table", "status"}),
+
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "auth", Name: "logins_total",
+			Help: "Login attempts by method and result.",
+		}, []string{"method", "result"}),
+		resetsRequest: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "auth", Name: "password_resets_requested_total",
+			Help: "Password reset e-mails requested.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests, m.httpDuration, m.httpInFlight, m.dbQueries,
+		m.logins, m.resetsRequest,
+	)
+	return m
+}
+
+// Register adds extra collectors, such as the database pool stats
+func (m *Metrics) Register(cs ...prometheus.Collector) error {
+	if m == nil {
+		return nil
+	}
+	for _, c := range cs {
+		if err := m.registry.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler serves the registry in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	handler := promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+	if m.bearerToken == "" {
+		return handler
+	}
+	expected := []byte("Bearer " + m.bearerToken)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// Instrument records count, latency and status of every request, labelled by the mux route
+// template rather than the raw path so IDs don't explode the label cardinality. It must be
+// installed with Router.Use so the matched route is known.
+func (m *Metrics) Instrument(next http.Handler) http.Handler {
+	if m == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		m.httpInFlight.Inc()
+		defer m.httpInFlight.Dec()
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(recorder, r)
+
+		m.httpDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+		m.httpRequests.WithLabelValues(route, r.Method, strconv.Itoa(recorder.status)).Inc()
+	})
+}
+
+// Login counts a login attempt
+func (m *Metrics) Login(method string, success bool) {
+	if m == nil {
+		return
+	}
+	result := "failure"
+	if success {
+		result = "success"
+	}
+	m.logins.WithLabelValues(method, result).Inc()
+}
+
+// PasswordResetRequested counts a password reset request
+func (m *Metrics) PasswordResetRequested() {
+	if m == nil {
+		return
+	}
+	m.resetsRequest.Inc()
+}
+
+// statusRecorder captures the status code written by the wrapped handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,112 @@
+// metrics/metrics_test.go
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInstrument(t *testing.T) {
+	m := New("")
+	router := mux.NewRouter()
+	router.Use(m.Instrument)
+	router.HandleFunc("/api/v1/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}).Methods("GET", "DELETE")
+	router.PathPrefix("/static/").Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, request := range []struct{ method, target string }{
+		{"GET", "/api/v1/users/1"},
+		{"GET", "/api/v1/users/2"},
+		{"GET", "/api/v1/users/missing"},
+		{"DELETE", "/api/v1/users/3"},
+		{"GET", "/static/app.js"},
+		{"GET", "/static/app.css"},
+		{"GET", "/not/routed"},
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(request.method, request.target, nil))
+	}
+
+	tests := []struct {
+		route, method, status string
+		want                  float64
+	}{
+		{route: "/api/v1/users/{id}", method: "GET", status: "200", want: 2},
+		{route: "/api/v1/users/{id}", method: "GET", status: "404", want: 1},
+		{route: "/api/v1/users/{id}", method: "DELETE", status: "200", want: 1},
+		{route: "/static/", method: "GET", status: "200", want: 2},
+	}
+	for _, tt := range tests {
+		if got := testutil.ToFloat64(m.httpRequests.WithLabelValues(tt.route, tt.method, tt.status)); got != tt.want {
+			t.Errorf("requests{route=%q,method=%s,status=%s} = %v, want %v", tt.route, tt.method, tt.status, got, tt.want)
+		}
+	}
+	// One series per template, whatever the IDs; unrouted requests never reach the middleware
+	if got := testutil.CollectAndCount(m.httpRequests); got != len(tests) {
+		t.Errorf("%d request series, want %d", got, len(tests))
+	}
+	if got := testutil.CollectAndCount(m.httpDuration); got != 3 {
+		t.Errorf("%d duration series, want 3", got)
+	}
+	if got := testutil.ToFloat64(m.httpInFlight); got != 0 {
+		t.Errorf("%v requests still in flight", got)
+	}
+}
+
+func TestInstrumentOutsideRouter(t *testing.T) {
+	m := New("")
+	m.Instrument(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/1", nil))
+	if got := testutil.ToFloat64(m.httpRequests.WithLabelValues("unknown", "GET", "404")); got != 1 {
+		t.Fatalf("requests{route=unknown} = %v, want 1", got)
+	}
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *Metrics
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	m.Instrument(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	m.Login(LoginMethodPassword, true)
+	m.PasswordResetRequested()
+	if err := m.Register(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	m := New("scrape-token")
+	m.Login(LoginMethodPasskey, false)
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+	}{
+		{name: "no token", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", authorization: "Bearer guessed", wantStatus: http.StatusUnauthorized},
+		{name: "token without the scheme", authorization: "scrape-token", wantStatus: http.StatusUnauthorized},
+		{name: "token", authorization: "Bearer scrape-token", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/metrics", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			m.Handler().ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if rec.Code == http.StatusOK && !strings.Contains(rec.Body.String(), `testportal_auth_logins_total{method="passkey",result="failure"} 1`) {
+				t.Fatalf("the login counter is missing from the scrape:\n%s", rec.Body)
+			}
+		})
+	}
+}
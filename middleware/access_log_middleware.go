@@ -0,0 +1,49 @@
+// middleware/access_log_middleware.go
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/utils"
+)
+
+// AccessLog logs one line per request once it completes, with the request scope (request ID,
+// route, user) the inner middleware collected. Server errors are logged at error level and
+// requests to quietPaths, such as probes, at debug level.
+func AccessLog(logger *slog.Logger, quietPaths []string) func(http.Handler) http.Handler {
+	quiet := make(map[string]bool, len(quietPaths))
+	for _, path := range quietPaths {
+		quiet[path] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := &responseWriter{ResponseWriter: w}
+			next.ServeHTTP(rw, r)
+
+			status := rw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			level := slog.LevelInfo
+			switch {
+			case status >= http.StatusInternalServerError:
+				level = slog.LevelError
+			case quiet[r.URL.Path]:
+				level = slog.LevelDebug
+			}
+
+			logger.LogAttrs(r.Context(), level, "request completed",
+				slog.String("path", r.URL.Path),
+				slog.Int("status", status),
+				slog.Int64("bytes", rw.bytes),
+				slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.String("client_ip", utils.ClientIP(r)),
+				slog.String("user_agent", r.UserAgent()),
+			)
+		})
+	}
+}
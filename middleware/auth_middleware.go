@@ -3,9 +3,13 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
 
+	"github.com/ABDULS21985/test-portal/logging"
 	"github.com/ABDULS21985/test-portal/services"
 	"github.com/ABDULS21985/test-portal/utils"
 	"github.com/dgrijalva/jwt-go"
@@ -15,7 +19,9 @@ import (
 type key string
 
 const (
-	UserContext key = "user"
+	UserContext    key = "user"
+	ClaimsContext  key = "claims"
+	SessionContext key = "session"
 )
 
 // Claims defines the structure of JWT claims
@@ -26,11 +32,12 @@ type Claims struct {
 }
 
 type AuthMiddleware struct {
-	authService services.AuthService
+	authService    services.AuthService
+	sessionService services.SessionService
 }
 
-func NewAuthMiddleware(authService services.AuthService) *AuthMiddleware {
-	return &AuthMiddleware{authService: authService}
+func NewAuthMiddleware(authService services.AuthService, sessionService services.SessionService) *AuthMiddleware {
+	return &AuthMiddleware{authService: authService, sessionService: sessionService}
 }
 
 // NewAuthMiddleware creates a new instance of AuthMiddleware with the given JWT secret
@@ -79,16 +86,65 @@ func (m *AuthMiddleware) RequireAuth(next http.Handler) http.Handler {
 		}
 
 		token := strings.TrimPrefix(authHeader, "Bearer ")
-		_, err := m.authService.ValidateToken(token)
+		claims, err := m.authService.GetClaimsFromToken(r.Context(), token)
 		if err != nil {
 			utils.RespondWithError(w, http.StatusUnauthorized, "Invalid token")
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		// Make the caller's identity available to downstream handlers
+		ctx := context.WithValue(r.Context(), ClaimsContext, claims)
+
+		// Tokens are only good while their session is, so every token must name one
+		sessionID, ok := services.SessionIDFromClaims(claims)
+		if !ok {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Invalid token")
+			return
+		}
+		if _, err := m.sessionService.ValidateSession(ctx, sessionID); err != nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Session has been terminated")
+			return
+		}
+		ctx = context.WithValue(ctx, SessionContext, sessionID)
+
+		userID, err := uuid.Parse(fmt.Sprint(claims["user_id"]))
+		if err != nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Invalid token")
+			return
+		}
+		// Deprovisioned users lose access at once rather than when their token expires
+		if _, err := m.authService.ActiveUser(ctx, userID); err != nil {
+			if errors.Is(err, services.ErrAccountDisabled) {
+				utils.RespondWithError(w, http.StatusUnauthorized, "Account is disabled")
+				return
+			}
+			utils.RespondWithAppError(w, r, err)
+			return
+		}
+		ctx = context.WithValue(ctx, UserContext, userID)
+		logging.AddAttrs(ctx, slog.String("user_id", userID.String()))
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// ClaimsFromContext returns the token claims stored by RequireAuth
+func ClaimsFromContext(ctx context.Context) (jwt.MapClaims, bool) {
+	claims, ok := ctx.Value(ClaimsContext).(jwt.MapClaims)
+	return claims, ok
+}
+
+// SessionIDFromContext returns the login session of the current request
+func SessionIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	sessionID, ok := ctx.Value(SessionContext).(uuid.UUID)
+	return sessionID, ok
+}
+
+// UserIDFromContext returns the authenticated user's ID stored by RequireAuth
+func UserIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	userID, ok := ctx.Value(UserContext).(uuid.UUID)
+	return userID, ok
+}
+
 // RequireRole ensures the user has a specific role
 func (m *AuthMiddleware) RequireRole(role string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -100,7 +156,7 @@ func (m *AuthMiddleware) RequireRole(role string) func(http.Handler) http.Handle
 			}
 
 			token := strings.TrimPrefix(authHeader, "Bearer ")
-			claims, err := m.authService.GetClaimsFromToken(token)
+			claims, err := m.authService.GetClaimsFromToken(r.Context(), token)
 			if err != nil {
 				utils.RespondWithError(w, http.StatusUnauthorized, "Invalid token")
 				return
@@ -0,0 +1,82 @@
+// middleware/client_ip_middleware.go
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/ABDULS21985/test-portal/utils"
+)
+
+// ParseTrustedProxies parses proxy addresses and CIDRs such as 10.0.0.0/8
+func ParseTrustedProxies(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(value); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is neither an IP address nor a CIDR", value)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// ClientIP resolves the caller's address once for utils.ClientIP, so the access log, rate limits
+// and sessions all see the same one. It is the connection's peer unless that is a trusted proxy.
+// Then X-Forwarded-For is read from the right, where each proxy appended the address it saw,
+// and the first hop that isn't a trusted proxy is the caller; anything left of it was written by
+// the client. Without trusted proxies the header is ignored.
+func ClientIP(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, utils.WithClientIP(r, resolveClientIP(r, trusted)))
+		})
+	}
+}
+
+func resolveClientIP(r *http.Request, trusted []netip.Prefix) string {
+	client := utils.RemoteIP(r)
+	if !isTrustedProxy(client, trusted) {
+		return client
+	}
+
+	// Proxies may append to one header or add another, so all of them form the chain
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// Nothing before a malformed hop can be attributed; keep the last proxy
+			break
+		}
+		client = addr.Unmap().String()
+		if !isTrustedProxy(client, trusted) {
+			break
+		}
+	}
+	return client
+}
+
+func isTrustedProxy(ip string, trusted []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+// middleware/client_ip_middleware_test.go
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ABDULS21985/test-portal/utils"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.10", "2001:db8::/32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		trusted    bool
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{name: "no trusted proxies", remoteAddr: "10.1.1.1:4000", forwarded: []string{"203.0.113.7"}, want: "10.1.1.1"},
+		{name: "untrusted peer spoofing the header", trusted: true, remoteAddr: "198.51.100.4:4000", forwarded: []string{"203.0.113.7"}, want: "198.51.100.4"},
+		{name: "trusted peer", trusted: true, remoteAddr: "10.1.1.1:4000", forwarded: []string{"203.0.113.7"}, want: "203.0.113.7"},
+		{name: "client-supplied hops are ignored", trusted: true, remoteAddr: "10.1.1.1:4000", forwarded: []string{"1.2.3.4, 203.0.113.7"}, want: "203.0.113.7"},
+		{name: "several trusted hops", trusted: true, remoteAddr: "10.1.1.1:4000", forwarded: []string{"1.2.3.4, 203.0.113.7, 192.0.2.10", "10.2.2.2"}, want: "203.0.113.7"},
+		{name: "only trusted hops", trusted: true, remoteAddr: "10.1.1.1:4000", forwarded: []string{"10.3.3.3, 10.2.2.2"}, want: "10.3.3.3"},
+		{name: "malformed hop", trusted: true, remoteAddr: "10.1.1.1:4000", forwarded: []string{"203.0.113.7, garbage, 10.2.2.2"}, want: "10.2.2.2"},
+		{name: "trusted peer without the header", trusted: true, remoteAddr: "10.1.1.1:4000", want: "10.1.1.1"},
+		{name: "IPv6", trusted: true, remoteAddr: "[2001:db8::1]:4000", forwarded: []string{"2001:db8::2, 2001:db9::7"}, want: "2001:db9::7"},
+		{name: "IPv4-mapped peer", trusted: true, remoteAddr: "[::ffff:10.1.1.1]:4000", forwarded: []string{"203.0.113.7"}, want: "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var proxies = trusted
+			if !tt.trusted {
+				proxies = nil
+			}
+			var got string
+			handler := ClientIP(proxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = utils.ClientIP(r)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want {
+				t.Fatalf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("without the middleware", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "198.51.100.4:4000"
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		if got := utils.ClientIP(req); got != "198.51.100.4" {
+			t.Fatalf("ClientIP = %q, want the peer", got)
+		}
+	})
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "address", values: []string{"192.0.2.10"}, want: []string{"192.0.2.10/32"}},
+		{name: "CIDR is masked", values: []string{"10.1.2.3/8"}, want: []string{"10.0.0.0/8"}},
+		{name: "IPv6", values: []string{"2001:db8::1"}, want: []string{"2001:db8::1/128"}},
+		{name: "IPv4-mapped address", values: []string{"::ffff:192.0.2.10"}, want: []string{"192.0.2.10/32"}},
+		{name: "blank entries", values: []string{" ", "10.0.0.1 "}, want: []string{"10.0.0.1/32"}},
+		{name: "hostname", values: []string{"proxy.internal"}, wantErr: true},
+		{name: "bad prefix length", values: []string{"10.0.0.0/33"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTrustedProxies(tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].String() != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
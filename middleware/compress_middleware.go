@@ -0,0 +1,226 @@
+// middleware/compress_middleware.go
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Content codings Compress can produce
+const (
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+)
+
+// encoder is the part of gzip.Writer and brotli.Writer Compress uses
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// Encoders are reused since allocating their window for every response is the expensive part
+var encoderPools = map[string]*sync.Pool{
+	encodingBrotli: {New: func() any { return brotli.NewWriterLevel(nil, 4) }},
+	encodingGzip:   {New: func() any { return gzip.NewWriter(nil) }},
+}
+
+// Compress compresses responses with brotli or gzip, whichever the client prefers, brotli on a
+// tie. Responses are buffered until they reach minSize bytes; smaller ones, responses already
+// encoded by the handler (such as /metrics) and types that don't compress well are sent as is.
+func Compress(minSize int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, encoding: encoding, minSize: minSize}
+			next.ServeHTTP(cw, r)
+			// Not deferred: after a panic the buffered body is dropped so Recover can still answer
+			cw.Close()
+		})
+	}
+}
+
+// negotiateEncoding picks the coding from an Accept-Encoding header, or "" for none
+func negotiateEncoding(acceptEncoding string) string {
+	quality := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		quality[strings.ToLower(strings.TrimSpace(coding))] = q
+	}
+
+	pick := func(coding string) float64 {
+		if q, ok := quality[coding]; ok {
+			return q
+		}
+		return quality["*"]
+	}
+	br, gz := pick(encodingBrotli), pick(encodingGzip)
+	switch {
+	case br > 0 && br >= gz:
+		return encodingBrotli
+	case gz > 0:
+		return encodingGzip
+	}
+	return ""
+}
+
+// compressible reports whether a content type is worth compressing
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// compressWriter holds back the status and the start of the body until it knows whether to
+// compress, since Content-Encoding has to be set before the headers are sent
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	status  int
+	buf     []byte
+	decided bool
+	encoder encoder
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	// Informational responses go out as they come
+	if status < http.StatusOK {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if w.decided || w.status != 0 {
+		return
+	}
+	w.status = status
+	// Bodiless responses need no decision
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		w.decide(false)
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.decided {
+		if w.encoder != nil {
+			return w.encoder.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.minSize {
+		if err := w.decide(w.wantsCompression()); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush sends what was buffered so far, compressed if the content type allows it
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(w.wantsCompression())
+	}
+	if w.encoder != nil {
+		w.encoder.Flush()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Close ends the response, sending a body that stayed under minSize uncompressed
+func (w *compressWriter) Close() error {
+	if !w.decided {
+		if w.status == 0 && len(w.buf) == 0 {
+			return nil
+		}
+		return w.decide(false)
+	}
+	if w.encoder == nil {
+		return nil
+	}
+	err := w.encoder.Close()
+	w.encoder.Reset(nil)
+	encoderPools[w.encoding].Put(w.encoder)
+	w.encoder = nil
+	return err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *compressWriter) wantsCompression() bool {
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	if header.Get("Content-Type") == "" && len(w.buf) > 0 {
+		// Set it as net/http would, before Content-Encoding hides the body from its sniffing
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	return compressible(header.Get("Content-Type"))
+}
+
+// decide sends the headers and the buffered body, through an encoder if compress is set
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	if compress {
+		header := w.Header()
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", w.encoding)
+		w.encoder = encoderPools[w.encoding].Get().(encoder)
+		w.encoder.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(status)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.encoder != nil {
+		_, err := w.encoder.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
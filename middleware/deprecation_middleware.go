@@ -0,0 +1,51 @@
+// middleware/deprecation_middleware.go
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/logging"
+)
+
+// DeprecationOptions describes routes being retired
+type DeprecationOptions struct {
+	// Since is when the routes were deprecated
+	Since time.Time
+	// Sunset is when they stop being served; zero when no date is set yet
+	Sunset time.Time
+	// Successor is the path or URL of what replaces them, if anything
+	Successor string
+}
+
+// Deprecated announces that the routes it wraps are being retired. Responses carry Deprecation
+// (RFC 9745), Sunset (RFC 8594) and a successor-version Link, and the request's log lines are
+// flagged so remaining callers can be found before the sunset.
+func Deprecated(opts DeprecationOptions) func(http.Handler) http.Handler {
+	deprecation := "@" + strconv.FormatInt(opts.Since.Unix(), 10)
+	sunset := ""
+	if !opts.Sunset.IsZero() {
+		sunset = opts.Sunset.UTC().Format(http.TimeFormat)
+	}
+	link := ""
+	if opts.Successor != "" {
+		link = "<" + opts.Successor + `>; rel="successor-version"`
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := w.Header()
+			header.Set("Deprecation", deprecation)
+			if sunset != "" {
+				header.Set("Sunset", sunset)
+			}
+			if link != "" {
+				header.Add("Link", link)
+			}
+			logging.AddAttrs(r.Context(), slog.Bool("deprecated", true))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
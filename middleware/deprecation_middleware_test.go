@@ -0,0 +1,76 @@
+// middleware/deprecation_middleware_test.go
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/logging"
+)
+
+func TestDeprecated(t *testing.T) {
+	since := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2026, 7, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+
+	tests := []struct {
+		name       string
+		opts       DeprecationOptions
+		wantHeader http.Header
+	}{
+		{
+			name: "all set",
+			opts: DeprecationOptions{Since: since, Sunset: sunset, Successor: "/api/v2/admin"},
+			wantHeader: http.Header{
+				"Deprecation": {"@1768435200"},
+				"Sunset":      {"Wed, 01 Jul 2026 10:00:00 GMT"},
+				"Link":        {`<https://example.com/docs>; rel="help"`, `</api/v2/admin>; rel="successor-version"`},
+			},
+		},
+		{
+			name:       "no sunset or successor yet",
+			opts:       DeprecationOptions{Since: since},
+			wantHeader: http.Header{"Deprecation": {"@1768435200"}, "Link": {`<https://example.com/docs>; rel="help"`}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			logger, err := logging.New(&logs, logging.Options{Level: "info", Format: "json"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			handler := Deprecated(tt.opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/protected/admin", nil)
+			req = req.WithContext(logging.WithScope(req.Context()))
+			rec := httptest.NewRecorder()
+			// A Link set earlier in the chain is kept
+			rec.Header().Add("Link", `<https://example.com/docs>; rel="help"`)
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusTeapot {
+				t.Fatalf("status = %d, want the wrapped handler's", rec.Code)
+			}
+			if !reflect.DeepEqual(rec.Header(), tt.wantHeader) {
+				t.Fatalf("headers = %v, want %v", rec.Header(), tt.wantHeader)
+			}
+
+			// The request's log lines are flagged
+			logger.InfoContext(req.Context(), "request")
+			var line map[string]any
+			if err := json.Unmarshal(logs.Bytes(), &line); err != nil {
+				t.Fatal(err)
+			}
+			if line["deprecated"] != true {
+				t.Fatalf("log line %s isn't flagged as deprecated", logs.Bytes())
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// middleware/impersonation_middleware_test.go
+package middleware
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories"
+	"github.com/ABDULS21985/test-portal/repositories/memory"
+	"github.com/ABDULS21985/test-portal/services"
+)
+
+func TestImpersonationGuard(t *testing.T) {
+	ctx := context.Background()
+	repos := memory.NewStore().Repositories()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sessions := services.NewSessionService(repos.Sessions, logger)
+	auth := services.NewAuthService(repos.Users, sessions, services.NewJWTKeyRing([]byte("test-signing-key")), time.Hour, logger)
+
+	actor := &models.User{Email: "support@example.com", Role: models.RoleAdmin}
+	subject := &models.User{Email: "ada@example.com", Role: models.RoleUser}
+	for _, user := range []*models.User{actor, subject} {
+		if err := repos.Users.CreateUser(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	impersonating, _, err := auth.Impersonate(ctx, actor.ID, subject.ID, time.Hour, services.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	own, err := auth.IssueToken(ctx, subject, services.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	guard := NewImpersonationMiddleware(services.NewAuditService(repos.AuditLogs), []string{"/api/users/me/password", "/api/admin"}, logger)
+	var reached bool
+	handler := APIVersion(VersionOptions{Prefix: "/api", Default: 1, Supported: func(v int) bool { return v <= 2 }})(
+		NewAuthMiddleware(auth, sessions).RequireAuth(guard.Guard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reached = true
+			w.WriteHeader(http.StatusCreated)
+		}))),
+	)
+
+	tests := []struct {
+		name        string
+		token       string
+		method      string
+		path        string
+		wantStatus  int
+		wantHeaders bool
+		wantAction  string
+		wantPath    string // audited path, when the version middleware rewrote it
+	}{
+		{name: "own token", token: own, method: http.MethodDelete, path: "/api/v1/users/me", wantStatus: http.StatusCreated},
+		{name: "read", token: impersonating, method: http.MethodGet, path: "/api/v1/users/me", wantStatus: http.StatusCreated, wantHeaders: true, wantAction: services.AuditImpersonationRequest},
+		{name: "unrestricted write", token: impersonating, method: http.MethodPost, path: "/api/v1/tickets", wantStatus: http.StatusCreated, wantHeaders: true, wantAction: services.AuditImpersonationRequest},
+		{name: "delete", token: impersonating, method: http.MethodDelete, path: "/api/v1/tickets/1", wantStatus: http.StatusForbidden, wantHeaders: true, wantAction: services.AuditImpersonationBlocked},
+		{name: "restricted write", token: impersonating, method: http.MethodPut, path: "/api/v1/users/me/password", wantStatus: http.StatusForbidden, wantHeaders: true, wantAction: services.AuditImpersonationBlocked},
+		{name: "restricted write in another version", token: impersonating, method: http.MethodPost, path: "/api/v2/admin/roles", wantStatus: http.StatusForbidden, wantHeaders: true, wantAction: services.AuditImpersonationBlocked},
+		{name: "restricted write without a version", token: impersonating, method: http.MethodPatch, path: "/api/admin/roles", wantStatus: http.StatusForbidden, wantHeaders: true, wantAction: services.AuditImpersonationBlocked, wantPath: "/api/v1/admin/roles"},
+		{name: "read under a restricted prefix", token: impersonating, method: http.MethodGet, path: "/api/v1/admin/roles", wantStatus: http.StatusCreated, wantHeaders: true, wantAction: services.AuditImpersonationRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := auditEntries(t, repos.AuditLogs, subject)
+			reached = false
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if blocked := tt.wantStatus == http.StatusForbidden; reached == blocked {
+				t.Fatalf("handler reached = %v for a %d", reached, rec.Code)
+			}
+
+			if got := rec.Header().Get(ImpersonatingHeader) == "true"; got != tt.wantHeaders {
+				t.Fatalf("%s set = %v, want %v", ImpersonatingHeader, got, tt.wantHeaders)
+			}
+			if tt.wantHeaders {
+				if got := rec.Header().Get(ImpersonatorHeader); got != actor.ID.String() {
+					t.Fatalf("%s = %q, want %s", ImpersonatorHeader, got, actor.ID)
+				}
+				if got := rec.Header().Get(ImpersonatedSubHeader); got != subject.ID.String() {
+					t.Fatalf("%s = %q, want %s", ImpersonatedSubHeader, got, subject.ID)
+				}
+			}
+
+			entries := auditEntries(t, repos.AuditLogs, subject)[len(before):]
+			if tt.wantAction == "" {
+				if len(entries) != 0 {
+					t.Fatalf("a regular request was audited: %+v", entries)
+				}
+				return
+			}
+			if len(entries) != 1 {
+				t.Fatalf("%d audit entries, want 1", len(entries))
+			}
+			wantPath := tt.path
+			if tt.wantPath != "" {
+				wantPath = tt.wantPath
+			}
+			entry := entries[0]
+			if entry.Action != tt.wantAction || entry.ActorID != actor.ID || entry.Status != rec.Code || entry.Method != tt.method || entry.Path != wantPath {
+				t.Fatalf("audit entry = %+v", entry)
+			}
+		})
+	}
+}
+
+// auditEntries returns the audit trail of subject, oldest first
+func auditEntries(t *testing.T, repo repositories.AuditLogRepository, subject *models.User) []models.AuditLog {
+	t.Helper()
+	entries, err := repo.ListBySubject(context.Background(), subject.ID, repositories.ListOptions{Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries
+}
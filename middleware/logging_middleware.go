@@ -0,0 +1,61 @@
+// middleware/logging_middleware.go
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"regexp"
+
+	"github.com/ABDULS21985/test-portal/logging"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// RequestIDHeader carries the request ID in and out of the service
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID keeps caller-supplied IDs short and printable so they can't forge log lines
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._\-]{1,128}$`)
+
+type requestIDKey struct{}
+
+// RequestID assigns the request its ID, taken from X-Request-ID when the caller sent a valid one,
+// echoes it in the response and starts the request's logging scope with it. It wraps the whole
+// router, so unmatched requests and preflights get an ID too.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		ctx := context.WithValue(r.Context(), requestIDKey{}, requestID)
+		ctx = logging.WithScope(ctx,
+			slog.String("request_id", requestID),
+			slog.String("method", r.Method),
+		)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequestIDFromContext returns the ID RequestID assigned to the request
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	requestID, ok := ctx.Value(requestIDKey{}).(string)
+	return requestID, ok
+}
+
+// RequestScope adds the matched route template to the logging scope RequestID started. It runs
+// as router middleware, since only then is the route known.
+func RequestScope(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				logging.AddAttrs(r.Context(), slog.String("route", template))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,145 @@
+// middleware/ratelimit_middleware_test.go
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/ratelimit"
+	"github.com/ABDULS21985/test-portal/utils"
+
+	"github.com/google/uuid"
+)
+
+// brokenStore fails every hit, as an unreachable database would
+type brokenStore struct{}
+
+func (brokenStore) Take(context.Context, string, ratelimit.Limit, time.Time) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("connection refused")
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	limiter := NewRateLimiter(ratelimit.NewMemoryStore(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	limit := ratelimit.Limit{Algorithm: ratelimit.TokenBucket, Requests: 2, Period: time.Hour}
+	handler := limiter.Limit("login", limit, ByIP)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		wantStatus     int
+		wantRemaining  string
+		wantReset      string
+		wantRetryAfter string
+	}{
+		{wantStatus: http.StatusNoContent, wantRemaining: "1", wantReset: "1800"},
+		{wantStatus: http.StatusNoContent, wantRemaining: "0", wantReset: "3600"},
+		{wantStatus: http.StatusTooManyRequests, wantRemaining: "0", wantReset: "3600", wantRetryAfter: "1800"},
+	}
+	for i, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/login", nil))
+
+		if rec.Code != tt.wantStatus {
+			t.Fatalf("request %d: status = %d, want %d", i, rec.Code, tt.wantStatus)
+		}
+		for name, want := range map[string]string{
+			"RateLimit-Limit":     "2",
+			"RateLimit-Remaining": tt.wantRemaining,
+			"RateLimit-Reset":     tt.wantReset,
+			"RateLimit-Policy":    "2;w=3600",
+			"Retry-After":         tt.wantRetryAfter,
+		} {
+			// Reset and Retry-After round up, so the microseconds between requests don't show
+			if got := rec.Header().Get(name); got != want {
+				t.Fatalf("request %d: %s = %q, want %q", i, name, got, want)
+			}
+		}
+	}
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	userID := uuid.New()
+	withUser := func(r *http.Request) *http.Request {
+		return r.WithContext(context.WithValue(r.Context(), UserContext, userID))
+	}
+	withKey := func(key string) func(r *http.Request) *http.Request {
+		return func(r *http.Request) *http.Request {
+			r.Header.Set("X-API-Key", key)
+			return r
+		}
+	}
+
+	tests := []struct {
+		name    string
+		key     RateLimitKey
+		prepare func(r *http.Request) *http.Request
+		want    string
+	}{
+		{name: "IP", key: ByIP, want: "ip:203.0.113.7"},
+		{name: "user", key: ByUser, prepare: withUser, want: "user:" + userID.String()},
+		{name: "user before authentication", key: ByUser, want: "ip:203.0.113.7"},
+		{name: "API key", key: ByAPIKey("X-API-Key"), prepare: withKey("k-123"), want: "key:3605a9e4358da4302f8acea41f0f52cef85d0e3f727c7b020fc7305aec8d56b4"},
+		{name: "no API key", key: ByAPIKey("X-API-Key"), want: "ip:203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := utils.WithClientIP(httptest.NewRequest(http.MethodGet, "/", nil), "203.0.113.7")
+			if tt.prepare != nil {
+				req = tt.prepare(req)
+			}
+			if got := tt.key(req); got != tt.want {
+				t.Fatalf("key = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitSeparatesKeys(t *testing.T) {
+	limiter := NewRateLimiter(ratelimit.NewMemoryStore(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	limit := ratelimit.Limit{Algorithm: ratelimit.TokenBucket, Requests: 1, Period: time.Hour}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	login := limiter.Limit("login", limit, ByIP)(ok)
+	register := limiter.Limit("register", limit, ByIP)(ok)
+
+	serve := func(handler http.Handler, ip string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, utils.WithClientIP(httptest.NewRequest(http.MethodPost, "/", nil), ip))
+		return rec.Code
+	}
+	steps := []struct {
+		name    string
+		handler http.Handler
+		ip      string
+		want    int
+	}{
+		{name: "first client", handler: login, ip: "203.0.113.7", want: http.StatusOK},
+		{name: "first client again", handler: login, ip: "203.0.113.7", want: http.StatusTooManyRequests},
+		{name: "another client", handler: login, ip: "198.51.100.4", want: http.StatusOK},
+		{name: "another group", handler: register, ip: "203.0.113.7", want: http.StatusOK},
+	}
+	for _, step := range steps {
+		if got := serve(step.handler, step.ip); got != step.want {
+			t.Fatalf("%s: status = %d, want %d", step.name, got, step.want)
+		}
+	}
+}
+
+func TestRateLimitStoreFailure(t *testing.T) {
+	limiter := NewRateLimiter(brokenStore{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	reached := false
+	handler := limiter.Limit("login", ratelimit.Limit{Requests: 1, Period: time.Minute}, ByIP)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/login", nil))
+	if !reached || rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Limit") != "" {
+		t.Fatalf("reached = %v, status = %d, headers = %v; want the request let through", reached, rec.Code, rec.Header())
+	}
+}
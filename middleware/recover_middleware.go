@@ -0,0 +1,39 @@
+// middleware/recover_middleware.go
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/ABDULS21985/test-portal/utils"
+)
+
+// Recover turns a panicking handler into a logged error and, if nothing was sent yet, a 500
+// problem response instead of a dropped connection. http.ErrAbortHandler is re-raised, since
+// it is how a handler deliberately aborts a response.
+func Recover(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := &responseWriter{ResponseWriter: w}
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+
+				logger.ErrorContext(r.Context(), "panic while handling request",
+					slog.Any("panic", recovered),
+					slog.String("stack", string(debug.Stack())),
+				)
+				if rw.status == 0 {
+					utils.RespondWithError(w, http.StatusInternalServerError, "")
+				}
+			}()
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// middleware/scim_middleware.go
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"strings"
+
+	"github.com/ABDULS21985/test-portal/scim"
+)
+
+const (
+	SCIMTenantContext key = "scim_tenant"
+)
+
+// SCIMAuthMiddleware authenticates SCIM clients with a static bearer token per tenant
+type SCIMAuthMiddleware struct {
+	tenants map[[sha256.Size]byte]string
+}
+
+// NewSCIMAuthMiddleware creates a new instance of SCIMAuthMiddleware from a tenant -> token map
+func NewSCIMAuthMiddleware(tenantTokens map[string]string) *SCIMAuthMiddleware {
+	tenants := make(map[[sha256.Size]byte]string, len(tenantTokens))
+	for tenant, token := range tenantTokens {
+		if token == "" {
+			continue
+		}
+		tenants[sha256.Sum256([]byte(token))] = tenant
+	}
+	return &SCIMAuthMiddleware{tenants: tenants}
+}
+
+// RequireTenantToken resolves the bearer token to a tenant and stores it in the request context
+func (m *SCIMAuthMiddleware) RequireTenantToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			scim.RespondWithError(w, scim.NewError(http.StatusUnauthorized, "", "Authorization header is missing"))
+			return
+		}
+
+		// Comparing digests keeps lookup time independent of how much of the token matched
+		tenant, ok := m.tenants[sha256.Sum256([]byte(token))]
+		if !ok {
+			scim.RespondWithError(w, scim.NewError(http.StatusUnauthorized, "", "Invalid token"))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), SCIMTenantContext, tenant)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// SCIMTenantFromContext returns the tenant resolved by RequireTenantToken
+func SCIMTenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(SCIMTenantContext).(string)
+	return tenant
+}
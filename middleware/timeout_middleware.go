@@ -0,0 +1,67 @@
+// middleware/timeout_middleware.go
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/utils"
+)
+
+// Timeout gives every request a deadline. Database queries and outgoing calls made with the
+// request context are cancelled once it passes. Whatever error the handler then reports (a
+// failed lookup often looks like "not found" or "invalid credentials") is replaced with a 504,
+// unless the handler already answered 504 in its own format. A timeout of zero disables it.
+func Timeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if timeout <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			next.ServeHTTP(&timeoutWriter{ResponseWriter: w, ctx: ctx}, r.WithContext(ctx))
+		})
+	}
+}
+
+// timeoutWriter swaps error responses written after the deadline for a 504
+type timeoutWriter struct {
+	http.ResponseWriter
+	ctx         context.Context
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (w *timeoutWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	if status >= http.StatusBadRequest && status != http.StatusGatewayTimeout && errors.Is(w.ctx.Err(), context.DeadlineExceeded) {
+		w.timedOut = true
+		utils.RespondWithError(w.ResponseWriter, http.StatusGatewayTimeout, "Request timed out")
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	// The handler's own error body is dropped in favour of the timeout response
+	if w.timedOut {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *timeoutWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
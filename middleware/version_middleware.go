@@ -0,0 +1,163 @@
+// middleware/version_middleware.go
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ABDULS21985/test-portal/apperrors"
+	"github.com/ABDULS21985/test-portal/logging"
+	"github.com/ABDULS21985/test-portal/utils"
+)
+
+// APIVersionHeader tells the client which API version served the response
+const APIVersionHeader = "API-Version"
+
+// VersionOptions configures APIVersion
+type VersionOptions struct {
+	// Prefix is where the API is mounted, such as "/api"; each version lives under
+	// Prefix/v1, Prefix/v2 and so on
+	Prefix string
+	// MediaType is the vendor media type a client names a version with, such as
+	// "application/vnd.testportal" for Accept: application/vnd.testportal.v2+json
+	MediaType string
+	// Default is the version served when a request names none
+	Default int
+	// Supported reports whether a version has routes
+	Supported func(version int) bool
+}
+
+type apiVersionKey struct{}
+
+// apiVersion is the version serving a request and its path without the version segment
+type apiVersion struct {
+	version int
+	path    string
+}
+
+// APIVersion resolves the API version of requests under opts.Prefix. A path naming a version,
+// such as /api/v2/users, is served as is. Any other is rewritten to the version its Accept
+// header asks for, either as MediaType.v2+json or with a version=2 parameter, falling back to
+// opts.Default; one that isn't supported gets a 406. It must wrap the router, since routing
+// happens on the rewritten path.
+func APIVersion(opts VersionOptions) func(http.Handler) http.Handler {
+	prefix := strings.TrimSuffix(opts.Prefix, "/")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rest, ok := strings.CutPrefix(r.URL.Path, prefix)
+			if !ok || (rest != "" && rest[0] != '/') {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			version, unversioned, explicit := pathVersion(rest)
+			if !explicit {
+				w.Header().Add("Vary", "Accept")
+				var err error
+				if version, err = acceptedVersion(r.Header.Values("Accept"), opts.MediaType); err != nil {
+					utils.RespondWithAppError(w, r, apperrors.NotAcceptable("The Accept header names an invalid API version"))
+					return
+				}
+				if version == 0 {
+					version = opts.Default
+				}
+				if !opts.Supported(version) {
+					utils.RespondWithAppError(w, r, apperrors.NotAcceptable(fmt.Sprintf("API version %d is not available", version)))
+					return
+				}
+				r = withVersionedPath(r, prefix, version)
+			} else if !opts.Supported(version) {
+				// Left to the router, which has nothing under the version and answers 404
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set(APIVersionHeader, strconv.Itoa(version))
+			logging.AddAttrs(r.Context(), slog.Int("api_version", version))
+			ctx := context.WithValue(r.Context(), apiVersionKey{}, apiVersion{version: version, path: prefix + unversioned})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// APIVersionFromContext returns the API version APIVersion resolved for the request
+func APIVersionFromContext(ctx context.Context) (int, bool) {
+	v, ok := ctx.Value(apiVersionKey{}).(apiVersion)
+	return v.version, ok
+}
+
+// unversionedPath is the request path without its API version segment, so /api/v1/users and
+// /api/v2/users both read /api/users
+func unversionedPath(r *http.Request) string {
+	if v, ok := r.Context().Value(apiVersionKey{}).(apiVersion); ok {
+		return v.path
+	}
+	return r.URL.Path
+}
+
+// pathVersion reads the version segment at the start of rest, such as "/v2/users", returning the
+// version and what follows it
+func pathVersion(rest string) (int, string, bool) {
+	if rest == "" {
+		return 0, rest, false
+	}
+	segment, tail, _ := strings.Cut(rest[1:], "/")
+	if len(segment) < 2 || segment[0] != 'v' {
+		return 0, rest, false
+	}
+	version, err := strconv.Atoi(segment[1:])
+	if err != nil || version < 1 || segment[1] == '+' {
+		return 0, rest, false
+	}
+	if tail != "" || strings.HasSuffix(rest, "/") {
+		tail = "/" + tail
+	}
+	return version, tail, true
+}
+
+// acceptedVersion returns the first version the Accept header names, or 0 when it names none
+func acceptedVersion(accept []string, mediaType string) (int, error) {
+	for _, value := range accept {
+		for _, part := range strings.Split(value, ",") {
+			name, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+
+			var number string
+			switch {
+			case name == mediaType+"+json" || name == "application/json":
+				number = params["version"]
+			case strings.HasPrefix(name, mediaType+".v") && strings.HasSuffix(name, "+json"):
+				number = strings.TrimSuffix(strings.TrimPrefix(name, mediaType+".v"), "+json")
+			}
+			if number == "" {
+				continue
+			}
+			version, err := strconv.Atoi(number)
+			if err != nil || version < 1 {
+				return 0, fmt.Errorf("invalid API version %q", number)
+			}
+			return version, nil
+		}
+	}
+	return 0, nil
+}
+
+// withVersionedPath returns a shallow copy of r whose path has the version segment inserted
+// after prefix
+func withVersionedPath(r *http.Request, prefix string, version int) *http.Request {
+	segment := prefix + "/v" + strconv.Itoa(version)
+	clone := r.Clone(r.Context())
+	clone.URL.Path = segment + strings.TrimPrefix(r.URL.Path, prefix)
+	if r.URL.RawPath != "" {
+		clone.URL.RawPath = segment + strings.TrimPrefix(r.URL.RawPath, prefix)
+	}
+	return clone
+}
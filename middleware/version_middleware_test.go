@@ -0,0 +1,90 @@
+// middleware/version_middleware_test.go
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ABDULS21985/test-portal/utils"
+)
+
+func TestAPIVersion(t *testing.T) {
+	type seen struct {
+		Path        string
+		RawPath     string
+		Version     int
+		Unversioned string
+	}
+	handler := APIVersion(VersionOptions{
+		Prefix:    "/api/",
+		MediaType: "application/vnd.testportal",
+		Default:   1,
+		Supported: func(version int) bool { return version == 1 || version == 2 },
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version, _ := APIVersionFromContext(r.Context())
+		utils.RespondWithJSON(w, http.StatusOK, seen{Path: r.URL.Path, RawPath: r.URL.RawPath, Version: version, Unversioned: unversionedPath(r)})
+	}))
+
+	tests := []struct {
+		name       string
+		target     string
+		accept     []string
+		wantStatus int
+		want       seen
+		wantVary   bool
+		wantHeader string
+	}{
+		{name: "default version", target: "/api/users", wantStatus: http.StatusOK, want: seen{Path: "/api/v1/users", Version: 1, Unversioned: "/api/users"}, wantVary: true, wantHeader: "1"},
+		{name: "vendor media type", target: "/api/users", accept: []string{"application/vnd.testportal.v2+json"}, wantStatus: http.StatusOK, want: seen{Path: "/api/v2/users", Version: 2, Unversioned: "/api/users"}, wantVary: true, wantHeader: "2"},
+		{name: "version parameter", target: "/api/users", accept: []string{"application/json; version=2"}, wantStatus: http.StatusOK, want: seen{Path: "/api/v2/users", Version: 2, Unversioned: "/api/users"}, wantVary: true, wantHeader: "2"},
+		{name: "vendor type with a version parameter", target: "/api/users", accept: []string{"application/vnd.testportal+json;version=2"}, wantStatus: http.StatusOK, want: seen{Path: "/api/v2/users", Version: 2, Unversioned: "/api/users"}, wantVary: true, wantHeader: "2"},
+		{name: "first versioned entry wins", target: "/api/users", accept: []string{"text/html, application/json", "application/vnd.testportal.v2+json, application/json; version=1"}, wantStatus: http.StatusOK, want: seen{Path: "/api/v2/users", Version: 2, Unversioned: "/api/users"}, wantVary: true, wantHeader: "2"},
+		{name: "unversioned accept", target: "/api/users", accept: []string{"application/json", "*/*"}, wantStatus: http.StatusOK, want: seen{Path: "/api/v1/users", Version: 1, Unversioned: "/api/users"}, wantVary: true, wantHeader: "1"},
+		{name: "malformed entries are skipped", target: "/api/users", accept: []string{"application/json; version", "application/vnd.testportal.v2+json"}, wantStatus: http.StatusOK, want: seen{Path: "/api/v2/users", Version: 2, Unversioned: "/api/users"}, wantVary: true, wantHeader: "2"},
+		{name: "unsupported version", target: "/api/users", accept: []string{"application/vnd.testportal.v3+json"}, wantStatus: http.StatusNotAcceptable, wantVary: true},
+		{name: "invalid version", target: "/api/users", accept: []string{"application/vnd.testportal.v0+json"}, wantStatus: http.StatusNotAcceptable, wantVary: true},
+		{name: "non-numeric version", target: "/api/users", accept: []string{"application/json; version=latest"}, wantStatus: http.StatusNotAcceptable, wantVary: true},
+		{name: "path version", target: "/api/v2/users", wantStatus: http.StatusOK, want: seen{Path: "/api/v2/users", Version: 2, Unversioned: "/api/users"}, wantHeader: "2"},
+		{name: "path beats accept", target: "/api/v2/users", accept: []string{"application/vnd.testportal.v1+json"}, wantStatus: http.StatusOK, want: seen{Path: "/api/v2/users", Version: 2, Unversioned: "/api/users"}, wantHeader: "2"},
+		{name: "bare versioned prefix", target: "/api/v1", wantStatus: http.StatusOK, want: seen{Path: "/api/v1", Version: 1, Unversioned: "/api"}, wantHeader: "1"},
+		{name: "versioned prefix with a slash", target: "/api/v1/", wantStatus: http.StatusOK, want: seen{Path: "/api/v1/", Version: 1, Unversioned: "/api/"}, wantHeader: "1"},
+		{name: "unsupported path version goes to the router", target: "/api/v9/users", wantStatus: http.StatusOK, want: seen{Path: "/api/v9/users", Unversioned: "/api/v9/users"}},
+		{name: "segment that isn't a version", target: "/api/vendors", wantStatus: http.StatusOK, want: seen{Path: "/api/v1/vendors", Version: 1, Unversioned: "/api/vendors"}, wantVary: true, wantHeader: "1"},
+		{name: "signed number isn't a version", target: "/api/v+2/users", wantStatus: http.StatusOK, want: seen{Path: "/api/v1/v+2/users", Version: 1, Unversioned: "/api/v+2/users"}, wantVary: true, wantHeader: "1"},
+		{name: "escaped path", target: "/api/users/a%2Fb", wantStatus: http.StatusOK, want: seen{Path: "/api/v1/users/a/b", RawPath: "/api/v1/users/a%2Fb", Version: 1, Unversioned: "/api/users/a/b"}, wantVary: true, wantHeader: "1"},
+		{name: "outside the prefix", target: "/healthz", wantStatus: http.StatusOK, want: seen{Path: "/healthz", Unversioned: "/healthz"}},
+		{name: "prefix of a longer segment", target: "/apidocs", wantStatus: http.StatusOK, want: seen{Path: "/apidocs", Unversioned: "/apidocs"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			for _, accept := range tt.accept {
+				req.Header.Add("Accept", accept)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if got := rec.Header().Get("Vary") == "Accept"; got != tt.wantVary {
+				t.Fatalf("Vary = %q, want Accept: %v", rec.Header().Get("Vary"), tt.wantVary)
+			}
+			if got := rec.Header().Get(APIVersionHeader); got != tt.wantHeader {
+				t.Fatalf("%s = %q, want %q", APIVersionHeader, got, tt.wantHeader)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+			var got seen
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("handler saw %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,26 +2,28 @@
 package migrations
 
 import (
-	"log"
-
-	"github.com/ABDULS21985/test-portal/models"
+	"context"
+	"log/slog"
 
 	"gorm.io/gorm"
 )
 
-// RunMigrations applies all database schema updates
-func RunMigrations(db *gorm.DB) {
-	log.Println("Starting database migrations...")
-
-	err := db.AutoMigrate(
-		&models.User{},
-		&models.PasswordResetToken{},
-		// Add more models here as needed
-	)
+// RunMigrations applies all pending versioned migrations, the embedded ones and extra
+func RunMigrations(ctx context.Context, db *gorm.DB, logger *slog.Logger, extra ...Migration) error {
+	logger.Info("starting database migrations")
 
+	migrator, err := NewMigrator(db, extra...)
+	if err != nil {
+		return err
+	}
+	applied, err := migrator.Up(ctx)
+	for _, m := range applied {
+		logger.Info("applied migration", slog.Int64("version", m.Version), slog.String("name", m.Name))
+	}
 	if err != nil {
-		log.Fatalf("Database migration failed: %v", err)
+		return err
 	}
 
-	log.Println("Database migrations completed successfully.")
+	logger.Info("database migrations completed")
+	return nil
 }
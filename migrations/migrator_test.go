@@ -0,0 +1,330 @@
+// migrations/migrator_test.go
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/logging"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       fstest.MapFS
+		wantVersion []int64
+		wantErr     string
+	}{
+		{
+			name: "ordered by version",
+			files: fstest.MapFS{
+				"sql/0010_later.up.sql":   {Data: []byte("SELECT 10")},
+				"sql/0002_first.up.sql":   {Data: []byte("SELECT 2")},
+				"sql/0002_first.down.sql": {Data: []byte("SELECT -2")},
+			},
+			wantVersion: []int64{2, 10},
+		},
+		{name: "bad file name", files: fstest.MapFS{"sql/2_Widgets.up.sql": {Data: []byte("SELECT 1")}}, wantErr: "unexpected file name"},
+		{name: "down without up", files: fstest.MapFS{"sql/0001_widgets.down.sql": {Data: []byte("SELECT 1")}}, wantErr: "has no up script"},
+		{
+			name: "version reused",
+			files: fstest.MapFS{
+				"sql/0001_widgets.up.sql": {Data: []byte("SELECT 1")},
+				"sql/0001_gadgets.up.sql": {Data: []byte("SELECT 1")},
+			},
+			wantErr: "is used by both",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := Load(tt.files, "sql")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var versions []int64
+			for _, m := range migrations {
+				versions = append(versions, m.Version)
+			}
+			if fmt.Sprint(versions) != fmt.Sprint(tt.wantVersion) {
+				t.Fatalf("versions = %v, want %v", versions, tt.wantVersion)
+			}
+			if migrations[0].Down != "SELECT -2" || migrations[0].Checksum == "" {
+				t.Fatalf("got %+v", migrations[0])
+			}
+		})
+	}
+}
+
+func TestLoadChecksum(t *testing.T) {
+	checksum := func(up, down string) string {
+		t.Helper()
+		migrations, err := Load(fstest.MapFS{
+			"sql/0001_widgets.up.sql":   {Data: []byte(up)},
+			"sql/0001_widgets.down.sql": {Data: []byte(down)},
+		}, "sql")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return migrations[0].Checksum
+	}
+
+	original := checksum("CREATE TABLE widgets ()", "DROP TABLE widgets")
+	if checksum("CREATE TABLE widgets ()", "DROP TABLE IF EXISTS widgets") != original {
+		t.Error("editing the down script changed the checksum")
+	}
+	if checksum("CREATE TABLE widgets (id int)", "DROP TABLE widgets") == original {
+		t.Error("editing the up script kept the checksum")
+	}
+}
+
+func TestEmbedded(t *testing.T) {
+	migrations, err := Load(embedded, "sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range migrations {
+		if m.Version != int64(i+1) {
+			t.Errorf("%04d_%s: versions should be consecutive from 1", m.Version, m.Name)
+		}
+		if m.Down == "" {
+			t.Errorf("%04d_%s has no down script", m.Version, m.Name)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := []Migration{{Version: 1, Name: "a"}, {Version: 3, Name: "c"}}
+
+	merged, err := merge(base, []Migration{{Version: 2, Name: "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merged) != 3 || merged[1].Name != "b" {
+		t.Fatalf("merged = %+v, want b between a and c", merged)
+	}
+	if _, err := merge(base, []Migration{{Version: 3, Name: "other"}}); err == nil {
+		t.Fatal("a reused version was accepted")
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "sql")
+
+	up, down, err := Create(dir, "Add widgets!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(up) != "0001_add_widgets.up.sql" || filepath.Base(down) != "0001_add_widgets.down.sql" {
+		t.Fatalf("created %s and %s", up, down)
+	}
+	// Numbering continues after the highest existing version
+	if up, _, err = Create(dir, "gadgets"); err != nil || filepath.Base(up) != "0002_gadgets.up.sql" {
+		t.Fatalf("second migration: %s, %v", up, err)
+	}
+	if _, _, err := Create(dir, "  !! "); err == nil {
+		t.Fatal("a name without letters was accepted")
+	}
+	if _, err := Load(os.DirFS(dir), "."); err != nil {
+		t.Fatalf("Load can't read the created migrations: %v", err)
+	}
+}
+
+// openSchema connects to TEST_DATABASE_URL with a new, empty schema first on the search path,
+// dropped when the test ends. It skips the test when TEST_DATABASE_URL isn't set.
+func openSchema(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logging.NewGormLogger(logging.Discard(), 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("migrations_test_%d", time.Now().UnixNano())
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// The uuid-ossp functions live in public
+	if strings.Contains(dsn, "://") {
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		dsn += separator + "search_path=" + schema + ",public"
+	} else {
+		dsn += " search_path=" + schema + ",public"
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logging.NewGormLogger(logging.Discard(), 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// widgets is a migration after the embedded ones, with up as its up script
+func widgets(t *testing.T, up string) Migration {
+	t.Helper()
+	migrations, err := Load(fstest.MapFS{
+		"sql/9001_widgets.up.sql":   {Data: []byte(up)},
+		"sql/9001_widgets.down.sql": {Data: []byte("DROP TABLE widgets")},
+	}, "sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return migrations[0]
+}
+
+func newMigrator(t *testing.T, db *gorm.DB, extra ...Migration) *Migrator {
+	t.Helper()
+	migrator, err := NewMigrator(db, extra...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return migrator
+}
+
+func TestMigratorPostgres(t *testing.T) {
+	ctx := context.Background()
+	db := openSchema(t)
+	embeddedCount := len(newMigrator(t, db).migrations)
+	migrator := newMigrator(t, db, widgets(t, "CREATE TABLE widgets (id int)"))
+
+	t.Run("pending before the first run", func(t *testing.T) {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pending) != embeddedCount+1 {
+			t.Fatalf("%d pending, want all %d", len(pending), embeddedCount+1)
+		}
+	})
+
+	t.Run("apply", func(t *testing.T) {
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(applied) != embeddedCount+1 {
+			t.Fatalf("applied %d, want %d", len(applied), embeddedCount+1)
+		}
+		if err := db.Exec("INSERT INTO widgets VALUES (1)").Error; err != nil {
+			t.Fatalf("the widgets migration didn't run: %v", err)
+		}
+		if again, err := migrator.Up(ctx); err != nil || len(again) != 0 {
+			t.Fatalf("a second run applied %d, %v", len(again), err)
+		}
+		if pending, err := migrator.Pending(ctx); err != nil || len(pending) != 0 {
+			t.Fatalf("%d still pending, %v", len(pending), err)
+		}
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		edited := newMigrator(t, db, widgets(t, "CREATE TABLE widgets (id bigint)"))
+		if _, err := edited.Up(ctx); err == nil || !strings.Contains(err.Error(), "modified after it was applied") {
+			t.Fatalf("err = %v, want the edit refused", err)
+		}
+		statuses, err := edited.Status(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		last := statuses[len(statuses)-1]
+		if !last.Applied || !last.ChecksumMismatch || statuses[0].ChecksumMismatch {
+			t.Fatalf("statuses report %+v first and %+v last", statuses[0], last)
+		}
+	})
+
+	t.Run("down", func(t *testing.T) {
+		rolledBack, err := migrator.Down(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rolledBack) != 1 || rolledBack[0].Name != "widgets" {
+			t.Fatalf("rolled back %+v, want only widgets", rolledBack)
+		}
+		if err := db.Exec("SELECT 1 FROM widgets").Error; err == nil {
+			t.Fatal("the widgets table survived its rollback")
+		}
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pending) != 1 || pending[0].Version != 9001 {
+			t.Fatalf("pending = %+v, want widgets again", pending)
+		}
+	})
+}
+
+// TestMigratorAdoptsAutoMigrate upgrades the schema GORM AutoMigrate created for the original
+// models, before versioned migrations existed
+func TestMigratorAdoptsAutoMigrate(t *testing.T) {
+	ctx := context.Background()
+	db := openSchema(t)
+	for _, statement := range []string{
+		`CREATE EXTENSION IF NOT EXISTS "uuid-ossp"`,
+		`CREATE TABLE users (
+			id uuid DEFAULT uuid_generate_v4(), name varchar(100) NOT NULL, email varchar(100) NOT NULL,
+			password varchar(255) NOT NULL, role varchar(50) NOT NULL,
+			created_at timestamptz DEFAULT CURRENT_TIMESTAMP, updated_at timestamptz DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (id))`,
+		`CREATE UNIQUE INDEX idx_users_email ON users (email)`,
+		`CREATE TABLE password_reset_tokens (
+			id uuid DEFAULT uuid_generate_v4(), user_id uuid NOT NULL, token varchar(255) NOT NULL,
+			expires_at timestamptz NOT NULL, created_at timestamptz DEFAULT CURRENT_TIMESTAMP, PRIMARY KEY (id))`,
+		`CREATE UNIQUE INDEX idx_password_reset_tokens_token ON password_reset_tokens (token)`,
+		`INSERT INTO users (name, email, password, role) VALUES ('Ada', 'ada@example.com', 'hash', 'user')`,
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := RunMigrations(ctx, db, logging.Discard()); err != nil {
+		t.Fatal(err)
+	}
+
+	var user struct {
+		AuthSource string
+		Tenant     string
+		Disabled   bool
+	}
+	if err := db.Raw("SELECT auth_source, tenant, disabled FROM users WHERE email = ?", "ada@example.com").Scan(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.AuthSource != "local" || user.Tenant != "" || user.Disabled {
+		t.Fatalf("the existing user came through as %+v", user)
+	}
+	var indexes int64
+	if err := db.Raw("SELECT count(*) FROM pg_indexes WHERE schemaname = current_schema() AND indexname IN ('idx_users_tenant', 'idx_users_external_id', 'idx_users_phone_index')").Scan(&indexes).Error; err != nil {
+		t.Fatal(err)
+	}
+	if indexes != 3 {
+		t.Fatalf("%d of the new users indexes exist, want 3", indexes)
+	}
+}
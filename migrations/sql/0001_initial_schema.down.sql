@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS web_authn_challenges;
+DROP TABLE IF EXISTS web_authn_credentials;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS audit_logs;
+DROP TABLE IF EXISTS roles;
+DROP TABLE IF EXISTS password_reset_tokens;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,107 @@
+-- Baseline schema. Everything is IF NOT EXISTS so that databases previously managed by
+-- GORM AutoMigrate can adopt versioned migrations. Those only have the users and
+-- password_reset_tokens tables of the original models: CREATE TABLE IF NOT EXISTS leaves their
+-- users table as it is, so the columns added since are added below before they are indexed.
+
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+CREATE TABLE IF NOT EXISTS users (
+    id             uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name           varchar(100) NOT NULL,
+    email          varchar(100) NOT NULL,
+    password       varchar(255) NOT NULL,
+    role           varchar(50)  NOT NULL,
+    auth_source    varchar(20)  NOT NULL DEFAULT 'local',
+    external_id    varchar(255),
+    last_synced_at timestamptz,
+    tenant         varchar(100) NOT NULL DEFAULT '',
+    disabled       boolean      NOT NULL DEFAULT false,
+    created_at     timestamptz  DEFAULT CURRENT_TIMESTAMP,
+    updated_at     timestamptz  DEFAULT CURRENT_TIMESTAMP
+);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS auth_source varchar(20) NOT NULL DEFAULT 'local';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS external_id varchar(255);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_synced_at timestamptz;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tenant varchar(100) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled boolean NOT NULL DEFAULT false;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+CREATE INDEX IF NOT EXISTS idx_users_external_id ON users (external_id);
+CREATE INDEX IF NOT EXISTS idx_users_tenant ON users (tenant);
+
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id         uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id    uuid         NOT NULL,
+    token      varchar(255) NOT NULL,
+    expires_at timestamptz  NOT NULL,
+    created_at timestamptz  DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_password_reset_tokens_token ON password_reset_tokens (token);
+
+CREATE TABLE IF NOT EXISTS roles (
+    id          uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name        varchar(50)  NOT NULL,
+    tenant      varchar(100) NOT NULL DEFAULT '',
+    external_id varchar(255),
+    created_at  timestamptz  DEFAULT CURRENT_TIMESTAMP,
+    updated_at  timestamptz  DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_roles_tenant_name ON roles (name, tenant);
+
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id         uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    action     varchar(100) NOT NULL,
+    actor_id   uuid         NOT NULL,
+    subject_id uuid,
+    method     varchar(10),
+    path       varchar(255),
+    status     bigint,
+    ip_address varchar(64),
+    user_agent varchar(255),
+    details    text,
+    created_at timestamptz  DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_action ON audit_logs (action);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_actor_id ON audit_logs (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_subject_id ON audit_logs (subject_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs (created_at);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id           uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id      uuid        NOT NULL,
+    ip_address   varchar(64),
+    user_agent   varchar(255),
+    created_at   timestamptz DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at timestamptz NOT NULL,
+    expires_at   timestamptz NOT NULL,
+    revoked_at   timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
+
+CREATE TABLE IF NOT EXISTS web_authn_credentials (
+    id               uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id          uuid         NOT NULL,
+    name             varchar(100) NOT NULL,
+    credential_id    bytea        NOT NULL,
+    public_key       bytea        NOT NULL,
+    attestation_type varchar(50),
+    aa_guid          bytea,
+    sign_count       bigint       NOT NULL DEFAULT 0,
+    transports       varchar(255),
+    backup_eligible  boolean      NOT NULL DEFAULT false,
+    backup_state     boolean      NOT NULL DEFAULT false,
+    created_at       timestamptz  DEFAULT CURRENT_TIMESTAMP,
+    last_used_at     timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_web_authn_credentials_user_id ON web_authn_credentials (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_web_authn_credentials_credential_id ON web_authn_credentials (credential_id);
+
+CREATE TABLE IF NOT EXISTS web_authn_challenges (
+    id           uuid PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id      uuid,
+    ceremony     varchar(20) NOT NULL,
+    session_data text        NOT NULL,
+    expires_at   timestamptz NOT NULL,
+    created_at   timestamptz DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_web_authn_challenges_user_id ON web_authn_challenges (user_id);
+CREATE INDEX IF NOT EXISTS idx_web_authn_challenges_expires_at ON web_authn_challenges (expires_at);
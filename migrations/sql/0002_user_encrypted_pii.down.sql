@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_users_phone_index;
+ALTER TABLE users DROP COLUMN IF EXISTS date_of_birth;
+ALTER TABLE users DROP COLUMN IF EXISTS phone_index;
+ALTER TABLE users DROP COLUMN IF EXISTS phone;
@@ -0,0 +1,6 @@
+-- Encrypted personal data on users. The values are AES-GCM ciphertexts written by the
+-- "encrypted" GORM serializer; phone_index is an HMAC blind index for equality lookups.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS phone text;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS phone_index varchar(64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS date_of_birth text;
+CREATE INDEX IF NOT EXISTS idx_users_phone_index ON users (phone_index);
@@ -0,0 +1,11 @@
+-- Rate limiter state shared by every replica, one row per limited key. Rows past expires_at
+-- carry no information and are deleted periodically.
+CREATE TABLE IF NOT EXISTS rate_limits (
+    key          varchar(255)     PRIMARY KEY,
+    tokens       double precision NOT NULL DEFAULT 0,
+    count        integer          NOT NULL DEFAULT 0,
+    prev_count   integer          NOT NULL DEFAULT 0,
+    window_start timestamptz      NOT NULL,
+    expires_at   timestamptz      NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_rate_limits_expires_at ON rate_limits (expires_at);
@@ -0,0 +1,24 @@
+// models/audit_log.go
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditLog records a security-relevant action. ActorID is who performed it; SubjectID is the
+// account it was performed as or against, when different from the actor.
+type AuditLog struct {
+	ID        uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	Action    string     `gorm:"type:varchar(100);not null;index" json:"action"`
+	ActorID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"actor_id"`
+	SubjectID *uuid.UUID `gorm:"type:uuid;index" json:"subject_id,omitempty"`
+	Method    string     `gorm:"type:varchar(10)" json:"method,omitempty"`
+	Path      string     `gorm:"type:varchar(255)" json:"path,omitempty"`
+	Status    int        `json:"status,omitempty"`
+	IPAddress string     `gorm:"type:varchar(64)" json:"ip_address,omitempty"`
+	UserAgent string     `gorm:"type:varchar(255)" json:"user_agent,omitempty"`
+	Details   string     `gorm:"type:text" json:"details,omitempty"`
+	CreatedAt time.Time  `gorm:"default:CURRENT_TIMESTAMP;index" json:"created_at"`
+}
@@ -0,0 +1,19 @@
+// models/role.go
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Role is a named role that users can be assigned via User.Role. Roles are scoped to the tenant
+// that provisioned them and surface as SCIM groups.
+type Role struct {
+	ID         uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	Name       string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_roles_tenant_name" json:"name"`
+	Tenant     string    `gorm:"type:varchar(100);not null;default:'';uniqueIndex:idx_roles_tenant_name" json:"tenant"`
+	ExternalID string    `gorm:"type:varchar(255)" json:"external_id,omitempty"`
+	CreatedAt  time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
@@ -0,0 +1,26 @@
+// models/session.go
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is a single login of a user on a device. Tokens carry the session ID so that a
+// terminated session invalidates its token before the token itself expires.
+type Session struct {
+	ID         uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	IPAddress  string     `gorm:"type:varchar(64)" json:"ip_address"`
+	UserAgent  string     `gorm:"type:varchar(255)" json:"user_agent"`
+	CreatedAt  time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	LastSeenAt time.Time  `gorm:"not null" json:"last_seen_at"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// IsActive reports whether the session can still be used
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
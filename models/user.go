@@ -3,22 +3,55 @@ package models
 import (
 	"time"
 
+	"github.com/ABDULS21985/test-portal/encryption"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// Authentication sources a user account can originate from
+const (
+	AuthSourceLocal = "local"
+	AuthSourceLDAP  = "ldap"
+)
+
+// Roles the portal itself gives meaning to; RoleUser is what self-registered accounts get
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// User is a portal account. Phone and DateOfBirth are encrypted at rest, and PhoneIndex is a
+// blind index for equality lookups on the phone number. Email stays in plaintext on purpose: it
+// is the login name and must be unique, SCIM filters it with co and sw, lists sort by it, and
+// FindUsers searches it; a blind index only supports exact matches, so encrypting it would break
+// all of those.
 type User struct {
-	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
-	Name      string    `gorm:"type:varchar(100);not null" json:"name"`
-	Email     string    `gorm:"type:varchar(100);uniqueIndex;not null" json:"email"`
-	Password  string    `gorm:"type:varchar(255);not null" json:"-"`
-	Role      string    `gorm:"type:varchar(50);not null" json:"role"`
-	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
-	UpdatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+	ID           uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	Name         string     `gorm:"type:varchar(100);not null" json:"name" validate:"max=100"`
+	Email        string     `gorm:"type:varchar(100);uniqueIndex;not null" json:"email" validate:"required,email,max=100"`
+	Password     string     `gorm:"type:varchar(255);not null" json:"-"`
+	Role         string     `gorm:"type:varchar(50);not null" json:"role" validate:"max=50"`
+	AuthSource   string     `gorm:"type:varchar(20);not null;default:'local'" json:"auth_source"`
+	ExternalID   string     `gorm:"type:varchar(255);index" json:"external_id,omitempty"`
+	LastSyncedAt *time.Time `json:"last_synced_at,omitempty"`
+	Tenant       string     `gorm:"type:varchar(100);not null;default:'';index" json:"tenant,omitempty" validate:"max=100"`
+	Disabled     bool       `gorm:"not null;default:false" json:"disabled"`
+	Phone        string     `gorm:"type:text;serializer:encrypted" json:"phone,omitempty"`
+	PhoneIndex   string     `gorm:"type:varchar(64);index" json:"-"`
+	DateOfBirth  *time.Time `gorm:"type:text;serializer:encrypted" json:"date_of_birth,omitempty"`
+	CreatedAt    time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
 }
 
-// BeforeSave hook updates the UpdatedAt field before saving the record
+// BeforeSave hook updates the UpdatedAt field and the phone search hash before saving the record
 func (u *User) BeforeSave(tx *gorm.DB) (err error) {
 	u.UpdatedAt = time.Now()
+	u.PhoneIndex, err = encryption.BlindIndex(u.Phone)
 	return
 }
+
+// IsLocal reports whether the user's password is managed by the portal itself
+func (u *User) IsLocal() bool {
+	return u.AuthSource == "" || u.AuthSource == AuthSourceLocal
+}
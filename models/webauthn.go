@@ -0,0 +1,42 @@
+// models/webauthn.go
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebAuthn ceremonies a challenge can belong to
+const (
+	WebAuthnCeremonyRegistration = "registration"
+	WebAuthnCeremonyLogin        = "login" // passwordless, the passkey is the only factor
+	WebAuthnCeremonyMFA          = "mfa"   // second factor after a successful password check
+)
+
+// WebAuthnCredential is a passkey or security key registered to a user
+type WebAuthnCredential struct {
+	ID              uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	UserID          uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Name            string     `gorm:"type:varchar(100);not null" json:"name"`
+	CredentialID    []byte     `gorm:"type:bytea;uniqueIndex;not null" json:"-"`
+	PublicKey       []byte     `gorm:"type:bytea;not null" json:"-"`
+	AttestationType string     `gorm:"type:varchar(50)" json:"attestation_type"`
+	AAGUID          []byte     `gorm:"type:bytea" json:"-"`
+	SignCount       uint32     `gorm:"not null;default:0" json:"-"`
+	Transports      string     `gorm:"type:varchar(255)" json:"transports"`
+	BackupEligible  bool       `gorm:"not null;default:false" json:"backup_eligible"`
+	BackupState     bool       `gorm:"not null;default:false" json:"backup_state"`
+	CreatedAt       time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	LastUsedAt      *time.Time `json:"last_used_at,omitempty"`
+}
+
+// WebAuthnChallenge holds the server side state of an in-flight registration or login ceremony
+type WebAuthnChallenge struct {
+	ID          uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"id"`
+	UserID      *uuid.UUID `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	Ceremony    string     `gorm:"type:varchar(20);not null" json:"ceremony"`
+	SessionData string     `gorm:"type:text;not null" json:"-"`
+	ExpiresAt   time.Time  `gorm:"not null;index" json:"expires_at"`
+	CreatedAt   time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
@@ -0,0 +1,44 @@
+// openapi/coverage.go
+package openapi
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+)
+
+// Undocumented lists the routes of router, as "METHOD /path", that d has no operation for. A
+// HEAD route counts as documented when its GET is; a route matching any method is listed as
+// "* /path". Subrouter prefixes are skipped since they don't serve requests themselves.
+func Undocumented(router *mux.Router, d *Document) ([]string, error) {
+	var missing []string
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		if route.GetHandler() == nil {
+			return nil
+		}
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			// A route without methods answers every method, so none of them can be documented
+			missing = append(missing, "* "+path)
+			return nil
+		}
+
+		for _, method := range methods {
+			if _, ok := d.Operation(method, path); ok {
+				continue
+			}
+			if _, ok := d.Operation(http.MethodGet, path); ok && method == http.MethodHead {
+				continue
+			}
+			missing = append(missing, method+" "+path)
+		}
+		return nil
+	})
+	sort.Strings(missing)
+	return missing, err
+}
@@ -0,0 +1,153 @@
+// Renders the OpenAPI document served at /openapi.json, grouped by tag. Everything is built with
+// DOM calls so nothing in the document is interpreted as HTML.
+"use strict";
+
+const METHODS = ["get", "head", "post", "put", "patch", "delete", "options"];
+
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  for (const [name, value] of Object.entries(attrs || {})) {
+    node.setAttribute(name, value);
+  }
+  for (const child of children) {
+    if (child !== null && child !== undefined) {
+      node.append(child);
+    }
+  }
+  return node;
+}
+
+function refName(ref) {
+  return ref.slice(ref.lastIndexOf("/") + 1);
+}
+
+// describe summarises a schema on one line: its type, format and constraints
+function describe(schema) {
+  if (schema.$ref) {
+    return el("a", { href: "#schema-" + refName(schema.$ref) }, refName(schema.$ref));
+  }
+  if (schema.oneOf) {
+    const span = el("span");
+    schema.oneOf.forEach((option, i) => {
+      if (i > 0) span.append(" | ");
+      span.append(describe(option));
+    });
+    return span;
+  }
+  let text = schema.type || "any";
+  if (schema.type === "array" && schema.items) {
+    const span = el("span", {}, "array of ");
+    span.append(describe(schema.items));
+    return span;
+  }
+  if (schema.type === "object" && schema.additionalProperties) {
+    const span = el("span", {}, "map of ");
+    span.append(describe(schema.additionalProperties));
+    return span;
+  }
+  const notes = [];
+  if (schema.format) notes.push(schema.format);
+  if (schema.enum) notes.push("one of " + schema.enum.join(", "));
+  if (schema.minLength !== undefined) notes.push("min length " + schema.minLength);
+  if (schema.maxLength !== undefined) notes.push("max length " + schema.maxLength);
+  if (schema.minItems !== undefined) notes.push("min items " + schema.minItems);
+  if (schema.maxItems !== undefined) notes.push("max items " + schema.maxItems);
+  if (schema.minimum !== undefined) notes.push("min " + schema.minimum);
+  if (schema.maximum !== undefined) notes.push("max " + schema.maximum);
+  if (notes.length) text += " (" + notes.join("; ") + ")";
+  return text;
+}
+
+// properties renders an object schema's fields as a table
+function properties(schema) {
+  if (!schema.properties) {
+    return el("p", {}, describe(schema));
+  }
+  const required = new Set(schema.required || []);
+  const body = el("tbody");
+  for (const [name, property] of Object.entries(schema.properties)) {
+    body.append(el("tr", {},
+      el("td", {}, el("code", {}, name), required.has(name) ? el("span", { class: "required" }, " required") : null),
+      el("td", {}, describe(property)),
+      el("td", {}, property.description || "")));
+  }
+  return el("table", {}, el("thead", {}, el("tr", {}, el("th", {}, "Field"), el("th", {}, "Type"), el("th", {}, ""))), body);
+}
+
+function content(media) {
+  const list = el("div");
+  for (const [type, entry] of Object.entries(media || {})) {
+    list.append(el("p", { class: "media" }, type + ": "), el("div", {}, properties(entry.schema || {})));
+  }
+  return list;
+}
+
+function operation(method, path, op) {
+  const details = el("details", { class: "operation " + method, id: op.operationId || "" },
+    el("summary", {},
+      el("span", { class: "method" }, method.toUpperCase()),
+      el("code", { class: "path" }, path),
+      el("span", { class: "summary" }, op.summary || ""),
+      op.security && op.security.length ? el("span", { class: "lock", title: "Requires authentication" }, "🔒") : null,
+      op.deprecated ? el("span", { class: "deprecated" }, "deprecated") : null));
+
+  if (op.description) details.append(el("p", {}, op.description));
+  if (op.security && op.security.length) {
+    details.append(el("p", {}, "Authentication: " + op.security.map((s) => Object.keys(s).join(" + ")).join(" or ")));
+  }
+  if (op.parameters && op.parameters.length) {
+    const body = el("tbody");
+    for (const p of op.parameters) {
+      body.append(el("tr", {}, el("td", {}, el("code", {}, p.name)), el("td", {}, p.in), el("td", {}, describe(p.schema || {})), el("td", {}, p.description || "")));
+    }
+    details.append(el("h4", {}, "Parameters"), el("table", {}, body));
+  }
+  if (op.requestBody) {
+    details.append(el("h4", {}, "Request body"), content(op.requestBody.content));
+  }
+  details.append(el("h4", {}, "Responses"));
+  for (const [status, response] of Object.entries(op.responses || {})) {
+    details.append(el("div", { class: "response" }, el("strong", {}, status + " "), response.description), content(response.content));
+  }
+  return details;
+}
+
+function render(spec) {
+  document.title = spec.info.title + " " + spec.info.version;
+  document.getElementById("title").textContent = spec.info.title + " " + spec.info.version;
+  document.getElementById("description").textContent = spec.info.description || "";
+
+  const groups = new Map((spec.tags || []).map((tag) => [tag.name, { tag, operations: [] }]));
+  for (const path of Object.keys(spec.paths).sort()) {
+    for (const method of METHODS) {
+      const op = spec.paths[path][method];
+      if (!op) continue;
+      const name = (op.tags && op.tags[0]) || "other";
+      if (!groups.has(name)) groups.set(name, { tag: { name }, operations: [] });
+      groups.get(name).operations.push(operation(method, path, op));
+    }
+  }
+
+  const main = document.getElementById("operations");
+  main.replaceChildren();
+  for (const { tag, operations } of groups.values()) {
+    if (!operations.length) continue;
+    main.append(el("section", {}, el("h2", {}, tag.name), tag.description ? el("p", {}, tag.description) : null, ...operations));
+  }
+
+  const schemas = el("section", {}, el("h2", {}, "Schemas"));
+  for (const name of Object.keys(spec.components.schemas || {}).sort()) {
+    schemas.append(el("div", { class: "schema", id: "schema-" + name }, el("h3", {}, name), properties(spec.components.schemas[name])));
+  }
+  main.append(schemas);
+}
+
+fetch("../openapi.json")
+  .then((response) => {
+    if (!response.ok) throw new Error(response.status + " " + response.statusText);
+    return response.json();
+  })
+  .then(render)
+  .catch((err) => {
+    document.getElementById("operations").replaceChildren(el("p", { class: "error" }, "Could not load the API document: " + err.message));
+  });
@@ -0,0 +1,49 @@
+// openapi/handler.go
+package openapi
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"sync"
+)
+
+// docsCSP lets the docs page load its own script and styles and fetch the document, which the
+// API-wide policy forbids
+const docsCSP = "default-src 'none'; script-src 'self'; style-src 'self'; connect-src 'self'; img-src 'self' data:; frame-ancestors 'none'"
+
+//go:embed docs
+var docsFiles embed.FS
+
+// Handler serves d as JSON. It is encoded on the first request, by when every module has
+// documented its routes.
+func (d *Document) Handler() http.Handler {
+	var once sync.Once
+	var body []byte
+	var err error
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() { body, err = json.MarshalIndent(d, "", "  ") })
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(body)
+	})
+}
+
+// DocsHandler serves the interactive documentation page under prefix, such as "/docs/". The
+// page reads the document from /openapi.json.
+func DocsHandler(prefix string) http.Handler {
+	files, err := fs.Sub(docsFiles, "docs")
+	if err != nil {
+		panic(err)
+	}
+	fileServer := http.StripPrefix(prefix, http.FileServerFS(files))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", docsCSP)
+		fileServer.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,205 @@
+// openapi/openapi.go
+package openapi
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/ABDULS21985/test-portal/utils"
+)
+
+// Version is the OpenAPI version documents are written in
+const Version = "3.1.0"
+
+// Document is an OpenAPI document. Only the parts the portal uses are modelled.
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Tags       []Tag                `json:"tags,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+
+	// schemaTypes remembers which Go type each component schema was generated from
+	schemaTypes map[string]string
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Tag groups operations in the docs
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations on one path, by lower-case method
+type PathItem map[string]*Operation
+
+// Operation documents one method on one path
+type Operation struct {
+	OperationID string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+// Parameter is a path, query or header parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes the body an operation accepts
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+// Response describes one response of an operation
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType is the schema of a body in one content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds the reusable schemas and the security schemes
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how a client authenticates
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// New creates an empty document
+func New(info Info) *Document {
+	return &Document{
+		OpenAPI:     Version,
+		Info:        info,
+		Paths:       map[string]*PathItem{},
+		Components:  Components{Schemas: map[string]*Schema{}, SecuritySchemes: map[string]*SecurityScheme{}},
+		schemaTypes: map[string]string{},
+	}
+}
+
+// pathParam matches a mux path variable, with or without a pattern
+var pathParam = regexp.MustCompile(`\{([^{}:]+)(?::[^{}]*)?\}`)
+
+// Add documents method on path, a mux path template. Path variables are declared as string
+// parameters unless op declares them, and operations without a default response get the
+// problem details one.
+func (d *Document) Add(method, path string, op Operation) {
+	path = pathParam.ReplaceAllString(path, "{$1}")
+	for _, match := range pathParam.FindAllStringSubmatch(path, -1) {
+		if !hasParameter(op.Parameters, match[1], "path") {
+			op.Parameters = append(op.Parameters, Parameter{Name: match[1], In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+	if op.Responses == nil {
+		op.Responses = map[string]*Response{}
+	}
+	if _, ok := op.Responses["default"]; !ok {
+		op.Responses["default"] = d.Problem("Error")
+	}
+	if op.OperationID == "" {
+		op.OperationID = operationID(method, path)
+	}
+
+	item := d.Paths[path]
+	if item == nil {
+		item = &PathItem{}
+		d.Paths[path] = item
+	}
+	(*item)[strings.ToLower(method)] = &op
+}
+
+// Operation returns the documented operation for method on path, if any
+func (d *Document) Operation(method, path string) (*Operation, bool) {
+	item, ok := d.Paths[pathParam.ReplaceAllString(path, "{$1}")]
+	if !ok {
+		return nil, false
+	}
+	op, ok := (*item)[strings.ToLower(method)]
+	return op, ok
+}
+
+// Body is a required request body of content type application/json described by v
+func (d *Document) Body(v any) *RequestBody {
+	return d.BodyAs("application/json", v)
+}
+
+// BodyAs is a required request body of the given content type described by v
+func (d *Document) BodyAs(contentType string, v any) *RequestBody {
+	return &RequestBody{Required: true, Content: map[string]MediaType{contentType: {Schema: d.Schema(v)}}}
+}
+
+// JSON is a response with an application/json body described by v
+func (d *Document) JSON(description string, v any) *Response {
+	return d.ResponseAs("application/json", description, v)
+}
+
+// ResponseAs is a response whose body of the given content type is described by v
+func (d *Document) ResponseAs(contentType, description string, v any) *Response {
+	return &Response{Description: description, Content: map[string]MediaType{contentType: {Schema: d.Schema(v)}}}
+}
+
+// Empty is a response without a body
+func Empty(description string) *Response {
+	return &Response{Description: description}
+}
+
+// Problem is an RFC 7807 problem details response
+func (d *Document) Problem(description string) *Response {
+	return &Response{Description: description, Content: map[string]MediaType{
+		utils.ProblemContentType: {Schema: d.Schema(utils.Problem{})},
+	}}
+}
+
+// Secured is the security requirement of an operation authenticated with one of schemes
+func Secured(schemes ...string) []map[string][]string {
+	requirements := make([]map[string][]string, len(schemes))
+	for i, scheme := range schemes {
+		requirements[i] = map[string][]string{scheme: {}}
+	}
+	return requirements
+}
+
+func hasParameter(params []Parameter, name, in string) bool {
+	for _, p := range params {
+		if p.Name == name && p.In == in {
+			return true
+		}
+	}
+	return false
+}
+
+// operationID derives an ID such as "post_api_users_register" or "get_api_users_id"
+func operationID(method, path string) string {
+	id := strings.ToLower(method)
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '{' || r == '}' || r == '-' || r == '.' }) {
+		id += "_" + part
+	}
+	return id
+}
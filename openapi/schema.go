@@ -0,0 +1,220 @@
+// openapi/schema.go
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// Schema is a JSON Schema (draft 2020-12, as used by OpenAPI 3.1)
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+}
+
+// OneOf is a schema matching exactly one of schemas
+func OneOf(schemas ...*Schema) *Schema {
+	return &Schema{OneOf: schemas}
+}
+
+// Ref refers to the component schema name
+func (d *Document) Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+var (
+	timeType            = reflect.TypeFor[time.Time]()
+	uuidType            = reflect.TypeFor[uuid.UUID]()
+	rawMessageType      = reflect.TypeFor[json.RawMessage]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// Schema describes the JSON encoding of v, which may also be a *Schema to use as is. Named
+// struct types become component schemas referred to by name; fields are described from their
+// json tags, and their validate tags (as checked by utils.Validate) add the constraints.
+func (d *Document) Schema(v any) *Schema {
+	if schema, ok := v.(*Schema); ok {
+		return schema
+	}
+	return d.schemaFor(reflect.TypeOf(v))
+}
+
+func (d *Document) schemaFor(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	case t == rawMessageType:
+		return &Schema{}
+	case reflect.PointerTo(t).Implements(textUnmarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t)
+		}
+		return d.component(t)
+	default:
+		// interface{} and anything else encoding/json decides at run time
+		return &Schema{}
+	}
+}
+
+// component registers the schema of a named struct type, once, and refers to it
+func (d *Document) component(t reflect.Type) *Schema {
+	typeName := t.PkgPath() + "." + t.Name()
+	name := t.Name()
+	if existing, ok := d.schemaTypes[name]; ok && existing != typeName {
+		// Another package's type took the plain name, e.g. scim.User next to models.User
+		name = exportedName(path.Base(t.PkgPath())) + name
+	}
+	if _, ok := d.schemaTypes[name]; !ok {
+		d.schemaTypes[name] = typeName
+		// Registered before its fields are described, so self references terminate
+		d.Components.Schemas[name] = &Schema{}
+		*d.Components.Schemas[name] = *d.structSchema(t)
+	}
+	return d.Ref(name)
+}
+
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	d.addFields(schema, t)
+	return schema
+}
+
+func (d *Document) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			for embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				d.addFields(schema, embedded)
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := d.schemaFor(field.Type)
+		if rules := field.Tag.Get("validate"); rules != "" {
+			var required bool
+			property, required = constrain(property, rules)
+			if required {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+		schema.Properties[name] = property
+	}
+}
+
+// constrain applies validate rules to a copy of schema, and reports whether the value is required
+func constrain(schema *Schema, rules string) (*Schema, bool) {
+	if schema.Ref != "" {
+		return schema, strings.Contains(","+rules+",", ",required,")
+	}
+	constrained := *schema
+	required := false
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+		case "email":
+			constrained.Format = "email"
+		case "uuid":
+			constrained.Format = "uuid"
+		case "oneof":
+			constrained.Enum = strings.Fields(arg)
+		case "min", "max":
+			n, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				continue
+			}
+			bound, count := n, int(n)
+			switch {
+			case constrained.Type == "string" && name == "min":
+				constrained.MinLength = &count
+			case constrained.Type == "string":
+				constrained.MaxLength = &count
+			case constrained.Type == "array" && name == "min":
+				constrained.MinItems = &count
+			case constrained.Type == "array":
+				constrained.MaxItems = &count
+			case name == "min":
+				constrained.Minimum = &bound
+			default:
+				constrained.Maximum = &bound
+			}
+		}
+	}
+	return &constrained, required
+}
+
+func exportedName(s string) string {
+	if s == "" {
+		return s
+	}
+	runes := []rune(s)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
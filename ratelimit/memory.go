@@ -0,0 +1,59 @@
+// ratelimit/memory.go
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepEvery is how many hits MemoryStore takes between sweeps of expired keys
+const sweepEvery = 1024
+
+// MemoryStore keeps limits in process memory, so each replica enforces them on its own
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	hits    int
+}
+
+type memoryEntry struct {
+	state     state
+	expiresAt time.Time
+}
+
+// NewMemoryStore returns an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry)}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.hits++
+	if s.hits%sweepEvery == 0 {
+		s.sweep(now)
+	}
+
+	entry := s.entries[key]
+	if now.After(entry.expiresAt) {
+		entry.state = state{}
+	}
+	next, result := limit.take(entry.state, now)
+	s.entries[key] = memoryEntry{state: next, expiresAt: now.Add(limit.ttl())}
+	return result, nil
+}
+
+// sweep drops the keys whose state has expired
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, entry := range s.entries {
+		if now.After(entry.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// ratelimit/memory_test.go
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testStore checks the behaviour every Store must have. Keys start with prefix so runs against
+// a shared database don't see each other.
+func testStore(t *testing.T, store Store, prefix string) {
+	ctx := context.Background()
+	limit := Limit{Algorithm: TokenBucket, Requests: 2, Period: time.Hour}
+
+	t.Run("keys are limited separately", func(t *testing.T) {
+		for _, want := range []bool{true, true, false} {
+			result, err := store.Take(ctx, prefix+"alice", limit, epoch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Allowed != want {
+				t.Fatalf("alice allowed = %v, want %v", result.Allowed, want)
+			}
+		}
+		result, err := store.Take(ctx, prefix+"bob", limit, epoch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Allowed || result.Remaining != 1 {
+			t.Fatalf("bob's first hit = %+v", result)
+		}
+	})
+
+	t.Run("state carries over between hits", func(t *testing.T) {
+		window := Limit{Algorithm: SlidingWindow, Requests: 2, Period: 10 * time.Second}
+		var results []Result
+		for _, offset := range []time.Duration{0, time.Second, 2 * time.Second, 12 * time.Second} {
+			result, err := store.Take(ctx, prefix+"window", window, at(offset))
+			if err != nil {
+				t.Fatal(err)
+			}
+			results = append(results, result)
+		}
+		if !results[0].Allowed || !results[1].Allowed || results[2].Allowed || results[3].Allowed {
+			t.Fatalf("results = %+v, want allowed, allowed, refused, refused", results)
+		}
+	})
+
+	t.Run("idle keys start afresh", func(t *testing.T) {
+		window := Limit{Algorithm: SlidingWindow, Requests: 1, Period: 10 * time.Second}
+		if _, err := store.Take(ctx, prefix+"expiring", window, epoch); err != nil {
+			t.Fatal(err)
+		}
+		// Past the TTL the stored state, expired or swept, no longer counts
+		result, err := store.Take(ctx, prefix+"expiring", window, epoch.Add(window.ttl()+window.Period))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Allowed {
+			t.Fatalf("hit after expiry = %+v", result)
+		}
+	})
+
+	t.Run("concurrent first hits", func(t *testing.T) {
+		burst := Limit{Algorithm: TokenBucket, Requests: 5, Period: time.Hour}
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			allowed int
+		)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				result, err := store.Take(ctx, prefix+"burst", burst, epoch)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if result.Allowed {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		if allowed != burst.Requests {
+			t.Fatalf("%d of 20 concurrent hits allowed, want %d", allowed, burst.Requests)
+		}
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := store.Take(cancelled, prefix+"cancelled", limit, epoch); err == nil {
+			t.Fatal("a cancelled hit was counted")
+		}
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore(), "")
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Algorithm: TokenBucket, Requests: 1, Period: time.Minute}
+	ctx := context.Background()
+	for _, key := range []string{"old", "new"} {
+		now := epoch
+		if key == "new" {
+			now = epoch.Add(limit.ttl())
+		}
+		if _, err := store.Take(ctx, key, limit, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store.sweep(epoch.Add(limit.ttl() + time.Second))
+	if _, ok := store.entries["old"]; ok {
+		t.Fatal("an expired key survived the sweep")
+	}
+	if _, ok := store.entries["new"]; !ok {
+		t.Fatal("a live key was swept")
+	}
+}
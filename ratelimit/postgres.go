@@ -0,0 +1,89 @@
+// ratelimit/postgres.go
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PostgresStore keeps limits in the rate_limits table, so every replica enforces them together
+type PostgresStore struct {
+	db              *gorm.DB
+	cleanupInterval time.Duration
+	logger          *slog.Logger
+}
+
+// NewPostgresStore returns a store on db that deletes expired rows every cleanupInterval once
+// started
+func NewPostgresStore(db *gorm.DB, cleanupInterval time.Duration, logger *slog.Logger) *PostgresStore {
+	return &PostgresStore{db: db, cleanupInterval: cleanupInterval, logger: logger}
+}
+
+// rateLimitRow is one key's state in the rate_limits table
+type rateLimitRow struct {
+	Key         string
+	Tokens      float64
+	Count       int
+	PrevCount   int
+	WindowStart time.Time
+	ExpiresAt   time.Time
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	var result Result
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Make sure the row exists so concurrent first hits queue on its lock instead of racing
+		err := tx.Exec(`INSERT INTO rate_limits (key, window_start, expires_at) VALUES (?, ?, ?)
+			ON CONFLICT (key) DO NOTHING`, key, time.Time{}, now).Error
+		if err != nil {
+			return err
+		}
+
+		var row rateLimitRow
+		err = tx.Raw(`SELECT key, tokens, count, prev_count, window_start, expires_at
+			FROM rate_limits WHERE key = ? FOR UPDATE`, key).Scan(&row).Error
+		if err != nil {
+			return err
+		}
+
+		current := state{Tokens: row.Tokens, Count: row.Count, PrevCount: row.PrevCount, Start: row.WindowStart}
+		if !now.Before(row.ExpiresAt) {
+			current = state{}
+		}
+		var next state
+		next, result = limit.take(current, now)
+
+		return tx.Exec(`UPDATE rate_limits
+			SET tokens = ?, count = ?, prev_count = ?, window_start = ?, expires_at = ?
+			WHERE key = ?`, next.Tokens, next.Count, next.PrevCount, next.Start, now.Add(limit.ttl()), key).Error
+	})
+	return result, err
+}
+
+// Start deletes expired rows every cleanup interval until stop is closed
+func (s *PostgresStore) Start(stop <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	ticker := time.NewTicker(s.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := s.db.WithContext(ctx).Exec(`DELETE FROM rate_limits WHERE expires_at < ?`, time.Now()).Error
+			if err != nil && ctx.Err() == nil {
+				s.logger.Error("rate limit cleanup failed", slog.Any("error", err))
+			}
+		case <-stop:
+			return
+		}
+	}
+}
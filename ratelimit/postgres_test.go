@@ -0,0 +1,54 @@
+// ratelimit/postgres_test.go
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/logging"
+	"github.com/ABDULS21985/test-portal/migrations"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// TestPostgresStore runs the store checks against the database named by TEST_DATABASE_URL,
+// which it migrates; it is skipped when that isn't set.
+func TestPostgresStore(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logging.NewGormLogger(logging.Discard(), 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := migrations.RunMigrations(context.Background(), db, logging.Discard()); err != nil {
+		t.Fatal(err)
+	}
+
+	prefix := fmt.Sprintf("test-%d:", time.Now().UnixNano())
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM rate_limits WHERE key LIKE ?", prefix+"%")
+	})
+	store := NewPostgresStore(db, time.Minute, logging.Discard())
+	testStore(t, store, prefix)
+
+	// The first hit on a key inserts its row
+	var count int64
+	if err := db.Table("rate_limits").Where("key = ?", prefix+"bob").Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("%d rows for a key that was hit once, want 1", count)
+	}
+}
@@ -0,0 +1,162 @@
+// ratelimit/ratelimit.go
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Algorithm decides how hits are counted against a limit
+type Algorithm string
+
+const (
+	// TokenBucket allows bursts of up to Requests, refilled evenly over Period
+	TokenBucket Algorithm = "token_bucket"
+	// SlidingWindow allows Requests in any Period, estimated from the current and previous
+	// fixed windows
+	SlidingWindow Algorithm = "sliding_window"
+)
+
+// Limit is how many requests a key may make per period
+type Limit struct {
+	Algorithm Algorithm
+	Requests  int
+	Period    time.Duration
+}
+
+// ParseLimit parses "<requests>/<period>[ <algorithm>]", e.g. "10/1m" or "100/1h sliding_window".
+// The algorithm defaults to TokenBucket.
+func ParseLimit(spec string) (Limit, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields) > 2 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q, want <requests>/<period>[ <algorithm>]", spec)
+	}
+
+	requests, period, ok := strings.Cut(fields[0], "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q, want <requests>/<period>", spec)
+	}
+	limit := Limit{Algorithm: TokenBucket}
+	var err error
+	if limit.Requests, err = strconv.Atoi(requests); err != nil || limit.Requests < 1 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: requests must be a positive number", spec)
+	}
+	if limit.Period, err = time.ParseDuration(period); err != nil || limit.Period <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: period must be a positive duration", spec)
+	}
+
+	if len(fields) == 2 {
+		limit.Algorithm = Algorithm(fields[1])
+		if limit.Algorithm != TokenBucket && limit.Algorithm != SlidingWindow {
+			return Limit{}, fmt.Errorf("invalid rate limit %q: unknown algorithm %q", spec, fields[1])
+		}
+	}
+	return limit, nil
+}
+
+// Policy renders the limit for the RateLimit-Policy header, e.g. "10;w=60"
+func (l Limit) Policy() string {
+	return strconv.Itoa(l.Requests) + ";w=" + strconv.Itoa(int(math.Ceil(l.Period.Seconds())))
+}
+
+// Result is the outcome of one hit
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // how long until a hit would be allowed, when it wasn't
+	Reset      time.Duration // how long until the bucket is full again or the current window ends
+}
+
+// Store records hits and decides whether they are allowed, atomically per key, so concurrent
+// requests (or replicas sharing the store) can't exceed a limit together
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// state is what a store keeps per key
+type state struct {
+	Tokens    float64   // token bucket: tokens left at Start
+	Count     int       // sliding window: hits in the window beginning at Start
+	PrevCount int       // sliding window: hits in the window before it
+	Start     time.Time // token bucket: last refill; sliding window: current window start
+}
+
+// take applies one hit to s, returning the new state and the result
+func (l Limit) take(s state, now time.Time) (state, Result) {
+	if l.Algorithm == SlidingWindow {
+		return l.takeSlidingWindow(s, now)
+	}
+	return l.takeTokenBucket(s, now)
+}
+
+func (l Limit) takeTokenBucket(s state, now time.Time) (state, Result) {
+	capacity := float64(l.Requests)
+	perSecond := capacity / l.Period.Seconds()
+
+	tokens := capacity
+	if !s.Start.IsZero() {
+		tokens = math.Min(capacity, s.Tokens+now.Sub(s.Start).Seconds()*perSecond)
+	}
+	result := Result{Limit: l.Requests}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - tokens) / perSecond)
+	}
+	result.Remaining = int(tokens)
+	result.Reset = seconds((capacity - tokens) / perSecond)
+	return state{Tokens: tokens, Start: now}, result
+}
+
+func (l Limit) takeSlidingWindow(s state, now time.Time) (state, Result) {
+	windowStart := now.Truncate(l.Period)
+	switch {
+	case s.Start.Equal(windowStart):
+	case s.Start.Equal(windowStart.Add(-l.Period)):
+		s = state{PrevCount: s.Count, Start: windowStart}
+	default:
+		s = state{Start: windowStart}
+	}
+
+	// The previous window counts in proportion to how much of it the sliding window still covers
+	elapsed := now.Sub(windowStart)
+	prevWeight := 1 - elapsed.Seconds()/l.Period.Seconds()
+	estimate := float64(s.PrevCount)*prevWeight + float64(s.Count)
+
+	result := Result{Limit: l.Requests, Reset: windowStart.Add(l.Period).Sub(now)}
+	if estimate+1 <= float64(l.Requests) {
+		s.Count++
+		result.Allowed = true
+		result.Remaining = int(float64(l.Requests) - estimate - 1)
+		return s, result
+	}
+
+	// Wait for enough of the previous window to slide out, or for the current one to end
+	// and its own hits to start sliding out
+	room := float64(l.Requests - 1 - s.Count)
+	if room >= 0 && s.PrevCount > 0 {
+		result.RetryAfter = seconds(l.Period.Seconds()*(1-room/float64(s.PrevCount))) - elapsed
+	} else {
+		untilNext := windowStart.Add(l.Period).Sub(now)
+		result.RetryAfter = untilNext + seconds(l.Period.Seconds()*(1-float64(l.Requests-1)/float64(s.Count)))
+	}
+	if result.RetryAfter < 0 {
+		result.RetryAfter = 0
+	}
+	return s, result
+}
+
+// ttl is how long a key's state matters; after it, a fresh state gives the same answer
+func (l Limit) ttl() time.Duration {
+	return 2 * l.Period
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,136 @@
+// ratelimit/ratelimit_test.go
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// epoch is aligned to every period used below, so sliding windows start on it
+var epoch = time.Unix(1_700_000_000, 0).UTC()
+
+func at(offset time.Duration) time.Time {
+	return epoch.Add(offset)
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Limit
+		wantErr bool
+	}{
+		{spec: "10/1m", want: Limit{Algorithm: TokenBucket, Requests: 10, Period: time.Minute}},
+		{spec: " 100/1h   sliding_window ", want: Limit{Algorithm: SlidingWindow, Requests: 100, Period: time.Hour}},
+		{spec: "5/30s token_bucket", want: Limit{Algorithm: TokenBucket, Requests: 5, Period: 30 * time.Second}},
+		{spec: "", wantErr: true},
+		{spec: "10", wantErr: true},
+		{spec: "0/1m", wantErr: true},
+		{spec: "ten/1m", wantErr: true},
+		{spec: "10/0s", wantErr: true},
+		{spec: "10/minute", wantErr: true},
+		{spec: "10/1m leaky_bucket", wantErr: true},
+		{spec: "10/1m token_bucket extra", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseLimit(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parsed as %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	for limit, want := range map[Limit]string{
+		{Requests: 10, Period: time.Minute}:            "10;w=60",
+		{Requests: 3, Period: 1500 * time.Millisecond}: "3;w=2",
+	} {
+		if got := limit.Policy(); got != want {
+			t.Errorf("%+v: Policy() = %q, want %q", limit, got, want)
+		}
+	}
+}
+
+// hit is one request at an offset from epoch and what the limiter should answer
+type hit struct {
+	at         time.Duration
+	allowed    bool
+	remaining  int
+	retryAfter time.Duration
+	reset      time.Duration
+}
+
+// replay applies hits in order to a fresh state. Every refused hit is also checked against
+// its RetryAfter: a retry that early is allowed, one a little earlier is not.
+func replay(t *testing.T, limit Limit, hits []hit) {
+	t.Helper()
+	var s state
+	for i, want := range hits {
+		before := s
+		next, got := limit.take(s, at(want.at))
+		s = next
+		if got.Allowed != want.allowed || got.Remaining != want.remaining || got.RetryAfter != want.retryAfter || got.Reset != want.reset || got.Limit != limit.Requests {
+			t.Fatalf("hit %d at %v = %+v, want %+v", i, want.at, got, want)
+		}
+		if got.Allowed {
+			continue
+		}
+		if _, retry := limit.take(before, at(want.at+got.RetryAfter)); !retry.Allowed {
+			t.Fatalf("hit %d: a retry after %v was refused: %+v", i, got.RetryAfter, retry)
+		}
+		if _, early := limit.take(before, at(want.at+got.RetryAfter-10*time.Millisecond)); early.Allowed {
+			t.Fatalf("hit %d: a retry before %v was allowed", i, got.RetryAfter)
+		}
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	limit := Limit{Algorithm: TokenBucket, Requests: 3, Period: 3 * time.Second}
+	replay(t, limit, []hit{
+		// A full bucket takes a burst of Requests at once
+		{at: 0, allowed: true, remaining: 2, reset: time.Second},
+		{at: 0, allowed: true, remaining: 1, reset: 2 * time.Second},
+		{at: 0, allowed: true, remaining: 0, reset: 3 * time.Second},
+		{at: 0, allowed: false, remaining: 0, retryAfter: time.Second, reset: 3 * time.Second},
+		// Tokens refill evenly, one per second here
+		{at: 500 * time.Millisecond, allowed: false, remaining: 0, retryAfter: 500 * time.Millisecond, reset: 2500 * time.Millisecond},
+		{at: time.Second, allowed: true, remaining: 0, reset: 3 * time.Second},
+		{at: 3 * time.Second, allowed: true, remaining: 1, reset: 2 * time.Second},
+		// An idle bucket refills to capacity, never beyond
+		{at: time.Minute, allowed: true, remaining: 2, reset: time.Second},
+		{at: time.Minute, allowed: true, remaining: 1, reset: 2 * time.Second},
+		{at: time.Minute, allowed: true, remaining: 0, reset: 3 * time.Second},
+		{at: time.Minute, allowed: false, remaining: 0, retryAfter: time.Second, reset: 3 * time.Second},
+	})
+}
+
+func TestSlidingWindow(t *testing.T) {
+	limit := Limit{Algorithm: SlidingWindow, Requests: 4, Period: 10 * time.Second}
+	replay(t, limit, []hit{
+		{at: 0, allowed: true, remaining: 3, reset: 10 * time.Second},
+		{at: 0, allowed: true, remaining: 2, reset: 10 * time.Second},
+		{at: time.Second, allowed: true, remaining: 1, reset: 9 * time.Second},
+		{at: time.Second, allowed: true, remaining: 0, reset: 9 * time.Second},
+		// Full with no previous window: wait for this window to end and then for a quarter of
+		// its hits to slide out, 9s + 2.5s
+		{at: time.Second, allowed: false, remaining: 0, retryAfter: 11500 * time.Millisecond, reset: 9 * time.Second},
+		// Rollover: 2.5s into the next window the previous one still weighs 0.75 * 4 = 3
+		{at: 12400 * time.Millisecond, allowed: false, remaining: 0, retryAfter: 100 * time.Millisecond, reset: 7600 * time.Millisecond},
+		{at: 12500 * time.Millisecond, allowed: true, remaining: 0, reset: 7500 * time.Millisecond},
+		// Now the previous window has to slide out until 3 - 1 = 2 of its 4 hits are left
+		{at: 12500 * time.Millisecond, allowed: false, remaining: 0, retryAfter: 2500 * time.Millisecond, reset: 7500 * time.Millisecond},
+		{at: 15 * time.Second, allowed: true, remaining: 0, reset: 5 * time.Second},
+		// After a whole idle window nothing carries over
+		{at: 35 * time.Second, allowed: true, remaining: 3, reset: 5 * time.Second},
+	})
+}
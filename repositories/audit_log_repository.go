@@ -0,0 +1,40 @@
+// repositories/audit_log_repository.go
+package repositories
+
+import (
+	"context"
+	"github.com/ABDULS21985/test-portal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AuditLogRepository defines the contract for audit log persistence
+type AuditLogRepository interface {
+	CreateEntry(ctx context.Context, entry *models.AuditLog) error
+	ListBySubject(ctx context.Context, subjectID uuid.UUID, opts ListOptions) ([]models.AuditLog, error)
+}
+
+type auditLogRepository struct {
+	db *gorm.DB
+}
+
+// NewAuditLogRepository creates a new instance of auditLogRepository
+func NewAuditLogRepository(db *gorm.DB) AuditLogRepository {
+	return &auditLogRepository{
+		db: db,
+	}
+}
+
+func (r *auditLogRepository) CreateEntry(ctx context.Context, entry *models.AuditLog) error {
+	return translateError(r.db.WithContext(ctx).Create(entry).Error, "audit log entry")
+}
+
+func (r *auditLogRepository) ListBySubject(ctx context.Context, subjectID uuid.UUID, opts ListOptions) ([]models.AuditLog, error) {
+	var entries []models.AuditLog
+	query := applyListOptions(r.db.WithContext(ctx).Where("subject_id = ?", subjectID).Order("created_at DESC"), opts)
+	if err := query.Find(&entries).Error; err != nil {
+		return nil, translateError(err, "audit log entry")
+	}
+	return entries, nil
+}
@@ -0,0 +1,125 @@
+// repositories/criteria.go
+package repositories
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ABDULS21985/test-portal/apperrors"
+
+	"gorm.io/gorm"
+)
+
+// Criteria is a storage-agnostic filter expression over a model's columns.
+// Exactly one of the comparison (Field/Op), And, Or or Not forms is populated.
+type Criteria struct {
+	Field string // column name
+	Op    string // eq, ne, co, sw, ew, gt, ge, lt, le, pr
+	Value interface{}
+
+	And []Criteria
+	Or  []Criteria
+	Not *Criteria
+}
+
+// ListOptions controls pagination of list queries
+type ListOptions struct {
+	Offset int
+	Limit  int
+}
+
+// applyCriteria adds the criteria as a WHERE clause, rejecting any column not in allowed.
+// The returned query is a new session so it can be reused for both a count and a page fetch.
+func applyCriteria(db *gorm.DB, criteria *Criteria, allowed map[string]bool) (*gorm.DB, error) {
+	if criteria != nil {
+		sql, args, err := buildCriteria(*criteria, allowed)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where(sql, args...)
+	}
+	return db.Session(&gorm.Session{}), nil
+}
+
+func applyListOptions(db *gorm.DB, opts ListOptions) *gorm.DB {
+	if opts.Offset > 0 {
+		db = db.Offset(opts.Offset)
+	}
+	if opts.Limit > 0 {
+		db = db.Limit(opts.Limit)
+	}
+	return db
+}
+
+func buildCriteria(c Criteria, allowed map[string]bool) (string, []interface{}, error) {
+	switch {
+	case len(c.And) > 0:
+		return joinCriteria(c.And, " AND ", allowed)
+	case len(c.Or) > 0:
+		return joinCriteria(c.Or, " OR ", allowed)
+	case c.Not != nil:
+		sql, args, err := buildCriteria(*c.Not, allowed)
+		if err != nil {
+			return "", nil, err
+		}
+		return "NOT (" + sql + ")", args, nil
+	}
+
+	if !allowed[c.Field] {
+		return "", nil, apperrors.Validation(fmt.Sprintf("filtering on %q is not supported", c.Field))
+	}
+	column := c.Field
+
+	if c.Op == "pr" {
+		return fmt.Sprintf("(%s IS NOT NULL AND CAST(%s AS TEXT) <> '')", column, column), nil, nil
+	}
+
+	// Strings compare case-insensitively, everything else as-is
+	if s, ok := c.Value.(string); ok {
+		column = "LOWER(CAST(" + column + " AS TEXT))"
+		value := strings.ToLower(s)
+		switch c.Op {
+		case "co":
+			return column + " LIKE ? ESCAPE '\\'", []interface{}{"%" + escapeLike(value) + "%"}, nil
+		case "sw":
+			return column + " LIKE ? ESCAPE '\\'", []interface{}{escapeLike(value) + "%"}, nil
+		case "ew":
+			return column + " LIKE ? ESCAPE '\\'", []interface{}{"%" + escapeLike(value)}, nil
+		}
+		c.Value = value
+	}
+
+	if c.Value == nil {
+		switch c.Op {
+		case "eq":
+			return column + " IS NULL", nil, nil
+		case "ne":
+			return column + " IS NOT NULL", nil, nil
+		}
+	}
+
+	operators := map[string]string{"eq": "=", "ne": "<>", "gt": ">", "ge": ">=", "lt": "<", "le": "<="}
+	operator, ok := operators[c.Op]
+	if !ok {
+		return "", nil, apperrors.Validation(fmt.Sprintf("operator %q is not supported for %q", c.Op, c.Field))
+	}
+	return column + " " + operator + " ?", []interface{}{c.Value}, nil
+}
+
+func joinCriteria(children []Criteria, separator string, allowed map[string]bool) (string, []interface{}, error) {
+	parts := make([]string, 0, len(children))
+	var args []interface{}
+	for _, child := range children {
+		sql, childArgs, err := buildCriteria(child, allowed)
+		if err != nil {
+			return "", nil, err
+		}
+		parts = append(parts, "("+sql+")")
+		args = append(args, childArgs...)
+	}
+	return strings.Join(parts, separator), args, nil
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(value)
+}
@@ -0,0 +1,57 @@
+// repositories/errors.go
+package repositories
+
+import (
+	"errors"
+
+	"github.com/ABDULS21985/test-portal/apperrors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// Postgres SQLSTATEs translated into domain errors
+const (
+	sqlStateUniqueViolation     = "23505"
+	sqlStateForeignKeyViolation = "23503"
+	sqlStateNotNullViolation    = "23502"
+	sqlStateCheckViolation      = "23514"
+	sqlStateStringTooLong       = "22001"
+)
+
+// uniqueViolations phrases a unique index violation for the client, by index name
+var uniqueViolations = map[string]string{
+	"idx_users_email":                         "a user with this email already exists",
+	"idx_roles_tenant_name":                   "a role with this name already exists",
+	"idx_web_authn_credentials_credential_id": "this passkey is already registered",
+}
+
+// translateError turns GORM and Postgres errors into domain errors, keeping the original as the
+// cause. resource names the entity in messages, e.g. "user". Anything unrecognised, including
+// the serialization failures TxManager retries, is returned unchanged.
+func translateError(err error, resource string) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperrors.NotFound(resource + " not found").WithCause(err)
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch pgErr.Code {
+	case sqlStateUniqueViolation:
+		message, ok := uniqueViolations[pgErr.ConstraintName]
+		if !ok {
+			message = "this " + resource + " already exists"
+		}
+		return apperrors.Conflict(message).WithCause(err)
+	case sqlStateForeignKeyViolation:
+		return apperrors.Conflict(resource + " refers to or is referenced by another record").WithCause(err)
+	case sqlStateNotNullViolation, sqlStateCheckViolation, sqlStateStringTooLong:
+		return apperrors.Validation("invalid " + resource).WithCause(err)
+	}
+	return err
+}
@@ -0,0 +1,55 @@
+// repositories/memory/audit_log_repository.go
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories"
+
+	"github.com/google/uuid"
+)
+
+type auditLogRepository struct {
+	store *Store
+}
+
+// NewAuditLogRepository creates a new instance of auditLogRepository
+func NewAuditLogRepository(store *Store) repositories.AuditLogRepository {
+	return &auditLogRepository{
+		store: store,
+	}
+}
+
+func (r *auditLogRepository) CreateEntry(ctx context.Context, entry *models.AuditLog) error {
+	return r.store.write(ctx, func() error {
+		if entry.ID == uuid.Nil {
+			entry.ID = uuid.New()
+		}
+		if entry.CreatedAt.IsZero() {
+			entry.CreatedAt = time.Now()
+		}
+		r.store.auditLogs = append(r.store.auditLogs, *entry)
+		return nil
+	})
+}
+
+func (r *auditLogRepository) ListBySubject(ctx context.Context, subjectID uuid.UUID, opts repositories.ListOptions) ([]models.AuditLog, error) {
+	entries := []models.AuditLog{}
+	err := r.store.read(ctx, func() error {
+		for _, entry := range r.store.auditLogs {
+			if entry.SubjectID != nil && *entry.SubjectID == subjectID {
+				entries = append(entries, entry)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(entries, func(a, b models.AuditLog) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return page(entries, opts), nil
+}
@@ -0,0 +1,181 @@
+// repositories/memory/criteria.go
+package memory
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/apperrors"
+	"github.com/ABDULS21985/test-portal/repositories"
+
+	"github.com/google/uuid"
+)
+
+// row maps a model's column names to their values, with NULL as nil
+type row map[string]interface{}
+
+// predicate reports whether a row matches compiled criteria
+type predicate func(row) bool
+
+// compileCriteria turns criteria into a predicate with the semantics of the SQL that
+// repositories.Criteria produces. Like the GORM repositories it rejects columns missing from
+// allowed and unsupported operators anywhere in the tree, even where evaluation would short-circuit.
+func compileCriteria(criteria *repositories.Criteria, allowed row) (predicate, error) {
+	if criteria == nil {
+		return func(row) bool { return true }, nil
+	}
+	c := *criteria
+
+	switch {
+	case len(c.And) > 0, len(c.Or) > 0:
+		children := c.And
+		if len(children) == 0 {
+			children = c.Or
+		}
+		predicates := make([]predicate, 0, len(children))
+		for i := range children {
+			p, err := compileCriteria(&children[i], allowed)
+			if err != nil {
+				return nil, err
+			}
+			predicates = append(predicates, p)
+		}
+		isOr := len(c.And) == 0
+		return func(r row) bool {
+			for _, p := range predicates {
+				if p(r) == isOr {
+					return isOr
+				}
+			}
+			return !isOr
+		}, nil
+	case c.Not != nil:
+		p, err := compileCriteria(c.Not, allowed)
+		if err != nil {
+			return nil, err
+		}
+		return func(r row) bool { return !p(r) }, nil
+	}
+
+	if _, ok := allowed[c.Field]; !ok {
+		return nil, apperrors.Validation(fmt.Sprintf("filtering on %q is not supported", c.Field))
+	}
+	field := c.Field
+
+	if c.Op == "pr" {
+		return func(r row) bool { return r[field] != nil && text(r[field]) != "" }, nil
+	}
+
+	// Strings compare case-insensitively against the column's text form, everything else as-is
+	if s, ok := c.Value.(string); ok {
+		value := strings.ToLower(s)
+		var match func(column string) bool
+		switch c.Op {
+		case "co":
+			match = func(column string) bool { return strings.Contains(column, value) }
+		case "sw":
+			match = func(column string) bool { return strings.HasPrefix(column, value) }
+		case "ew":
+			match = func(column string) bool { return strings.HasSuffix(column, value) }
+		default:
+			test, ok := operators[c.Op]
+			if !ok {
+				return nil, unsupportedOperator(c)
+			}
+			match = func(column string) bool { return test(strings.Compare(column, value)) }
+		}
+		return func(r row) bool {
+			return r[field] != nil && match(strings.ToLower(text(r[field])))
+		}, nil
+	}
+
+	if c.Value == nil {
+		switch c.Op {
+		case "eq":
+			return func(r row) bool { return r[field] == nil }, nil
+		case "ne":
+			return func(r row) bool { return r[field] != nil }, nil
+		}
+	}
+
+	test, ok := operators[c.Op]
+	if !ok {
+		return nil, unsupportedOperator(c)
+	}
+	value := c.Value
+	return func(r row) bool {
+		cmp, ok := compare(r[field], value)
+		return ok && test(cmp)
+	}, nil
+}
+
+// operators tests the result of a three-way comparison for each comparison operator
+var operators = map[string]func(int) bool{
+	"eq": func(cmp int) bool { return cmp == 0 },
+	"ne": func(cmp int) bool { return cmp != 0 },
+	"gt": func(cmp int) bool { return cmp > 0 },
+	"ge": func(cmp int) bool { return cmp >= 0 },
+	"lt": func(cmp int) bool { return cmp < 0 },
+	"le": func(cmp int) bool { return cmp <= 0 },
+}
+
+func unsupportedOperator(c repositories.Criteria) error {
+	return apperrors.Validation(fmt.Sprintf("operator %q is not supported for %q", c.Op, c.Field))
+}
+
+// text renders a column value the way Postgres casts it to TEXT
+func text(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case uuid.UUID:
+		return v.String()
+	case time.Time:
+		return v.UTC().Format("2006-01-02 15:04:05.999999-07")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// compare three-way compares a column value with a criteria value. ok is false when either is
+// NULL or their types can't be compared, in which case SQL wouldn't match the row either.
+func compare(column, value interface{}) (cmp int, ok bool) {
+	switch c := column.(type) {
+	case time.Time:
+		if v, isTime := value.(time.Time); isTime {
+			return c.Compare(v), true
+		}
+	case bool:
+		if v, isBool := value.(bool); isBool {
+			switch {
+			case c == v:
+				return 0, true
+			case v:
+				return -1, true
+			default:
+				return 1, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// page applies list options to rows already in query order
+func page[T any](rows []T, opts repositories.ListOptions) []T {
+	if opts.Offset > 0 {
+		rows = rows[min(opts.Offset, len(rows)):]
+	}
+	if opts.Limit > 0 && opts.Limit < len(rows) {
+		rows = rows[:opts.Limit]
+	}
+	return rows
+}
+
+// byCreated orders rows by creation time then ID, the order FindUsers and FindRoles page in
+func byCreated(aCreated, bCreated time.Time, aID, bID uuid.UUID) int {
+	if cmp := aCreated.Compare(bCreated); cmp != 0 {
+		return cmp
+	}
+	return strings.Compare(aID.String(), bID.String())
+}
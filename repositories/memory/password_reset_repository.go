@@ -0,0 +1,63 @@
+// repositories/memory/password_reset_repository.go
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/apperrors"
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories"
+
+	"github.com/google/uuid"
+)
+
+type passwordResetRepository struct {
+	store *Store
+}
+
+// NewPasswordResetRepository creates a new instance of passwordResetRepository
+func NewPasswordResetRepository(store *Store) repositories.PasswordResetRepository {
+	return &passwordResetRepository{
+		store: store,
+	}
+}
+
+func (r *passwordResetRepository) CreateToken(ctx context.Context, token *models.PasswordResetToken) error {
+	return r.store.write(ctx, func() error {
+		if token.ID == uuid.Nil {
+			token.ID = uuid.New()
+		}
+		for id, existing := range r.store.resetTokens {
+			if id == token.ID || existing.Token == token.Token {
+				return apperrors.Conflict("this password reset token already exists")
+			}
+		}
+		if token.CreatedAt.IsZero() {
+			token.CreatedAt = time.Now()
+		}
+		r.store.resetTokens[token.ID] = *token
+		return nil
+	})
+}
+
+func (r *passwordResetRepository) GetToken(ctx context.Context, token string) (*models.PasswordResetToken, error) {
+	var prt *models.PasswordResetToken
+	err := r.store.read(ctx, func() error {
+		for _, candidate := range r.store.resetTokens {
+			if candidate.Token == token {
+				prt = &candidate
+				return nil
+			}
+		}
+		return apperrors.NotFound("password reset token not found")
+	})
+	return prt, err
+}
+
+func (r *passwordResetRepository) DeleteToken(ctx context.Context, id uuid.UUID) error {
+	return r.store.write(ctx, func() error {
+		delete(r.store.resetTokens, id)
+		return nil
+	})
+}
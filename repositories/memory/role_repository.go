@@ -0,0 +1,128 @@
+// repositories/memory/role_repository.go
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/apperrors"
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories"
+
+	"github.com/google/uuid"
+)
+
+var errRoleNotFound = apperrors.NotFound("role not found")
+
+type roleRepository struct {
+	store *Store
+}
+
+// NewRoleRepository creates a new instance of roleRepository
+func NewRoleRepository(store *Store) repositories.RoleRepository {
+	return &roleRepository{
+		store: store,
+	}
+}
+
+// roleRow exposes the columns FindRoles may filter on
+func roleRow(role *models.Role) row {
+	return row{
+		"id": role.ID, "name": role.Name, "tenant": role.Tenant, "external_id": role.ExternalID,
+		"created_at": role.CreatedAt, "updated_at": role.UpdatedAt,
+	}
+}
+
+func (r *roleRepository) CreateRole(ctx context.Context, role *models.Role) error {
+	return r.store.write(ctx, func() error {
+		if role.ID == uuid.Nil {
+			role.ID = uuid.New()
+		}
+		if _, exists := r.store.roles[role.ID]; exists {
+			return apperrors.Conflict("this role already exists")
+		}
+		now := time.Now()
+		if role.CreatedAt.IsZero() {
+			role.CreatedAt = now
+		}
+		if role.UpdatedAt.IsZero() {
+			role.UpdatedAt = now
+		}
+		return r.save(role)
+	})
+}
+
+func (r *roleRepository) GetRoleByID(ctx context.Context, id uuid.UUID) (*models.Role, error) {
+	return r.find(ctx, func(role *models.Role) bool { return role.ID == id })
+}
+
+func (r *roleRepository) GetRoleByName(ctx context.Context, tenant, name string) (*models.Role, error) {
+	return r.find(ctx, func(role *models.Role) bool { return role.Tenant == tenant && role.Name == name })
+}
+
+func (r *roleRepository) UpdateRole(ctx context.Context, role *models.Role) error {
+	return r.store.write(ctx, func() error {
+		if role.ID == uuid.Nil {
+			role.ID = uuid.New()
+		}
+		role.UpdatedAt = time.Now()
+		return r.save(role)
+	})
+}
+
+func (r *roleRepository) DeleteRole(ctx context.Context, id uuid.UUID) error {
+	return r.store.write(ctx, func() error {
+		delete(r.store.roles, id)
+		return nil
+	})
+}
+
+func (r *roleRepository) FindRoles(ctx context.Context, criteria *repositories.Criteria, opts repositories.ListOptions) ([]models.Role, int64, error) {
+	match, err := compileCriteria(criteria, roleRow(&models.Role{}))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	roles := []models.Role{}
+	err = r.store.read(ctx, func() error {
+		for _, role := range r.store.roles {
+			if match(roleRow(&role)) {
+				roles = append(roles, role)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	slices.SortFunc(roles, func(a, b models.Role) int { return byCreated(a.CreatedAt, b.CreatedAt, a.ID, b.ID) })
+	return page(roles, opts), int64(len(roles)), nil
+}
+
+// find returns a copy of the first role that matches
+func (r *roleRepository) find(ctx context.Context, match func(*models.Role) bool) (*models.Role, error) {
+	var role *models.Role
+	err := r.store.read(ctx, func() error {
+		for _, candidate := range r.store.roles {
+			if match(&candidate) {
+				role = &candidate
+				return nil
+			}
+		}
+		return errRoleNotFound
+	})
+	return role, err
+}
+
+// save stores a copy of role, enforcing the unique name per tenant. The caller holds the write lock.
+func (r *roleRepository) save(role *models.Role) error {
+	for id, existing := range r.store.roles {
+		if id != role.ID && existing.Tenant == role.Tenant && existing.Name == role.Name {
+			return apperrors.Conflict("a role with this name already exists")
+		}
+	}
+	r.store.roles[role.ID] = *role
+	return nil
+}
@@ -0,0 +1,107 @@
+// repositories/memory/session_repository.go
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/apperrors"
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories"
+
+	"github.com/google/uuid"
+)
+
+type sessionRepository struct {
+	store *Store
+}
+
+// NewSessionRepository creates a new instance of sessionRepository
+func NewSessionRepository(store *Store) repositories.SessionRepository {
+	return &sessionRepository{
+		store: store,
+	}
+}
+
+func (r *sessionRepository) CreateSession(ctx context.Context, session *models.Session) error {
+	return r.store.write(ctx, func() error {
+		if session.ID == uuid.Nil {
+			session.ID = uuid.New()
+		}
+		if _, exists := r.store.sessions[session.ID]; exists {
+			return apperrors.Conflict("this session already exists")
+		}
+		if session.CreatedAt.IsZero() {
+			session.CreatedAt = time.Now()
+		}
+		r.store.sessions[session.ID] = *session
+		return nil
+	})
+}
+
+func (r *sessionRepository) GetSession(ctx context.Context, id uuid.UUID) (*models.Session, error) {
+	var session models.Session
+	err := r.store.read(ctx, func() error {
+		found, ok := r.store.sessions[id]
+		if !ok {
+			return apperrors.NotFound("session not found")
+		}
+		session = found
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *sessionRepository) ListActiveSessions(ctx context.Context, userID uuid.UUID, now time.Time) ([]models.Session, error) {
+	sessions := []models.Session{}
+	err := r.store.read(ctx, func() error {
+		for _, session := range r.store.sessions {
+			if session.UserID == userID && session.IsActive(now) {
+				sessions = append(sessions, session)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(sessions, func(a, b models.Session) int { return b.LastSeenAt.Compare(a.LastSeenAt) })
+	return sessions, nil
+}
+
+func (r *sessionRepository) TouchSession(ctx context.Context, id uuid.UUID, lastSeen time.Time) error {
+	return r.store.write(ctx, func() error {
+		if session, ok := r.store.sessions[id]; ok {
+			session.LastSeenAt = lastSeen
+			r.store.sessions[id] = session
+		}
+		return nil
+	})
+}
+
+func (r *sessionRepository) RevokeSession(ctx context.Context, id uuid.UUID, revokedAt time.Time) error {
+	return r.store.write(ctx, func() error {
+		if session, ok := r.store.sessions[id]; ok && session.RevokedAt == nil {
+			session.RevokedAt = &revokedAt
+			r.store.sessions[id] = session
+		}
+		return nil
+	})
+}
+
+func (r *sessionRepository) RevokeUserSessions(ctx context.Context, userID uuid.UUID, revokedAt time.Time) error {
+	return r.store.write(ctx, func() error {
+		for id, session := range r.store.sessions {
+			if session.UserID == userID && session.RevokedAt == nil {
+				session.RevokedAt = &revokedAt
+				r.store.sessions[id] = session
+			}
+		}
+		return nil
+	})
+}
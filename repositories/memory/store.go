@@ -0,0 +1,101 @@
+// repositories/memory/store.go
+package memory
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories"
+
+	"github.com/google/uuid"
+)
+
+// Store holds the rows of every in-memory repository. Repositories created on the same store
+// see each other's writes, like GORM repositories sharing a database.
+type Store struct {
+	mu   sync.RWMutex
+	txMu sync.Mutex
+
+	users       map[uuid.UUID]models.User
+	resetTokens map[uuid.UUID]models.PasswordResetToken
+	roles       map[uuid.UUID]models.Role
+	auditLogs   []models.AuditLog
+	sessions    map[uuid.UUID]models.Session
+	credentials map[uuid.UUID]models.WebAuthnCredential
+	challenges  map[uuid.UUID]models.WebAuthnChallenge
+}
+
+// NewStore creates an empty store
+func NewStore() *Store {
+	return &Store{
+		users:       make(map[uuid.UUID]models.User),
+		resetTokens: make(map[uuid.UUID]models.PasswordResetToken),
+		roles:       make(map[uuid.UUID]models.Role),
+		sessions:    make(map[uuid.UUID]models.Session),
+		credentials: make(map[uuid.UUID]models.WebAuthnCredential),
+		challenges:  make(map[uuid.UUID]models.WebAuthnChallenge),
+	}
+}
+
+// Repositories creates every repository on top of the store
+func (s *Store) Repositories() repositories.Repositories {
+	return repositories.Repositories{
+		Users:          NewUserRepository(s),
+		PasswordResets: NewPasswordResetRepository(s),
+		Roles:          NewRoleRepository(s),
+		AuditLogs:      NewAuditLogRepository(s),
+		Sessions:       NewSessionRepository(s),
+		WebAuthn:       NewWebAuthnRepository(s),
+	}
+}
+
+// read runs fn under the read lock, failing like a database query once ctx is done
+func (s *Store) read(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return fn()
+}
+
+// write runs fn under the write lock, failing like a database query once ctx is done
+func (s *Store) write(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn()
+}
+
+// snapshot copies every table so a failed transaction can be rolled back
+func (s *Store) snapshot() *Store {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return &Store{
+		users:       maps.Clone(s.users),
+		resetTokens: maps.Clone(s.resetTokens),
+		roles:       maps.Clone(s.roles),
+		auditLogs:   slices.Clone(s.auditLogs),
+		sessions:    maps.Clone(s.sessions),
+		credentials: maps.Clone(s.credentials),
+		challenges:  maps.Clone(s.challenges),
+	}
+}
+
+// restore puts back the tables of a snapshot
+func (s *Store) restore(snapshot *Store) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users = snapshot.users
+	s.resetTokens = snapshot.resetTokens
+	s.roles = snapshot.roles
+	s.auditLogs = snapshot.auditLogs
+	s.sessions = snapshot.sessions
+	s.credentials = snapshot.credentials
+	s.challenges = snapshot.challenges
+}
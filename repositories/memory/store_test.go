@@ -0,0 +1,16 @@
+// repositories/memory/store_test.go
+package memory
+
+import (
+	"testing"
+
+	"github.com/ABDULS21985/test-portal/repositories"
+	"github.com/ABDULS21985/test-portal/repositories/repotest"
+)
+
+func TestContract(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) (repositories.Repositories, repositories.TxManager) {
+		store := NewStore()
+		return store.Repositories(), NewTxManager(store)
+	})
+}
@@ -0,0 +1,40 @@
+// repositories/memory/tx_manager.go
+package memory
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ABDULS21985/test-portal/repositories"
+)
+
+type txKey struct{}
+
+type txManager struct {
+	store *Store
+}
+
+// NewTxManager creates a TxManager over the store. Transactions run one at a time and a failed
+// one is rolled back by restoring the tables as they were when it began, so writes made outside
+// any transaction while it runs are rolled back with it. Nested calls roll back only their own
+// work, like a savepoint. Isolation options are ignored since transactions never overlap.
+func NewTxManager(store *Store) repositories.TxManager {
+	return &txManager{
+		store: store,
+	}
+}
+
+func (m *txManager) WithinTransaction(ctx context.Context, fn func(ctx context.Context, repos repositories.Repositories) error, opts ...*sql.TxOptions) error {
+	if ctx.Value(txKey{}) == nil {
+		m.store.txMu.Lock()
+		defer m.store.txMu.Unlock()
+		ctx = context.WithValue(ctx, txKey{}, true)
+	}
+
+	snapshot := m.store.snapshot()
+	if err := fn(ctx, m.store.Repositories()); err != nil {
+		m.store.restore(snapshot)
+		return err
+	}
+	return nil
+}
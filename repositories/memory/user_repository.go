@@ -0,0 +1,186 @@
+// repositories/memory/user_repository.go
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/apperrors"
+	"github.com/ABDULS21985/test-portal/encryption"
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories"
+
+	"github.com/google/uuid"
+)
+
+var errUserNotFound = apperrors.NotFound("user not found")
+
+type userRepository struct {
+	store *Store
+}
+
+// NewUserRepository creates a new instance of userRepository
+func NewUserRepository(store *Store) repositories.UserRepository {
+	return &userRepository{
+		store: store,
+	}
+}
+
+// userRow exposes the columns FindUsers may filter on
+func userRow(u *models.User) row {
+	return row{
+		"id": u.ID, "name": u.Name, "email": u.Email, "role": u.Role, "tenant": u.Tenant,
+		"external_id": u.ExternalID, "auth_source": u.AuthSource, "disabled": u.Disabled,
+		"created_at": u.CreatedAt, "updated_at": u.UpdatedAt,
+	}
+}
+
+func (r *userRepository) CreateUser(ctx context.Context, user *models.User) error {
+	return r.store.write(ctx, func() error {
+		if user.ID == uuid.Nil {
+			user.ID = uuid.New()
+		}
+		if _, exists := r.store.users[user.ID]; exists {
+			return apperrors.Conflict("this user already exists")
+		}
+		if user.CreatedAt.IsZero() {
+			user.CreatedAt = time.Now()
+		}
+		if user.AuthSource == "" {
+			user.AuthSource = models.AuthSourceLocal
+		}
+		return r.save(user)
+	})
+}
+
+func (r *userRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	var user models.User
+	err := r.store.read(ctx, func() error {
+		found, ok := r.store.users[id]
+		if !ok {
+			return errUserNotFound
+		}
+		user = found
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.find(ctx, func(u *models.User) bool { return u.Email == email })
+}
+
+// GetUserByPhone looks a user up by the blind index of their encrypted phone number
+func (r *userRepository) GetUserByPhone(ctx context.Context, phone string) (*models.User, error) {
+	index, err := encryption.BlindIndex(phone)
+	if err != nil {
+		return nil, err
+	}
+	if index == "" {
+		return nil, errUserNotFound
+	}
+	return r.find(ctx, func(u *models.User) bool { return u.PhoneIndex == index })
+}
+
+func (r *userRepository) UpdateUser(ctx context.Context, user *models.User) error {
+	return r.store.write(ctx, func() error {
+		if user.ID == uuid.Nil {
+			user.ID = uuid.New()
+		}
+		return r.save(user)
+	})
+}
+
+// UpdateUserProfile writes only the profile fields of user over the stored row
+func (r *userRepository) UpdateUserProfile(ctx context.Context, user *models.User) error {
+	return r.store.write(ctx, func() error {
+		stored, ok := r.store.users[user.ID]
+		if !ok {
+			return errUserNotFound
+		}
+		stored.Name, stored.Email, stored.Password = user.Name, user.Email, user.Password
+		stored.Phone, stored.DateOfBirth = user.Phone, user.DateOfBirth
+		if err := r.save(&stored); err != nil {
+			return err
+		}
+		user.PhoneIndex, user.UpdatedAt = stored.PhoneIndex, stored.UpdatedAt
+		return nil
+	})
+}
+
+func (r *userRepository) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	return r.store.write(ctx, func() error {
+		delete(r.store.users, id)
+		return nil
+	})
+}
+
+func (r *userRepository) ListUsersByAuthSource(ctx context.Context, source string) ([]models.User, error) {
+	users := []models.User{}
+	err := r.store.read(ctx, func() error {
+		for _, user := range r.store.users {
+			if user.AuthSource == source {
+				users = append(users, user)
+			}
+		}
+		return nil
+	})
+	return users, err
+}
+
+func (r *userRepository) FindUsers(ctx context.Context, criteria *repositories.Criteria, opts repositories.ListOptions) ([]models.User, int64, error) {
+	match, err := compileCriteria(criteria, userRow(&models.User{}))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	users := []models.User{}
+	err = r.store.read(ctx, func() error {
+		for _, user := range r.store.users {
+			if match(userRow(&user)) {
+				users = append(users, user)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	slices.SortFunc(users, func(a, b models.User) int { return byCreated(a.CreatedAt, b.CreatedAt, a.ID, b.ID) })
+	return page(users, opts), int64(len(users)), nil
+}
+
+// find returns a copy of the first user that matches
+func (r *userRepository) find(ctx context.Context, match func(*models.User) bool) (*models.User, error) {
+	var user *models.User
+	err := r.store.read(ctx, func() error {
+		for _, candidate := range r.store.users {
+			if match(&candidate) {
+				user = &candidate
+				return nil
+			}
+		}
+		return errUserNotFound
+	})
+	return user, err
+}
+
+// save runs the model's save hook and stores a copy, enforcing the unique email index. The
+// caller holds the write lock.
+func (r *userRepository) save(user *models.User) error {
+	for id, existing := range r.store.users {
+		if id != user.ID && existing.Email == user.Email {
+			return apperrors.Conflict("a user with this email already exists")
+		}
+	}
+	if err := user.BeforeSave(nil); err != nil {
+		return err
+	}
+	r.store.users[user.ID] = *user
+	return nil
+}
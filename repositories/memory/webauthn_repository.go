@@ -0,0 +1,142 @@
+// repositories/memory/webauthn_repository.go
+package memory
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/apperrors"
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories"
+
+	"github.com/google/uuid"
+)
+
+type webAuthnRepository struct {
+	store *Store
+}
+
+// NewWebAuthnRepository creates a new instance of webAuthnRepository
+func NewWebAuthnRepository(store *Store) repositories.WebAuthnRepository {
+	return &webAuthnRepository{
+		store: store,
+	}
+}
+
+func (r *webAuthnRepository) CreateCredential(ctx context.Context, credential *models.WebAuthnCredential) error {
+	return r.store.write(ctx, func() error {
+		if credential.ID == uuid.Nil {
+			credential.ID = uuid.New()
+		}
+		if _, exists := r.store.credentials[credential.ID]; exists {
+			return apperrors.Conflict("this passkey already exists")
+		}
+		if credential.CreatedAt.IsZero() {
+			credential.CreatedAt = time.Now()
+		}
+		return r.save(credential)
+	})
+}
+
+func (r *webAuthnRepository) ListCredentials(ctx context.Context, userID uuid.UUID) ([]models.WebAuthnCredential, error) {
+	credentials := []models.WebAuthnCredential{}
+	err := r.store.read(ctx, func() error {
+		for _, credential := range r.store.credentials {
+			if credential.UserID == userID {
+				credentials = append(credentials, credential)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(credentials, func(a, b models.WebAuthnCredential) int {
+		return byCreated(a.CreatedAt, b.CreatedAt, a.ID, b.ID)
+	})
+	return credentials, nil
+}
+
+func (r *webAuthnRepository) GetCredentialByCredentialID(ctx context.Context, credentialID []byte) (*models.WebAuthnCredential, error) {
+	var credential *models.WebAuthnCredential
+	err := r.store.read(ctx, func() error {
+		for _, candidate := range r.store.credentials {
+			if bytes.Equal(candidate.CredentialID, credentialID) {
+				credential = &candidate
+				return nil
+			}
+		}
+		return apperrors.NotFound("passkey not found")
+	})
+	return credential, err
+}
+
+func (r *webAuthnRepository) UpdateCredential(ctx context.Context, credential *models.WebAuthnCredential) error {
+	return r.store.write(ctx, func() error {
+		if credential.ID == uuid.Nil {
+			credential.ID = uuid.New()
+		}
+		return r.save(credential)
+	})
+}
+
+func (r *webAuthnRepository) DeleteCredential(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	var deleted bool
+	err := r.store.write(ctx, func() error {
+		if credential, ok := r.store.credentials[id]; ok && credential.UserID == userID {
+			delete(r.store.credentials, id)
+			deleted = true
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+func (r *webAuthnRepository) CreateChallenge(ctx context.Context, challenge *models.WebAuthnChallenge) error {
+	return r.store.write(ctx, func() error {
+		if challenge.ID == uuid.Nil {
+			challenge.ID = uuid.New()
+		}
+		if _, exists := r.store.challenges[challenge.ID]; exists {
+			return apperrors.Conflict("this passkey challenge already exists")
+		}
+		if challenge.CreatedAt.IsZero() {
+			challenge.CreatedAt = time.Now()
+		}
+		r.store.challenges[challenge.ID] = *challenge
+		return nil
+	})
+}
+
+// ConsumeChallenge deletes and returns an unexpired challenge, so each one can be answered only once
+func (r *webAuthnRepository) ConsumeChallenge(ctx context.Context, id uuid.UUID, now time.Time) (*models.WebAuthnChallenge, error) {
+	var challenge models.WebAuthnChallenge
+	err := r.store.write(ctx, func() error {
+		found, ok := r.store.challenges[id]
+		if !ok || !found.ExpiresAt.After(now) {
+			return apperrors.NotFound("passkey challenge not found")
+		}
+		delete(r.store.challenges, id)
+		challenge = found
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// save stores a copy of credential, enforcing the unique credential ID. The caller holds the
+// write lock.
+func (r *webAuthnRepository) save(credential *models.WebAuthnCredential) error {
+	for id, existing := range r.store.credentials {
+		if id != credential.ID && bytes.Equal(existing.CredentialID, credential.CredentialID) {
+			return apperrors.Conflict("this passkey is already registered")
+		}
+	}
+	r.store.credentials[credential.ID] = *credential
+	return nil
+}
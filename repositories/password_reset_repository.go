@@ -2,6 +2,7 @@
 package repositories
 
 import (
+	"context"
 	"github.com/ABDULS21985/test-portal/models"
 
 	"github.com/google/uuid"
@@ -10,9 +11,9 @@ import (
 
 // PasswordResetRepository defines the contract for password reset token operations
 type PasswordResetRepository interface {
-	CreateToken(token *models.PasswordResetToken) error
-	GetToken(token string) (*models.PasswordResetToken, error)
-	DeleteToken(id uuid.UUID) error
+	CreateToken(ctx context.Context, token *models.PasswordResetToken) error
+	GetToken(ctx context.Context, token string) (*models.PasswordResetToken, error)
+	DeleteToken(ctx context.Context, id uuid.UUID) error
 }
 
 // passwordResetRepository is the concrete implementation of PasswordResetRepository interface
@@ -27,18 +28,18 @@ func NewPasswordResetRepository(db *gorm.DB) PasswordResetRepository {
 	}
 }
 
-func (r *passwordResetRepository) CreateToken(token *models.PasswordResetToken) error {
-	return r.db.Create(token).Error
+func (r *passwordResetRepository) CreateToken(ctx context.Context, token *models.PasswordResetToken) error {
+	return translateError(r.db.WithContext(ctx).Create(token).Error, "password reset token")
 }
 
-func (r *passwordResetRepository) GetToken(token string) (*models.PasswordResetToken, error) {
+func (r *passwordResetRepository) GetToken(ctx context.Context, token string) (*models.PasswordResetToken, error) {
 	var prt models.PasswordResetToken
-	if err := r.db.First(&prt, "token = ?", token).Error; err != nil {
-		return nil, err
+	if err := r.db.WithContext(ctx).First(&prt, "token = ?", token).Error; err != nil {
+		return nil, translateError(err, "password reset token")
 	}
 	return &prt, nil
 }
 
-func (r *passwordResetRepository) DeleteToken(id uuid.UUID) error {
-	return r.db.Delete(&models.PasswordResetToken{}, "id = ?", id).Error
+func (r *passwordResetRepository) DeleteToken(ctx context.Context, id uuid.UUID) error {
+	return translateError(r.db.WithContext(ctx).Delete(&models.PasswordResetToken{}, "id = ?", id).Error, "password reset token")
 }
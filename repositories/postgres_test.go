@@ -0,0 +1,48 @@
+// repositories/postgres_test.go
+package repositories_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/ABDULS21985/test-portal/logging"
+	"github.com/ABDULS21985/test-portal/migrations"
+	"github.com/ABDULS21985/test-portal/repositories"
+	"github.com/ABDULS21985/test-portal/repositories/repotest"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// tables are emptied before every contract subtest
+const tables = "users, password_reset_tokens, roles, audit_logs, sessions, web_authn_credentials, web_authn_challenges"
+
+// TestPostgresContract runs the repository contract against the database named by
+// TEST_DATABASE_URL, which it migrates and truncates; it is skipped when that isn't set.
+func TestPostgresContract(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logging.NewGormLogger(logging.Discard(), 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := migrations.RunMigrations(context.Background(), db, logging.Discard()); err != nil {
+		t.Fatal(err)
+	}
+
+	repotest.Run(t, func(t *testing.T) (repositories.Repositories, repositories.TxManager) {
+		if err := db.Exec("TRUNCATE " + tables).Error; err != nil {
+			t.Fatal(err)
+		}
+		return repositories.NewRepositories(db), repositories.NewTxManager(db)
+	})
+}
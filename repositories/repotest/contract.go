@@ -0,0 +1,620 @@
+// repositories/repotest/contract.go
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/apperrors"
+	"github.com/ABDULS21985/test-portal/encryption/encryptiontest"
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories"
+
+	"github.com/google/uuid"
+)
+
+// Open returns repositories and a transaction manager over empty storage
+type Open func(t *testing.T) (repositories.Repositories, repositories.TxManager)
+
+// Run checks that a repository implementation behaves like the others: the GORM repositories
+// on Postgres and the in-memory ones must pass the same suite. open is called once per subtest.
+func Run(t *testing.T, open Open) {
+	encryptiontest.Use(t)
+
+	for _, suite := range []struct {
+		name string
+		run  func(*testing.T, Open)
+	}{
+		{"Users", testUsers},
+		{"FindUsers", testFindUsers},
+		{"Sessions", testSessions},
+		{"PasswordResets", testPasswordResets},
+		{"Roles", testRoles},
+		{"AuditLogs", testAuditLogs},
+		{"WebAuthn", testWebAuthn},
+		{"Transactions", testTransactions},
+	} {
+		t.Run(suite.name, func(t *testing.T) { suite.run(t, open) })
+	}
+}
+
+// wantKind fails the test unless err is a domain error of the given kind
+func wantKind(t *testing.T, err error, kind apperrors.Kind) {
+	t.Helper()
+	if err == nil || apperrors.KindOf(err) != kind {
+		t.Fatalf("err = %v, want a %v error", err, kind)
+	}
+}
+
+// at returns a time Postgres stores without rounding
+func at(offset time.Duration) time.Time {
+	return time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC).Add(offset)
+}
+
+func newUser(email string) *models.User {
+	return &models.User{Name: "User " + email, Email: email, Password: "hash", Role: models.RoleUser}
+}
+
+func mustCreateUser(t *testing.T, repos repositories.Repositories, user *models.User) *models.User {
+	t.Helper()
+	if err := repos.Users.CreateUser(context.Background(), user); err != nil {
+		t.Fatalf("CreateUser(%s): %v", user.Email, err)
+	}
+	return user
+}
+
+func testUsers(t *testing.T, open Open) {
+	ctx := context.Background()
+	repos, _ := open(t)
+
+	dob := at(0)
+	ada := newUser("ada@example.com")
+	ada.Phone, ada.DateOfBirth, ada.Tenant = "+44 20 7946 0000", &dob, "acme"
+	mustCreateUser(t, repos, ada)
+	if ada.ID == uuid.Nil || ada.CreatedAt.IsZero() {
+		t.Fatalf("CreateUser left ID %s and CreatedAt %v unset", ada.ID, ada.CreatedAt)
+	}
+
+	t.Run("get by ID", func(t *testing.T) {
+		got, err := repos.Users.GetUserByID(ctx, ada.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Email != ada.Email || got.Tenant != "acme" || got.AuthSource != models.AuthSourceLocal {
+			t.Fatalf("got %+v", got)
+		}
+		if got.Phone != ada.Phone || got.DateOfBirth == nil || !got.DateOfBirth.Equal(dob) {
+			t.Fatalf("encrypted columns came back as %q and %v", got.Phone, got.DateOfBirth)
+		}
+	})
+
+	t.Run("get by email and phone", func(t *testing.T) {
+		if got, err := repos.Users.GetUserByEmail(ctx, "ada@example.com"); err != nil || got.ID != ada.ID {
+			t.Fatalf("GetUserByEmail = %v, %v", got, err)
+		}
+		if got, err := repos.Users.GetUserByPhone(ctx, ada.Phone); err != nil || got.ID != ada.ID {
+			t.Fatalf("GetUserByPhone = %v, %v", got, err)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := repos.Users.GetUserByID(ctx, uuid.New())
+		wantKind(t, err, apperrors.KindNotFound)
+		_, err = repos.Users.GetUserByEmail(ctx, "nobody@example.com")
+		wantKind(t, err, apperrors.KindNotFound)
+		_, err = repos.Users.GetUserByPhone(ctx, "")
+		wantKind(t, err, apperrors.KindNotFound)
+		_, err = repos.Users.GetUserByPhone(ctx, "+1 555 0100")
+		wantKind(t, err, apperrors.KindNotFound)
+	})
+
+	t.Run("duplicate email", func(t *testing.T) {
+		wantKind(t, repos.Users.CreateUser(ctx, newUser("ada@example.com")), apperrors.KindConflict)
+
+		alan := mustCreateUser(t, repos, newUser("alan@example.com"))
+		alan.Email = "ada@example.com"
+		wantKind(t, repos.Users.UpdateUser(ctx, alan), apperrors.KindConflict)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		user := mustCreateUser(t, repos, newUser("grace@example.com"))
+		user.Disabled, user.Role, user.Phone = true, models.RoleAdmin, "+1 555 0199"
+		if err := repos.Users.UpdateUser(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+		got, err := repos.Users.GetUserByPhone(ctx, "+1 555 0199")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != user.ID || !got.Disabled || got.Role != models.RoleAdmin {
+			t.Fatalf("got %+v after the update", got)
+		}
+	})
+
+	t.Run("update profile", func(t *testing.T) {
+		stored := newUser("linus@example.com")
+		stored.Tenant, stored.Role = "acme", models.RoleAdmin
+		mustCreateUser(t, repos, stored)
+
+		// A stale copy: the profile update must not undo the account being disabled meanwhile
+		profile := *stored
+		stored.Disabled = true
+		if err := repos.Users.UpdateUser(ctx, stored); err != nil {
+			t.Fatal(err)
+		}
+		profile.Name, profile.Phone = "Linus", "+1 555 0142"
+		profile.Tenant, profile.Role, profile.AuthSource = "other", models.RoleUser, models.AuthSourceLDAP
+		if err := repos.Users.UpdateUserProfile(ctx, &profile); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := repos.Users.GetUserByPhone(ctx, "+1 555 0142")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != stored.ID || got.Name != "Linus" || got.Password != "hash" {
+			t.Fatalf("got %+v after the profile update", got)
+		}
+		if !got.Disabled || got.Tenant != "acme" || got.Role != models.RoleAdmin || got.AuthSource != models.AuthSourceLocal {
+			t.Fatalf("the profile update wrote other columns: %+v", got)
+		}
+
+		missing := newUser("missing@example.com")
+		missing.ID = uuid.New()
+		wantKind(t, repos.Users.UpdateUserProfile(ctx, missing), apperrors.KindNotFound)
+		profile.Email = "ada@example.com"
+		wantKind(t, repos.Users.UpdateUserProfile(ctx, &profile), apperrors.KindConflict)
+	})
+
+	t.Run("list by auth source", func(t *testing.T) {
+		ldapUser := newUser("ldap@example.com")
+		ldapUser.AuthSource = models.AuthSourceLDAP
+		mustCreateUser(t, repos, ldapUser)
+
+		users, err := repos.Users.ListUsersByAuthSource(ctx, models.AuthSourceLDAP)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(users) != 1 || users[0].ID != ldapUser.ID {
+			t.Fatalf("got %d LDAP users, want only %s", len(users), ldapUser.Email)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		user := mustCreateUser(t, repos, newUser("gone@example.com"))
+		if err := repos.Users.DeleteUser(ctx, user.ID); err != nil {
+			t.Fatal(err)
+		}
+		_, err := repos.Users.GetUserByID(ctx, user.ID)
+		wantKind(t, err, apperrors.KindNotFound)
+		if err := repos.Users.DeleteUser(ctx, user.ID); err != nil {
+			t.Fatalf("deleting a missing user: %v", err)
+		}
+	})
+}
+
+func testFindUsers(t *testing.T, open Open) {
+	ctx := context.Background()
+	repos, _ := open(t)
+
+	// Created out of order so the result order comes from created_at, not insertion
+	for i, spec := range []struct {
+		email, tenant string
+		created       time.Duration
+		disabled      bool
+	}{
+		{"carol@acme.example", "acme", 3 * time.Minute, false},
+		{"alice@acme.example", "ACME", 1 * time.Minute, false},
+		{"bob@acme.example", "acme", 2 * time.Minute, true},
+		{"dave@other.example", "other", 4 * time.Minute, false},
+	} {
+		user := newUser(spec.email)
+		user.Tenant, user.Disabled, user.CreatedAt = spec.tenant, spec.disabled, at(spec.created)
+		user.ExternalID = fmt.Sprint("ext-", i)
+		mustCreateUser(t, repos, user)
+	}
+
+	tests := []struct {
+		name      string
+		criteria  *repositories.Criteria
+		opts      repositories.ListOptions
+		wantTotal int64
+		want      []string
+		wantKind  apperrors.Kind
+	}{
+		{name: "everyone", wantTotal: 4, want: []string{"alice@acme.example", "bob@acme.example", "carol@acme.example", "dave@other.example"}},
+		{name: "case-insensitive equality", criteria: &repositories.Criteria{Field: "tenant", Op: "eq", Value: "Acme"}, wantTotal: 3, want: []string{"alice@acme.example", "bob@acme.example", "carol@acme.example"}},
+		{name: "paged", criteria: &repositories.Criteria{Field: "tenant", Op: "eq", Value: "acme"}, opts: repositories.ListOptions{Offset: 1, Limit: 1}, wantTotal: 3, want: []string{"bob@acme.example"}},
+		{name: "contains", criteria: &repositories.Criteria{Field: "email", Op: "co", Value: "O"}, wantTotal: 3, want: []string{"bob@acme.example", "carol@acme.example", "dave@other.example"}},
+		{name: "starts with", criteria: &repositories.Criteria{Field: "email", Op: "sw", Value: "al"}, wantTotal: 1, want: []string{"alice@acme.example"}},
+		{name: "boolean", criteria: &repositories.Criteria{Field: "disabled", Op: "eq", Value: true}, wantTotal: 1, want: []string{"bob@acme.example"}},
+		{name: "time range", criteria: &repositories.Criteria{Field: "created_at", Op: "gt", Value: at(2 * time.Minute)}, wantTotal: 2, want: []string{"carol@acme.example", "dave@other.example"}},
+		{name: "and, or, not", criteria: &repositories.Criteria{And: []repositories.Criteria{
+			{Or: []repositories.Criteria{{Field: "tenant", Op: "eq", Value: "other"}, {Field: "email", Op: "ew", Value: "@acme.example"}}},
+			{Not: &repositories.Criteria{Field: "disabled", Op: "eq", Value: true}},
+		}}, wantTotal: 3, want: []string{"alice@acme.example", "carol@acme.example", "dave@other.example"}},
+		{name: "present", criteria: &repositories.Criteria{Field: "external_id", Op: "pr"}, wantTotal: 4, want: []string{"alice@acme.example", "bob@acme.example", "carol@acme.example", "dave@other.example"}},
+		{name: "unknown column", criteria: &repositories.Criteria{Field: "password", Op: "eq", Value: "hash"}, wantKind: apperrors.KindValidation},
+		{name: "unknown operator", criteria: &repositories.Criteria{Field: "email", Op: "like", Value: "a"}, wantKind: apperrors.KindValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, total, err := repos.Users.FindUsers(ctx, tt.criteria, tt.opts)
+			if tt.wantKind != apperrors.KindInternal {
+				wantKind(t, err, tt.wantKind)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, user := range users {
+				got = append(got, user.Email)
+			}
+			if total != tt.wantTotal || fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("got %v of %d, want %v of %d", got, total, tt.want, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func testSessions(t *testing.T, open Open) {
+	ctx := context.Background()
+	repos, _ := open(t)
+	user := mustCreateUser(t, repos, newUser("ada@example.com"))
+	other := mustCreateUser(t, repos, newUser("alan@example.com"))
+	now := at(0)
+
+	newSession := func(userID uuid.UUID, lastSeen, expiresIn time.Duration) *models.Session {
+		t.Helper()
+		session := &models.Session{UserID: userID, IPAddress: "192.0.2.1", LastSeenAt: now.Add(lastSeen), ExpiresAt: now.Add(expiresIn)}
+		if err := repos.Sessions.CreateSession(ctx, session); err != nil {
+			t.Fatal(err)
+		}
+		return session
+	}
+	older := newSession(user.ID, -time.Hour, time.Hour)
+	newer := newSession(user.ID, -time.Minute, time.Hour)
+	expired := newSession(user.ID, -2*time.Hour, -time.Minute)
+	othersSession := newSession(other.ID, 0, time.Hour)
+
+	active := func(userID uuid.UUID) []uuid.UUID {
+		t.Helper()
+		sessions, err := repos.Sessions.ListActiveSessions(ctx, userID, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []uuid.UUID
+		for _, session := range sessions {
+			ids = append(ids, session.ID)
+		}
+		return ids
+	}
+
+	if got := active(user.ID); fmt.Sprint(got) != fmt.Sprint([]uuid.UUID{newer.ID, older.ID}) {
+		t.Fatalf("active sessions = %v, want the unexpired ones, most recently seen first", got)
+	}
+
+	if err := repos.Sessions.TouchSession(ctx, older.ID, now); err != nil {
+		t.Fatal(err)
+	}
+	if got := active(user.ID); fmt.Sprint(got) != fmt.Sprint([]uuid.UUID{older.ID, newer.ID}) {
+		t.Fatalf("after touching, active sessions = %v", got)
+	}
+
+	if err := repos.Sessions.RevokeSession(ctx, newer.ID, now); err != nil {
+		t.Fatal(err)
+	}
+	// Revoking again keeps the first revocation time
+	if err := repos.Sessions.RevokeSession(ctx, newer.ID, now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := repos.Sessions.GetSession(ctx, newer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked.RevokedAt == nil || !revoked.RevokedAt.Equal(now) {
+		t.Fatalf("revoked_at = %v, want %v", revoked.RevokedAt, now)
+	}
+
+	if err := repos.Sessions.RevokeUserSessions(ctx, user.ID, now); err != nil {
+		t.Fatal(err)
+	}
+	if got := active(user.ID); len(got) != 0 {
+		t.Fatalf("%d sessions still active after revoking them all", len(got))
+	}
+	if got := active(other.ID); fmt.Sprint(got) != fmt.Sprint([]uuid.UUID{othersSession.ID}) {
+		t.Fatal("revoking one user's sessions revoked another's")
+	}
+	if got, err := repos.Sessions.GetSession(ctx, expired.ID); err != nil || got.RevokedAt == nil {
+		t.Fatalf("expired session = %+v, %v; want it revoked too", got, err)
+	}
+
+	_, err = repos.Sessions.GetSession(ctx, uuid.New())
+	wantKind(t, err, apperrors.KindNotFound)
+}
+
+func testPasswordResets(t *testing.T, open Open) {
+	ctx := context.Background()
+	repos, _ := open(t)
+	user := mustCreateUser(t, repos, newUser("ada@example.com"))
+
+	token := &models.PasswordResetToken{UserID: user.ID, Token: "reset-token", ExpiresAt: at(time.Hour)}
+	if err := repos.PasswordResets.CreateToken(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repos.PasswordResets.GetToken(ctx, "reset-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != token.ID || got.UserID != user.ID || !got.ExpiresAt.Equal(token.ExpiresAt) {
+		t.Fatalf("got %+v, want %+v", got, token)
+	}
+
+	duplicate := &models.PasswordResetToken{UserID: user.ID, Token: "reset-token", ExpiresAt: at(time.Hour)}
+	wantKind(t, repos.PasswordResets.CreateToken(ctx, duplicate), apperrors.KindConflict)
+
+	if err := repos.PasswordResets.DeleteToken(ctx, token.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = repos.PasswordResets.GetToken(ctx, "reset-token")
+	wantKind(t, err, apperrors.KindNotFound)
+}
+
+func testRoles(t *testing.T, open Open) {
+	ctx := context.Background()
+	repos, _ := open(t)
+
+	editors := &models.Role{Name: "editors", Tenant: "acme", CreatedAt: at(0)}
+	if err := repos.Roles.CreateRole(ctx, editors); err != nil {
+		t.Fatal(err)
+	}
+	// Names are unique per tenant only
+	if err := repos.Roles.CreateRole(ctx, &models.Role{Name: "editors", Tenant: "other", CreatedAt: at(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	wantKind(t, repos.Roles.CreateRole(ctx, &models.Role{Name: "editors", Tenant: "acme"}), apperrors.KindConflict)
+
+	got, err := repos.Roles.GetRoleByName(ctx, "acme", "editors")
+	if err != nil || got.ID != editors.ID {
+		t.Fatalf("GetRoleByName = %v, %v", got, err)
+	}
+	_, err = repos.Roles.GetRoleByName(ctx, "nobody", "editors")
+	wantKind(t, err, apperrors.KindNotFound)
+
+	editors.Name, editors.ExternalID = "writers", "group-1"
+	if err := repos.Roles.UpdateRole(ctx, editors); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := repos.Roles.GetRoleByID(ctx, editors.ID); err != nil || got.Name != "writers" || got.ExternalID != "group-1" {
+		t.Fatalf("GetRoleByID = %v, %v after the update", got, err)
+	}
+
+	roles, total, err := repos.Roles.FindRoles(ctx, &repositories.Criteria{Field: "name", Op: "sw", Value: "EDIT"}, repositories.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(roles) != 1 || roles[0].Tenant != "other" {
+		t.Fatalf("FindRoles = %+v of %d, want the other tenant's editors", roles, total)
+	}
+
+	if err := repos.Roles.DeleteRole(ctx, editors.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = repos.Roles.GetRoleByID(ctx, editors.ID)
+	wantKind(t, err, apperrors.KindNotFound)
+}
+
+func testAuditLogs(t *testing.T, open Open) {
+	ctx := context.Background()
+	repos, _ := open(t)
+	actor, subject := uuid.New(), uuid.New()
+
+	for i, action := range []string{"first", "third", "second"} {
+		offset := map[string]time.Duration{"first": 0, "second": time.Minute, "third": 2 * time.Minute}[action]
+		entry := &models.AuditLog{Action: action, ActorID: actor, SubjectID: &subject, Status: 200 + i, CreatedAt: at(offset)}
+		if err := repos.AuditLogs.CreateEntry(ctx, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repos.AuditLogs.CreateEntry(ctx, &models.AuditLog{Action: "unrelated", ActorID: actor}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts repositories.ListOptions
+		want []string
+	}{
+		{name: "newest first", want: []string{"third", "second", "first"}},
+		{name: "paged", opts: repositories.ListOptions{Offset: 1, Limit: 1}, want: []string{"second"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := repos.AuditLogs.ListBySubject(ctx, subject, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, entry := range entries {
+				got = append(got, entry.Action)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func testWebAuthn(t *testing.T, open Open) {
+	ctx := context.Background()
+	repos, _ := open(t)
+	user := mustCreateUser(t, repos, newUser("ada@example.com"))
+	other := mustCreateUser(t, repos, newUser("alan@example.com"))
+
+	newCredential := func(userID uuid.UUID, id string, created time.Duration) *models.WebAuthnCredential {
+		return &models.WebAuthnCredential{UserID: userID, Name: id, CredentialID: []byte(id), PublicKey: []byte("key"), CreatedAt: at(created)}
+	}
+	second := newCredential(user.ID, "second", time.Minute)
+	first := newCredential(user.ID, "first", 0)
+	for _, credential := range []*models.WebAuthnCredential{second, first} {
+		if err := repos.WebAuthn.CreateCredential(ctx, credential); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wantKind(t, repos.WebAuthn.CreateCredential(ctx, newCredential(other.ID, "first", 0)), apperrors.KindConflict)
+
+	t.Run("list and look up", func(t *testing.T) {
+		credentials, err := repos.WebAuthn.ListCredentials(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(credentials) != 2 || credentials[0].ID != first.ID || credentials[1].ID != second.ID {
+			t.Fatalf("got %d credentials, want first then second", len(credentials))
+		}
+		got, err := repos.WebAuthn.GetCredentialByCredentialID(ctx, []byte("second"))
+		if err != nil || got.ID != second.ID {
+			t.Fatalf("GetCredentialByCredentialID = %v, %v", got, err)
+		}
+		_, err = repos.WebAuthn.GetCredentialByCredentialID(ctx, []byte("missing"))
+		wantKind(t, err, apperrors.KindNotFound)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		used := at(time.Hour)
+		first.SignCount, first.LastUsedAt = 7, &used
+		if err := repos.WebAuthn.UpdateCredential(ctx, first); err != nil {
+			t.Fatal(err)
+		}
+		got, err := repos.WebAuthn.GetCredentialByCredentialID(ctx, []byte("first"))
+		if err != nil || got.SignCount != 7 || got.LastUsedAt == nil || !got.LastUsedAt.Equal(used) {
+			t.Fatalf("got %+v, %v after the update", got, err)
+		}
+	})
+
+	t.Run("delete only your own", func(t *testing.T) {
+		deleted, err := repos.WebAuthn.DeleteCredential(ctx, other.ID, second.ID)
+		if err != nil || deleted {
+			t.Fatalf("another user deleted the passkey: %v, %v", deleted, err)
+		}
+		deleted, err = repos.WebAuthn.DeleteCredential(ctx, user.ID, second.ID)
+		if err != nil || !deleted {
+			t.Fatalf("DeleteCredential = %v, %v", deleted, err)
+		}
+	})
+
+	t.Run("challenges are consumed once", func(t *testing.T) {
+		now := at(0)
+		challenge := &models.WebAuthnChallenge{UserID: &user.ID, Ceremony: models.WebAuthnCeremonyLogin, SessionData: "{}", ExpiresAt: now.Add(time.Minute)}
+		if err := repos.WebAuthn.CreateChallenge(ctx, challenge); err != nil {
+			t.Fatal(err)
+		}
+		got, err := repos.WebAuthn.ConsumeChallenge(ctx, challenge.ID, now)
+		if err != nil || got.Ceremony != models.WebAuthnCeremonyLogin || got.UserID == nil || *got.UserID != user.ID {
+			t.Fatalf("ConsumeChallenge = %+v, %v", got, err)
+		}
+		_, err = repos.WebAuthn.ConsumeChallenge(ctx, challenge.ID, now)
+		wantKind(t, err, apperrors.KindNotFound)
+
+		expired := &models.WebAuthnChallenge{Ceremony: models.WebAuthnCeremonyLogin, SessionData: "{}", ExpiresAt: now}
+		if err := repos.WebAuthn.CreateChallenge(ctx, expired); err != nil {
+			t.Fatal(err)
+		}
+		_, err = repos.WebAuthn.ConsumeChallenge(ctx, expired.ID, now)
+		wantKind(t, err, apperrors.KindNotFound)
+	})
+}
+
+func testTransactions(t *testing.T, open Open) {
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+
+	exists := func(t *testing.T, repos repositories.Repositories, email string) bool {
+		t.Helper()
+		_, err := repos.Users.GetUserByEmail(ctx, email)
+		if err != nil && apperrors.KindOf(err) != apperrors.KindNotFound {
+			t.Fatal(err)
+		}
+		return err == nil
+	}
+
+	tests := []struct {
+		name    string
+		fn      func(ctx context.Context, tx repositories.TxManager, repos repositories.Repositories) error
+		wantErr error
+		want    map[string]bool
+	}{
+		{
+			name: "commit",
+			fn: func(ctx context.Context, _ repositories.TxManager, repos repositories.Repositories) error {
+				return repos.Users.CreateUser(ctx, newUser("a@example.com"))
+			},
+			want: map[string]bool{"a@example.com": true},
+		},
+		{
+			name: "rollback",
+			fn: func(ctx context.Context, _ repositories.TxManager, repos repositories.Repositories) error {
+				if err := repos.Users.CreateUser(ctx, newUser("a@example.com")); err != nil {
+					return err
+				}
+				return errAbort
+			},
+			wantErr: errAbort,
+			want:    map[string]bool{"a@example.com": false},
+		},
+		{
+			name: "nested rollback keeps the outer work",
+			fn: func(ctx context.Context, tx repositories.TxManager, repos repositories.Repositories) error {
+				if err := repos.Users.CreateUser(ctx, newUser("outer@example.com")); err != nil {
+					return err
+				}
+				err := tx.WithinTransaction(ctx, func(ctx context.Context, repos repositories.Repositories) error {
+					if err := repos.Users.CreateUser(ctx, newUser("inner@example.com")); err != nil {
+						return err
+					}
+					return errAbort
+				})
+				if !errors.Is(err, errAbort) {
+					return fmt.Errorf("nested transaction returned %v", err)
+				}
+				return nil
+			},
+			want: map[string]bool{"outer@example.com": true, "inner@example.com": false},
+		},
+		{
+			name: "a failed statement can be recovered from in a nested transaction",
+			fn: func(ctx context.Context, tx repositories.TxManager, repos repositories.Repositories) error {
+				if err := repos.Users.CreateUser(ctx, newUser("a@example.com")); err != nil {
+					return err
+				}
+				err := tx.WithinTransaction(ctx, func(ctx context.Context, repos repositories.Repositories) error {
+					return repos.Users.CreateUser(ctx, newUser("a@example.com"))
+				})
+				if apperrors.KindOf(err) != apperrors.KindConflict {
+					return fmt.Errorf("duplicate insert returned %v", err)
+				}
+				return repos.Users.CreateUser(ctx, newUser("b@example.com"))
+			},
+			want: map[string]bool{"a@example.com": true, "b@example.com": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repos, tx := open(t)
+			err := tx.WithinTransaction(ctx, func(ctx context.Context, txRepos repositories.Repositories) error {
+				return tt.fn(ctx, tx, txRepos)
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("WithinTransaction = %v, want %v", err, tt.wantErr)
+			}
+			for email, want := range tt.want {
+				if got := exists(t, repos, email); got != want {
+					t.Errorf("%s exists = %v, want %v", email, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+// repositories/role_repository.go
+package repositories
+
+import (
+	"context"
+	"github.com/ABDULS21985/test-portal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RoleRepository defines the contract for role persistence
+type RoleRepository interface {
+	CreateRole(ctx context.Context, role *models.Role) error
+	GetRoleByID(ctx context.Context, id uuid.UUID) (*models.Role, error)
+	GetRoleByName(ctx context.Context, tenant, name string) (*models.Role, error)
+	UpdateRole(ctx context.Context, role *models.Role) error
+	DeleteRole(ctx context.Context, id uuid.UUID) error
+	FindRoles(ctx context.Context, criteria *Criteria, opts ListOptions) ([]models.Role, int64, error)
+}
+
+// roleColumns are the columns FindRoles may filter on
+var roleColumns = map[string]bool{
+	"id": true, "name": true, "tenant": true, "external_id": true, "created_at": true, "updated_at": true,
+}
+
+type roleRepository struct {
+	db *gorm.DB
+}
+
+// NewRoleRepository creates a new instance of roleRepository
+func NewRoleRepository(db *gorm.DB) RoleRepository {
+	return &roleRepository{
+		db: db,
+	}
+}
+
+func (r *roleRepository) CreateRole(ctx context.Context, role *models.Role) error {
+	return translateError(r.db.WithContext(ctx).Create(role).Error, "role")
+}
+
+func (r *roleRepository) GetRoleByID(ctx context.Context, id uuid.UUID) (*models.Role, error) {
+	var role models.Role
+	if err := r.db.WithContext(ctx).First(&role, "id = ?", id).Error; err != nil {
+		return nil, translateError(err, "role")
+	}
+	return &role, nil
+}
+
+func (r *roleRepository) GetRoleByName(ctx context.Context, tenant, name string) (*models.Role, error) {
+	var role models.Role
+	if err := r.db.WithContext(ctx).First(&role, "tenant = ? AND name = ?", tenant, name).Error; err != nil {
+		return nil, translateError(err, "role")
+	}
+	return &role, nil
+}
+
+func (r *roleRepository) UpdateRole(ctx context.Context, role *models.Role) error {
+	return translateError(r.db.WithContext(ctx).Save(role).Error, "role")
+}
+
+func (r *roleRepository) DeleteRole(ctx context.Context, id uuid.UUID) error {
+	return translateError(r.db.WithContext(ctx).Delete(&models.Role{}, "id = ?", id).Error, "role")
+}
+
+func (r *roleRepository) FindRoles(ctx context.Context, criteria *Criteria, opts ListOptions) ([]models.Role, int64, error) {
+	query, err := applyCriteria(r.db.WithContext(ctx).Model(&models.Role{}), criteria, roleColumns)
+	if err != nil {
+		return nil, 0, translateError(err, "role")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, translateError(err, "role")
+	}
+
+	var roles []models.Role
+	if err := applyListOptions(query.Order("created_at, id"), opts).Find(&roles).Error; err != nil {
+		return nil, 0, translateError(err, "role")
+	}
+	return roles, total, nil
+}
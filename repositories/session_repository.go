@@ -0,0 +1,75 @@
+// repositories/session_repository.go
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SessionRepository defines the contract for login session persistence
+type SessionRepository interface {
+	CreateSession(ctx context.Context, session *models.Session) error
+	GetSession(ctx context.Context, id uuid.UUID) (*models.Session, error)
+	ListActiveSessions(ctx context.Context, userID uuid.UUID, now time.Time) ([]models.Session, error)
+	TouchSession(ctx context.Context, id uuid.UUID, lastSeen time.Time) error
+	RevokeSession(ctx context.Context, id uuid.UUID, revokedAt time.Time) error
+	RevokeUserSessions(ctx context.Context, userID uuid.UUID, revokedAt time.Time) error
+}
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+// NewSessionRepository creates a new instance of sessionRepository
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &sessionRepository{
+		db: db,
+	}
+}
+
+func (r *sessionRepository) CreateSession(ctx context.Context, session *models.Session) error {
+	return translateError(r.db.WithContext(ctx).Create(session).Error, "session")
+}
+
+func (r *sessionRepository) GetSession(ctx context.Context, id uuid.UUID) (*models.Session, error) {
+	var session models.Session
+	if err := r.db.WithContext(ctx).First(&session, "id = ?", id).Error; err != nil {
+		return nil, translateError(err, "session")
+	}
+	return &session, nil
+}
+
+func (r *sessionRepository) ListActiveSessions(ctx context.Context, userID uuid.UUID, now time.Time) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, translateError(err, "session")
+	}
+	return sessions, nil
+}
+
+func (r *sessionRepository) TouchSession(ctx context.Context, id uuid.UUID, lastSeen time.Time) error {
+	return translateError(r.db.WithContext(ctx).Model(&models.Session{}).Where("id = ?", id).Update("last_seen_at", lastSeen).Error, "session")
+}
+
+func (r *sessionRepository) RevokeSession(ctx context.Context, id uuid.UUID, revokedAt time.Time) error {
+	err := r.db.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", revokedAt).Error
+	return translateError(err, "session")
+}
+
+func (r *sessionRepository) RevokeUserSessions(ctx context.Context, userID uuid.UUID, revokedAt time.Time) error {
+	err := r.db.WithContext(ctx).Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", revokedAt).Error
+	return translateError(err, "session")
+}
@@ -0,0 +1,108 @@
+// repositories/tx_manager.go
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// Repositories bundles one instance of every repository, all bound to the same database
+// handle or transaction
+type Repositories struct {
+	Users          UserRepository
+	PasswordResets PasswordResetRepository
+	Roles          RoleRepository
+	AuditLogs      AuditLogRepository
+	Sessions       SessionRepository
+	WebAuthn       WebAuthnRepository
+}
+
+// NewRepositories creates every repository on top of db
+func NewRepositories(db *gorm.DB) Repositories {
+	return Repositories{
+		Users:          NewUserRepository(db),
+		PasswordResets: NewPasswordResetRepository(db),
+		Roles:          NewRoleRepository(db),
+		AuditLogs:      NewAuditLogRepository(db),
+		Sessions:       NewSessionRepository(db),
+		WebAuthn:       NewWebAuthnRepository(db),
+	}
+}
+
+// TxManager runs multi-step workflows atomically
+type TxManager interface {
+	// WithinTransaction calls fn with repositories bound to a transaction, committing when fn
+	// returns nil and rolling back otherwise. Called again with the ctx it passed to fn, it nests
+	// through a savepoint, so only the inner work is undone when the inner fn fails.
+	//
+	// The outermost transaction is retried from the start when Postgres aborts it with a
+	// serialization failure or deadlock, so fn must not have side effects outside the database.
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context, repos Repositories) error, opts ...*sql.TxOptions) error
+}
+
+// Retry policy for serialization failures and deadlocks
+const (
+	maxTxAttempts = 5
+	txRetryDelay  = 10 * time.Millisecond
+)
+
+// Postgres SQLSTATEs after which a transaction can succeed when simply run again
+const (
+	sqlStateSerializationFailure = "40001"
+	sqlStateDeadlockDetected     = "40P01"
+)
+
+type txKey struct{}
+
+type txManager struct {
+	db *gorm.DB
+}
+
+// NewTxManager creates a new instance of txManager
+func NewTxManager(db *gorm.DB) TxManager {
+	return &txManager{
+		db: db,
+	}
+}
+
+func (m *txManager) WithinTransaction(ctx context.Context, fn func(ctx context.Context, repos Repositories) error, opts ...*sql.TxOptions) error {
+	// Nested: GORM turns a transaction inside a transaction into a savepoint
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx).Transaction(func(sp *gorm.DB) error {
+			return fn(context.WithValue(ctx, txKey{}, sp), NewRepositories(sp))
+		})
+	}
+
+	delay := txRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return fn(context.WithValue(ctx, txKey{}, tx), NewRepositories(tx))
+		}, opts...)
+		if err == nil || attempt == maxTxAttempts || !isRetryable(err) {
+			return err
+		}
+
+		// Jitter keeps the conflicting transactions from colliding again in lockstep
+		select {
+		case <-time.After(delay/2 + rand.N(delay)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		delay *= 2
+	}
+}
+
+// isRetryable reports whether err is a serialization failure or deadlock
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == sqlStateSerializationFailure || pgErr.Code == sqlStateDeadlockDetected
+}
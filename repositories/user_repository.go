@@ -2,6 +2,8 @@
 package repositories
 
 import (
+	"context"
+	"github.com/ABDULS21985/test-portal/encryption"
 	"github.com/ABDULS21985/test-portal/models"
 
 	"github.com/google/uuid"
@@ -9,13 +11,27 @@ import (
 )
 
 type UserRepository interface {
-	CreateUser(user *models.User) error
-	GetUserByID(id uuid.UUID) (*models.User, error)
-	GetUserByEmail(email string) (*models.User, error)
-	UpdateUser(user *models.User) error
-	DeleteUser(id uuid.UUID) error
+	CreateUser(ctx context.Context, user *models.User) error
+	GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	GetUserByPhone(ctx context.Context, phone string) (*models.User, error)
+	UpdateUser(ctx context.Context, user *models.User) error
+	UpdateUserProfile(ctx context.Context, user *models.User) error
+	DeleteUser(ctx context.Context, id uuid.UUID) error
+	ListUsersByAuthSource(ctx context.Context, source string) ([]models.User, error)
+	FindUsers(ctx context.Context, criteria *Criteria, opts ListOptions) ([]models.User, int64, error)
 }
 
+// userColumns are the columns FindUsers may filter on
+var userColumns = map[string]bool{
+	"id": true, "name": true, "email": true, "role": true, "tenant": true, "external_id": true,
+	"auth_source": true, "disabled": true, "created_at": true, "updated_at": true,
+}
+
+// profileColumns are the columns UpdateUserProfile writes; the save hook derives phone_index
+// and updated_at
+var profileColumns = []string{"name", "email", "password", "phone", "phone_index", "date_of_birth", "updated_at"}
+
 type userRepository struct {
 	db *gorm.DB
 }
@@ -26,30 +42,86 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 	}
 }
 
-func (r *userRepository) CreateUser(user *models.User) error {
-	return r.db.Create(user).Error
+func (r *userRepository) CreateUser(ctx context.Context, user *models.User) error {
+	return translateError(r.db.WithContext(ctx).Create(user).Error, "user")
 }
 
-func (r *userRepository) GetUserByID(id uuid.UUID) (*models.User, error) {
+func (r *userRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
 	var user models.User
-	if err := r.db.First(&user, "id = ?", id).Error; err != nil {
-		return nil, err
+	if err := r.db.WithContext(ctx).First(&user, "id = ?", id).Error; err != nil {
+		return nil, translateError(err, "user")
 	}
 	return &user, nil
 }
 
-func (r *userRepository) GetUserByEmail(email string) (*models.User, error) {
+func (r *userRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
 	var user models.User
-	if err := r.db.First(&user, "email = ?", email).Error; err != nil {
+	if err := r.db.WithContext(ctx).First(&user, "email = ?", email).Error; err != nil {
+		return nil, translateError(err, "user")
+	}
+	return &user, nil
+}
+
+// GetUserByPhone looks a user up by the blind index of their encrypted phone number
+func (r *userRepository) GetUserByPhone(ctx context.Context, phone string) (*models.User, error) {
+	index, err := encryption.BlindIndex(phone)
+	if err != nil {
 		return nil, err
 	}
+	if index == "" {
+		return nil, translateError(gorm.ErrRecordNotFound, "user")
+	}
+
+	var user models.User
+	if err := r.db.WithContext(ctx).First(&user, "phone_index = ?", index).Error; err != nil {
+		return nil, translateError(err, "user")
+	}
 	return &user, nil
 }
 
-func (r *userRepository) UpdateUser(user *models.User) error {
-	return r.db.Save(user).Error
+func (r *userRepository) UpdateUser(ctx context.Context, user *models.User) error {
+	return translateError(r.db.WithContext(ctx).Save(user).Error, "user")
+}
+
+// UpdateUserProfile writes only the profile columns of user, so concurrent changes to its role,
+// tenant or account state are kept
+func (r *userRepository) UpdateUserProfile(ctx context.Context, user *models.User) error {
+	result := r.db.WithContext(ctx).Model(user).Select(profileColumns).Updates(user)
+	if result.Error != nil {
+		return translateError(result.Error, "user")
+	}
+	if result.RowsAffected == 0 {
+		return translateError(gorm.ErrRecordNotFound, "user")
+	}
+	return nil
 }
 
-func (r *userRepository) DeleteUser(id uuid.UUID) error {
-	return r.db.Delete(&models.User{}, "id = ?", id).Error
+func (r *userRepository) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	return translateError(r.db.WithContext(ctx).Delete(&models.User{}, "id = ?", id).Error, "user")
+}
+
+func (r *userRepository) ListUsersByAuthSource(ctx context.Context, source string) ([]models.User, error) {
+	var users []models.User
+	if err := r.db.WithContext(ctx).Where("auth_source = ?", source).Find(&users).Error; err != nil {
+		return nil, translateError(err, "user")
+	}
+	return users, nil
+}
+
+func (r *userRepository) FindUsers(ctx context.Context, criteria *Criteria, opts ListOptions) ([]models.User, int64, error) {
+	query, err := applyCriteria(r.db.WithContext(ctx).Model(&models.User{}), criteria, userColumns)
+	if err != nil {
+		return nil, 0, translateError(err, "user")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, translateError(err, "user")
+	}
+
+	var users []models.User
+	if err := applyListOptions(query.Order("created_at, id"), opts).Find(&users).Error; err != nil {
+		return nil, 0, translateError(err, "user")
+	}
+	return users, total, nil
 }
@@ -0,0 +1,84 @@
+// repositories/webauthn_repository.go
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WebAuthnRepository defines the contract for passkey credentials and ceremony challenges
+type WebAuthnRepository interface {
+	CreateCredential(ctx context.Context, credential *models.WebAuthnCredential) error
+	ListCredentials(ctx context.Context, userID uuid.UUID) ([]models.WebAuthnCredential, error)
+	GetCredentialByCredentialID(ctx context.Context, credentialID []byte) (*models.WebAuthnCredential, error)
+	UpdateCredential(ctx context.Context, credential *models.WebAuthnCredential) error
+	DeleteCredential(ctx context.Context, userID, id uuid.UUID) (bool, error)
+
+	CreateChallenge(ctx context.Context, challenge *models.WebAuthnChallenge) error
+	ConsumeChallenge(ctx context.Context, id uuid.UUID, now time.Time) (*models.WebAuthnChallenge, error)
+}
+
+type webAuthnRepository struct {
+	db *gorm.DB
+}
+
+// NewWebAuthnRepository creates a new instance of webAuthnRepository
+func NewWebAuthnRepository(db *gorm.DB) WebAuthnRepository {
+	return &webAuthnRepository{
+		db: db,
+	}
+}
+
+func (r *webAuthnRepository) CreateCredential(ctx context.Context, credential *models.WebAuthnCredential) error {
+	return translateError(r.db.WithContext(ctx).Create(credential).Error, "passkey")
+}
+
+func (r *webAuthnRepository) ListCredentials(ctx context.Context, userID uuid.UUID) ([]models.WebAuthnCredential, error) {
+	var credentials []models.WebAuthnCredential
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&credentials).Error; err != nil {
+		return nil, translateError(err, "passkey")
+	}
+	return credentials, nil
+}
+
+func (r *webAuthnRepository) GetCredentialByCredentialID(ctx context.Context, credentialID []byte) (*models.WebAuthnCredential, error) {
+	var credential models.WebAuthnCredential
+	if err := r.db.WithContext(ctx).First(&credential, "credential_id = ?", credentialID).Error; err != nil {
+		return nil, translateError(err, "passkey")
+	}
+	return &credential, nil
+}
+
+func (r *webAuthnRepository) UpdateCredential(ctx context.Context, credential *models.WebAuthnCredential) error {
+	return translateError(r.db.WithContext(ctx).Save(credential).Error, "passkey")
+}
+
+func (r *webAuthnRepository) DeleteCredential(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	result := r.db.WithContext(ctx).Delete(&models.WebAuthnCredential{}, "id = ? AND user_id = ?", id, userID)
+	return result.RowsAffected > 0, translateError(result.Error, "passkey")
+}
+
+func (r *webAuthnRepository) CreateChallenge(ctx context.Context, challenge *models.WebAuthnChallenge) error {
+	return translateError(r.db.WithContext(ctx).Create(challenge).Error, "passkey challenge")
+}
+
+// ConsumeChallenge deletes and returns an unexpired challenge, so each one can be answered only once
+func (r *webAuthnRepository) ConsumeChallenge(ctx context.Context, id uuid.UUID, now time.Time) (*models.WebAuthnChallenge, error) {
+	var challenges []models.WebAuthnChallenge
+	err := r.db.WithContext(ctx).Clauses(clause.Returning{}).
+		Where("id = ? AND expires_at > ?", id, now).
+		Delete(&challenges).Error
+	if err != nil {
+		return nil, translateError(err, "passkey challenge")
+	}
+	if len(challenges) == 0 {
+		return nil, translateError(gorm.ErrRecordNotFound, "passkey challenge")
+	}
+	return &challenges[0], nil
+}
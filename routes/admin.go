@@ -0,0 +1,74 @@
+// routes/admin.go
+package routes
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/app"
+	"github.com/ABDULS21985/test-portal/controllers"
+	"github.com/ABDULS21985/test-portal/middleware"
+	"github.com/ABDULS21985/test-portal/openapi"
+)
+
+// adminModule serves the admin area and the support tooling
+type adminModule struct{}
+
+// legacyAdminArea retires /protected/admin, which predates versioning, in favour of /api/v1/admin
+var legacyAdminArea = middleware.DeprecationOptions{
+	Since:     time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC),
+	Sunset:    time.Date(2027, time.April, 19, 0, 0, 0, 0, time.UTC),
+	Successor: "/api/v1/admin",
+}
+
+func (adminModule) Name() string { return "admin" }
+
+func (adminModule) RegisterRoutes(a *app.App) {
+	impersonationController := controllers.NewImpersonationController(a.Services.Auth, a.Services.Audit, a.Config.ImpersonationTTL, a.Logger)
+
+	// Admin area, also served at its deprecated path until the sunset
+	protected := middleware.Chain(a.Middleware.Auth.RequireAuth, a.Middleware.Impersonation.Guard, a.RateLimit("api", middleware.ByUser))
+	greeting := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Welcome, Admin!"))
+	})
+	a.API.Handle("/admin", protected(greeting)).Methods("GET")
+	a.Router.Handle("/protected/admin", middleware.Deprecated(legacyAdminArea)(protected(greeting))).Methods("GET")
+
+	// Support tooling (admin only)
+	support := a.API.PathPrefix("/admin").Subrouter()
+	support.Use(a.Middleware.Auth.RequireAuth, a.Middleware.Impersonation.Guard, a.Middleware.Auth.RequireRole("admin"), a.RateLimit("api", middleware.ByUser))
+	support.HandleFunc("/impersonate/{id}", impersonationController.Impersonate).Methods("POST")
+}
+
+func (adminModule) Document(a *app.App, doc *openapi.Document) {
+	doc.Tags = append(doc.Tags, openapi.Tag{Name: "admin", Description: "Admin area and support tooling"})
+	tags := []string{"admin"}
+	secured := openapi.Secured(app.BearerAuth)
+
+	doc.Add("GET", "/api/v1/admin", openapi.Operation{
+		Summary:   "Admin area greeting",
+		Tags:      tags,
+		Security:  secured,
+		Responses: map[string]*openapi.Response{"200": doc.ResponseAs("text/plain", "A greeting", &openapi.Schema{Type: "string"})},
+	})
+	doc.Add("GET", "/protected/admin", openapi.Operation{
+		Summary:     "Admin area greeting (deprecated)",
+		Description: "Replaced by /api/v1/admin and removed after " + legacyAdminArea.Sunset.Format(time.DateOnly) + ".",
+		Tags:        tags,
+		Security:    secured,
+		Deprecated:  true,
+		Responses:   map[string]*openapi.Response{"200": doc.ResponseAs("text/plain", "A greeting", &openapi.Schema{Type: "string"})},
+	})
+	doc.Add("POST", "/api/v1/admin/impersonate/{id}", openapi.Operation{
+		Summary:     "Impersonate a user",
+		Description: "Admins only. The token acts as the user for a short time and every use is audited.",
+		Tags:        tags,
+		Security:    secured,
+		RequestBody: doc.Body(controllers.ImpersonationRequest{}),
+		Responses: map[string]*openapi.Response{
+			"200": doc.JSON("A token acting as the user", controllers.ImpersonationResponse{}),
+			"403": doc.Problem("The caller is not an admin, or is already impersonating"),
+			"404": doc.Problem("No such user"),
+		},
+	})
+}
@@ -0,0 +1,120 @@
+// routes/auth.go
+package routes
+
+import (
+	"net/http"
+
+	"github.com/ABDULS21985/test-portal/app"
+	"github.com/ABDULS21985/test-portal/controllers"
+	"github.com/ABDULS21985/test-portal/middleware"
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/openapi"
+	"github.com/ABDULS21985/test-portal/services"
+)
+
+// authModule serves password and passkey login and passkey management, and keeps LDAP users in
+// sync with the directory
+type authModule struct{}
+
+func (authModule) Name() string { return "auth" }
+
+func (authModule) RegisterRoutes(a *app.App) {
+	authController := controllers.NewAuthController(a.Services.Auth, a.Services.WebAuthn, a.Metrics)
+	webAuthnController := controllers.NewWebAuthnController(a.Services.WebAuthn, a.Services.Auth, a.Metrics)
+
+	// Auth Routes (Public), sharing one limit per client address
+	limitLogin := a.RateLimit("auth", middleware.ByIP)
+	a.API.Handle("/auth/login", limitLogin(http.HandlerFunc(authController.LoginUser))).Methods("POST")
+	a.API.Handle("/auth/webauthn/login/begin", limitLogin(http.HandlerFunc(webAuthnController.BeginLogin))).Methods("POST")
+	a.API.Handle("/auth/webauthn/login/finish", limitLogin(http.HandlerFunc(webAuthnController.FinishLogin))).Methods("POST")
+
+	// Passkey management (authenticated)
+	passkeys := a.API.PathPrefix("/auth/webauthn").Subrouter()
+	passkeys.Use(a.Middleware.Auth.RequireAuth, a.Middleware.Impersonation.Guard, a.RateLimit("api", middleware.ByUser))
+	passkeys.HandleFunc("/register/begin", webAuthnController.BeginRegistration).Methods("POST")
+	passkeys.HandleFunc("/register/finish", webAuthnController.FinishRegistration).Methods("POST")
+	passkeys.HandleFunc("/credentials", webAuthnController.ListCredentials).Methods("GET")
+	passkeys.HandleFunc("/credentials/{id}", webAuthnController.DeleteCredential).Methods("DELETE")
+}
+
+// Jobs syncs LDAP users in the background when a directory is configured
+func (authModule) Jobs(a *app.App) ([]app.Job, error) {
+	if a.Services.LDAP == nil {
+		return nil, nil
+	}
+	syncer, err := services.NewLDAPSyncer(a.Services.LDAP, a.Repos.Users, a.Config.LDAPSyncInterval, a.Logger)
+	if err != nil {
+		return nil, err
+	}
+	return []app.Job{syncer.Start}, nil
+}
+
+func (authModule) Document(a *app.App, doc *openapi.Document) {
+	doc.Tags = append(doc.Tags, openapi.Tag{Name: "auth", Description: "Password and passkey login, and passkey management"})
+	tags := []string{"auth"}
+	secured := openapi.Secured(app.BearerAuth)
+
+	doc.Add("POST", "/api/v1/auth/login", openapi.Operation{
+		Summary:     "Log in with a password",
+		Description: "Users with a passkey get a challenge instead of a token and finish at /api/v1/auth/webauthn/login/finish.",
+		Tags:        tags,
+		RequestBody: doc.Body(controllers.LoginRequest{}),
+		Responses: map[string]*openapi.Response{
+			"200": doc.JSON("A token, or a passkey challenge", openapi.OneOf(doc.Schema(controllers.TokenResponse{}), doc.Schema(controllers.MFAChallengeResponse{}))),
+			"401": doc.Problem("Invalid credentials"),
+			"429": doc.Problem("Too many login attempts"),
+		},
+	})
+	beginLogin := doc.Body(controllers.BeginLoginRequest{})
+	beginLogin.Required = false
+	doc.Add("POST", "/api/v1/auth/webauthn/login/begin", openapi.Operation{
+		Summary:     "Start a passkey login",
+		Description: "Without an email the browser offers any discoverable passkey.",
+		Tags:        tags,
+		RequestBody: beginLogin,
+		Responses: map[string]*openapi.Response{
+			"200": doc.JSON("Options for navigator.credentials.get()", controllers.CeremonyResponse{}),
+			"429": doc.Problem("Too many login attempts"),
+		},
+	})
+	doc.Add("POST", "/api/v1/auth/webauthn/login/finish", openapi.Operation{
+		Summary:     "Finish a passkey login",
+		Description: "Completes a passwordless login or the second factor of a password login.",
+		Tags:        tags,
+		RequestBody: doc.Body(controllers.FinishLoginRequest{}),
+		Responses: map[string]*openapi.Response{
+			"200": doc.JSON("The user and their token", controllers.TokenResponse{}),
+			"401": doc.Problem("Invalid credentials"),
+			"429": doc.Problem("Too many login attempts"),
+		},
+	})
+
+	doc.Add("POST", "/api/v1/auth/webauthn/register/begin", openapi.Operation{
+		Summary:   "Start registering a passkey",
+		Tags:      tags,
+		Security:  secured,
+		Responses: map[string]*openapi.Response{"200": doc.JSON("Options for navigator.credentials.create()", controllers.CeremonyResponse{})},
+	})
+	doc.Add("POST", "/api/v1/auth/webauthn/register/finish", openapi.Operation{
+		Summary:     "Finish registering a passkey",
+		Tags:        tags,
+		Security:    secured,
+		RequestBody: doc.Body(controllers.FinishRegistrationRequest{}),
+		Responses:   map[string]*openapi.Response{"201": doc.JSON("The new passkey", models.WebAuthnCredential{})},
+	})
+	doc.Add("GET", "/api/v1/auth/webauthn/credentials", openapi.Operation{
+		Summary:   "List your passkeys",
+		Tags:      tags,
+		Security:  secured,
+		Responses: map[string]*openapi.Response{"200": doc.JSON("Your passkeys", []models.WebAuthnCredential{})},
+	})
+	doc.Add("DELETE", "/api/v1/auth/webauthn/credentials/{id}", openapi.Operation{
+		Summary:  "Delete one of your passkeys",
+		Tags:     tags,
+		Security: secured,
+		Responses: map[string]*openapi.Response{
+			"200": doc.JSON("The passkey was deleted", controllers.MessageResponse{}),
+			"404": doc.Problem("No such passkey"),
+		},
+	})
+}
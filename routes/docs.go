@@ -0,0 +1,32 @@
+// routes/docs.go
+package routes
+
+import (
+	"github.com/ABDULS21985/test-portal/app"
+	"github.com/ABDULS21985/test-portal/openapi"
+)
+
+// docsModule serves the OpenAPI document and a page browsing it
+type docsModule struct{}
+
+func (docsModule) Name() string { return "docs" }
+
+func (docsModule) RegisterRoutes(a *app.App) {
+	a.Router.Handle("/openapi.json", a.OpenAPI.Handler()).Methods("GET")
+	a.Router.PathPrefix("/docs/").Handler(openapi.DocsHandler("/docs/")).Methods("GET")
+}
+
+func (docsModule) Document(a *app.App, doc *openapi.Document) {
+	doc.Tags = append(doc.Tags, openapi.Tag{Name: "docs", Description: "This document and its documentation page"})
+
+	doc.Add("GET", "/openapi.json", openapi.Operation{
+		Summary:   "OpenAPI document",
+		Tags:      []string{"docs"},
+		Responses: map[string]*openapi.Response{"200": doc.JSON("The OpenAPI 3.1 document describing every route", &openapi.Schema{Type: "object"})},
+	})
+	doc.Add("GET", "/docs/", openapi.Operation{
+		Summary:   "Documentation page",
+		Tags:      []string{"docs"},
+		Responses: map[string]*openapi.Response{"200": doc.ResponseAs("text/html", "A page rendering this document, and its assets", &openapi.Schema{Type: "string"})},
+	})
+}
@@ -0,0 +1,64 @@
+// routes/health.go
+package routes
+
+import (
+	"github.com/ABDULS21985/test-portal/app"
+	"github.com/ABDULS21985/test-portal/buildinfo"
+	"github.com/ABDULS21985/test-portal/controllers"
+	"github.com/ABDULS21985/test-portal/openapi"
+	"github.com/ABDULS21985/test-portal/services"
+)
+
+// healthModule serves the probes, build information and metrics
+type healthModule struct{}
+
+func (healthModule) Name() string { return "health" }
+
+func (healthModule) RegisterRoutes(a *app.App) {
+	healthController := controllers.NewHealthController(a.Services.Health)
+
+	// Probes and build information (public)
+	a.Router.HandleFunc("/healthz", healthController.Liveness).Methods("GET", "HEAD")
+	a.Router.HandleFunc("/readyz", healthController.Readiness).Methods("GET", "HEAD")
+	a.Router.HandleFunc("/version", healthController.Version).Methods("GET")
+	a.Router.Handle("/metrics", a.Metrics.Handler()).Methods("GET")
+}
+
+func (healthModule) Document(a *app.App, doc *openapi.Document) {
+	doc.Tags = append(doc.Tags, openapi.Tag{Name: "health", Description: "Probes, build information and metrics"})
+
+	doc.Add("GET", "/healthz", openapi.Operation{
+		Summary:     "Liveness probe",
+		Description: "Answers while the process serves requests; no dependency is checked. HEAD is also accepted.",
+		Tags:        []string{"health"},
+		Responses: map[string]*openapi.Response{
+			"200": doc.JSON("The process is up", &openapi.Schema{
+				Type:       "object",
+				Properties: map[string]*openapi.Schema{"status": {Type: "string", Enum: []string{services.HealthStatusOK}}},
+			}),
+		},
+	})
+	doc.Add("GET", "/readyz", openapi.Operation{
+		Summary:     "Readiness probe",
+		Description: "Runs the dependency checks. HEAD is also accepted.",
+		Tags:        []string{"health"},
+		Responses: map[string]*openapi.Response{
+			"200": doc.JSON("Every critical check passed", services.HealthReport{}),
+			"503": doc.JSON("A critical check failed", services.HealthReport{}),
+		},
+	})
+	doc.Add("GET", "/version", openapi.Operation{
+		Summary:   "Build information",
+		Tags:      []string{"health"},
+		Responses: map[string]*openapi.Response{"200": doc.JSON("The running build", buildinfo.Info{})},
+	})
+	doc.Add("GET", "/metrics", openapi.Operation{
+		Summary:     "Prometheus metrics",
+		Description: "Requires METRICS_BEARER_TOKEN as a bearer token when one is configured.",
+		Tags:        []string{"health"},
+		Responses: map[string]*openapi.Response{
+			"200": doc.ResponseAs("text/plain", "Metrics in the Prometheus exposition format", &openapi.Schema{Type: "string"}),
+			"401": openapi.Empty("Missing or wrong token"),
+		},
+	})
+}
@@ -0,0 +1,43 @@
+// routes/routes_test.go
+package routes
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/ABDULS21985/test-portal/app"
+	"github.com/ABDULS21985/test-portal/config"
+	"github.com/ABDULS21985/test-portal/repositories/memory"
+)
+
+// TestRoutesDocumented fails when a module registers a route the OpenAPI document doesn't describe
+func TestRoutesDocumented(t *testing.T) {
+	t.Setenv("JWT_SECRET", "a-test-signing-secret")
+	t.Setenv("ENCRYPTION_KEY", strings.Repeat("ab", 32))
+	cfg, _, err := config.Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := memory.NewStore()
+	repos := store.Repositories()
+	application, err := app.New(cfg, app.Options{
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Repositories: &repos,
+		TxManager:    memory.NewTxManager(store),
+		Modules:      Modules(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	undocumented, err := application.UndocumentedRoutes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(undocumented) > 0 {
+		t.Fatalf("routes missing from the OpenAPI document:\n  %s", strings.Join(undocumented, "\n  "))
+	}
+}
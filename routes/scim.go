@@ -0,0 +1,116 @@
+// routes/scim.go
+package routes
+
+import (
+	"github.com/ABDULS21985/test-portal/app"
+	"github.com/ABDULS21985/test-portal/controllers"
+	"github.com/ABDULS21985/test-portal/middleware"
+	"github.com/ABDULS21985/test-portal/openapi"
+	"github.com/ABDULS21985/test-portal/scim"
+)
+
+// scimModule serves SCIM 2.0 provisioning, authenticated with per-tenant bearer tokens
+type scimModule struct{}
+
+func (scimModule) Name() string { return "scim" }
+
+func (scimModule) RegisterRoutes(a *app.App) {
+	scimController := controllers.NewSCIMController(a.Services.SCIM, a.Logger)
+
+	// SCIM 2.0 provisioning (per-tenant bearer token, each with its own limit)
+	scimRouter := a.Router.PathPrefix("/scim/v2").Subrouter()
+	scimRouter.Use(a.Middleware.SCIMAuth.RequireTenantToken, a.RateLimit("scim", middleware.ByAPIKey("Authorization")))
+	scimRouter.HandleFunc("/ServiceProviderConfig", scimController.ServiceProviderConfig).Methods("GET")
+	scimRouter.HandleFunc("/Users", scimController.ListUsers).Methods("GET")
+	scimRouter.HandleFunc("/Users", scimController.CreateUser).Methods("POST")
+	scimRouter.HandleFunc("/Users/{id}", scimController.GetUser).Methods("GET")
+	scimRouter.HandleFunc("/Users/{id}", scimController.ReplaceUser).Methods("PUT")
+	scimRouter.HandleFunc("/Users/{id}", scimController.PatchUser).Methods("PATCH")
+	scimRouter.HandleFunc("/Users/{id}", scimController.DeleteUser).Methods("DELETE")
+	scimRouter.HandleFunc("/Groups", scimController.ListGroups).Methods("GET")
+	scimRouter.HandleFunc("/Groups", scimController.CreateGroup).Methods("POST")
+	scimRouter.HandleFunc("/Groups/{id}", scimController.GetGroup).Methods("GET")
+	scimRouter.HandleFunc("/Groups/{id}", scimController.ReplaceGroup).Methods("PUT")
+	scimRouter.HandleFunc("/Groups/{id}", scimController.PatchGroup).Methods("PATCH")
+	scimRouter.HandleFunc("/Groups/{id}", scimController.DeleteGroup).Methods("DELETE")
+}
+
+// scimTokenAuth names the security scheme of the SCIM routes
+const scimTokenAuth = "scimToken"
+
+func (scimModule) Document(a *app.App, doc *openapi.Document) {
+	doc.Tags = append(doc.Tags, openapi.Tag{Name: "scim", Description: "SCIM 2.0 provisioning (RFC 7644)"})
+	doc.Components.SecuritySchemes[scimTokenAuth] = &openapi.SecurityScheme{
+		Type: "http", Scheme: "bearer", Description: "Per-tenant token from SCIM_TENANT_TOKENS",
+	}
+
+	body := func(v any) *openapi.RequestBody { return doc.BodyAs(scim.ContentType, v) }
+	response := func(description string, v any) *openapi.Response {
+		return doc.ResponseAs(scim.ContentType, description, v)
+	}
+	// Every SCIM operation answers errors in the SCIM format rather than as problem details
+	add := func(method, path, summary string, op openapi.Operation) {
+		op.Summary = summary
+		op.Tags = []string{"scim"}
+		op.Security = openapi.Secured(scimTokenAuth)
+		op.Responses["default"] = response("SCIM error", scim.Error{})
+		doc.Add(method, path, op)
+	}
+	listParams := []openapi.Parameter{
+		{Name: "filter", In: "query", Description: "SCIM filter expression, e.g. userName eq \"ana@example.com\"", Schema: &openapi.Schema{Type: "string"}},
+		{Name: "startIndex", In: "query", Description: "1-based index of the first result", Schema: &openapi.Schema{Type: "integer"}},
+		{Name: "count", In: "query", Description: "Page size", Schema: &openapi.Schema{Type: "integer"}},
+	}
+	excludedAttributes := openapi.Parameter{Name: "excludedAttributes", In: "query", Description: "\"members\" leaves group members out", Schema: &openapi.Schema{Type: "string"}}
+
+	add("GET", "/scim/v2/ServiceProviderConfig", "Supported SCIM features", openapi.Operation{
+		Responses: map[string]*openapi.Response{"200": response("The service provider configuration", &openapi.Schema{Type: "object"})},
+	})
+
+	add("GET", "/scim/v2/Users", "List users", openapi.Operation{
+		Parameters: listParams,
+		Responses:  map[string]*openapi.Response{"200": response("A page of users in Resources", scim.ListResponse{})},
+	})
+	add("POST", "/scim/v2/Users", "Create a user", openapi.Operation{
+		RequestBody: body(scim.User{}),
+		Responses:   map[string]*openapi.Response{"201": response("The new user", scim.User{})},
+	})
+	add("GET", "/scim/v2/Users/{id}", "Get a user", openapi.Operation{
+		Responses: map[string]*openapi.Response{"200": response("The user", scim.User{})},
+	})
+	add("PUT", "/scim/v2/Users/{id}", "Replace a user", openapi.Operation{
+		RequestBody: body(scim.User{}),
+		Responses:   map[string]*openapi.Response{"200": response("The updated user", scim.User{})},
+	})
+	add("PATCH", "/scim/v2/Users/{id}", "Patch a user", openapi.Operation{
+		RequestBody: body(scim.PatchRequest{}),
+		Responses:   map[string]*openapi.Response{"200": response("The updated user", scim.User{})},
+	})
+	add("DELETE", "/scim/v2/Users/{id}", "Deprovision a user", openapi.Operation{
+		Responses: map[string]*openapi.Response{"204": openapi.Empty("The user was deprovisioned")},
+	})
+
+	add("GET", "/scim/v2/Groups", "List groups", openapi.Operation{
+		Parameters: append(listParams, excludedAttributes),
+		Responses:  map[string]*openapi.Response{"200": response("A page of groups in Resources", scim.ListResponse{})},
+	})
+	add("POST", "/scim/v2/Groups", "Create a group", openapi.Operation{
+		RequestBody: body(scim.Group{}),
+		Responses:   map[string]*openapi.Response{"201": response("The new group", scim.Group{})},
+	})
+	add("GET", "/scim/v2/Groups/{id}", "Get a group", openapi.Operation{
+		Parameters: []openapi.Parameter{excludedAttributes},
+		Responses:  map[string]*openapi.Response{"200": response("The group", scim.Group{})},
+	})
+	add("PUT", "/scim/v2/Groups/{id}", "Replace a group", openapi.Operation{
+		RequestBody: body(scim.Group{}),
+		Responses:   map[string]*openapi.Response{"200": response("The updated group", scim.Group{})},
+	})
+	add("PATCH", "/scim/v2/Groups/{id}", "Patch a group", openapi.Operation{
+		RequestBody: body(scim.PatchRequest{}),
+		Responses:   map[string]*openapi.Response{"200": response("The updated group", scim.Group{})},
+	})
+	add("DELETE", "/scim/v2/Groups/{id}", "Delete a group", openapi.Operation{
+		Responses: map[string]*openapi.Response{"204": openapi.Empty("The group was deleted")},
+	})
+}
@@ -0,0 +1,125 @@
+// routes/users.go
+package routes
+
+import (
+	"net/http"
+
+	"github.com/ABDULS21985/test-portal/app"
+	"github.com/ABDULS21985/test-portal/controllers"
+	"github.com/ABDULS21985/test-portal/middleware"
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/openapi"
+)
+
+// usersModule serves registration, password resets, profiles and the caller's sessions
+type usersModule struct{}
+
+func (usersModule) Name() string { return "users" }
+
+func (usersModule) RegisterRoutes(a *app.App) {
+	userController := controllers.NewUserController(a.Services.Users)
+	passwordResetController := controllers.NewPasswordResetController(a.Services.PasswordReset, a.Metrics)
+	sessionController := controllers.NewSessionController(a.Services.Sessions)
+
+	// User Routes
+	// Registration is handled by User Controller
+	limitRegister := a.RateLimit("register", middleware.ByIP)
+	a.API.Handle("/users/register", limitRegister(http.HandlerFunc(userController.RegisterUser))).Methods("POST")
+
+	// Password Reset Routes
+	limitReset := a.RateLimit("password_reset", middleware.ByIP)
+	a.API.Handle("/password-reset/request", limitReset(http.HandlerFunc(passwordResetController.RequestPasswordReset))).Methods("POST")
+	a.API.Handle("/password-reset/reset", limitReset(http.HandlerFunc(passwordResetController.ResetPassword))).Methods("POST")
+
+	// Protected User Routes
+	protected := a.API.PathPrefix("/users").Subrouter()
+	protected.Use(a.Middleware.Auth.RequireAuth, a.Middleware.Impersonation.Guard, a.RateLimit("api", middleware.ByUser))
+	protected.HandleFunc("/me/sessions", sessionController.ListMySessions).Methods("GET")
+	protected.HandleFunc("/me/sessions/{id}", sessionController.RevokeMySession).Methods("DELETE")
+	protected.HandleFunc("/{id}", userController.GetUserProfile).Methods("GET")
+	protected.HandleFunc("/{id}", userController.UpdateUserProfile).Methods("PUT")
+	protected.HandleFunc("/{id}", userController.DeleteUser).Methods("DELETE")
+}
+
+func (usersModule) Document(a *app.App, doc *openapi.Document) {
+	doc.Tags = append(doc.Tags, openapi.Tag{Name: "users", Description: "Registration, password resets, profiles and sessions"})
+	tags := []string{"users"}
+	secured := openapi.Secured(app.BearerAuth)
+
+	doc.Add("POST", "/api/v1/users/register", openapi.Operation{
+		Summary:     "Register a user",
+		Tags:        tags,
+		RequestBody: doc.Body(controllers.RegisterRequest{}),
+		Responses: map[string]*openapi.Response{
+			"201": doc.JSON("The new user", models.User{}),
+			"400": doc.Problem("The name, email or password is invalid"),
+			"409": doc.Problem("The email is already registered"),
+			"429": doc.Problem("Too many registrations"),
+		},
+	})
+	doc.Add("POST", "/api/v1/password-reset/request", openapi.Operation{
+		Summary:     "Request a password reset",
+		Description: "Answers the same whether or not the email is registered.",
+		Tags:        tags,
+		RequestBody: doc.Body(controllers.PasswordResetRequest{}),
+		Responses: map[string]*openapi.Response{
+			"200": doc.JSON("The request was accepted", controllers.PasswordResetResponse{}),
+			"429": doc.Problem("Too many reset requests"),
+		},
+	})
+	doc.Add("POST", "/api/v1/password-reset/reset", openapi.Operation{
+		Summary:     "Reset a password with a reset token",
+		Tags:        tags,
+		RequestBody: doc.Body(controllers.ResetPasswordRequest{}),
+		Responses: map[string]*openapi.Response{
+			"200": doc.JSON("The password was changed", controllers.MessageResponse{}),
+			"429": doc.Problem("Too many reset attempts"),
+		},
+	})
+
+	doc.Add("GET", "/api/v1/users/me/sessions", openapi.Operation{
+		Summary:   "List your sessions",
+		Tags:      tags,
+		Security:  secured,
+		Responses: map[string]*openapi.Response{"200": doc.JSON("Your active sessions", []controllers.SessionResponse{})},
+	})
+	doc.Add("DELETE", "/api/v1/users/me/sessions/{id}", openapi.Operation{
+		Summary:  "Log out one of your sessions",
+		Tags:     tags,
+		Security: secured,
+		Responses: map[string]*openapi.Response{
+			"200": doc.JSON("The session was terminated", controllers.MessageResponse{}),
+			"404": doc.Problem("No such session"),
+		},
+	})
+	doc.Add("GET", "/api/v1/users/{id}", openapi.Operation{
+		Summary:  "Get a user's profile",
+		Tags:     tags,
+		Security: secured,
+		Responses: map[string]*openapi.Response{
+			"200": doc.JSON("The user", models.User{}),
+			"404": doc.Problem("No such user"),
+		},
+	})
+	doc.Add("PUT", "/api/v1/users/{id}", openapi.Operation{
+		Summary:     "Update a user's profile",
+		Tags:        tags,
+		Security:    secured,
+		RequestBody: doc.Body(controllers.UpdateProfileRequest{}),
+		Responses: map[string]*openapi.Response{
+			"200": doc.JSON("The updated user", models.User{}),
+			"400": doc.Problem("A field is invalid or can't be changed"),
+			"404": doc.Problem("No such user"),
+			"409": doc.Problem("The email is already registered"),
+		},
+	})
+	doc.Add("DELETE", "/api/v1/users/{id}", openapi.Operation{
+		Summary:  "Delete a user",
+		Tags:     tags,
+		Security: secured,
+		Responses: map[string]*openapi.Response{
+			"200": doc.JSON("The user was deleted", controllers.MessageResponse{}),
+			"404": doc.Problem("No such user"),
+		},
+	})
+}
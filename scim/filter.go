@@ -0,0 +1,294 @@
+// scim/filter.go
+package scim
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Filter is a parsed SCIM filter expression (RFC 7644 section 3.4.2.2).
+// Exactly one of the comparison (Attr/Op), And, Or or Not forms is populated.
+type Filter struct {
+	Attr  string // lower-cased attribute path, e.g. "username" or "emails.value"
+	Op    string // eq, ne, co, sw, ew, gt, ge, lt, le, pr
+	Value interface{}
+
+	And []*Filter
+	Or  []*Filter
+	Not *Filter
+}
+
+var comparisonOps = map[string]bool{
+	"eq": true, "ne": true, "co": true, "sw": true, "ew": true,
+	"gt": true, "ge": true, "lt": true, "le": true,
+}
+
+// ParseFilter parses a SCIM filter string such as
+// `userName eq "bjensen" and (active eq true or emails[type eq "work"])`
+func ParseFilter(input string) (*Filter, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+
+	filter, err := p.parseOr("")
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q", p.peek().text)
+	}
+	return filter, nil
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenString
+	tokenLParen
+	tokenRParen
+	tokenLBracket
+	tokenRBracket
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{tokenLParen, "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{tokenRParen, ")"})
+			i++
+		case r == '[':
+			tokens = append(tokens, token{tokenLBracket, "["})
+			i++
+		case r == ']':
+			tokens = append(tokens, token{tokenRBracket, "]"})
+			i++
+		case r == '"':
+			var sb strings.Builder
+			i++
+			closed := false
+			for i < len(runes) {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					sb.WriteRune(runes[i+1])
+					i += 2
+					continue
+				}
+				if runes[i] == '"' {
+					closed = true
+					i++
+					break
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated string in filter")
+			}
+			tokens = append(tokens, token{tokenString, sb.String()})
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune("()[]\"", runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokenWord, string(runes[start:i])})
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *filterParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *filterParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() (token, error) {
+	if p.done() {
+		return token{}, fmt.Errorf("unexpected end of filter")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	return t, nil
+}
+
+func (p *filterParser) peekKeyword(keyword string) bool {
+	return !p.done() && p.peek().kind == tokenWord && strings.EqualFold(p.peek().text, keyword)
+}
+
+// parseOr handles the lowest precedence operator; prefix is the enclosing value path, if any
+func (p *filterParser) parseOr(prefix string) (*Filter, error) {
+	left, err := p.parseAnd(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if !p.peekKeyword("or") {
+		return left, nil
+	}
+
+	or := &Filter{Or: []*Filter{left}}
+	for p.peekKeyword("or") {
+		p.pos++
+		right, err := p.parseAnd(prefix)
+		if err != nil {
+			return nil, err
+		}
+		or.Or = append(or.Or, right)
+	}
+	return or, nil
+}
+
+func (p *filterParser) parseAnd(prefix string) (*Filter, error) {
+	left, err := p.parseUnary(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if !p.peekKeyword("and") {
+		return left, nil
+	}
+
+	and := &Filter{And: []*Filter{left}}
+	for p.peekKeyword("and") {
+		p.pos++
+		right, err := p.parseUnary(prefix)
+		if err != nil {
+			return nil, err
+		}
+		and.And = append(and.And, right)
+	}
+	return and, nil
+}
+
+func (p *filterParser) parseUnary(prefix string) (*Filter, error) {
+	if p.peekKeyword("not") {
+		p.pos++
+		inner, err := p.parseGroup(prefix)
+		if err != nil {
+			return nil, err
+		}
+		return &Filter{Not: inner}, nil
+	}
+	if !p.done() && p.peek().kind == tokenLParen {
+		return p.parseGroup(prefix)
+	}
+	return p.parseAttrExpr(prefix)
+}
+
+func (p *filterParser) parseGroup(prefix string) (*Filter, error) {
+	if t, err := p.next(); err != nil || t.kind != tokenLParen {
+		return nil, fmt.Errorf("expected '('")
+	}
+	inner, err := p.parseOr(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if t, err := p.next(); err != nil || t.kind != tokenRParen {
+		return nil, fmt.Errorf("expected ')'")
+	}
+	return inner, nil
+}
+
+func (p *filterParser) parseAttrExpr(prefix string) (*Filter, error) {
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if t.kind != tokenWord {
+		return nil, fmt.Errorf("expected attribute path, got %q", t.text)
+	}
+	attr := normalizeAttr(t.text)
+	if prefix != "" {
+		attr = prefix + "." + attr
+	}
+
+	// Value path, e.g. emails[type eq "work" and value co "@example.com"]
+	if !p.done() && p.peek().kind == tokenLBracket {
+		p.pos++
+		inner, err := p.parseOr(attr)
+		if err != nil {
+			return nil, err
+		}
+		if t, err := p.next(); err != nil || t.kind != tokenRBracket {
+			return nil, fmt.Errorf("expected ']'")
+		}
+		return inner, nil
+	}
+
+	opToken, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	op := strings.ToLower(opToken.text)
+	if op == "pr" {
+		return &Filter{Attr: attr, Op: op}, nil
+	}
+	if !comparisonOps[op] {
+		return nil, fmt.Errorf("unsupported operator %q", opToken.text)
+	}
+
+	valueToken, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	value, err := parseValue(valueToken)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{Attr: attr, Op: op, Value: value}, nil
+}
+
+func parseValue(t token) (interface{}, error) {
+	if t.kind == tokenString {
+		return t.text, nil
+	}
+	if t.kind != tokenWord {
+		return nil, fmt.Errorf("expected comparison value, got %q", t.text)
+	}
+
+	switch strings.ToLower(t.text) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	if n, err := strconv.ParseFloat(t.text, 64); err == nil {
+		return n, nil
+	}
+	return nil, fmt.Errorf("invalid comparison value %q", t.text)
+}
+
+// normalizeAttr lower-cases an attribute path and strips any schema URN prefix
+func normalizeAttr(attr string) string {
+	if strings.HasPrefix(strings.ToLower(attr), "urn:") {
+		if idx := strings.LastIndex(attr, ":"); idx >= 0 {
+			attr = attr[idx+1:]
+		}
+	}
+	return strings.ToLower(attr)
+}
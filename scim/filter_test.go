@@ -0,0 +1,93 @@
+// scim/filter_test.go
+package scim
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// format renders a filter with explicit grouping, e.g. (or (a eq 1) (not (b pr)))
+func format(f *Filter) string {
+	var parts []string
+	switch {
+	case len(f.And) > 0:
+		for _, child := range f.And {
+			parts = append(parts, format(child))
+		}
+		return "(and " + strings.Join(parts, " ") + ")"
+	case len(f.Or) > 0:
+		for _, child := range f.Or {
+			parts = append(parts, format(child))
+		}
+		return "(or " + strings.Join(parts, " ") + ")"
+	case f.Not != nil:
+		return "(not " + format(f.Not) + ")"
+	case f.Op == "pr":
+		return "(" + f.Attr + " pr)"
+	}
+	return fmt.Sprintf("(%s %s %#v)", f.Attr, f.Op, f.Value)
+}
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+		want   string
+	}{
+		{name: "comparison", filter: `userName eq "bjensen"`, want: `(username eq "bjensen")`},
+		{name: "case-insensitive keywords", filter: `UserName EQ "bjensen" AND active Eq true`, want: `(and (username eq "bjensen") (active eq true))`},
+		{name: "presence", filter: `title pr`, want: `(title pr)`},
+		{name: "and binds tighter than or", filter: `a eq 1 or b eq 2 and c eq 3`, want: `(or (a eq 1) (and (b eq 2) (c eq 3)))`},
+		{name: "and before or", filter: `a eq 1 and b eq 2 or c eq 3`, want: `(or (and (a eq 1) (b eq 2)) (c eq 3))`},
+		{name: "parentheses", filter: `a eq 1 and (b eq 2 or c eq 3)`, want: `(and (a eq 1) (or (b eq 2) (c eq 3)))`},
+		{name: "chained operators", filter: `a pr or b pr or c pr`, want: `(or (a pr) (b pr) (c pr))`},
+		{name: "not", filter: `not (a eq 1 or b eq 2) and c pr`, want: `(and (not (or (a eq 1) (b eq 2))) (c pr))`},
+		{name: "value path", filter: `emails[type eq "work" and value co "@example.com"]`, want: `(and (emails.type eq "work") (emails.value co "@example.com"))`},
+		{name: "value path in an expression", filter: `active eq true and emails[primary eq true]`, want: `(and (active eq true) (emails.primary eq true))`},
+		{name: "schema URN", filter: `urn:ietf:params:scim:schemas:core:2.0:User:userName sw "j"`, want: `(username sw "j")`},
+		{name: "null", filter: `externalId eq null`, want: `(externalid eq <nil>)`},
+		{name: "number", filter: `meta.version gt 2.5`, want: `(meta.version gt 2.5)`},
+		{name: "escaped quote", filter: `displayName eq "Barbara \"Babs\" Jensen"`, want: `(displayname eq "Barbara \"Babs\" Jensen")`},
+		{name: "spaces in a string", filter: `displayName co " and "`, want: `(displayname co " and ")`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := ParseFilter(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := format(filter); got != tt.want {
+				t.Fatalf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+	}{
+		{name: "empty", filter: ``},
+		{name: "missing value", filter: `userName eq`},
+		{name: "missing operator", filter: `userName`},
+		{name: "unsupported operator", filter: `userName like "b%"`},
+		{name: "unquoted string", filter: `userName eq bjensen`},
+		{name: "unterminated string", filter: `userName eq "bjensen`},
+		{name: "unclosed parenthesis", filter: `(userName eq "b"`},
+		{name: "stray parenthesis", filter: `userName eq "b")`},
+		{name: "unclosed value path", filter: `emails[type eq "work"`},
+		{name: "not without parentheses", filter: `not userName eq "b"`},
+		{name: "dangling and", filter: `userName eq "b" and`},
+		{name: "two expressions", filter: `userName eq "b" active eq true`},
+		{name: "value where an attribute belongs", filter: `"userName" eq "b"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if filter, err := ParseFilter(tt.filter); err == nil {
+				t.Fatalf("parsed as %s, want an error", format(filter))
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+// scim/response.go
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Respond writes payload as an application/scim+json response
+func Respond(w http.ResponseWriter, statusCode int, payload interface{}) {
+	response, err := json.Marshal(payload)
+	if err != nil {
+		RespondWithError(w, NewError(http.StatusInternalServerError, "", "Internal Server Error"))
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(statusCode)
+	w.Write(response)
+}
+
+// RespondWithError writes a SCIM error response
+func RespondWithError(w http.ResponseWriter, scimErr *Error) {
+	Respond(w, scimErr.HTTPStatus, scimErr)
+}
@@ -0,0 +1,98 @@
+// server/certs.go
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// CertReloader serves the current certificate and picks up renewed files (e.g. from cert-manager
+// or certbot) without a restart
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	logger   *slog.Logger
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// NewCertReloader creates a new instance of CertReloader and loads the initial pair
+func NewCertReloader(certFile, keyFile string, logger *slog.Logger) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile, logger: logger}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Reload reads the key pair from disk, keeping the previous one if the files are invalid
+func (r *CertReloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	modTime := r.latestModTime()
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.mu.Unlock()
+	return nil
+}
+
+// Watch reloads the certificate when the files change or on SIGHUP, until ctx is done
+func (r *CertReloader) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		case <-ticker.C:
+			r.mu.RLock()
+			unchanged := !r.latestModTime().After(r.modTime)
+			r.mu.RUnlock()
+			if unchanged {
+				continue
+			}
+		}
+		if err := r.Reload(); err != nil {
+			r.logger.Error("TLS certificate reload failed, keeping the current certificate", slog.Any("error", err))
+			continue
+		}
+		r.logger.Info("TLS certificate reloaded")
+	}
+}
+
+func (r *CertReloader) latestModTime() time.Time {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
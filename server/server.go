@@ -0,0 +1,113 @@
+// server/server.go
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// Options configures the HTTP server
+type Options struct {
+	Addr              string
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+
+	// TLSCertFile and TLSKeyFile enable native TLS; the pair is reloaded when the files change
+	TLSCertFile       string
+	TLSKeyFile        string
+	CertCheckInterval time.Duration
+
+	// H2C serves HTTP/2 without TLS, for deployments behind a proxy that speaks h2c
+	H2C bool
+}
+
+// Server is an http.Server that drains in-flight requests on Shutdown
+type Server struct {
+	opts   Options
+	http   *http.Server
+	certs  *CertReloader
+	logger *slog.Logger
+}
+
+// New creates a new instance of Server for handler
+func New(handler http.Handler, opts Options, logger *slog.Logger) (*Server, error) {
+	s := &Server{opts: opts, logger: logger}
+
+	if opts.H2C && opts.TLSCertFile == "" {
+		handler = h2c.NewHandler(handler, &http2.Server{IdleTimeout: opts.IdleTimeout})
+	}
+
+	s.http = &http.Server{
+		Addr:              opts.Addr,
+		Handler:           handler,
+		ReadTimeout:       opts.ReadTimeout,
+		ReadHeaderTimeout: opts.ReadHeaderTimeout,
+		WriteTimeout:      opts.WriteTimeout,
+		IdleTimeout:       opts.IdleTimeout,
+		MaxHeaderBytes:    opts.MaxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+	}
+
+	if opts.TLSCertFile != "" {
+		certs, err := NewCertReloader(opts.TLSCertFile, opts.TLSKeyFile, logger)
+		if err != nil {
+			return nil, err
+		}
+		s.certs = certs
+		s.http.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certs.GetCertificate,
+		}
+	}
+	return s, nil
+}
+
+// Run serves until ctx is cancelled or serving fails. It doesn't drain; call Shutdown once it
+// returns, with a deadline shared with the rest of the shutdown.
+func (s *Server) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.opts.Addr)
+	if err != nil {
+		return err
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if s.certs != nil {
+			go s.certs.Watch(ctx, s.opts.CertCheckInterval)
+			serveErr <- s.http.ServeTLS(listener, "", "")
+		} else {
+			serveErr <- s.http.Serve(listener)
+		}
+	}()
+
+	select {
+	case err := <-serveErr:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests to finish until ctx is
+// done, then drops whatever is still running
+func (s *Server) Shutdown(ctx context.Context) error {
+	if err := s.http.Shutdown(ctx); err != nil {
+		s.http.Close()
+		return err
+	}
+	return nil
+}
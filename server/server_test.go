@@ -0,0 +1,161 @@
+// server/server_test.go
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// freeAddr returns a loopback address nothing is listening on
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+// startServer runs handler on a free address until cancel is called and waits until it accepts
+// connections; Run's result is sent on the returned channel
+func startServer(t *testing.T, handler http.Handler) (*Server, string, context.CancelFunc, <-chan error) {
+	t.Helper()
+	addr := freeAddr(t)
+	s, err := New(handler, Options{Addr: addr}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- s.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		s.http.Close()
+	})
+
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			return s, addr, cancel, runErr
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the server never listened on %s: %v", addr, err)
+		}
+	}
+}
+
+// get requests url in the background, sending the status code or the error on the channel
+func get(url string) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			result <- err
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			err = errors.New(resp.Status)
+		}
+		result <- err
+	}()
+	return result
+}
+
+func TestShutdownDrainsRequests(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	s, addr, cancel, runErr := startServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	inFlight := get("http://" + addr + "/slow")
+	<-started
+
+	// Cancelling Run leaves the server up for Shutdown to drain
+	cancel()
+	if err := <-runErr; err != nil {
+		t.Fatalf("Run returned %v", err)
+	}
+
+	ctx, stop := context.WithTimeout(context.Background(), 5*time.Second)
+	defer stop()
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- s.Shutdown(ctx) }()
+
+	// New connections are refused right away, while the request in flight keeps running
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			break
+		}
+		conn.Close()
+		if time.Now().After(deadline) {
+			t.Fatal("the server still accepts connections while shutting down")
+		}
+	}
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown returned %v before the request in flight finished", err)
+	case err := <-inFlight:
+		t.Fatalf("the request in flight ended early: %v", err)
+	default:
+	}
+
+	close(release)
+	if err := <-inFlight; err != nil {
+		t.Fatalf("the request in flight failed: %v", err)
+	}
+	if err := <-shutdown; err != nil {
+		t.Fatalf("Shutdown returned %v", err)
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	started := make(chan struct{})
+	s, addr, cancel, _ := startServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	}))
+	inFlight := get("http://" + addr + "/stuck")
+	<-started
+	cancel()
+
+	ctx, stop := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer stop()
+	if err := s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown returned %v, want the deadline", err)
+	}
+	// Past the deadline the remaining connections are dropped
+	select {
+	case err := <-inFlight:
+		if err == nil {
+			t.Fatal("the stuck request succeeded")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the stuck request is still running after Shutdown gave up")
+	}
+}
+
+func TestRunListenError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	s, err := New(http.NotFoundHandler(), Options{Addr: listener.Addr().String()}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Run(context.Background()); err == nil {
+		t.Fatal("Run on an address in use returned nil")
+	}
+}
@@ -0,0 +1,47 @@
+// server/workers.go
+package server
+
+import (
+	"context"
+	"sync"
+)
+
+// Workers runs background loops that take a stop channel (the LDAP syncer, the config reloader)
+// and lets shutdown wait for them to return
+type Workers struct {
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewWorkers creates a new instance of Workers
+func NewWorkers() *Workers {
+	return &Workers{stop: make(chan struct{})}
+}
+
+// Go starts fn in a goroutine; fn must return once stop is closed
+func (w *Workers) Go(fn func(stop <-chan struct{})) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		fn(w.stop)
+	}()
+}
+
+// Stop signals every worker and waits for them to return or for ctx to be done
+func (w *Workers) Stop(ctx context.Context) error {
+	w.stopOnce.Do(func() { close(w.stop) })
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
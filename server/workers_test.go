@@ -0,0 +1,48 @@
+// server/workers_test.go
+package server
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkersStop(t *testing.T) {
+	workers := NewWorkers()
+	var returned atomic.Int32
+	for i := 0; i < 3; i++ {
+		workers.Go(func(stop <-chan struct{}) {
+			<-stop
+			time.Sleep(10 * time.Millisecond)
+			returned.Add(1)
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := workers.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := returned.Load(); got != 3 {
+		t.Fatalf("Stop returned with %d of 3 workers done", got)
+	}
+	// Stopping again is harmless
+	if err := workers.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWorkersStopDeadline(t *testing.T) {
+	workers := NewWorkers()
+	stuck := make(chan struct{})
+	defer close(stuck)
+	workers.Go(func(<-chan struct{}) { <-stuck })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := workers.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Stop returned %v, want the deadline", err)
+	}
+}
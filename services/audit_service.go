@@ -0,0 +1,35 @@
+// services/audit_service.go
+package services
+
+import (
+	"context"
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories"
+)
+
+// Audit actions
+const (
+	AuditImpersonationStart   = "impersonation.start"
+	AuditImpersonationRequest = "impersonation.request"
+	AuditImpersonationBlocked = "impersonation.blocked"
+)
+
+// AuditService defines the contract for writing the audit trail
+type AuditService interface {
+	Record(ctx context.Context, entry *models.AuditLog) error
+}
+
+type auditService struct {
+	repo repositories.AuditLogRepository
+}
+
+// NewAuditService creates a new instance of auditService
+func NewAuditService(repo repositories.AuditLogRepository) AuditService {
+	return &auditService{
+		repo: repo,
+	}
+}
+
+func (s *auditService) Record(ctx context.Context, entry *models.AuditLog) error {
+	return s.repo.CreateEntry(ctx, entry)
+}
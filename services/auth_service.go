@@ -2,8 +2,11 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"log/slog"
 
+	"github.com/ABDULS21985/test-portal/apperrors"
 	"github.com/ABDULS21985/test-portal/models"
 	"github.com/ABDULS21985/test-portal/repositories"
 
@@ -11,99 +14,276 @@ import (
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // AuthService defines the contract for authentication-related business logic
 type AuthService interface {
-	AuthenticateUser(email, password string) (*models.User, string, error)
-	GenerateToken(userID uuid.UUID, role string) (string, error)
-	ValidateToken(token string) (*jwt.Token, error)
-	GetClaimsFromToken(token string) (jwt.MapClaims, error)
+	AuthenticateUser(ctx context.Context, email, password string, client ClientInfo) (*models.User, string, error)
+	VerifyCredentials(ctx context.Context, email, password string) (*models.User, error)
+	IssueToken(ctx context.Context, user *models.User, client ClientInfo) (string, error)
+	ValidateToken(ctx context.Context, token string) (*jwt.Token, error)
+	GetClaimsFromToken(ctx context.Context, token string) (jwt.MapClaims, error)
+	Impersonate(ctx context.Context, actorID, subjectID uuid.UUID, ttl time.Duration, client ClientInfo) (string, *models.User, error)
+	ActiveUser(ctx context.Context, userID uuid.UUID) (*models.User, error)
 }
 
+// Claims carried by impersonation tokens. "act" follows RFC 8693: the token's subject is the
+// impersonated user and act.sub is the staff member acting on their behalf.
+const (
+	ClaimImpersonating = "imp"
+	ClaimActor         = "act"
+)
+
+var (
+	// ErrCannotImpersonate is returned when the target account may not be impersonated
+	ErrCannotImpersonate = apperrors.Forbidden("this user cannot be impersonated")
+	// ErrInvalidToken is returned for a token that is malformed, expired or wrongly signed
+	ErrInvalidToken = apperrors.Unauthorized("invalid token")
+	// ErrAccountDisabled is returned for a token whose user has since been disabled or deleted
+	ErrAccountDisabled = apperrors.Unauthorized("account is disabled")
+)
+
 type authService struct {
-	userRepo  repositories.UserRepository
-	jwtSecret []byte
+	userRepo       repositories.UserRepository
+	sessionService SessionService
+	keys           *JWTKeyRing
+	tokenTTL       time.Duration
+	logger         *slog.Logger
+	providers      []CredentialProvider
 }
 
-// NewAuthService creates a new instance of authService
-func NewAuthService(userRepo repositories.UserRepository, jwtSecret []byte) AuthService {
+// NewAuthService creates a new instance of authService. Login sessions and their tokens last
+// tokenTTL. Credentials are checked against the given providers in order; with none, only local
+// bcrypt passwords are accepted.
+func NewAuthService(userRepo repositories.UserRepository, sessionService SessionService, keys *JWTKeyRing, tokenTTL time.Duration, logger *slog.Logger, providers ...CredentialProvider) AuthService {
+	if len(providers) == 0 {
+		providers = []CredentialProvider{NewLocalCredentialProvider(userRepo)}
+	}
 	return &authService{
-		userRepo:  userRepo,
-		jwtSecret: jwtSecret,
+		userRepo:       userRepo,
+		sessionService: sessionService,
+		keys:           keys,
+		tokenTTL:       tokenTTL,
+		logger:         logger,
+		providers:      providers,
 	}
 }
 
+// ClaimSessionID is the JWT claim holding the login session a token belongs to
+const ClaimSessionID = "sid"
+
 type Claims struct {
-	UserID uuid.UUID `json:"user_id"`
-	Email  string    `json:"email"`
+	UserID    uuid.UUID `json:"user_id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	SessionID uuid.UUID `json:"sid"`
 	jwt.StandardClaims
 }
 
-func (s *authService) AuthenticateUser(email, password string) (*models.User, string, error) {
-	user, err := s.userRepo.GetUserByEmail(email)
+func (s *authService) AuthenticateUser(ctx context.Context, email, password string, client ClientInfo) (_ *models.User, _ string, err error) {
+	ctx, span := startSpan(ctx, "AuthService.AuthenticateUser")
+	defer endSpan(span, &err)
+
+	user, err := s.authenticate(ctx, email, password)
 	if err != nil {
 		return nil, "", err
 	}
 
-	// Compare the hashed password
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	tokenString, err := s.issueSessionToken(ctx, user, client)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return user, tokenString, nil
+}
+
+// VerifyCredentials checks the password without logging the user in, for flows that need a
+// second factor before a token is issued
+func (s *authService) VerifyCredentials(ctx context.Context, email, password string) (_ *models.User, err error) {
+	ctx, span := startSpan(ctx, "AuthService.VerifyCredentials")
+	defer endSpan(span, &err)
+	return s.authenticate(ctx, email, password)
+}
+
+// IssueToken logs in a user whose identity has already been fully verified
+func (s *authService) IssueToken(ctx context.Context, user *models.User, client ClientInfo) (_ string, err error) {
+	ctx, span := startSpan(ctx, "AuthService.IssueToken", attribute.String("user.id", user.ID.String()))
+	defer endSpan(span, &err)
+	return s.issueSessionToken(ctx, user, client)
+}
+
+// issueSessionToken records a new login session for the user and signs a token bound to it
+func (s *authService) issueSessionToken(ctx context.Context, user *models.User, client ClientInfo) (string, error) {
+	session, err := s.sessionService.StartSession(ctx, user.ID, client, s.tokenTTL)
 	if err != nil {
-		return nil, "", errors.New("invalid credentials")
+		return "", err
 	}
 
-	// Create JWT token
-	expirationTime := time.Now().Add(24 * time.Hour)
 	claims := &Claims{
-		UserID: user.ID,
-		Email:  user.Email,
+		UserID:    user.ID,
+		Email:     user.Email,
+		Role:      user.Role,
+		SessionID: session.ID,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(),
+			ExpiresAt: session.ExpiresAt.Unix(),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(s.jwtSecret)
-	if err != nil {
-		return nil, "", err
-	}
-
-	return user, tokenString, nil
+	return token.SignedString(s.keys.SigningKey())
 }
 
-// GenerateToken generates a JWT for a given user ID and role
-func (a *authService) GenerateToken(userID uuid.UUID, role string) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"role":    role,
-		"exp":     time.Now().Add(time.Hour * 72).Unix(), // Token valid for 72 hours
+// authenticate walks the provider chain until one of them accepts the credentials
+func (s *authService) authenticate(ctx context.Context, email, password string) (*models.User, error) {
+	for _, provider := range s.providers {
+		user, err := provider.Authenticate(ctx, email, password)
+		if err == nil {
+			if user.Disabled {
+				return nil, ErrInvalidCredentials
+			}
+			return user, nil
+		}
+		// Out of time; the remaining providers would fail the same way
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if !errors.Is(err, ErrUnknownUser) && !errors.Is(err, ErrInvalidCredentials) {
+			s.logger.ErrorContext(ctx, "credential provider failed", slog.String("provider", provider.Name()), slog.Any("error", err))
+		}
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(a.jwtSecret)
+	return nil, ErrInvalidCredentials
 }
 
-// ValidateToken validates a given JWT token
-func (a *authService) ValidateToken(token string) (*jwt.Token, error) {
-	return jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
+// ValidateToken validates a given JWT token against the current key and, after a rotation, the
+// previous one
+func (a *authService) ValidateToken(ctx context.Context, token string) (*jwt.Token, error) {
+	var (
+		parsed *jwt.Token
+		err    error
+	)
+	for _, key := range a.keys.VerificationKeys() {
+		parsed, err = jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, errors.New("unexpected signing method")
+			}
+			return key, nil
+		})
+		var validationErr *jwt.ValidationError
+		if err == nil || !errors.As(err, &validationErr) || validationErr.Errors&jwt.ValidationErrorSignatureInvalid == 0 {
+			break
 		}
-		return a.jwtSecret, nil
-	})
+	}
+	return parsed, err
 }
 
 // GetClaimsFromToken extracts claims from a validated token
-func (a *authService) GetClaimsFromToken(token string) (jwt.MapClaims, error) {
-	parsedToken, err := a.ValidateToken(token)
+func (a *authService) GetClaimsFromToken(ctx context.Context, token string) (jwt.MapClaims, error) {
+	parsedToken, err := a.ValidateToken(ctx, token)
 	if err != nil || !parsedToken.Valid {
-		return nil, errors.New("invalid token")
+		return nil, ErrInvalidToken
 	}
 
 	claims, ok := parsedToken.Claims.(jwt.MapClaims)
 	if !ok {
-		return nil, errors.New("unable to parse claims")
+		return nil, ErrInvalidToken
 	}
 
 	return claims, nil
 }
+
+// Impersonate mints a short-lived token that authenticates as subjectID while recording actorID
+// as the real caller. Staff may only impersonate users of their own tenant. The token has a
+// session of its own, started from client, so it can be revoked like any login.
+func (a *authService) Impersonate(ctx context.Context, actorID, subjectID uuid.UUID, ttl time.Duration, client ClientInfo) (_ string, _ *models.User, err error) {
+	ctx, span := startSpan(ctx, "AuthService.Impersonate", attribute.String("impersonation.actor_id", actorID.String()), attribute.String("user.id", subjectID.String()))
+	defer endSpan(span, &err)
+
+	if actorID == subjectID {
+		return "", nil, ErrCannotImpersonate
+	}
+
+	actor, err := a.userRepo.GetUserByID(ctx, actorID)
+	if err != nil {
+		return "", nil, err
+	}
+	subject, err := a.userRepo.GetUserByID(ctx, subjectID)
+	if err != nil {
+		return "", nil, err
+	}
+	// Staff accounts are off limits so impersonation can't be used to escalate privileges
+	if subject.Role == models.RoleAdmin || subject.Disabled || subject.Tenant != actor.Tenant {
+		return "", nil, ErrCannotImpersonate
+	}
+
+	session, err := a.sessionService.StartSession(ctx, subject.ID, client, ttl)
+	if err != nil {
+		return "", nil, err
+	}
+
+	claims := jwt.MapClaims{
+		"user_id":          subject.ID,
+		"email":            subject.Email,
+		"role":             subject.Role,
+		ClaimSessionID:     session.ID,
+		ClaimImpersonating: true,
+		ClaimActor:         map[string]string{"sub": actorID.String()},
+		"exp":              session.ExpiresAt.Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString(a.keys.SigningKey())
+	if err != nil {
+		return "", nil, err
+	}
+	return tokenString, subject, nil
+}
+
+// ActiveUser returns the user a token was issued to, as long as the account still exists and
+// hasn't been disabled
+func (a *authService) ActiveUser(ctx context.Context, userID uuid.UUID) (_ *models.User, err error) {
+	ctx, span := startSpan(ctx, "AuthService.ActiveUser", attribute.String("user.id", userID.String()))
+	defer endSpan(span, &err)
+
+	user, err := a.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if apperrors.KindOf(err) == apperrors.KindNotFound {
+			return nil, ErrAccountDisabled
+		}
+		return nil, err
+	}
+	if user.Disabled {
+		return nil, ErrAccountDisabled
+	}
+	return user, nil
+}
+
+// ImpersonatorFromClaims returns the acting staff member's ID if the claims belong to an
+// impersonation token
+func ImpersonatorFromClaims(claims jwt.MapClaims) (uuid.UUID, bool) {
+	if imp, _ := claims[ClaimImpersonating].(bool); !imp {
+		return uuid.Nil, false
+	}
+	actor, ok := claims[ClaimActor].(map[string]interface{})
+	if !ok {
+		return uuid.Nil, false
+	}
+	sub, _ := actor["sub"].(string)
+	actorID, err := uuid.Parse(sub)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return actorID, true
+}
+
+// SessionIDFromClaims returns the login session a token is bound to, if any
+func SessionIDFromClaims(claims jwt.MapClaims) (uuid.UUID, bool) {
+	sid, ok := claims[ClaimSessionID].(string)
+	if !ok {
+		return uuid.Nil, false
+	}
+	sessionID, err := uuid.Parse(sid)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return sessionID, true
+}
@@ -0,0 +1,217 @@
+// services/auth_service_test.go
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories"
+	"github.com/ABDULS21985/test-portal/repositories/memory"
+
+	"github.com/google/uuid"
+)
+
+const testTokenTTL = 90 * time.Minute
+
+// authFixture is an AuthService over in-memory repositories
+type authFixture struct {
+	repos    repositories.Repositories
+	sessions SessionService
+	auth     AuthService
+}
+
+func newAuthFixture(t *testing.T) *authFixture {
+	t.Helper()
+	repos := memory.NewStore().Repositories()
+	sessions := NewSessionService(repos.Sessions, discardLogger())
+	keys := NewJWTKeyRing([]byte("test-signing-key"))
+	return &authFixture{
+		repos:    repos,
+		sessions: sessions,
+		auth:     NewAuthService(repos.Users, sessions, keys, testTokenTTL, discardLogger()),
+	}
+}
+
+// addUser stores a local user with a bcrypt hash of password
+func (f *authFixture) addUser(t *testing.T, user models.User, password string) *models.User {
+	t.Helper()
+	hash, err := hashPassword(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.Password = string(hash)
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+	if err := f.repos.Users.CreateUser(context.Background(), &user); err != nil {
+		t.Fatal(err)
+	}
+	return &user
+}
+
+func TestAuthenticateUser(t *testing.T) {
+	f := newAuthFixture(t)
+	f.addUser(t, models.User{Email: "ada@example.com"}, "correct horse")
+	f.addUser(t, models.User{Email: "gone@example.com", Disabled: true}, "correct horse")
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantErr  error
+	}{
+		{name: "valid", email: "ada@example.com", password: "correct horse"},
+		{name: "wrong password", email: "ada@example.com", password: "battery staple", wantErr: ErrInvalidCredentials},
+		{name: "unknown user", email: "nobody@example.com", password: "correct horse", wantErr: ErrInvalidCredentials},
+		{name: "disabled user", email: "gone@example.com", password: "correct horse", wantErr: ErrInvalidCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			user, token, err := f.auth.AuthenticateUser(ctx, tt.email, tt.password, ClientInfo{IPAddress: "192.0.2.1"})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) || token != "" {
+					t.Fatalf("err = %v, token %q; want %v", err, token, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			claims, err := f.auth.GetClaimsFromToken(ctx, token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sessionID, ok := SessionIDFromClaims(claims)
+			if !ok {
+				t.Fatal("the token carries no session")
+			}
+			session, err := f.sessions.ValidateSession(ctx, sessionID)
+			if err != nil || session.UserID != user.ID || session.IPAddress != "192.0.2.1" {
+				t.Fatalf("session = %+v, %v", session, err)
+			}
+			if exp, _ := claims["exp"].(float64); int64(exp) != session.ExpiresAt.Unix() {
+				t.Fatalf("token expires at %v, its session at %v", exp, session.ExpiresAt.Unix())
+			}
+			if ttl := session.ExpiresAt.Sub(session.CreatedAt); ttl != testTokenTTL {
+				t.Fatalf("session lasts %v, want the configured %v", ttl, testTokenTTL)
+			}
+		})
+	}
+}
+
+func TestGetClaimsFromToken(t *testing.T) {
+	f := newAuthFixture(t)
+	user := f.addUser(t, models.User{Email: "ada@example.com"}, "correct horse")
+	token, err := f.auth.IssueToken(context.Background(), user, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKeys := NewAuthService(f.repos.Users, f.sessions, NewJWTKeyRing([]byte("another-key")), testTokenTTL, discardLogger())
+
+	tests := []struct {
+		name  string
+		auth  AuthService
+		token string
+		valid bool
+	}{
+		{name: "issued here", auth: f.auth, token: token, valid: true},
+		{name: "signed with another key", auth: otherKeys, token: token},
+		{name: "tampered", auth: f.auth, token: token[:len(token)-2] + "xx"},
+		{name: "garbage", auth: f.auth, token: "not-a-token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.auth.GetClaimsFromToken(context.Background(), tt.token)
+			if (err == nil) != tt.valid {
+				t.Fatalf("err = %v, want valid %v", err, tt.valid)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("err = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestImpersonate(t *testing.T) {
+	f := newAuthFixture(t)
+	staff := f.addUser(t, models.User{Email: "staff@acme.example", Role: models.RoleAdmin, Tenant: "acme"}, "pw")
+	otherAdmin := f.addUser(t, models.User{Email: "boss@acme.example", Role: models.RoleAdmin, Tenant: "acme"}, "pw")
+	member := f.addUser(t, models.User{Email: "member@acme.example", Tenant: "acme"}, "pw")
+	disabled := f.addUser(t, models.User{Email: "left@acme.example", Tenant: "acme", Disabled: true}, "pw")
+	outsider := f.addUser(t, models.User{Email: "member@other.example", Tenant: "other"}, "pw")
+
+	tests := []struct {
+		name    string
+		subject uuid.UUID
+		wantErr bool
+	}{
+		{name: "member of the same tenant", subject: member.ID},
+		{name: "themselves", subject: staff.ID, wantErr: true},
+		{name: "another admin", subject: otherAdmin.ID, wantErr: true},
+		{name: "disabled user", subject: disabled.ID, wantErr: true},
+		{name: "another tenant", subject: outsider.ID, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			token, subject, err := f.auth.Impersonate(ctx, staff.ID, tt.subject, 15*time.Minute, ClientInfo{})
+			if tt.wantErr {
+				if !errors.Is(err, ErrCannotImpersonate) {
+					t.Fatalf("err = %v, want ErrCannotImpersonate", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			claims, err := f.auth.GetClaimsFromToken(ctx, token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actor, ok := ImpersonatorFromClaims(claims); !ok || actor != staff.ID {
+				t.Fatalf("actor = %v, %v; want %v", actor, ok, staff.ID)
+			}
+			sessionID, ok := SessionIDFromClaims(claims)
+			if !ok {
+				t.Fatal("the impersonation token carries no session")
+			}
+			session, err := f.sessions.ValidateSession(ctx, sessionID)
+			if err != nil || session.UserID != subject.ID {
+				t.Fatalf("session = %+v, %v; want one for the subject", session, err)
+			}
+		})
+	}
+}
+
+func TestActiveUser(t *testing.T) {
+	f := newAuthFixture(t)
+	active := f.addUser(t, models.User{Email: "ada@example.com"}, "pw")
+	disabled := f.addUser(t, models.User{Email: "gone@example.com", Disabled: true}, "pw")
+
+	tests := []struct {
+		name    string
+		id      uuid.UUID
+		wantErr error
+	}{
+		{name: "active", id: active.ID},
+		{name: "disabled", id: disabled.ID, wantErr: ErrAccountDisabled},
+		{name: "deleted", id: uuid.New(), wantErr: ErrAccountDisabled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := f.auth.ActiveUser(context.Background(), tt.id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && user.ID != tt.id {
+				t.Fatalf("got user %s, want %s", user.ID, tt.id)
+			}
+		})
+	}
+}
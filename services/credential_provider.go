@@ -0,0 +1,63 @@
+// services/credential_provider.go
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ABDULS21985/test-portal/apperrors"
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrUnknownUser is returned by a provider that has no account for the given email,
+	// telling the chain to move on to the next provider
+	ErrUnknownUser = errors.New("user not known to provider")
+	// ErrInvalidCredentials is returned when the account exists but the password is wrong
+	ErrInvalidCredentials = apperrors.Unauthorized("invalid credentials")
+)
+
+// CredentialProvider verifies a user's credentials against a single backend
+type CredentialProvider interface {
+	Name() string
+	Authenticate(ctx context.Context, email, password string) (*models.User, error)
+}
+
+type localCredentialProvider struct {
+	userRepo repositories.UserRepository
+}
+
+// NewLocalCredentialProvider creates a provider that checks bcrypt hashes stored in the users table
+func NewLocalCredentialProvider(userRepo repositories.UserRepository) CredentialProvider {
+	return &localCredentialProvider{
+		userRepo: userRepo,
+	}
+}
+
+func (p *localCredentialProvider) Name() string {
+	return models.AuthSourceLocal
+}
+
+func (p *localCredentialProvider) Authenticate(ctx context.Context, email, password string) (*models.User, error) {
+	user, err := p.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if apperrors.KindOf(err) == apperrors.KindNotFound {
+			return nil, ErrUnknownUser
+		}
+		return nil, err
+	}
+
+	// Directory-backed accounts have no usable local password
+	if !user.IsLocal() {
+		return nil, ErrUnknownUser
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return user, nil
+}
@@ -0,0 +1,222 @@
+// services/health_service.go
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"sync"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/database"
+	"github.com/ABDULS21985/test-portal/migrations"
+
+	"gorm.io/gorm"
+)
+
+// Health check statuses
+const (
+	HealthStatusOK       = "ok"
+	HealthStatusDegraded = "degraded"
+	HealthStatusFailing  = "failing"
+)
+
+// HealthCheck is one dependency probed by the readiness endpoint. A failing critical check makes
+// the service not ready; a failing optional one only degrades it.
+type HealthCheck struct {
+	Name     string
+	Critical bool
+	Check    func(ctx context.Context) error
+}
+
+// HealthCheckResult is the outcome of a single check. Errors are logged rather than reported,
+// since the endpoint is public and they can name hosts and users.
+type HealthCheckResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// HealthReport aggregates the results of every check
+type HealthReport struct {
+	Status string              `json:"status"`
+	Checks []HealthCheckResult `json:"checks"`
+}
+
+// Ready reports whether every critical check passed
+func (r *HealthReport) Ready() bool {
+	return r.Status != HealthStatusFailing
+}
+
+// HealthService runs the readiness checks
+type HealthService interface {
+	Readiness(ctx context.Context) *HealthReport
+}
+
+type healthService struct {
+	timeout time.Duration
+	checks  []HealthCheck
+	logger  *slog.Logger
+}
+
+// NewHealthService creates a new instance of healthService; each check gets at most timeout
+func NewHealthService(timeout time.Duration, logger *slog.Logger, checks ...HealthCheck) HealthService {
+	return &healthService{
+		timeout: timeout,
+		checks:  checks,
+		logger:  logger,
+	}
+}
+
+// Readiness runs all checks concurrently
+func (s *healthService) Readiness(ctx context.Context) *HealthReport {
+	results := make([]HealthCheckResult, len(s.checks))
+	var wg sync.WaitGroup
+	for i, check := range s.checks {
+		wg.Add(1)
+		go func(i int, check HealthCheck) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, s.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check.Check(checkCtx)
+			results[i] = HealthCheckResult{Name: check.Name, Status: HealthStatusOK}
+			if err != nil {
+				results[i].Status = HealthStatusFailing
+				s.logger.WarnContext(ctx, "health check failed", slog.String("check", check.Name), slog.Bool("critical", check.Critical), slog.Duration("duration", time.Since(start)), slog.Any("error", err))
+			}
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := &HealthReport{Status: HealthStatusOK, Checks: results}
+	for i, result := range results {
+		if result.Status == HealthStatusOK {
+			continue
+		}
+		if s.checks[i].Critical {
+			report.Status = HealthStatusFailing
+			break
+		}
+		report.Status = HealthStatusDegraded
+	}
+	return report
+}
+
+// DatabaseHealthCheck pings the database
+func DatabaseHealthCheck(db *gorm.DB) HealthCheck {
+	return HealthCheck{
+		Name:     "database",
+		Critical: true,
+		Check: func(ctx context.Context) error {
+			return database.Ping(ctx, db)
+		},
+	}
+}
+
+// MigrationsHealthCheck fails while migrations are pending, so traffic only reaches an instance
+// whose schema matches its code
+func MigrationsHealthCheck(migrator *migrations.Migrator) HealthCheck {
+	return HealthCheck{
+		Name:     "migrations",
+		Critical: true,
+		Check: func(ctx context.Context) error {
+			pending, err := migrator.Pending(ctx)
+			if err != nil {
+				return err
+			}
+			if len(pending) > 0 {
+				return fmt.Errorf("%d pending migration(s), next is %04d_%s", len(pending), pending[0].Version, pending[0].Name)
+			}
+			return nil
+		},
+	}
+}
+
+// SMTPHealthCheck checks that the mail server accepts connections and, when credentials are
+// set, that they still log in. Every probe connects, but the login is only repeated once
+// loginInterval has passed or the credentials change: logging in on every probe floods the
+// server's auth log and can trip its lockout. Mail is not needed to serve most requests, so the
+// check is optional.
+func SMTPHealthCheck(host string, port int, credentials *SMTPCredentials, loginInterval time.Duration) HealthCheck {
+	address := net.JoinHostPort(host, fmt.Sprint(port))
+	logins := &smtpLoginCache{interval: loginInterval}
+	return HealthCheck{
+		Name: "smtp",
+		Check: func(ctx context.Context) error {
+			var dialer net.Dialer
+			conn, err := dialer.DialContext(ctx, "tcp", address)
+			if err != nil {
+				return err
+			}
+			username, password := credentials.Get()
+			if username == "" {
+				return conn.Close()
+			}
+			if recent, err := logins.get(username, password, time.Now()); recent {
+				conn.Close()
+				return err
+			}
+
+			if deadline, ok := ctx.Deadline(); ok {
+				conn.SetDeadline(deadline)
+			}
+			err = smtpLogin(conn, host, username, password)
+			// A login cut short by the probe's deadline says nothing about the credentials
+			if ctx.Err() == nil {
+				logins.put(username, password, time.Now(), err)
+			}
+			return err
+		},
+	}
+}
+
+// smtpLoginCache remembers the outcome of the last SMTP login for the credentials it used
+type smtpLoginCache struct {
+	interval time.Duration
+
+	mu       sync.Mutex
+	username string
+	password string
+	at       time.Time
+	err      error
+}
+
+// get reports whether the last login used these credentials less than interval ago, and how it
+// went
+func (c *smtpLoginCache) get(username, password string, now time.Time) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.at.IsZero() || c.username != username || c.password != password || now.Sub(c.at) >= c.interval {
+		return false, nil
+	}
+	return true, c.err
+}
+
+func (c *smtpLoginCache) put(username, password string, now time.Time, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.username, c.password, c.at, c.err = username, password, now, err
+}
+
+// smtpLogin authenticates on conn, upgrading to TLS first when the server offers it
+func smtpLogin(conn net.Conn, host, username, password string) error {
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if err := client.Auth(smtp.PlainAuth("", username, password, host)); err != nil {
+		return err
+	}
+	return client.Quit()
+}
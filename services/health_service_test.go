@@ -0,0 +1,296 @@
+// services/health_service_test.go
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func passing(context.Context) error { return nil }
+
+func failing(context.Context) error {
+	return errors.New("dial tcp db.internal:5432: connection refused")
+}
+
+func TestHealthReadiness(t *testing.T) {
+	tests := []struct {
+		name       string
+		checks     []HealthCheck
+		wantStatus string
+		wantChecks []HealthCheckResult
+	}{
+		{name: "no checks", wantStatus: HealthStatusOK, wantChecks: []HealthCheckResult{}},
+		{
+			name:       "all passing",
+			checks:     []HealthCheck{{Name: "database", Critical: true, Check: passing}, {Name: "smtp", Check: passing}},
+			wantStatus: HealthStatusOK,
+			wantChecks: []HealthCheckResult{{Name: "database", Status: HealthStatusOK}, {Name: "smtp", Status: HealthStatusOK}},
+		},
+		{
+			name:       "optional failing",
+			checks:     []HealthCheck{{Name: "database", Critical: true, Check: passing}, {Name: "smtp", Check: failing}},
+			wantStatus: HealthStatusDegraded,
+			wantChecks: []HealthCheckResult{{Name: "database", Status: HealthStatusOK}, {Name: "smtp", Status: HealthStatusFailing}},
+		},
+		{
+			name:       "critical failing",
+			checks:     []HealthCheck{{Name: "database", Critical: true, Check: failing}, {Name: "smtp", Check: passing}},
+			wantStatus: HealthStatusFailing,
+			wantChecks: []HealthCheckResult{{Name: "database", Status: HealthStatusFailing}, {Name: "smtp", Status: HealthStatusOK}},
+		},
+		{
+			name:       "critical outranks optional",
+			checks:     []HealthCheck{{Name: "smtp", Check: failing}, {Name: "database", Critical: true, Check: failing}},
+			wantStatus: HealthStatusFailing,
+			wantChecks: []HealthCheckResult{{Name: "smtp", Status: HealthStatusFailing}, {Name: "database", Status: HealthStatusFailing}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewHealthService(time.Second, slog.New(slog.NewTextHandler(io.Discard, nil)), tt.checks...)
+			report := service.Readiness(context.Background())
+			if report.Status != tt.wantStatus || !reflect.DeepEqual(report.Checks, tt.wantChecks) {
+				t.Fatalf("report = %+v, want %s %+v", report, tt.wantStatus, tt.wantChecks)
+			}
+			if report.Ready() != (tt.wantStatus != HealthStatusFailing) {
+				t.Fatalf("Ready() = %v for status %s", report.Ready(), report.Status)
+			}
+		})
+	}
+}
+
+func TestHealthReadinessHidesErrors(t *testing.T) {
+	var logged strings.Builder
+	service := NewHealthService(time.Second, slog.New(slog.NewTextHandler(&logged, nil)), HealthCheck{Name: "database", Critical: true, Check: failing})
+	body, err := json.Marshal(service.Readiness(context.Background()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(body), "db.internal") {
+		t.Fatalf("the report leaks the error: %s", body)
+	}
+	if !strings.Contains(logged.String(), "db.internal") {
+		t.Fatalf("the error wasn't logged: %q", logged.String())
+	}
+}
+
+func TestHealthReadinessTimeout(t *testing.T) {
+	hanging := HealthCheck{Name: "database", Critical: true, Check: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	service := NewHealthService(20*time.Millisecond, slog.New(slog.NewTextHandler(io.Discard, nil)), hanging, HealthCheck{Name: "smtp", Check: passing})
+
+	start := time.Now()
+	report := service.Readiness(context.Background())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("a hanging check held readiness for %v", elapsed)
+	}
+	if report.Status != HealthStatusFailing || report.Checks[0].Status != HealthStatusFailing || report.Checks[1].Status != HealthStatusOK {
+		t.Fatalf("report = %+v", report)
+	}
+}
+
+func TestHealthReadinessConcurrent(t *testing.T) {
+	// Each check waits until all of them have started, which only happens if they run together
+	const checks = 3
+	var started sync.WaitGroup
+	started.Add(checks)
+	waitForAll := func(ctx context.Context) error {
+		started.Done()
+		done := make(chan struct{})
+		go func() {
+			started.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	var list []HealthCheck
+	for i := 0; i < checks; i++ {
+		list = append(list, HealthCheck{Name: "check", Critical: true, Check: waitForAll})
+	}
+
+	report := NewHealthService(time.Second, slog.New(slog.NewTextHandler(io.Discard, nil)), list...).Readiness(context.Background())
+	if report.Status != HealthStatusOK {
+		t.Fatalf("report = %+v, want the checks to run concurrently", report)
+	}
+}
+
+// fakeSMTP is a minimal mail server that accepts PLAIN logins with one password and counts
+// connections and login attempts
+type fakeSMTP struct {
+	listener    net.Listener
+	password    string
+	connections atomic.Int32
+	logins      atomic.Int32
+}
+
+func newFakeSMTP(t *testing.T, password string) *fakeSMTP {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	s := &fakeSMTP{listener: listener, password: password}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTP) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	s.connections.Add(1)
+	reader := bufio.NewReader(conn)
+	fmt.Fprint(conn, "220 localhost ESMTP\r\n")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			fmt.Fprint(conn, "250-localhost\r\n250 AUTH PLAIN\r\n")
+		case "AUTH":
+			s.logins.Add(1)
+			_, initial, _ := strings.Cut(arg, " ")
+			response, _ := base64.StdEncoding.DecodeString(initial)
+			if parts := strings.Split(string(response), "\x00"); len(parts) == 3 && parts[2] == s.password {
+				fmt.Fprint(conn, "235 2.7.0 Authentication successful\r\n")
+			} else {
+				fmt.Fprint(conn, "535 5.7.8 Authentication credentials invalid\r\n")
+			}
+		case "QUIT":
+			fmt.Fprint(conn, "221 2.0.0 Bye\r\n")
+			return
+		default:
+			fmt.Fprint(conn, "502 5.5.2 Command not recognized\r\n")
+		}
+	}
+}
+
+// waitForConnections waits until the server has seen want connections
+func (s *fakeSMTP) waitForConnections(t *testing.T, want int32) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); s.connections.Load() < want; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d connections, want %d", s.connections.Load(), want)
+		}
+	}
+}
+
+func TestSMTPHealthCheck(t *testing.T) {
+	server := newFakeSMTP(t, "right")
+	credentials := NewSMTPCredentials("mailer", "right")
+	check := SMTPHealthCheck("127.0.0.1", server.port(), credentials, time.Hour)
+
+	// The probes run in order and share the login cache
+	probes := []struct {
+		name       string
+		password   string // set before the probe
+		wantErr    bool
+		wantLogins int32
+	}{
+		{name: "first probe logs in", wantLogins: 1},
+		{name: "a recent login is reused", wantLogins: 1},
+		{name: "rotated password logs in again", password: "wrong", wantErr: true, wantLogins: 2},
+		{name: "a recent failure is reused", wantErr: true, wantLogins: 2},
+		{name: "fixed password logs in again", password: "right", wantLogins: 3},
+	}
+	for i, probe := range probes {
+		if probe.password != "" {
+			credentials.Set("mailer", probe.password)
+		}
+		err := check.Check(context.Background())
+		if (err != nil) != probe.wantErr {
+			t.Fatalf("%s: err = %v, want error %v", probe.name, err, probe.wantErr)
+		}
+		if got := server.logins.Load(); got != probe.wantLogins {
+			t.Fatalf("%s: %d logins, want %d", probe.name, got, probe.wantLogins)
+		}
+		// Every probe connects, whether or not it logs in
+		server.waitForConnections(t, int32(i+1))
+	}
+
+	// A server that is down fails the check even with a recent login
+	server.listener.Close()
+	if err := check.Check(context.Background()); err == nil {
+		t.Fatal("the check passed with the server down")
+	}
+}
+
+func TestSMTPHealthCheckWithoutLogin(t *testing.T) {
+	server := newFakeSMTP(t, "right")
+	check := SMTPHealthCheck("127.0.0.1", server.port(), NewSMTPCredentials("", ""), time.Hour)
+	for i := 1; i <= 2; i++ {
+		if err := check.Check(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		server.waitForConnections(t, int32(i))
+	}
+	if got := server.logins.Load(); got != 0 {
+		t.Fatalf("%d logins without credentials", got)
+	}
+}
+
+func TestSMTPLoginCache(t *testing.T) {
+	epoch := time.Unix(1_700_000_000, 0)
+	failed := errors.New("535 5.7.8 Authentication credentials invalid")
+	cache := &smtpLoginCache{interval: time.Minute}
+	if recent, _ := cache.get("mailer", "right", epoch); recent {
+		t.Fatal("an empty cache has a recent login")
+	}
+	cache.put("mailer", "right", epoch, failed)
+
+	tests := []struct {
+		name       string
+		username   string
+		password   string
+		at         time.Duration
+		wantRecent bool
+	}{
+		{name: "same credentials", username: "mailer", password: "right", at: time.Minute - time.Nanosecond, wantRecent: true},
+		{name: "expired", username: "mailer", password: "right", at: time.Minute},
+		{name: "other password", username: "mailer", password: "rotated", at: time.Second},
+		{name: "other user", username: "postmaster", password: "right", at: time.Second},
+	}
+	for _, tt := range tests {
+		recent, err := cache.get(tt.username, tt.password, epoch.Add(tt.at))
+		if recent != tt.wantRecent {
+			t.Errorf("%s: recent = %v, want %v", tt.name, recent, tt.wantRecent)
+		}
+		if recent && err != failed {
+			t.Errorf("%s: err = %v, want the stored failure", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// services/jwt_keys.go
+package services
+
+import (
+	"bytes"
+	"sync"
+)
+
+// JWTKeyRing holds the key tokens are signed with. When the secret is rotated the previous key
+// is kept for verification, so tokens issued just before the rotation stay valid until they
+// expire instead of logging everybody out.
+type JWTKeyRing struct {
+	mu       sync.RWMutex
+	current  []byte
+	previous []byte
+}
+
+// NewJWTKeyRing creates a new instance of JWTKeyRing signing with secret
+func NewJWTKeyRing(secret []byte) *JWTKeyRing {
+	return &JWTKeyRing{current: secret}
+}
+
+// SigningKey returns the key new tokens are signed with
+func (k *JWTKeyRing) SigningKey() []byte {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current
+}
+
+// VerificationKeys returns the keys a token may have been signed with, current first
+func (k *JWTKeyRing) VerificationKeys() [][]byte {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if k.previous == nil {
+		return [][]byte{k.current}
+	}
+	return [][]byte{k.current, k.previous}
+}
+
+// Rotate makes secret the signing key and keeps the old one for verification. It reports
+// whether the key actually changed.
+func (k *JWTKeyRing) Rotate(secret []byte) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if bytes.Equal(k.current, secret) {
+		return false
+	}
+	k.previous, k.current = k.current, secret
+	return true
+}
@@ -0,0 +1,422 @@
+// services/ldap_provider.go
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/apperrors"
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// LDAPGroupRole maps a directory group DN onto a portal role
+type LDAPGroupRole struct {
+	GroupDN string
+	Role    string
+}
+
+// ParseLDAPGroupRoles parses a "groupDN:role;groupDN:role" list. The role is taken after the last
+// colon because group DNs themselves contain commas and equals signs.
+func ParseLDAPGroupRoles(value string) ([]LDAPGroupRole, error) {
+	var mappings []LDAPGroupRole
+	for _, pair := range strings.Split(value, ";") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		idx := strings.LastIndex(pair, ":")
+		if idx <= 0 || idx == len(pair)-1 {
+			return nil, fmt.Errorf("invalid LDAP group mapping %q", pair)
+		}
+		mappings = append(mappings, LDAPGroupRole{
+			GroupDN: strings.TrimSpace(pair[:idx]),
+			Role:    strings.TrimSpace(pair[idx+1:]),
+		})
+	}
+	return mappings, nil
+}
+
+// LDAPConfig holds the settings needed to authenticate against an LDAP or Active Directory server
+type LDAPConfig struct {
+	URL                string
+	BindDN             string
+	BindPassword       string
+	BaseDN             string
+	UserFilter         string // must contain a single %s for the escaped email, e.g. "(mail=%s)"
+	EmailAttribute     string
+	NameAttribute      string
+	GroupAttribute     string
+	GroupRoles         []LDAPGroupRole // checked in order, first match wins
+	DefaultRole        string
+	StartTLS           bool
+	InsecureSkipVerify bool
+	Timeout            time.Duration
+}
+
+// LDAPConn is the subset of *ldap.Conn used by the provider, so a stand-in server can be used in tests
+type LDAPConn interface {
+	Bind(username, password string) error
+	Search(searchRequest *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close() error
+}
+
+// LDAPDialer opens a new connection to the directory, giving up when ctx is done
+type LDAPDialer func(ctx context.Context) (LDAPConn, error)
+
+type ldapCredentialProvider struct {
+	cfg      LDAPConfig
+	dial     LDAPDialer
+	userRepo repositories.UserRepository
+}
+
+// NewLDAPCredentialProvider creates a provider that authenticates users with an LDAP bind and
+// mirrors their directory attributes into the users table
+func NewLDAPCredentialProvider(cfg LDAPConfig, userRepo repositories.UserRepository) CredentialProvider {
+	return NewLDAPCredentialProviderWithDialer(cfg, userRepo, defaultLDAPDialer(cfg))
+}
+
+// NewLDAPCredentialProviderWithDialer is like NewLDAPCredentialProvider but uses the given dialer
+func NewLDAPCredentialProviderWithDialer(cfg LDAPConfig, userRepo repositories.UserRepository, dial LDAPDialer) CredentialProvider {
+	return &ldapCredentialProvider{
+		cfg:      withLDAPDefaults(cfg),
+		dial:     dial,
+		userRepo: userRepo,
+	}
+}
+
+func withLDAPDefaults(cfg LDAPConfig) LDAPConfig {
+	if cfg.UserFilter == "" {
+		cfg.UserFilter = "(mail=%s)"
+	}
+	if cfg.EmailAttribute == "" {
+		cfg.EmailAttribute = "mail"
+	}
+	if cfg.NameAttribute == "" {
+		cfg.NameAttribute = "cn"
+	}
+	if cfg.GroupAttribute == "" {
+		cfg.GroupAttribute = "memberOf"
+	}
+	if cfg.DefaultRole == "" {
+		cfg.DefaultRole = "user"
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	return cfg
+}
+
+func defaultLDAPDialer(cfg LDAPConfig) LDAPDialer {
+	return func(ctx context.Context) (LDAPConn, error) {
+		// Never wait longer than the caller's deadline allows
+		timeout := cfg.Timeout
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout = min(timeout, time.Until(deadline))
+		}
+		if timeout <= 0 {
+			return nil, context.DeadlineExceeded
+		}
+
+		tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+		conn, err := ldap.DialURL(cfg.URL, ldap.DialWithTLSConfig(tlsConfig), ldap.DialWithDialer(&net.Dialer{Timeout: timeout}))
+		if err != nil {
+			return nil, err
+		}
+		conn.SetTimeout(timeout)
+
+		if cfg.StartTLS {
+			if err := conn.StartTLS(tlsConfig); err != nil {
+				conn.Close()
+				return nil, err
+			}
+		}
+		return conn, nil
+	}
+}
+
+func (p *ldapCredentialProvider) Name() string {
+	return models.AuthSourceLDAP
+}
+
+func (p *ldapCredentialProvider) Authenticate(ctx context.Context, email, password string) (*models.User, error) {
+	// An empty password would turn the user bind into an unauthenticated bind, which most servers accept
+	if email == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := p.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := p.bindServiceAccount(conn); err != nil {
+		return nil, err
+	}
+
+	filter := fmt.Sprintf(p.cfg.UserFilter, ldap.EscapeFilter(email))
+	entry, err := p.searchOne(conn, p.cfg.BaseDN, ldap.ScopeWholeSubtree, filter)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, ErrUnknownUser
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("ldap: user bind: %w", err)
+	}
+
+	return p.upsertUser(ctx, entry)
+}
+
+// SyncUser refreshes a directory-backed user's attributes and role from the server
+func (p *ldapCredentialProvider) SyncUser(ctx context.Context, conn LDAPConn, user *models.User) error {
+	entry, err := p.searchOne(conn, user.ExternalID, ldap.ScopeBaseObject, "(objectClass=*)")
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return ErrUnknownUser
+	}
+
+	p.applyEntry(user, entry)
+	return p.userRepo.UpdateUser(ctx, user)
+}
+
+// connect dials the directory and closes the connection as soon as ctx is done, which aborts
+// whatever bind or search is in flight. The returned connection's Close stops that watch.
+func (p *ldapCredentialProvider) connect(ctx context.Context) (LDAPConn, error) {
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: connect: %w", err)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	return &ctxConn{LDAPConn: conn, stop: stop}, nil
+}
+
+// ctxConn is an LDAPConn tied to a context by connect
+type ctxConn struct {
+	LDAPConn
+	stop func() bool
+}
+
+func (c *ctxConn) Close() error {
+	c.stop()
+	return c.LDAPConn.Close()
+}
+
+func (p *ldapCredentialProvider) bindServiceAccount(conn LDAPConn) error {
+	if p.cfg.BindDN == "" {
+		return nil
+	}
+	if err := conn.Bind(p.cfg.BindDN, p.cfg.BindPassword); err != nil {
+		return fmt.Errorf("ldap: service bind: %w", err)
+	}
+	return nil
+}
+
+func (p *ldapCredentialProvider) searchOne(conn LDAPConn, baseDN string, scope int, filter string) (*ldap.Entry, error) {
+	request := ldap.NewSearchRequest(
+		baseDN,
+		scope,
+		ldap.NeverDerefAliases,
+		2, // we only need to know whether the match is unique
+		int(p.cfg.Timeout.Seconds()),
+		false,
+		filter,
+		[]string{p.cfg.EmailAttribute, p.cfg.NameAttribute, p.cfg.GroupAttribute},
+		nil,
+	)
+
+	result, err := conn.Search(request)
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("ldap: search: %w", err)
+	}
+
+	switch len(result.Entries) {
+	case 0:
+		return nil, nil
+	case 1:
+		return result.Entries[0], nil
+	default:
+		return nil, errors.New("ldap: filter matched more than one entry")
+	}
+}
+
+func (p *ldapCredentialProvider) upsertUser(ctx context.Context, entry *ldap.Entry) (*models.User, error) {
+	email := entry.GetAttributeValue(p.cfg.EmailAttribute)
+	if email == "" {
+		return nil, errors.New("ldap: entry has no email attribute")
+	}
+
+	user, err := p.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if apperrors.KindOf(err) != apperrors.KindNotFound {
+			return nil, err
+		}
+		user = &models.User{
+			Email:      email,
+			AuthSource: models.AuthSourceLDAP,
+			// Never matches a bcrypt hash, so the local provider can't be used for this account
+			Password: "!ldap",
+		}
+		p.applyEntry(user, entry)
+		if err := p.userRepo.CreateUser(ctx, user); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+
+	// Refuse to take over an account whose password is managed locally
+	if user.AuthSource != models.AuthSourceLDAP {
+		return nil, fmt.Errorf("ldap: %s belongs to a %s account", email, user.AuthSource)
+	}
+
+	p.applyEntry(user, entry)
+	if err := p.userRepo.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (p *ldapCredentialProvider) applyEntry(user *models.User, entry *ldap.Entry) {
+	now := time.Now()
+
+	if name := entry.GetAttributeValue(p.cfg.NameAttribute); name != "" {
+		user.Name = name
+	}
+	if email := entry.GetAttributeValue(p.cfg.EmailAttribute); email != "" {
+		user.Email = email
+	}
+	user.ExternalID = entry.DN
+	user.Role = p.mapRole(entry.GetAttributeValues(p.cfg.GroupAttribute))
+	// The directory decides who has an account: one that reappears after SyncAll disabled it is
+	// enabled again
+	user.Disabled = false
+	user.LastSyncedAt = &now
+}
+
+func (p *ldapCredentialProvider) mapRole(groups []string) string {
+	for _, mapping := range p.cfg.GroupRoles {
+		for _, group := range groups {
+			if strings.EqualFold(group, mapping.GroupDN) {
+				return mapping.Role
+			}
+		}
+	}
+	return p.cfg.DefaultRole
+}
+
+// LDAPSyncer periodically refreshes the attributes of every directory-backed user
+type LDAPSyncer struct {
+	provider *ldapCredentialProvider
+	userRepo repositories.UserRepository
+	interval time.Duration
+	logger   *slog.Logger
+}
+
+// NewLDAPSyncer creates a syncer for users authenticated by the given LDAP provider
+func NewLDAPSyncer(provider CredentialProvider, userRepo repositories.UserRepository, interval time.Duration, logger *slog.Logger) (*LDAPSyncer, error) {
+	ldapProvider, ok := provider.(*ldapCredentialProvider)
+	if !ok {
+		return nil, errors.New("ldap syncer requires an LDAP credential provider")
+	}
+	return &LDAPSyncer{
+		provider: ldapProvider,
+		userRepo: userRepo,
+		interval: interval,
+		logger:   logger.With(slog.String("component", "ldap_sync")),
+	}, nil
+}
+
+// Start runs SyncAll every interval until stop is closed; closing stop also cancels a sync in
+// progress
+func (s *LDAPSyncer) Start(stop <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.SyncAll(ctx); err != nil {
+				s.logger.Error("LDAP sync failed", slog.Any("error", err))
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// SyncAll refreshes every LDAP-sourced user in a single directory session. Users that have been
+// removed from the directory are disabled, which also ends their sessions at the next request.
+func (s *LDAPSyncer) SyncAll(ctx context.Context) error {
+	users, err := s.userRepo.ListUsersByAuthSource(ctx, models.AuthSourceLDAP)
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		return nil
+	}
+
+	conn, err := s.provider.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := s.provider.bindServiceAccount(conn); err != nil {
+		return err
+	}
+
+	synced, disabled := 0, 0
+	for i := range users {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		user := &users[i]
+		err := s.provider.SyncUser(ctx, conn, user)
+		switch {
+		case errors.Is(err, ErrUnknownUser):
+			if user.Disabled {
+				continue
+			}
+			s.logger.Warn("user no longer exists in the directory; disabling", slog.String("user_id", user.ID.String()), slog.String("external_id", user.ExternalID))
+			user.Disabled = true
+			if err := s.userRepo.UpdateUser(ctx, user); err != nil {
+				s.logger.Error("could not disable user", slog.String("user_id", user.ID.String()), slog.Any("error", err))
+				continue
+			}
+			disabled++
+		case err != nil:
+			s.logger.Warn("could not refresh user", slog.String("user_id", user.ID.String()), slog.Any("error", err))
+		default:
+			synced++
+		}
+	}
+
+	s.logger.Info("LDAP sync finished", slog.Int("refreshed", synced), slog.Int("disabled", disabled), slog.Int("total", len(users)))
+	return nil
+}
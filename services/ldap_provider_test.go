@@ -0,0 +1,285 @@
+// services/ldap_provider_test.go
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ABDULS21985/test-portal/apperrors"
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories"
+	"github.com/ABDULS21985/test-portal/repositories/memory"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	testBaseDN     = "ou=people,dc=example,dc=com"
+	testServiceDN  = "cn=portal,dc=example,dc=com"
+	testServicePW  = "service-secret"
+	testAdminGroup = "cn=portal-admins,ou=groups,dc=example,dc=com"
+)
+
+// fakeDirectory is an in-process LDAP stand-in. It understands binds and the equality and
+// presence filters the provider sends.
+type fakeDirectory struct {
+	mu      sync.Mutex
+	entries map[string]fakeEntry // by DN
+}
+
+type fakeEntry struct {
+	password string
+	attrs    map[string][]string
+}
+
+func newFakeDirectory() *fakeDirectory {
+	return &fakeDirectory{entries: map[string]fakeEntry{}}
+}
+
+func (d *fakeDirectory) add(uid, email, name, password string, groups ...string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dn := "uid=" + uid + "," + testBaseDN
+	d.entries[dn] = fakeEntry{password: password, attrs: map[string][]string{
+		"mail":     {email},
+		"cn":       {name},
+		"memberOf": groups,
+	}}
+	return dn
+}
+
+func (d *fakeDirectory) remove(dn string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.entries, dn)
+}
+
+func (d *fakeDirectory) dial(ctx context.Context) (LDAPConn, error) {
+	return &fakeConn{dir: d}, nil
+}
+
+type fakeConn struct {
+	dir *fakeDirectory
+}
+
+func (c *fakeConn) Bind(username, password string) error {
+	c.dir.mu.Lock()
+	defer c.dir.mu.Unlock()
+	if username == testServiceDN && password == testServicePW {
+		return nil
+	}
+	if entry, ok := c.dir.entries[username]; ok && password != "" && entry.password == password {
+		return nil
+	}
+	return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+}
+
+func (c *fakeConn) Search(request *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	c.dir.mu.Lock()
+	defer c.dir.mu.Unlock()
+
+	attr, value, _ := strings.Cut(strings.Trim(request.Filter, "()"), "=")
+	result := &ldap.SearchResult{}
+	for dn, entry := range c.dir.entries {
+		if request.Scope == ldap.ScopeBaseObject && dn != request.BaseDN {
+			continue
+		}
+		if !strings.HasSuffix(dn, request.BaseDN) {
+			continue
+		}
+		if value != "*" && !containsFold(entry.attrs[attr], value) {
+			continue
+		}
+		result.Entries = append(result.Entries, ldap.NewEntry(dn, entry.attrs))
+	}
+	if request.Scope == ldap.ScopeBaseObject && len(result.Entries) == 0 {
+		return nil, ldap.NewError(ldap.LDAPResultNoSuchObject, errors.New("no such object"))
+	}
+	return result, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func testLDAPConfig() LDAPConfig {
+	return LDAPConfig{
+		BindDN:       testServiceDN,
+		BindPassword: testServicePW,
+		BaseDN:       testBaseDN,
+		GroupRoles:   []LDAPGroupRole{{GroupDN: testAdminGroup, Role: models.RoleAdmin}},
+	}
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestLDAPAuthenticate(t *testing.T) {
+	dir := newFakeDirectory()
+	dir.add("ada", "ada@example.com", "Ada Lovelace", "correct horse", testAdminGroup)
+	dir.add("alan", "alan@example.com", "Alan Turing", "enigma")
+
+	users := memory.NewStore().Repositories().Users
+	local := &models.User{Email: "local@example.com", Password: "hash", AuthSource: models.AuthSourceLocal}
+	if err := users.CreateUser(context.Background(), local); err != nil {
+		t.Fatal(err)
+	}
+	dir.add("local", "local@example.com", "Local", "secret")
+
+	provider := NewLDAPCredentialProviderWithDialer(testLDAPConfig(), users, dir.dial)
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantErr  error
+		wantRole string
+	}{
+		{name: "mapped group", email: "ada@example.com", password: "correct horse", wantRole: models.RoleAdmin},
+		{name: "default role", email: "alan@example.com", password: "enigma", wantRole: models.RoleUser},
+		{name: "wrong password", email: "alan@example.com", password: "bombe", wantErr: ErrInvalidCredentials},
+		{name: "empty password", email: "alan@example.com", password: "", wantErr: ErrInvalidCredentials},
+		{name: "unknown user", email: "grace@example.com", password: "cobol", wantErr: ErrUnknownUser},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := provider.Authenticate(context.Background(), tt.email, tt.password)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if user.Role != tt.wantRole || user.AuthSource != models.AuthSourceLDAP {
+				t.Fatalf("got role %q from %q, want %q from ldap", user.Role, user.AuthSource, tt.wantRole)
+			}
+			stored, err := users.GetUserByEmail(context.Background(), tt.email)
+			if err != nil || stored.ID != user.ID {
+				t.Fatalf("user was not stored: %v", err)
+			}
+		})
+	}
+
+	t.Run("local account", func(t *testing.T) {
+		if _, err := provider.Authenticate(context.Background(), "local@example.com", "secret"); err == nil {
+			t.Fatal("a locally managed account was taken over by the directory")
+		}
+	})
+
+	t.Run("second login updates the same user", func(t *testing.T) {
+		first, err := users.GetUserByEmail(context.Background(), "alan@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, err := provider.Authenticate(context.Background(), "alan@example.com", "enigma")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again.ID != first.ID {
+			t.Fatalf("a second user was created: %s and %s", first.ID, again.ID)
+		}
+	})
+}
+
+// failingLookups is a user repository whose email lookups fail with an internal error
+type failingLookups struct {
+	repositories.UserRepository
+	created int
+}
+
+func (r *failingLookups) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return nil, errors.New("connection reset by peer")
+}
+
+func (r *failingLookups) CreateUser(ctx context.Context, user *models.User) error {
+	r.created++
+	return r.UserRepository.CreateUser(ctx, user)
+}
+
+func TestLDAPAuthenticateRepositoryError(t *testing.T) {
+	dir := newFakeDirectory()
+	dir.add("alan", "alan@example.com", "Alan Turing", "enigma")
+	users := &failingLookups{UserRepository: memory.NewStore().Repositories().Users}
+	provider := NewLDAPCredentialProviderWithDialer(testLDAPConfig(), users, dir.dial)
+
+	_, err := provider.Authenticate(context.Background(), "alan@example.com", "enigma")
+	if err == nil || apperrors.KindOf(err) == apperrors.KindNotFound {
+		t.Fatalf("err = %v, want the repository error", err)
+	}
+	if users.created != 0 {
+		t.Fatalf("created %d users after a failed lookup", users.created)
+	}
+}
+
+func TestLDAPSyncAll(t *testing.T) {
+	ctx := context.Background()
+	dir := newFakeDirectory()
+	dir.add("ada", "ada@example.com", "Ada Lovelace", "correct horse")
+	alanDN := dir.add("alan", "alan@example.com", "Alan Turing", "enigma")
+
+	users := memory.NewStore().Repositories().Users
+	provider := NewLDAPCredentialProviderWithDialer(testLDAPConfig(), users, dir.dial)
+	for email, password := range map[string]string{"ada@example.com": "correct horse", "alan@example.com": "enigma"} {
+		if _, err := provider.Authenticate(ctx, email, password); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Ada is promoted and renamed in the directory; Alan leaves
+	dir.add("ada", "ada@example.com", "Ada King", "correct horse", testAdminGroup)
+	dir.remove(alanDN)
+
+	syncer, err := NewLDAPSyncer(provider, users, 0, discardLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := syncer.SyncAll(ctx); err != nil {
+		t.Fatalf("SyncAll: %v", err)
+	}
+
+	ada, err := users.GetUserByEmail(ctx, "ada@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ada.Name != "Ada King" || ada.Role != models.RoleAdmin || ada.Disabled {
+		t.Fatalf("ada = %q, role %q, disabled %v; want the directory's attributes", ada.Name, ada.Role, ada.Disabled)
+	}
+
+	alan, err := users.GetUserByEmail(ctx, "alan@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !alan.Disabled {
+		t.Fatal("a user removed from the directory is still enabled")
+	}
+
+	// Alan comes back, and the next sync enables him again
+	dir.add("alan", "alan@example.com", "Alan Turing", "enigma")
+	if err := syncer.SyncAll(ctx); err != nil {
+		t.Fatalf("SyncAll: %v", err)
+	}
+	if alan, err = users.GetUserByEmail(ctx, "alan@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if alan.Disabled {
+		t.Fatal("a user back in the directory is still disabled")
+	}
+}
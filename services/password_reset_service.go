@@ -2,48 +2,50 @@
 package services
 
 import (
+	"context"
 	"crypto/rand"
+	"database/sql"
 	"encoding/hex"
-	"errors"
 	"time"
 
+	"github.com/ABDULS21985/test-portal/apperrors"
 	"github.com/ABDULS21985/test-portal/models"
 	"github.com/ABDULS21985/test-portal/repositories"
 
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrInvalidResetToken is returned for a reset token that is unknown, used or expired
+var ErrInvalidResetToken = apperrors.Validation("reset token is invalid or has expired")
+
 // PasswordResetService defines the contract for password reset operations
 type PasswordResetService interface {
-	CreatePasswordResetToken(userID uuid.UUID) (string, error)
-	ValidatePasswordResetToken(token string) (*models.PasswordResetToken, error)
-	ResetPassword(token, newPassword string) error
-	GetUserByEmail(email string) (*models.User, error)
+	CreatePasswordResetToken(ctx context.Context, userID uuid.UUID) (string, error)
+	ValidatePasswordResetToken(ctx context.Context, token string) (*models.PasswordResetToken, error)
+	ResetPassword(ctx context.Context, token, newPassword string) error
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
 }
 
 type passwordResetService struct {
 	userRepo          repositories.UserRepository
 	passwordResetRepo repositories.PasswordResetRepository
+	txManager         repositories.TxManager
 }
 
 // NewPasswordResetService creates a new instance of passwordResetService
-func NewPasswordResetService(userRepo repositories.UserRepository, passwordResetRepo repositories.PasswordResetRepository) PasswordResetService {
+func NewPasswordResetService(userRepo repositories.UserRepository, passwordResetRepo repositories.PasswordResetRepository, txManager repositories.TxManager) PasswordResetService {
 	return &passwordResetService{
 		userRepo:          userRepo,
 		passwordResetRepo: passwordResetRepo,
+		txManager:         txManager,
 	}
 }
 
-func (s *passwordResetService) GetUserByEmail(email string) (*models.User, error) {
-	user, err := s.userRepo.GetUserByEmail(email)
-	if err != nil {
-		return nil, errors.New("user not found")
-	}
-	return user, nil
+func (s *passwordResetService) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return s.userRepo.GetUserByEmail(ctx, email)
 }
 
-func (s *passwordResetService) CreatePasswordResetToken(userID uuid.UUID) (string, error) {
+func (s *passwordResetService) CreatePasswordResetToken(ctx context.Context, userID uuid.UUID) (string, error) {
 	// Generate a secure random token
 	tokenBytes := make([]byte, 16)
 	_, err := rand.Read(tokenBytes)
@@ -58,53 +60,61 @@ func (s *passwordResetService) CreatePasswordResetToken(userID uuid.UUID) (strin
 		ExpiresAt: time.Now().Add(1 * time.Hour), // Token valid for 1 hour
 	}
 
-	if err := s.passwordResetRepo.CreateToken(prt); err != nil {
+	if err := s.passwordResetRepo.CreateToken(ctx, prt); err != nil {
 		return "", err
 	}
 
 	return token, nil
 }
 
-func (s *passwordResetService) ValidatePasswordResetToken(token string) (*models.PasswordResetToken, error) {
-	prt, err := s.passwordResetRepo.GetToken(token)
+func (s *passwordResetService) ValidatePasswordResetToken(ctx context.Context, token string) (*models.PasswordResetToken, error) {
+	return validateResetToken(ctx, s.passwordResetRepo, token)
+}
+
+// validateResetToken loads an unexpired token through repo, which may be bound to a transaction
+func validateResetToken(ctx context.Context, repo repositories.PasswordResetRepository, token string) (*models.PasswordResetToken, error) {
+	prt, err := repo.GetToken(ctx, token)
 	if err != nil {
+		if apperrors.KindOf(err) == apperrors.KindNotFound {
+			return nil, ErrInvalidResetToken
+		}
 		return nil, err
 	}
 
 	if prt.ExpiresAt.Before(time.Now()) {
-		return nil, errors.New("token has expired")
+		return nil, ErrInvalidResetToken
 	}
 
 	return prt, nil
 }
 
-func (s *passwordResetService) ResetPassword(token, newPassword string) error {
-	prt, err := s.ValidatePasswordResetToken(token)
+// ResetPassword sets the new password and spends the token in one serializable transaction, so
+// a token used twice concurrently changes the password only once
+func (s *passwordResetService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	// Hash outside the transaction; bcrypt is slow and would hold it open
+	hashedPassword, err := hashPassword(newPassword)
 	if err != nil {
 		return err
 	}
 
-	user, err := s.userRepo.GetUserByID(prt.UserID)
-	if err != nil {
-		return err
-	}
-
-	// Hash the new password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
-	if err != nil {
-		return err
-	}
-	user.Password = string(hashedPassword)
-
-	// Update user password
-	if err := s.userRepo.UpdateUser(user); err != nil {
-		return err
-	}
-
-	// Delete the used token
-	if err := s.passwordResetRepo.DeleteToken(prt.ID); err != nil {
-		return err
-	}
-
-	return nil
+	return s.txManager.WithinTransaction(ctx, func(ctx context.Context, repos repositories.Repositories) error {
+		prt, err := validateResetToken(ctx, repos.PasswordResets, token)
+		if err != nil {
+			return err
+		}
+
+		user, err := repos.Users.GetUserByID(ctx, prt.UserID)
+		if err != nil {
+			return err
+		}
+		user.Password = string(hashedPassword)
+
+		// Update user password
+		if err := repos.Users.UpdateUser(ctx, user); err != nil {
+			return err
+		}
+
+		// Delete the used token
+		return repos.PasswordResets.DeleteToken(ctx, prt.ID)
+	}, &sql.TxOptions{Isolation: sql.LevelSerializable})
 }
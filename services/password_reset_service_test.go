@@ -0,0 +1,97 @@
+// services/password_reset_service_test.go
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/apperrors"
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories/memory"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestResetPassword(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	repos := store.Repositories()
+	resets := NewPasswordResetService(repos.Users, repos.PasswordResets, memory.NewTxManager(store))
+
+	user := &models.User{Email: "ada@example.com", Password: "old-hash", Role: models.RoleUser}
+	if err := repos.Users.CreateUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	newToken := func() string {
+		t.Helper()
+		token, err := resets.CreatePasswordResetToken(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	expired := &models.PasswordResetToken{UserID: user.ID, Token: "expired", ExpiresAt: time.Now().Add(-time.Minute)}
+	if err := repos.PasswordResets.CreateToken(ctx, expired); err != nil {
+		t.Fatal(err)
+	}
+	used := newToken()
+	if err := resets.ResetPassword(ctx, used, "first new password"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		password string
+		wantErr  error
+		wantKind apperrors.Kind
+	}{
+		{name: "valid token", token: newToken(), password: "second new password"},
+		{name: "token already used", token: used, password: "another password", wantErr: ErrInvalidResetToken},
+		{name: "expired token", token: "expired", password: "another password", wantErr: ErrInvalidResetToken},
+		{name: "unknown token", token: "nope", password: "another password", wantErr: ErrInvalidResetToken},
+		{name: "password too long for bcrypt", token: newToken(), password: strings.Repeat("x", 73), wantKind: apperrors.KindValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, err := repos.Users.GetUserByID(ctx, user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = resets.ResetPassword(ctx, tt.token, tt.password)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+			case tt.wantKind != apperrors.KindInternal:
+				if apperrors.KindOf(err) != tt.wantKind {
+					t.Fatalf("err = %v, want a %v error", err, tt.wantKind)
+				}
+			case err != nil:
+				t.Fatal(err)
+			}
+
+			after, err := repos.Users.GetUserByID(ctx, user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			changed := after.Password != before.Password
+			if changed != (tt.wantErr == nil && tt.wantKind == apperrors.KindInternal) {
+				t.Fatalf("password changed = %v", changed)
+			}
+			if changed {
+				if err := bcrypt.CompareHashAndPassword([]byte(after.Password), []byte(tt.password)); err != nil {
+					t.Fatal("the stored hash doesn't match the new password")
+				}
+				if _, err := resets.ValidatePasswordResetToken(ctx, tt.token); !errors.Is(err, ErrInvalidResetToken) {
+					t.Fatal("the token can be used again")
+				}
+			}
+		})
+	}
+}
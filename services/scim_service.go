@@ -0,0 +1,1022 @@
+// services/scim_service.go
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories"
+	"github.com/ABDULS21985/test-portal/scim"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	scimDefaultCount = 100
+	scimMaxCount     = 200
+	scimBasePath     = "/scim/v2"
+)
+
+// SCIMQuery holds the list parameters of a SCIM search
+type SCIMQuery struct {
+	Filter         string
+	StartIndex     int
+	Count          *int // nil means the server default
+	ExcludeMembers bool
+}
+
+// SCIMService implements SCIM 2.0 user and group provisioning (RFC 7644) on top of the user and
+// role repositories. Every operation is scoped to the tenant that owns the bearer token, except
+// that userName (the user's email) is unique across all tenants: users sign in by email alone,
+// so a tenant can't provision an address another tenant already holds. Client errors are
+// returned as *scim.Error.
+type SCIMService interface {
+	CreateUser(ctx context.Context, tenant string, user *scim.User) (*scim.User, error)
+	GetUser(ctx context.Context, tenant, id string) (*scim.User, error)
+	ReplaceUser(ctx context.Context, tenant, id string, user *scim.User) (*scim.User, error)
+	PatchUser(ctx context.Context, tenant, id string, patch *scim.PatchRequest) (*scim.User, error)
+	DeleteUser(ctx context.Context, tenant, id string) error
+	ListUsers(ctx context.Context, tenant string, query SCIMQuery) (*scim.ListResponse, error)
+
+	CreateGroup(ctx context.Context, tenant string, group *scim.Group) (*scim.Group, error)
+	GetGroup(ctx context.Context, tenant, id string, excludeMembers bool) (*scim.Group, error)
+	ReplaceGroup(ctx context.Context, tenant, id string, group *scim.Group) (*scim.Group, error)
+	PatchGroup(ctx context.Context, tenant, id string, patch *scim.PatchRequest) (*scim.Group, error)
+	DeleteGroup(ctx context.Context, tenant, id string) error
+	ListGroups(ctx context.Context, tenant string, query SCIMQuery) (*scim.ListResponse, error)
+}
+
+type scimService struct {
+	userRepo    repositories.UserRepository
+	roleRepo    repositories.RoleRepository
+	sessionRepo repositories.SessionRepository
+	txManager   repositories.TxManager
+	defaultRole string
+}
+
+// NewSCIMService creates a new instance of scimService. Users removed from every group fall back
+// to defaultRole; deactivated and deleted users are signed out of every session.
+func NewSCIMService(userRepo repositories.UserRepository, roleRepo repositories.RoleRepository, sessionRepo repositories.SessionRepository, txManager repositories.TxManager, defaultRole string) SCIMService {
+	return &scimService{
+		userRepo:    userRepo,
+		roleRepo:    roleRepo,
+		sessionRepo: sessionRepo,
+		txManager:   txManager,
+		defaultRole: defaultRole,
+	}
+}
+
+// inTx runs fn against a copy of the service whose repositories share one transaction. Group
+// changes rewrite the role of every member, and must not be left half done.
+func (s *scimService) inTx(ctx context.Context, fn func(ctx context.Context, tx *scimService) error) error {
+	return s.txManager.WithinTransaction(ctx, func(ctx context.Context, repos repositories.Repositories) error {
+		return fn(ctx, &scimService{
+			userRepo:    repos.Users,
+			roleRepo:    repos.Roles,
+			sessionRepo: repos.Sessions,
+			txManager:   s.txManager,
+			defaultRole: s.defaultRole,
+		})
+	})
+}
+
+// ---- Users ----
+
+func (s *scimService) CreateUser(ctx context.Context, tenant string, in *scim.User) (_ *scim.User, err error) {
+	ctx, span := startSpan(ctx, "SCIMService.CreateUser", attribute.String("scim.tenant", tenant))
+	defer endSpan(span, &err)
+
+	email := primaryEmail(in)
+	if email == "" {
+		return nil, scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "userName or a primary email is required")
+	}
+	if _, err := s.userRepo.GetUserByEmail(ctx, email); err == nil {
+		return nil, scim.NewError(http.StatusConflict, scim.ErrUniqueness, "a user with this userName already exists")
+	}
+
+	password, err := scimPassword(in.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		Name:       in.FullName(),
+		Email:      email,
+		Password:   password,
+		Role:       s.defaultRole,
+		AuthSource: models.AuthSourceLocal,
+		ExternalID: in.ExternalID,
+		Tenant:     tenant,
+		Disabled:   in.Active != nil && !*in.Active,
+	}
+	if err := s.userRepo.CreateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return s.toSCIMUser(ctx, user), nil
+}
+
+func (s *scimService) GetUser(ctx context.Context, tenant, id string) (_ *scim.User, err error) {
+	ctx, span := startSpan(ctx, "SCIMService.GetUser", attribute.String("scim.tenant", tenant), attribute.String("scim.resource.id", id))
+	defer endSpan(span, &err)
+
+	user, err := s.findUser(ctx, tenant, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.toSCIMUser(ctx, user), nil
+}
+
+func (s *scimService) ReplaceUser(ctx context.Context, tenant, id string, in *scim.User) (_ *scim.User, err error) {
+	ctx, span := startSpan(ctx, "SCIMService.ReplaceUser", attribute.String("scim.tenant", tenant), attribute.String("scim.resource.id", id))
+	defer endSpan(span, &err)
+
+	user, err := s.findUser(ctx, tenant, id)
+	if err != nil {
+		return nil, err
+	}
+
+	email := primaryEmail(in)
+	if email == "" {
+		return nil, scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "userName or a primary email is required")
+	}
+	if err := s.ensureEmailAvailable(ctx, user, email); err != nil {
+		return nil, err
+	}
+
+	user.Email = email
+	user.Name = in.FullName()
+	user.ExternalID = in.ExternalID
+	user.Disabled = in.Active != nil && !*in.Active
+	if in.Password != "" {
+		if user.Password, err = scimPassword(in.Password); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.saveUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return s.toSCIMUser(ctx, user), nil
+}
+
+func (s *scimService) PatchUser(ctx context.Context, tenant, id string, patch *scim.PatchRequest) (_ *scim.User, err error) {
+	ctx, span := startSpan(ctx, "SCIMService.PatchUser", attribute.String("scim.tenant", tenant), attribute.String("scim.resource.id", id))
+	defer endSpan(span, &err)
+
+	user, err := s.findUser(ctx, tenant, id)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range patch.Operations {
+		if err := s.applyUserOperation(ctx, user, op); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.saveUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return s.toSCIMUser(ctx, user), nil
+}
+
+func (s *scimService) DeleteUser(ctx context.Context, tenant, id string) (err error) {
+	ctx, span := startSpan(ctx, "SCIMService.DeleteUser", attribute.String("scim.tenant", tenant), attribute.String("scim.resource.id", id))
+	defer endSpan(span, &err)
+
+	user, err := s.findUser(ctx, tenant, id)
+	if err != nil {
+		return err
+	}
+	if err := s.sessionRepo.RevokeUserSessions(ctx, user.ID, time.Now()); err != nil {
+		return err
+	}
+	return s.userRepo.DeleteUser(ctx, user.ID)
+}
+
+// saveUser writes back a replaced or patched user, signing a deactivated one out everywhere
+func (s *scimService) saveUser(ctx context.Context, user *models.User) error {
+	if err := s.userRepo.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+	if user.Disabled {
+		return s.sessionRepo.RevokeUserSessions(ctx, user.ID, time.Now())
+	}
+	return nil
+}
+
+func (s *scimService) ListUsers(ctx context.Context, tenant string, query SCIMQuery) (_ *scim.ListResponse, err error) {
+	ctx, span := startSpan(ctx, "SCIMService.ListUsers", attribute.String("scim.tenant", tenant))
+	defer endSpan(span, &err)
+
+	criteria, err := s.tenantCriteria(tenant, query.Filter, userFilterColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	startIndex, count := normalizePaging(query)
+	users, total, err := s.userRepo.FindUsers(ctx, criteria, repositories.ListOptions{Offset: startIndex - 1, Limit: max(count, 1)})
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]*scim.User, 0, len(users))
+	for i := range users {
+		if count == 0 {
+			break
+		}
+		resources = append(resources, s.toSCIMUser(ctx, &users[i]))
+	}
+	return listResponse(total, startIndex, len(resources), resources), nil
+}
+
+func (s *scimService) findUser(ctx context.Context, tenant, id string) (*models.User, error) {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, scimNotFound("User", id)
+	}
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil || user.Tenant != tenant {
+		return nil, scimNotFound("User", id)
+	}
+	return user, nil
+}
+
+func (s *scimService) ensureEmailAvailable(ctx context.Context, user *models.User, email string) error {
+	if strings.EqualFold(user.Email, email) {
+		return nil
+	}
+	if _, err := s.userRepo.GetUserByEmail(ctx, email); err == nil {
+		return scim.NewError(http.StatusConflict, scim.ErrUniqueness, "a user with this userName already exists")
+	}
+	return nil
+}
+
+func (s *scimService) applyUserOperation(ctx context.Context, user *models.User, op scim.PatchOperation) error {
+	kind := strings.ToLower(op.Op)
+	if kind != "add" && kind != "replace" && kind != "remove" {
+		return scim.NewError(http.StatusBadRequest, scim.ErrInvalidSyntax, fmt.Sprintf("unsupported patch op %q", op.Op))
+	}
+
+	// Without a path the value is an object of attribute -> value pairs
+	if op.Path == "" {
+		if kind == "remove" {
+			return scim.NewError(http.StatusBadRequest, scim.ErrNoTarget, "remove requires a path")
+		}
+		var attrs map[string]json.RawMessage
+		if err := json.Unmarshal(op.Value, &attrs); err != nil {
+			return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "patch value must be an object when no path is given")
+		}
+		for attr, value := range attrs {
+			if err := s.applyUserAttribute(ctx, user, kind, attr, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return s.applyUserAttribute(ctx, user, kind, op.Path, op.Value)
+}
+
+func (s *scimService) applyUserAttribute(ctx context.Context, user *models.User, kind, path string, value json.RawMessage) error {
+	attr := strings.ToLower(path)
+	if strings.HasPrefix(attr, "urn:") {
+		attr = attr[strings.LastIndex(attr, ":")+1:]
+	}
+	// emails[type eq "work"].value and friends all address the single email we store
+	if strings.HasPrefix(attr, "emails[") {
+		attr = "emails.value"
+	}
+
+	if kind == "remove" {
+		switch attr {
+		case "externalid":
+			user.ExternalID = ""
+			return nil
+		case "name", "name.givenname", "name.familyname", "name.formatted", "displayname":
+			return nil
+		}
+		return scim.NewError(http.StatusBadRequest, scim.ErrMutability, fmt.Sprintf("%s cannot be removed", path))
+	}
+
+	switch attr {
+	case "active":
+		active, err := patchBool(value)
+		if err != nil {
+			return err
+		}
+		user.Disabled = !active
+	case "username", "emails.value":
+		email, err := patchString(value)
+		if err != nil {
+			return err
+		}
+		if err := s.ensureEmailAvailable(ctx, user, email); err != nil {
+			return err
+		}
+		user.Email = email
+	case "emails":
+		var emails []scim.MultiValued
+		if err := json.Unmarshal(value, &emails); err != nil || len(emails) == 0 {
+			return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "emails must be a non-empty list")
+		}
+		email := emails[0].Value
+		for _, e := range emails {
+			if e.Primary {
+				email = e.Value
+			}
+		}
+		if err := s.ensureEmailAvailable(ctx, user, email); err != nil {
+			return err
+		}
+		user.Email = email
+	case "displayname", "name.formatted":
+		name, err := patchString(value)
+		if err != nil {
+			return err
+		}
+		user.Name = name
+	case "name":
+		var name scim.Name
+		if err := json.Unmarshal(value, &name); err != nil {
+			return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "name must be an object")
+		}
+		if full := (&scim.User{Name: &name}).FullName(); full != "" {
+			user.Name = full
+		}
+	case "name.givenname", "name.familyname":
+		part, err := patchString(value)
+		if err != nil {
+			return err
+		}
+		given, family, _ := strings.Cut(user.Name, " ")
+		if attr == "name.givenname" {
+			given = part
+		} else {
+			family = part
+		}
+		user.Name = strings.TrimSpace(given + " " + family)
+	case "externalid":
+		externalID, err := patchString(value)
+		if err != nil {
+			return err
+		}
+		user.ExternalID = externalID
+	case "password":
+		password, err := patchString(value)
+		if err != nil {
+			return err
+		}
+		if user.Password, err = scimPassword(password); err != nil {
+			return err
+		}
+	default:
+		return scim.NewError(http.StatusBadRequest, scim.ErrInvalidPath, fmt.Sprintf("unsupported attribute %q", path))
+	}
+	return nil
+}
+
+func (s *scimService) toSCIMUser(ctx context.Context, user *models.User) *scim.User {
+	active := !user.Disabled
+	out := &scim.User{
+		Schemas:     []string{scim.SchemaUser},
+		ID:          user.ID.String(),
+		ExternalID:  user.ExternalID,
+		UserName:    user.Email,
+		Name:        &scim.Name{Formatted: user.Name},
+		DisplayName: user.Name,
+		Emails:      []scim.MultiValued{{Value: user.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Meta:        scimMeta("User", user.ID, user.CreatedAt, user.UpdatedAt),
+	}
+
+	if user.Role != "" {
+		if role, err := s.roleRepo.GetRoleByName(ctx, user.Tenant, user.Role); err == nil {
+			out.Groups = []scim.MultiValued{{
+				Value:   role.ID.String(),
+				Display: role.Name,
+				Ref:     scimBasePath + "/Groups/" + role.ID.String(),
+			}}
+		}
+	}
+	return out
+}
+
+// ---- Groups ----
+
+func (s *scimService) CreateGroup(ctx context.Context, tenant string, in *scim.Group) (_ *scim.Group, err error) {
+	ctx, span := startSpan(ctx, "SCIMService.CreateGroup", attribute.String("scim.tenant", tenant))
+	defer endSpan(span, &err)
+
+	var group *scim.Group
+	err = s.inTx(ctx, func(ctx context.Context, tx *scimService) (err error) {
+		group, err = tx.createGroup(ctx, tenant, in)
+		return err
+	})
+	return group, err
+}
+
+func (s *scimService) createGroup(ctx context.Context, tenant string, in *scim.Group) (*scim.Group, error) {
+	if in.DisplayName == "" {
+		return nil, scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "displayName is required")
+	}
+	if err := checkGroupName(in.DisplayName); err != nil {
+		return nil, err
+	}
+	if _, err := s.roleRepo.GetRoleByName(ctx, tenant, in.DisplayName); err == nil {
+		return nil, scim.NewError(http.StatusConflict, scim.ErrUniqueness, "a group with this displayName already exists")
+	}
+
+	role := &models.Role{
+		Name:       in.DisplayName,
+		Tenant:     tenant,
+		ExternalID: in.ExternalID,
+	}
+	if err := s.roleRepo.CreateRole(ctx, role); err != nil {
+		return nil, err
+	}
+
+	if err := s.setMembers(ctx, role, memberIDs(in.Members)); err != nil {
+		return nil, err
+	}
+	return s.toSCIMGroup(ctx, role, false)
+}
+
+func (s *scimService) GetGroup(ctx context.Context, tenant, id string, excludeMembers bool) (_ *scim.Group, err error) {
+	ctx, span := startSpan(ctx, "SCIMService.GetGroup", attribute.String("scim.tenant", tenant), attribute.String("scim.resource.id", id))
+	defer endSpan(span, &err)
+
+	role, err := s.findRole(ctx, tenant, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.toSCIMGroup(ctx, role, excludeMembers)
+}
+
+func (s *scimService) ReplaceGroup(ctx context.Context, tenant, id string, in *scim.Group) (_ *scim.Group, err error) {
+	ctx, span := startSpan(ctx, "SCIMService.ReplaceGroup", attribute.String("scim.tenant", tenant), attribute.String("scim.resource.id", id))
+	defer endSpan(span, &err)
+
+	var group *scim.Group
+	err = s.inTx(ctx, func(ctx context.Context, tx *scimService) (err error) {
+		group, err = tx.replaceGroup(ctx, tenant, id, in)
+		return err
+	})
+	return group, err
+}
+
+func (s *scimService) replaceGroup(ctx context.Context, tenant, id string, in *scim.Group) (*scim.Group, error) {
+	role, err := s.findRole(ctx, tenant, id)
+	if err != nil {
+		return nil, err
+	}
+	if in.DisplayName == "" {
+		return nil, scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "displayName is required")
+	}
+
+	if err := s.renameRole(ctx, role, in.DisplayName); err != nil {
+		return nil, err
+	}
+	role.ExternalID = in.ExternalID
+	if err := s.roleRepo.UpdateRole(ctx, role); err != nil {
+		return nil, err
+	}
+
+	if err := s.setMembers(ctx, role, memberIDs(in.Members)); err != nil {
+		return nil, err
+	}
+	return s.toSCIMGroup(ctx, role, false)
+}
+
+func (s *scimService) PatchGroup(ctx context.Context, tenant, id string, patch *scim.PatchRequest) (_ *scim.Group, err error) {
+	ctx, span := startSpan(ctx, "SCIMService.PatchGroup", attribute.String("scim.tenant", tenant), attribute.String("scim.resource.id", id))
+	defer endSpan(span, &err)
+
+	var group *scim.Group
+	err = s.inTx(ctx, func(ctx context.Context, tx *scimService) (err error) {
+		group, err = tx.patchGroup(ctx, tenant, id, patch)
+		return err
+	})
+	return group, err
+}
+
+func (s *scimService) patchGroup(ctx context.Context, tenant, id string, patch *scim.PatchRequest) (*scim.Group, error) {
+	role, err := s.findRole(ctx, tenant, id)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range patch.Operations {
+		if err := s.applyGroupOperation(ctx, role, op); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.roleRepo.UpdateRole(ctx, role); err != nil {
+		return nil, err
+	}
+	return s.toSCIMGroup(ctx, role, false)
+}
+
+func (s *scimService) DeleteGroup(ctx context.Context, tenant, id string) (err error) {
+	ctx, span := startSpan(ctx, "SCIMService.DeleteGroup", attribute.String("scim.tenant", tenant), attribute.String("scim.resource.id", id))
+	defer endSpan(span, &err)
+	return s.inTx(ctx, func(ctx context.Context, tx *scimService) error {
+		return tx.deleteGroup(ctx, tenant, id)
+	})
+}
+
+func (s *scimService) deleteGroup(ctx context.Context, tenant, id string) error {
+	role, err := s.findRole(ctx, tenant, id)
+	if err != nil {
+		return err
+	}
+	if err := s.setMembers(ctx, role, nil); err != nil {
+		return err
+	}
+	return s.roleRepo.DeleteRole(ctx, role.ID)
+}
+
+func (s *scimService) ListGroups(ctx context.Context, tenant string, query SCIMQuery) (_ *scim.ListResponse, err error) {
+	ctx, span := startSpan(ctx, "SCIMService.ListGroups", attribute.String("scim.tenant", tenant))
+	defer endSpan(span, &err)
+
+	criteria, err := s.tenantCriteria(tenant, query.Filter, groupFilterColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	startIndex, count := normalizePaging(query)
+	roles, total, err := s.roleRepo.FindRoles(ctx, criteria, repositories.ListOptions{Offset: startIndex - 1, Limit: max(count, 1)})
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]*scim.Group, 0, len(roles))
+	for i := range roles {
+		if count == 0 {
+			break
+		}
+		group, err := s.toSCIMGroup(ctx, &roles[i], query.ExcludeMembers)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, group)
+	}
+	return listResponse(total, startIndex, len(resources), resources), nil
+}
+
+func (s *scimService) findRole(ctx context.Context, tenant, id string) (*models.Role, error) {
+	roleID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, scimNotFound("Group", id)
+	}
+	role, err := s.roleRepo.GetRoleByID(ctx, roleID)
+	if err != nil || role.Tenant != tenant {
+		return nil, scimNotFound("Group", id)
+	}
+	return role, nil
+}
+
+func (s *scimService) applyGroupOperation(ctx context.Context, role *models.Role, op scim.PatchOperation) error {
+	kind := strings.ToLower(op.Op)
+	path := strings.ToLower(op.Path)
+
+	if path == "" {
+		if kind == "remove" {
+			return scim.NewError(http.StatusBadRequest, scim.ErrNoTarget, "remove requires a path")
+		}
+		var attrs map[string]json.RawMessage
+		if err := json.Unmarshal(op.Value, &attrs); err != nil {
+			return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "patch value must be an object when no path is given")
+		}
+		for attr, value := range attrs {
+			if err := s.applyGroupOperation(ctx, role, scim.PatchOperation{Op: op.Op, Path: attr, Value: value}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch {
+	case path == "displayname":
+		name, err := patchString(op.Value)
+		if err != nil {
+			return err
+		}
+		return s.renameRole(ctx, role, name)
+	case path == "externalid":
+		if kind == "remove" {
+			role.ExternalID = ""
+			return nil
+		}
+		externalID, err := patchString(op.Value)
+		if err != nil {
+			return err
+		}
+		role.ExternalID = externalID
+		return nil
+	case path == "members":
+		var members []scim.MultiValued
+		if len(op.Value) > 0 {
+			if err := json.Unmarshal(op.Value, &members); err != nil {
+				return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "members must be a list")
+			}
+		}
+		switch kind {
+		case "add":
+			return s.addMembers(ctx, role, memberIDs(members))
+		case "replace":
+			return s.setMembers(ctx, role, memberIDs(members))
+		case "remove":
+			if len(members) == 0 {
+				return s.setMembers(ctx, role, nil)
+			}
+			return s.removeMembers(ctx, role, memberIDs(members))
+		}
+	case strings.HasPrefix(path, "members[") && kind == "remove":
+		// members[value eq "2819c223-7f76-453a-919d-413861904646"]
+		filter, err := scim.ParseFilter(op.Path)
+		if err != nil || filter.Attr != "members.value" || filter.Op != "eq" {
+			return scim.NewError(http.StatusBadRequest, scim.ErrInvalidPath, "only members[value eq \"id\"] is supported")
+		}
+		value, _ := filter.Value.(string)
+		return s.removeMembers(ctx, role, []string{value})
+	}
+
+	return scim.NewError(http.StatusBadRequest, scim.ErrInvalidPath, fmt.Sprintf("unsupported patch %s on %q", op.Op, op.Path))
+}
+
+// renameRole changes a role's name and moves every member over to the new name
+func (s *scimService) renameRole(ctx context.Context, role *models.Role, name string) error {
+	if name == "" || name == role.Name {
+		return nil
+	}
+	if err := checkGroupName(name); err != nil {
+		return err
+	}
+	if _, err := s.roleRepo.GetRoleByName(ctx, role.Tenant, name); err == nil {
+		return scim.NewError(http.StatusConflict, scim.ErrUniqueness, "a group with this displayName already exists")
+	}
+
+	members, err := s.members(ctx, role)
+	if err != nil {
+		return err
+	}
+	for i := range members {
+		members[i].Role = name
+		if err := s.userRepo.UpdateUser(ctx, &members[i]); err != nil {
+			return err
+		}
+	}
+	role.Name = name
+	return nil
+}
+
+func (s *scimService) members(ctx context.Context, role *models.Role) ([]models.User, error) {
+	users, _, err := s.userRepo.FindUsers(ctx, &repositories.Criteria{And: []repositories.Criteria{
+		{Field: "tenant", Op: "eq", Value: role.Tenant},
+		{Field: "role", Op: "eq", Value: role.Name},
+	}}, repositories.ListOptions{})
+	return users, err
+}
+
+// setMembers makes ids the exact member list of role; users dropped from it fall back to the default role
+func (s *scimService) setMembers(ctx context.Context, role *models.Role, ids []string) error {
+	keep := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		keep[id] = true
+	}
+
+	current, err := s.members(ctx, role)
+	if err != nil {
+		return err
+	}
+	var removed []string
+	for _, user := range current {
+		if !keep[user.ID.String()] {
+			removed = append(removed, user.ID.String())
+		}
+	}
+
+	if err := s.removeMembers(ctx, role, removed); err != nil {
+		return err
+	}
+	return s.addMembers(ctx, role, ids)
+}
+
+// addMembers assigns role to each user. A user holds a single role, so joining a group implicitly
+// leaves the previous one.
+func (s *scimService) addMembers(ctx context.Context, role *models.Role, ids []string) error {
+	if err := checkGroupName(role.Name); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		user, err := s.findUser(ctx, role.Tenant, id)
+		if err != nil {
+			return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, fmt.Sprintf("member %s does not exist", id))
+		}
+		if user.Role == role.Name {
+			continue
+		}
+		user.Role = role.Name
+		if err := s.userRepo.UpdateUser(ctx, user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkGroupName refuses group names the portal gives privileges to. A group's name becomes its
+// members' role, which is global, so a tenant's token must never be able to hand out "admin".
+func checkGroupName(name string) error {
+	if strings.EqualFold(strings.TrimSpace(name), models.RoleAdmin) {
+		return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, fmt.Sprintf("%q is a reserved group name", name))
+	}
+	return nil
+}
+
+func (s *scimService) removeMembers(ctx context.Context, role *models.Role, ids []string) error {
+	for _, id := range ids {
+		user, err := s.findUser(ctx, role.Tenant, id)
+		if err != nil || user.Role != role.Name {
+			continue
+		}
+		user.Role = s.defaultRole
+		if err := s.userRepo.UpdateUser(ctx, user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *scimService) toSCIMGroup(ctx context.Context, role *models.Role, excludeMembers bool) (*scim.Group, error) {
+	out := &scim.Group{
+		Schemas:     []string{scim.SchemaGroup},
+		ID:          role.ID.String(),
+		ExternalID:  role.ExternalID,
+		DisplayName: role.Name,
+		Meta:        scimMeta("Group", role.ID, role.CreatedAt, role.UpdatedAt),
+	}
+	if excludeMembers {
+		return out, nil
+	}
+
+	members, err := s.members(ctx, role)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range members {
+		out.Members = append(out.Members, scim.MultiValued{
+			Value:   user.ID.String(),
+			Display: user.Name,
+			Ref:     scimBasePath + "/Users/" + user.ID.String(),
+		})
+	}
+	return out, nil
+}
+
+// ---- Filtering ----
+
+// userFilterColumns maps SCIM user attributes onto users table columns
+var userFilterColumns = map[string]string{
+	"id":                "id",
+	"username":          "email",
+	"emails":            "email",
+	"emails.value":      "email",
+	"externalid":        "external_id",
+	"displayname":       "name",
+	"name.formatted":    "name",
+	"active":            "disabled",
+	"meta.created":      "created_at",
+	"meta.lastmodified": "updated_at",
+}
+
+// groupFilterColumns maps SCIM group attributes onto roles table columns
+var groupFilterColumns = map[string]string{
+	"id":                "id",
+	"displayname":       "name",
+	"externalid":        "external_id",
+	"meta.created":      "created_at",
+	"meta.lastmodified": "updated_at",
+}
+
+func (s *scimService) tenantCriteria(tenant, filter string, columns map[string]string) (*repositories.Criteria, error) {
+	criteria := &repositories.Criteria{And: []repositories.Criteria{{Field: "tenant", Op: "eq", Value: tenant}}}
+	if filter == "" {
+		return criteria, nil
+	}
+
+	parsed, err := scim.ParseFilter(filter)
+	if err != nil {
+		return nil, scim.NewError(http.StatusBadRequest, scim.ErrInvalidFilter, err.Error())
+	}
+	converted, err := filterToCriteria(parsed, columns)
+	if err != nil {
+		return nil, scim.NewError(http.StatusBadRequest, scim.ErrInvalidFilter, err.Error())
+	}
+	if converted != nil {
+		criteria.And = append(criteria.And, *converted)
+	}
+	return criteria, nil
+}
+
+// filterToCriteria converts a parsed SCIM filter into repository criteria. A nil result means the
+// expression matches everything (e.g. emails.type, which we don't store).
+func filterToCriteria(f *scim.Filter, columns map[string]string) (*repositories.Criteria, error) {
+	switch {
+	case len(f.And) > 0:
+		out := &repositories.Criteria{}
+		for _, child := range f.And {
+			c, err := filterToCriteria(child, columns)
+			if err != nil {
+				return nil, err
+			}
+			if c != nil {
+				out.And = append(out.And, *c)
+			}
+		}
+		if len(out.And) == 0 {
+			return nil, nil
+		}
+		return out, nil
+	case len(f.Or) > 0:
+		out := &repositories.Criteria{}
+		for _, child := range f.Or {
+			c, err := filterToCriteria(child, columns)
+			if err != nil {
+				return nil, err
+			}
+			if c == nil {
+				return nil, nil
+			}
+			out.Or = append(out.Or, *c)
+		}
+		return out, nil
+	case f.Not != nil:
+		c, err := filterToCriteria(f.Not, columns)
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			return nil, fmt.Errorf("not() over an unsupported attribute")
+		}
+		return &repositories.Criteria{Not: c}, nil
+	}
+
+	if f.Attr == "emails.type" || f.Attr == "emails.primary" {
+		return nil, nil
+	}
+
+	column, ok := columns[f.Attr]
+	if !ok {
+		return nil, fmt.Errorf("filtering on %q is not supported", f.Attr)
+	}
+
+	// active is stored inverted as users.disabled
+	if column == "disabled" {
+		if f.Op == "pr" {
+			return nil, nil
+		}
+		active, ok := f.Value.(bool)
+		if !ok || (f.Op != "eq" && f.Op != "ne") {
+			return nil, fmt.Errorf("active only supports eq/ne with a boolean")
+		}
+		if f.Op == "ne" {
+			active = !active
+		}
+		return &repositories.Criteria{Field: column, Op: "eq", Value: !active}, nil
+	}
+
+	value := f.Value
+	if strings.HasPrefix(f.Attr, "meta.") {
+		raw, ok := f.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be compared with a date-time string", f.Attr)
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date-time %q", raw)
+		}
+		value = t
+	}
+
+	return &repositories.Criteria{Field: column, Op: f.Op, Value: value}, nil
+}
+
+// ---- Helpers ----
+
+func normalizePaging(query SCIMQuery) (int, int) {
+	startIndex := query.StartIndex
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	count := scimDefaultCount
+	if query.Count != nil {
+		count = *query.Count
+	}
+	if count < 0 {
+		count = 0
+	}
+	if count > scimMaxCount {
+		count = scimMaxCount
+	}
+	return startIndex, count
+}
+
+func listResponse(total int64, startIndex, itemsPerPage int, resources interface{}) *scim.ListResponse {
+	return &scim.ListResponse{
+		Schemas:      []string{scim.SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: itemsPerPage,
+		Resources:    resources,
+	}
+}
+
+func scimMeta(resourceType string, id uuid.UUID, created, updated time.Time) *scim.Meta {
+	return &scim.Meta{
+		ResourceType: resourceType,
+		Created:      created,
+		LastModified: updated,
+		Location:     scimBasePath + "/" + resourceType + "s/" + id.String(),
+		Version:      `W/"` + strconv.FormatInt(updated.UnixNano(), 10) + `"`,
+	}
+}
+
+func scimNotFound(resourceType, id string) error {
+	return scim.NewError(http.StatusNotFound, "", fmt.Sprintf("%s %s not found", resourceType, id))
+}
+
+func primaryEmail(user *scim.User) string {
+	for _, e := range user.Emails {
+		if e.Primary && e.Value != "" {
+			return e.Value
+		}
+	}
+	if strings.Contains(user.UserName, "@") {
+		return user.UserName
+	}
+	if len(user.Emails) > 0 {
+		return user.Emails[0].Value
+	}
+	return ""
+}
+
+// scimPassword hashes a provisioned password, or returns an unusable placeholder so the user has
+// to go through password reset before their first login
+func scimPassword(password string) (string, error) {
+	if password == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		return "!scim:" + hex.EncodeToString(b), nil
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+func patchString(value json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return "", scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "expected a string value")
+	}
+	return s, nil
+}
+
+// patchBool accepts JSON booleans as well as the "True"/"False" strings some identity providers send
+func patchBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		if parsed, err := strconv.ParseBool(s); err == nil {
+			return parsed, nil
+		}
+	}
+	return false, scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "expected a boolean value")
+}
+
+func memberIDs(members []scim.MultiValued) []string {
+	ids := make([]string, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, m.Value)
+	}
+	return ids
+}
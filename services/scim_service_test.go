@@ -0,0 +1,471 @@
+// services/scim_service_test.go
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories"
+	"github.com/ABDULS21985/test-portal/repositories/memory"
+	"github.com/ABDULS21985/test-portal/scim"
+)
+
+// scimFixture is a SCIMService over in-memory repositories
+type scimFixture struct {
+	repos repositories.Repositories
+	scim  SCIMService
+}
+
+func newSCIMFixture(t *testing.T) *scimFixture {
+	t.Helper()
+	store := memory.NewStore()
+	repos := store.Repositories()
+	return &scimFixture{
+		repos: repos,
+		scim:  NewSCIMService(repos.Users, repos.Roles, repos.Sessions, memory.NewTxManager(store), models.RoleUser),
+	}
+}
+
+// createUser provisions a user in tenant
+func (f *scimFixture) createUser(t *testing.T, tenant, userName string) *scim.User {
+	t.Helper()
+	user, err := f.scim.CreateUser(context.Background(), tenant, &scim.User{UserName: userName, DisplayName: userName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// wantSCIMError fails the test unless err is a SCIM error with the given status
+func wantSCIMError(t *testing.T, err error, status int) {
+	t.Helper()
+	var scimErr *scim.Error
+	if !errors.As(err, &scimErr) || scimErr.HTTPStatus != status {
+		t.Fatalf("err = %v, want a SCIM %d error", err, status)
+	}
+}
+
+func TestFilterToCriteria(t *testing.T) {
+	tenant := repositories.Criteria{Field: "tenant", Op: "eq", Value: "acme"}
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		filter  string
+		want    []repositories.Criteria // after the tenant criterion
+		wantErr bool
+	}{
+		{name: "no filter"},
+		{name: "userName", filter: `userName eq "ada@example.com"`, want: []repositories.Criteria{{Field: "email", Op: "eq", Value: "ada@example.com"}}},
+		{name: "email value path", filter: `emails[type eq "work" and value co "@example.com"]`, want: []repositories.Criteria{{And: []repositories.Criteria{{Field: "email", Op: "co", Value: "@example.com"}}}}},
+		{name: "only unstored attributes", filter: `emails[type eq "work"]`},
+		{name: "active is stored inverted", filter: `active eq true`, want: []repositories.Criteria{{Field: "disabled", Op: "eq", Value: false}}},
+		{name: "active ne", filter: `active ne true`, want: []repositories.Criteria{{Field: "disabled", Op: "eq", Value: true}}},
+		{name: "meta dates", filter: `meta.created gt "2026-01-02T03:04:05Z"`, want: []repositories.Criteria{{Field: "created_at", Op: "gt", Value: created}}},
+		{
+			name:   "or and not",
+			filter: `externalId eq "e1" or not (displayName sw "A")`,
+			want: []repositories.Criteria{{Or: []repositories.Criteria{
+				{Field: "external_id", Op: "eq", Value: "e1"},
+				{Not: &repositories.Criteria{Field: "name", Op: "sw", Value: "A"}},
+			}}},
+		},
+		{name: "or with an unstored side matches everything", filter: `userName eq "a" or emails[type eq "work"]`},
+		{name: "unknown attribute", filter: `title eq "Boss"`, wantErr: true},
+		{name: "tenant is not filterable", filter: `tenant eq "other"`, wantErr: true},
+		{name: "active with a string", filter: `active eq "yes"`, wantErr: true},
+		{name: "active with co", filter: `active co true`, wantErr: true},
+		{name: "bad date", filter: `meta.created gt "yesterday"`, wantErr: true},
+		{name: "not over an unstored attribute", filter: `not (emails[type eq "work"])`, wantErr: true},
+		{name: "syntax error", filter: `userName eq`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &scimService{}
+			criteria, err := s.tenantCriteria("acme", tt.filter, userFilterColumns)
+			if tt.wantErr {
+				var scimErr *scim.Error
+				if !errors.As(err, &scimErr) || scimErr.ScimType != scim.ErrInvalidFilter {
+					t.Fatalf("err = %v, want an invalidFilter error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := &repositories.Criteria{And: append([]repositories.Criteria{tenant}, tt.want...)}
+			if !reflect.DeepEqual(criteria, want) {
+				t.Fatalf("got  %+v\nwant %+v", criteria, want)
+			}
+		})
+	}
+}
+
+func TestSCIMListUsers(t *testing.T) {
+	f := newSCIMFixture(t)
+	for _, name := range []string{"a@acme.example", "b@acme.example", "c@acme.example"} {
+		f.createUser(t, "acme", name)
+	}
+	f.createUser(t, "other", "d@other.example")
+
+	count := func(n int) *int { return &n }
+	tests := []struct {
+		name         string
+		query        SCIMQuery
+		wantTotal    int64
+		wantStart    int
+		wantUserName []string
+	}{
+		{name: "only the tenant's users", wantTotal: 3, wantStart: 1, wantUserName: []string{"a@acme.example", "b@acme.example", "c@acme.example"}},
+		{name: "second page", query: SCIMQuery{StartIndex: 2, Count: count(1)}, wantTotal: 3, wantStart: 2, wantUserName: []string{"b@acme.example"}},
+		{name: "past the end", query: SCIMQuery{StartIndex: 10}, wantTotal: 3, wantStart: 10},
+		{name: "count 0 only totals", query: SCIMQuery{Count: count(0)}, wantTotal: 3, wantStart: 1},
+		{name: "start index below 1", query: SCIMQuery{StartIndex: -5, Count: count(1)}, wantTotal: 3, wantStart: 1, wantUserName: []string{"a@acme.example"}},
+		{name: "filter", query: SCIMQuery{Filter: `userName sw "c"`}, wantTotal: 1, wantStart: 1, wantUserName: []string{"c@acme.example"}},
+		{name: "filter can't reach another tenant", query: SCIMQuery{Filter: `userName eq "d@other.example"`}, wantTotal: 0, wantStart: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := f.scim.ListUsers(context.Background(), "acme", tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			users := list.Resources.([]*scim.User)
+			var names []string
+			for _, user := range users {
+				names = append(names, user.UserName)
+			}
+			if list.TotalResults != tt.wantTotal || list.StartIndex != tt.wantStart || list.ItemsPerPage != len(users) {
+				t.Fatalf("total %d, start %d, %d per page; want %d, %d", list.TotalResults, list.StartIndex, list.ItemsPerPage, tt.wantTotal, tt.wantStart)
+			}
+			if !reflect.DeepEqual(names, tt.wantUserName) {
+				t.Fatalf("users = %v, want %v", names, tt.wantUserName)
+			}
+		})
+	}
+
+	if _, err := f.scim.ListUsers(context.Background(), "acme", SCIMQuery{Filter: `title pr`}); err == nil {
+		t.Fatal("an unsupported filter was accepted")
+	}
+}
+
+func TestNormalizePaging(t *testing.T) {
+	count := func(n int) *int { return &n }
+	tests := []struct {
+		query     SCIMQuery
+		wantStart int
+		wantCount int
+	}{
+		{query: SCIMQuery{}, wantStart: 1, wantCount: scimDefaultCount},
+		{query: SCIMQuery{StartIndex: 0, Count: count(-1)}, wantStart: 1, wantCount: 0},
+		{query: SCIMQuery{StartIndex: 7, Count: count(10)}, wantStart: 7, wantCount: 10},
+		{query: SCIMQuery{Count: count(scimMaxCount + 1)}, wantStart: 1, wantCount: scimMaxCount},
+	}
+	for _, tt := range tests {
+		if start, count := normalizePaging(tt.query); start != tt.wantStart || count != tt.wantCount {
+			t.Errorf("normalizePaging(%+v) = %d, %d; want %d, %d", tt.query, start, count, tt.wantStart, tt.wantCount)
+		}
+	}
+}
+
+func TestSCIMPatchUser(t *testing.T) {
+	tests := []struct {
+		name       string
+		ops        []scim.PatchOperation
+		wantStatus int // 0 for success
+		check      func(t *testing.T, user *models.User)
+	}{
+		{
+			name: "replace active",
+			ops:  []scim.PatchOperation{{Op: "replace", Path: "active", Value: json.RawMessage(`false`)}},
+			check: func(t *testing.T, user *models.User) {
+				if !user.Disabled {
+					t.Error("the user is still active")
+				}
+			},
+		},
+		{
+			name: "active as a string",
+			ops:  []scim.PatchOperation{{Op: "Replace", Path: "active", Value: json.RawMessage(`"False"`)}},
+			check: func(t *testing.T, user *models.User) {
+				if !user.Disabled {
+					t.Error("the user is still active")
+				}
+			},
+		},
+		{
+			name: "add without a path",
+			ops:  []scim.PatchOperation{{Op: "add", Value: json.RawMessage(`{"displayName":"Ada Lovelace","externalId":"e-42"}`)}},
+			check: func(t *testing.T, user *models.User) {
+				if user.Name != "Ada Lovelace" || user.ExternalID != "e-42" {
+					t.Errorf("name %q, external ID %q", user.Name, user.ExternalID)
+				}
+			},
+		},
+		{
+			name: "replace name parts",
+			ops: []scim.PatchOperation{
+				{Op: "replace", Path: "name.givenName", Value: json.RawMessage(`"Augusta"`)},
+				{Op: "replace", Path: "name.familyName", Value: json.RawMessage(`"King"`)},
+			},
+			check: func(t *testing.T, user *models.User) {
+				if user.Name != "Augusta King" {
+					t.Errorf("name = %q", user.Name)
+				}
+			},
+		},
+		{
+			name: "replace the primary email",
+			ops:  []scim.PatchOperation{{Op: "replace", Path: `emails[type eq "work"].value`, Value: json.RawMessage(`"ada@new.example"`)}},
+			check: func(t *testing.T, user *models.User) {
+				if user.Email != "ada@new.example" {
+					t.Errorf("email = %q", user.Email)
+				}
+			},
+		},
+		{
+			name: "remove externalId",
+			ops: []scim.PatchOperation{
+				{Op: "add", Path: "externalId", Value: json.RawMessage(`"e-42"`)},
+				{Op: "remove", Path: "urn:ietf:params:scim:schemas:core:2.0:User:externalId"},
+			},
+			check: func(t *testing.T, user *models.User) {
+				if user.ExternalID != "" {
+					t.Errorf("external ID = %q", user.ExternalID)
+				}
+			},
+		},
+		{name: "remove userName", ops: []scim.PatchOperation{{Op: "remove", Path: "userName"}}, wantStatus: http.StatusBadRequest},
+		{name: "remove without a path", ops: []scim.PatchOperation{{Op: "remove"}}, wantStatus: http.StatusBadRequest},
+		{name: "unknown op", ops: []scim.PatchOperation{{Op: "move", Path: "active"}}, wantStatus: http.StatusBadRequest},
+		{name: "unknown attribute", ops: []scim.PatchOperation{{Op: "replace", Path: "title", Value: json.RawMessage(`"Boss"`)}}, wantStatus: http.StatusBadRequest},
+		{name: "wrong type", ops: []scim.PatchOperation{{Op: "replace", Path: "active", Value: json.RawMessage(`"maybe"`)}}, wantStatus: http.StatusBadRequest},
+		{name: "email taken", ops: []scim.PatchOperation{{Op: "replace", Path: "userName", Value: json.RawMessage(`"grace@acme.example"`)}}, wantStatus: http.StatusConflict},
+		{
+			name: "role can't be patched",
+			ops:  []scim.PatchOperation{{Op: "replace", Path: "role", Value: json.RawMessage(`"admin"`)}},
+			// The role only changes through group membership
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newSCIMFixture(t)
+			ada := f.createUser(t, "acme", "ada@acme.example")
+			f.createUser(t, "acme", "grace@acme.example")
+
+			_, err := f.scim.PatchUser(ctx, "acme", ada.ID, &scim.PatchRequest{Operations: tt.ops})
+			if tt.wantStatus != 0 {
+				wantSCIMError(t, err, tt.wantStatus)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			user, err := f.repos.Users.GetUserByEmail(ctx, ada.UserName)
+			if err != nil {
+				user, err = f.repos.Users.GetUserByEmail(ctx, "ada@new.example")
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, user)
+		})
+	}
+}
+
+func TestSCIMDeactivationRevokesSessions(t *testing.T) {
+	ctx := context.Background()
+	f := newSCIMFixture(t)
+	ada := f.createUser(t, "acme", "ada@acme.example")
+	user, err := f.repos.Users.GetUserByEmail(ctx, ada.UserName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := &models.Session{UserID: user.ID, LastSeenAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}
+	if err := f.repos.Sessions.CreateSession(ctx, session); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.scim.PatchUser(ctx, "acme", ada.ID, &scim.PatchRequest{Operations: []scim.PatchOperation{{Op: "replace", Path: "active", Value: json.RawMessage(`false`)}}}); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := f.repos.Sessions.GetSession(ctx, session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.RevokedAt == nil {
+		t.Fatal("the deactivated user's session is still active")
+	}
+}
+
+func TestSCIMTenantIsolation(t *testing.T) {
+	ctx := context.Background()
+	f := newSCIMFixture(t)
+	theirs := f.createUser(t, "other", "eve@other.example")
+	theirGroup, err := f.scim.CreateGroup(ctx, "other", &scim.Group{DisplayName: "staff"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ourGroup, err := f.scim.CreateGroup(ctx, "acme", &scim.Group{DisplayName: "staff"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	activeFalse := false
+
+	tests := []struct {
+		name       string
+		call       func() error
+		wantStatus int
+	}{
+		{name: "get user", call: func() error { _, err := f.scim.GetUser(ctx, "acme", theirs.ID); return err }, wantStatus: http.StatusNotFound},
+		{name: "replace user", call: func() error {
+			_, err := f.scim.ReplaceUser(ctx, "acme", theirs.ID, &scim.User{UserName: "eve@other.example", Active: &activeFalse})
+			return err
+		}, wantStatus: http.StatusNotFound},
+		{name: "patch user", call: func() error {
+			_, err := f.scim.PatchUser(ctx, "acme", theirs.ID, &scim.PatchRequest{Operations: []scim.PatchOperation{{Op: "replace", Path: "active", Value: json.RawMessage(`false`)}}})
+			return err
+		}, wantStatus: http.StatusNotFound},
+		{name: "delete user", call: func() error { return f.scim.DeleteUser(ctx, "acme", theirs.ID) }, wantStatus: http.StatusNotFound},
+		{name: "get group", call: func() error { _, err := f.scim.GetGroup(ctx, "acme", theirGroup.ID, false); return err }, wantStatus: http.StatusNotFound},
+		{name: "delete group", call: func() error { return f.scim.DeleteGroup(ctx, "acme", theirGroup.ID) }, wantStatus: http.StatusNotFound},
+		{name: "add another tenant's user to a group", call: func() error {
+			_, err := f.scim.PatchGroup(ctx, "acme", ourGroup.ID, &scim.PatchRequest{Operations: []scim.PatchOperation{
+				{Op: "add", Path: "members", Value: json.RawMessage(`[{"value":"` + theirs.ID + `"}]`)},
+			}})
+			return err
+		}, wantStatus: http.StatusBadRequest},
+		{name: "malformed ID", call: func() error { _, err := f.scim.GetUser(ctx, "acme", "42"); return err }, wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantSCIMError(t, tt.call(), tt.wantStatus)
+		})
+	}
+
+	user, err := f.repos.Users.GetUserByEmail(ctx, "eve@other.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Disabled || user.Role != models.RoleUser || user.Tenant != "other" {
+		t.Fatalf("another tenant's user was changed: %+v", user)
+	}
+	groups, err := f.scim.ListGroups(ctx, "acme", SCIMQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list := groups.Resources.([]*scim.Group); len(list) != 1 || list[0].ID != ourGroup.ID {
+		t.Fatalf("acme sees groups %+v", list)
+	}
+}
+
+// Users sign in by email alone, so userName is unique across tenants, not within one
+func TestSCIMUserNameUniqueAcrossTenants(t *testing.T) {
+	f := newSCIMFixture(t)
+	f.createUser(t, "other", "ada@example.com")
+	_, err := f.scim.CreateUser(context.Background(), "acme", &scim.User{UserName: "ada@example.com"})
+	wantSCIMError(t, err, http.StatusConflict)
+}
+
+func TestSCIMGroupMembership(t *testing.T) {
+	ctx := context.Background()
+	f := newSCIMFixture(t)
+	ada := f.createUser(t, "acme", "ada@acme.example")
+	grace := f.createUser(t, "acme", "grace@acme.example")
+	group, err := f.scim.CreateGroup(ctx, "acme", &scim.Group{DisplayName: "staff", Members: []scim.MultiValued{{Value: ada.ID}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	role := func(user *scim.User) string {
+		t.Helper()
+		stored, err := f.repos.Users.GetUserByEmail(ctx, user.UserName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stored.Role
+	}
+	patch := func(ops ...scim.PatchOperation) *scim.Group {
+		t.Helper()
+		patched, err := f.scim.PatchGroup(ctx, "acme", group.ID, &scim.PatchRequest{Operations: ops})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return patched
+	}
+
+	if role(ada) != "staff" {
+		t.Fatalf("a member has role %q", role(ada))
+	}
+	patched := patch(scim.PatchOperation{Op: "add", Path: "members", Value: json.RawMessage(`[{"value":"` + grace.ID + `"}]`)})
+	if len(patched.Members) != 2 || role(grace) != "staff" {
+		t.Fatalf("after add: %d members, grace has %q", len(patched.Members), role(grace))
+	}
+	patch(scim.PatchOperation{Op: "remove", Path: `members[value eq "` + ada.ID + `"]`})
+	if role(ada) != models.RoleUser {
+		t.Fatalf("a removed member kept role %q", role(ada))
+	}
+	patch(scim.PatchOperation{Op: "replace", Path: "displayName", Value: json.RawMessage(`"engineers"`)})
+	if role(grace) != "engineers" {
+		t.Fatalf("after the rename grace has %q", role(grace))
+	}
+	if err := f.scim.DeleteGroup(ctx, "acme", group.ID); err != nil {
+		t.Fatal(err)
+	}
+	if role(grace) != models.RoleUser {
+		t.Fatalf("a member of a deleted group kept role %q", role(grace))
+	}
+}
+
+func TestSCIMReservedGroupName(t *testing.T) {
+	ctx := context.Background()
+	f := newSCIMFixture(t)
+	member := f.createUser(t, "acme", "ada@acme.example")
+	group, err := f.scim.CreateGroup(ctx, "acme", &scim.Group{DisplayName: "staff", Members: []scim.MultiValued{{Value: member.ID}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{name: "create", call: func() error {
+			_, err := f.scim.CreateGroup(ctx, "acme", &scim.Group{DisplayName: "admin", Members: []scim.MultiValued{{Value: member.ID}}})
+			return err
+		}},
+		{name: "create in another case", call: func() error {
+			_, err := f.scim.CreateGroup(ctx, "acme", &scim.Group{DisplayName: " Admin "})
+			return err
+		}},
+		{name: "rename by replace", call: func() error {
+			_, err := f.scim.ReplaceGroup(ctx, "acme", group.ID, &scim.Group{DisplayName: "ADMIN"})
+			return err
+		}},
+		{name: "rename by patch", call: func() error {
+			_, err := f.scim.PatchGroup(ctx, "acme", group.ID, &scim.PatchRequest{Operations: []scim.PatchOperation{
+				{Op: "replace", Value: json.RawMessage(`{"displayName":"admin"}`)},
+			}})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantSCIMError(t, tt.call(), http.StatusBadRequest)
+			user, err := f.repos.Users.GetUserByEmail(ctx, member.UserName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user.Role == models.RoleAdmin {
+				t.Fatal("a SCIM token made a user admin")
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+// services/session_service.go
+package services
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/apperrors"
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories"
+
+	"github.com/google/uuid"
+)
+
+// lastSeenResolution limits how often a busy session's last-seen time is written back
+const lastSeenResolution = time.Minute
+
+var (
+	// ErrSessionNotFound is returned when a session doesn't exist or belongs to another user
+	ErrSessionNotFound = apperrors.NotFound("session not found")
+	// ErrSessionTerminated is returned when a token's session was revoked or has expired
+	ErrSessionTerminated = apperrors.Unauthorized("session has been terminated")
+)
+
+// ClientInfo describes the device a login came from
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+}
+
+// SessionService defines the contract for tracking and terminating login sessions
+type SessionService interface {
+	StartSession(ctx context.Context, userID uuid.UUID, client ClientInfo, ttl time.Duration) (*models.Session, error)
+	ValidateSession(ctx context.Context, id uuid.UUID) (*models.Session, error)
+	ListSessions(ctx context.Context, userID uuid.UUID) ([]models.Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error
+	RevokeAllSessions(ctx context.Context, userID uuid.UUID) error
+}
+
+type sessionService struct {
+	repo   repositories.SessionRepository
+	logger *slog.Logger
+}
+
+// NewSessionService creates a new instance of sessionService
+func NewSessionService(repo repositories.SessionRepository, logger *slog.Logger) SessionService {
+	return &sessionService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+func (s *sessionService) StartSession(ctx context.Context, userID uuid.UUID, client ClientInfo, ttl time.Duration) (*models.Session, error) {
+	now := time.Now()
+	session := &models.Session{
+		UserID:     userID,
+		IPAddress:  client.IPAddress,
+		UserAgent:  truncate(client.UserAgent, 255),
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(ttl),
+	}
+	if err := s.repo.CreateSession(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// ValidateSession checks that the session is still active and records that it was just used
+func (s *sessionService) ValidateSession(ctx context.Context, id uuid.UUID) (*models.Session, error) {
+	session, err := s.repo.GetSession(ctx, id)
+	if err != nil {
+		if apperrors.KindOf(err) == apperrors.KindNotFound {
+			return nil, ErrSessionTerminated
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if !session.IsActive(now) {
+		return nil, ErrSessionTerminated
+	}
+
+	if now.Sub(session.LastSeenAt) >= lastSeenResolution {
+		// A failed bookkeeping write shouldn't lock the user out
+		if err := s.repo.TouchSession(ctx, session.ID, now); err != nil {
+			s.logger.WarnContext(ctx, "failed to update session last seen", slog.String("session_id", session.ID.String()), slog.Any("error", err))
+		} else {
+			session.LastSeenAt = now
+		}
+	}
+	return session, nil
+}
+
+func (s *sessionService) ListSessions(ctx context.Context, userID uuid.UUID) ([]models.Session, error) {
+	return s.repo.ListActiveSessions(ctx, userID, time.Now())
+}
+
+func (s *sessionService) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	session, err := s.repo.GetSession(ctx, sessionID)
+	if err != nil && apperrors.KindOf(err) != apperrors.KindNotFound {
+		return err
+	}
+	if err != nil || session.UserID != userID {
+		return ErrSessionNotFound
+	}
+	if session.RevokedAt != nil {
+		return nil
+	}
+	return s.repo.RevokeSession(ctx, sessionID, time.Now())
+}
+
+// RevokeAllSessions signs the user out everywhere, as when their account is disabled
+func (s *sessionService) RevokeAllSessions(ctx context.Context, userID uuid.UUID) error {
+	return s.repo.RevokeUserSessions(ctx, userID, time.Now())
+}
+
+func truncate(value string, max int) string {
+	if len(value) <= max {
+		return value
+	}
+	// Drop any rune cut in half by the byte limit
+	return strings.ToValidUTF8(value[:max], "")
+}
@@ -0,0 +1,127 @@
+// services/session_service_test.go
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories/memory"
+
+	"github.com/google/uuid"
+)
+
+func TestValidateSession(t *testing.T) {
+	ctx := context.Background()
+	repo := memory.NewStore().Repositories().Sessions
+	sessions := NewSessionService(repo, discardLogger())
+	userID := uuid.New()
+
+	store := func(lastSeen, expiresIn time.Duration, revoked bool) uuid.UUID {
+		t.Helper()
+		now := time.Now()
+		session := &models.Session{UserID: userID, LastSeenAt: now.Add(lastSeen), ExpiresAt: now.Add(expiresIn)}
+		if revoked {
+			session.RevokedAt = &now
+		}
+		if err := repo.CreateSession(ctx, session); err != nil {
+			t.Fatal(err)
+		}
+		return session.ID
+	}
+
+	tests := []struct {
+		name        string
+		id          uuid.UUID
+		wantErr     error
+		wantTouched bool
+	}{
+		{name: "recently seen", id: store(-time.Second, time.Hour, false)},
+		{name: "idle", id: store(-time.Hour, time.Hour, false), wantTouched: true},
+		{name: "expired", id: store(-time.Hour, -time.Second, false), wantErr: ErrSessionTerminated},
+		{name: "revoked", id: store(0, time.Hour, true), wantErr: ErrSessionTerminated},
+		{name: "unknown", id: uuid.New(), wantErr: ErrSessionTerminated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, _ := repo.GetSession(ctx, tt.id)
+			session, err := sessions.ValidateSession(ctx, tt.id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			stored, err := repo.GetSession(ctx, tt.id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if touched := stored.LastSeenAt.After(before.LastSeenAt); touched != tt.wantTouched {
+				t.Fatalf("last seen updated = %v, want %v", touched, tt.wantTouched)
+			}
+			if !session.LastSeenAt.Equal(stored.LastSeenAt) {
+				t.Fatalf("returned last seen %v, stored %v", session.LastSeenAt, stored.LastSeenAt)
+			}
+		})
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	ctx := context.Background()
+	sessions := NewSessionService(memory.NewStore().Repositories().Sessions, discardLogger())
+	owner, other := uuid.New(), uuid.New()
+
+	start := func(userID uuid.UUID) uuid.UUID {
+		t.Helper()
+		session, err := sessions.StartSession(ctx, userID, ClientInfo{}, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return session.ID
+	}
+	revoked := start(owner)
+	if err := sessions.RevokeSession(ctx, owner, revoked); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		userID      uuid.UUID
+		id          uuid.UUID
+		wantErr     error
+		stillActive bool
+	}{
+		{name: "own session", userID: owner, id: start(owner)},
+		{name: "already revoked", userID: owner, id: revoked},
+		{name: "someone else's", userID: other, id: start(owner), wantErr: ErrSessionNotFound, stillActive: true},
+		{name: "unknown", userID: owner, id: uuid.New(), wantErr: ErrSessionNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := sessions.RevokeSession(ctx, tt.userID, tt.id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if _, err := sessions.ValidateSession(ctx, tt.id); (err == nil) != tt.stillActive {
+				t.Fatalf("session still active = %v, want %v", err == nil, tt.stillActive)
+			}
+		})
+	}
+}
+
+func TestStartSessionTruncatesUserAgent(t *testing.T) {
+	sessions := NewSessionService(memory.NewStore().Repositories().Sessions, discardLogger())
+	// A three byte rune straddles the 255 byte limit
+	userAgent := strings.Repeat("a", 254) + "€"
+	session, err := sessions.StartSession(context.Background(), uuid.New(), ClientInfo{UserAgent: userAgent}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(session.UserAgent) != 254 || !utf8.ValidString(session.UserAgent) {
+		t.Fatalf("user agent truncated to %d bytes, valid UTF-8 %v", len(session.UserAgent), utf8.ValidString(session.UserAgent))
+	}
+}
@@ -0,0 +1,35 @@
+// services/smtp_credentials.go
+package services
+
+import "sync"
+
+// SMTPCredentials holds the SMTP login. Mail code reads it for every connection, so a rotated
+// SMTP_PASS takes effect without a restart.
+type SMTPCredentials struct {
+	mu       sync.RWMutex
+	username string
+	password string
+}
+
+// NewSMTPCredentials creates a new instance of SMTPCredentials
+func NewSMTPCredentials(username, password string) *SMTPCredentials {
+	return &SMTPCredentials{username: username, password: password}
+}
+
+// Get returns the current login
+func (c *SMTPCredentials) Get() (username, password string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.username, c.password
+}
+
+// Set replaces the login. It reports whether it actually changed.
+func (c *SMTPCredentials) Set(username, password string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.username == username && c.password == password {
+		return false
+	}
+	c.username, c.password = username, password
+	return true
+}
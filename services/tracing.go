@@ -0,0 +1,47 @@
+// services/tracing.go
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/ABDULS21985/test-portal/apperrors"
+	"github.com/ABDULS21985/test-portal/scim"
+	"github.com/ABDULS21985/test-portal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startSpan begins a span named after the service method, e.g. "UserService.DeleteUser". Pair it
+// with a deferred endSpan over the method's named error result.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Start(ctx, name, attrs...)
+}
+
+// endSpan ends span, recording *errp. Like the HTTP spans, only server-side failures mark the
+// span as errored; a not found or a validation error is the caller's problem and is only
+// recorded as error.type.
+func endSpan(span trace.Span, errp *error) {
+	defer span.End()
+	err := *errp
+	if err == nil {
+		return
+	}
+
+	span.RecordError(err)
+	var scimErr *scim.Error
+	switch {
+	case errors.As(err, &scimErr):
+		if scimErr.HTTPStatus < http.StatusInternalServerError {
+			span.SetAttributes(attribute.String("error.type", scimErr.Status))
+			return
+		}
+	case apperrors.KindOf(err) != apperrors.KindInternal:
+		span.SetAttributes(attribute.String("error.type", apperrors.KindOf(err).String()))
+		return
+	}
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,134 @@
+// services/tracing_test.go
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories"
+	"github.com/ABDULS21985/test-portal/repositories/memory"
+	"github.com/ABDULS21985/test-portal/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs a provider exporting to memory for the rest of the test and returns a
+// function that flushes it and returns the finished spans
+func recordSpans(t *testing.T) func() tracetest.SpanStubs {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider, err := tracing.NewProvider(exporter, tracing.Options{ServiceName: "test-portal", SampleRatio: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		provider.Shutdown(context.Background())
+		otel.SetTracerProvider(previous)
+	})
+
+	return func() tracetest.SpanStubs {
+		if err := provider.ForceFlush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return exporter.GetSpans()
+	}
+}
+
+// brokenUsers fails every lookup the way a lost database connection would
+type brokenUsers struct {
+	repositories.UserRepository
+}
+
+func (brokenUsers) GetUserByID(context.Context, uuid.UUID) (*models.User, error) {
+	return nil, errors.New("connection reset by peer")
+}
+
+func TestServiceSpans(t *testing.T) {
+	repos := memory.NewStore().Repositories()
+	users := NewUserService(repos.Users)
+	broken := NewUserService(brokenUsers{repos.Users})
+	scimService := NewSCIMService(repos.Users, repos.Roles, repos.Sessions, nil, models.RoleUser)
+	missing := uuid.New()
+
+	tests := []struct {
+		name          string
+		call          func(ctx context.Context) error
+		wantSpan      string
+		wantStatus    codes.Code
+		wantErrorType string
+	}{
+		{
+			name: "success",
+			call: func(ctx context.Context) error {
+				return users.RegisterUser(ctx, &models.User{Email: "a@example.com", Password: "password1"})
+			},
+			wantSpan: "UserService.RegisterUser",
+		},
+		{
+			name:          "client error",
+			call:          func(ctx context.Context) error { _, err := users.GetUserProfile(ctx, missing); return err },
+			wantSpan:      "UserService.GetUserProfile",
+			wantErrorType: "not_found",
+		},
+		{
+			name:       "server error",
+			call:       func(ctx context.Context) error { _, err := broken.GetUserProfile(ctx, missing); return err },
+			wantSpan:   "UserService.GetUserProfile",
+			wantStatus: codes.Error,
+		},
+		{
+			name: "SCIM error",
+			call: func(ctx context.Context) error {
+				_, err := scimService.GetUser(ctx, "acme", missing.String())
+				return err
+			},
+			wantSpan:      "SCIMService.GetUser",
+			wantErrorType: "404",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spans := recordSpans(t)
+			ctx, parent := tracing.Start(context.Background(), "request")
+			tt.call(ctx)
+			parent.End()
+
+			finished := spans()
+			var span *tracetest.SpanStub
+			for i := range finished {
+				if finished[i].Name == tt.wantSpan {
+					span = &finished[i]
+				}
+			}
+			if span == nil {
+				t.Fatalf("no %s span among %d finished spans", tt.wantSpan, len(finished))
+			}
+			if span.Parent.SpanID() != parent.SpanContext().SpanID() {
+				t.Fatal("the service span is not a child of the request span")
+			}
+			if span.Status.Code != tt.wantStatus {
+				t.Fatalf("status = %v, want %v", span.Status.Code, tt.wantStatus)
+			}
+			var errorType string
+			for _, kv := range span.Attributes {
+				if kv.Key == attribute.Key("error.type") {
+					errorType = kv.Value.AsString()
+				}
+			}
+			if errorType != tt.wantErrorType {
+				t.Fatalf("error.type = %q, want %q", errorType, tt.wantErrorType)
+			}
+			if tt.wantStatus == codes.Unset && tt.wantErrorType == "" && len(span.Events) > 0 {
+				t.Fatalf("a successful call recorded %v", span.Events)
+			}
+		})
+	}
+}
@@ -2,19 +2,35 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/apperrors"
 	"github.com/ABDULS21985/test-portal/models"
 	"github.com/ABDULS21985/test-portal/repositories"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // UserService defines the contract for user-related business logic
 type UserService interface {
-	RegisterUser(user *models.User) error
-	GetUserProfile(id uuid.UUID) (*models.User, error)
-	UpdateUserProfile(user *models.User) error
-	DeleteUser(id uuid.UUID) error
+	RegisterUser(ctx context.Context, user *models.User) error
+	GetUserProfile(ctx context.Context, id uuid.UUID) (*models.User, error)
+	UpdateUserProfile(ctx context.Context, id uuid.UUID, update ProfileUpdate) (*models.User, error)
+	DeleteUser(ctx context.Context, id uuid.UUID) error
+}
+
+// ProfileUpdate holds the fields a user may change about themselves; nil fields keep their
+// stored values
+type ProfileUpdate struct {
+	Name        *string
+	Email       *string
+	Password    *string
+	Phone       *string
+	DateOfBirth *time.Time
 }
 
 type userService struct {
@@ -28,32 +44,78 @@ func NewUserService(repo repositories.UserRepository) UserService {
 	}
 }
 
-func (s *userService) RegisterUser(user *models.User) error {
+func (s *userService) RegisterUser(ctx context.Context, user *models.User) (err error) {
+	ctx, span := startSpan(ctx, "UserService.RegisterUser")
+	defer endSpan(span, &err)
+
+	if user.Email == "" || user.Password == "" {
+		return apperrors.Validation("email and password are required")
+	}
 	// Hash the password before saving
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	hashedPassword, err := hashPassword(user.Password)
 	if err != nil {
 		return err
 	}
 	user.Password = string(hashedPassword)
-	return s.repo.CreateUser(user)
+	return s.repo.CreateUser(ctx, user)
 }
 
-func (s *userService) GetUserProfile(id uuid.UUID) (*models.User, error) {
-	return s.repo.GetUserByID(id)
+func (s *userService) GetUserProfile(ctx context.Context, id uuid.UUID) (_ *models.User, err error) {
+	ctx, span := startSpan(ctx, "UserService.GetUserProfile", attribute.String("user.id", id.String()))
+	defer endSpan(span, &err)
+	return s.repo.GetUserByID(ctx, id)
 }
 
-func (s *userService) UpdateUserProfile(user *models.User) error {
-	// Optionally, handle password updates here
-	if user.Password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+func (s *userService) UpdateUserProfile(ctx context.Context, id uuid.UUID, update ProfileUpdate) (_ *models.User, err error) {
+	ctx, span := startSpan(ctx, "UserService.UpdateUserProfile", attribute.String("user.id", id.String()))
+	defer endSpan(span, &err)
+
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Name != nil {
+		user.Name = *update.Name
+	}
+	if update.Email != nil {
+		user.Email = *update.Email
+	}
+	if update.Phone != nil {
+		user.Phone = *update.Phone
+	}
+	if update.DateOfBirth != nil {
+		user.DateOfBirth = update.DateOfBirth
+	}
+	if update.Password != nil {
+		// Directory users sign in with their directory password
+		if !user.IsLocal() {
+			return nil, apperrors.Validation("the password of a " + user.AuthSource + " user can't be changed here")
+		}
+		hashedPassword, err := hashPassword(*update.Password)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		user.Password = string(hashedPassword)
 	}
-	return s.repo.UpdateUser(user)
+
+	if err := s.repo.UpdateUserProfile(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *userService) DeleteUser(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, span := startSpan(ctx, "UserService.DeleteUser", attribute.String("user.id", id.String()))
+	defer endSpan(span, &err)
+	return s.repo.DeleteUser(ctx, id)
 }
 
-func (s *userService) DeleteUser(id uuid.UUID) error {
-	return s.repo.DeleteUser(id)
+// hashPassword bcrypts a password, reporting one bcrypt can't hash as a validation error
+func hashPassword(password string) ([]byte, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return nil, apperrors.Validation("password must be at most 72 bytes").WithCause(err)
+	}
+	return hashed, err
 }
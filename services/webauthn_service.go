@@ -0,0 +1,393 @@
+// services/webauthn_service.go
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/ABDULS21985/test-portal/apperrors"
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrChallengeNotFound is returned when a ceremony challenge is unknown, expired or already used
+	ErrChallengeNotFound = apperrors.Validation("webauthn challenge not found or expired")
+	// ErrPasskeyRejected is returned when an authenticator response fails verification
+	ErrPasskeyRejected = apperrors.Validation("passkey verification failed")
+	// ErrCredentialNotFound is returned when a user has no credential with the given ID
+	ErrCredentialNotFound = apperrors.NotFound("passkey not found")
+)
+
+// WebAuthnConfig identifies this server as a WebAuthn relying party
+type WebAuthnConfig struct {
+	RPID          string
+	RPDisplayName string
+	RPOrigins     []string
+	ChallengeTTL  time.Duration
+}
+
+// WebAuthnService defines the contract for passkey registration and authentication ceremonies.
+// Passkeys can be used on their own (passwordless) or as a second factor after a password.
+type WebAuthnService interface {
+	BeginRegistration(ctx context.Context, userID uuid.UUID) (*protocol.CredentialCreation, uuid.UUID, error)
+	FinishRegistration(ctx context.Context, userID, challengeID uuid.UUID, name string, response []byte) (*models.WebAuthnCredential, error)
+	ListCredentials(ctx context.Context, userID uuid.UUID) ([]models.WebAuthnCredential, error)
+	DeleteCredential(ctx context.Context, userID, credentialID uuid.UUID) error
+	HasCredentials(ctx context.Context, userID uuid.UUID) (bool, error)
+
+	BeginLogin(ctx context.Context, email string) (*protocol.CredentialAssertion, uuid.UUID, error)
+	BeginSecondFactor(ctx context.Context, user *models.User) (*protocol.CredentialAssertion, uuid.UUID, error)
+	FinishLogin(ctx context.Context, challengeID uuid.UUID, response []byte) (*models.User, error)
+}
+
+type webAuthnService struct {
+	webAuthn     *webauthn.WebAuthn
+	userRepo     repositories.UserRepository
+	webAuthnRepo repositories.WebAuthnRepository
+	challengeTTL time.Duration
+}
+
+// NewWebAuthnService creates a new instance of webAuthnService
+func NewWebAuthnService(cfg WebAuthnConfig, userRepo repositories.UserRepository, webAuthnRepo repositories.WebAuthnRepository) (WebAuthnService, error) {
+	if cfg.ChallengeTTL == 0 {
+		cfg.ChallengeTTL = 5 * time.Minute
+	}
+
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: cfg.RPDisplayName,
+		RPOrigins:     cfg.RPOrigins,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementPreferred,
+			UserVerification: protocol.VerificationPreferred,
+		},
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        webauthn.TimeoutConfig{Enforce: true, Timeout: cfg.ChallengeTTL},
+			Registration: webauthn.TimeoutConfig{Enforce: true, Timeout: cfg.ChallengeTTL},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &webAuthnService{
+		webAuthn:     w,
+		userRepo:     userRepo,
+		webAuthnRepo: webAuthnRepo,
+		challengeTTL: cfg.ChallengeTTL,
+	}, nil
+}
+
+// webAuthnUser adapts a portal user and their stored credentials to webauthn.User
+type webAuthnUser struct {
+	user        *models.User
+	credentials []models.WebAuthnCredential
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte {
+	id := u.user.ID
+	return id[:]
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	if u.user.Name != "" {
+		return u.user.Name
+	}
+	return u.user.Email
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.credentials))
+	for _, c := range u.credentials {
+		var transports []protocol.AuthenticatorTransport
+		for _, t := range strings.Split(c.Transports, ",") {
+			if t != "" {
+				transports = append(transports, protocol.AuthenticatorTransport(t))
+			}
+		}
+
+		credentials = append(credentials, webauthn.Credential{
+			ID:              c.CredentialID,
+			PublicKey:       c.PublicKey,
+			AttestationType: c.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				UserPresent:    true,
+				BackupEligible: c.BackupEligible,
+				BackupState:    c.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    c.AAGUID,
+				SignCount: c.SignCount,
+			},
+		})
+	}
+	return credentials
+}
+
+func (s *webAuthnService) loadUser(ctx context.Context, userID uuid.UUID) (*webAuthnUser, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	credentials, err := s.webAuthnRepo.ListCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &webAuthnUser{user: user, credentials: credentials}, nil
+}
+
+func (s *webAuthnService) BeginRegistration(ctx context.Context, userID uuid.UUID) (*protocol.CredentialCreation, uuid.UUID, error) {
+	user, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	// Stop the same authenticator from being registered twice
+	var exclusions []protocol.CredentialDescriptor
+	for _, c := range user.WebAuthnCredentials() {
+		exclusions = append(exclusions, c.Descriptor())
+	}
+
+	creation, session, err := s.webAuthn.BeginRegistration(user, webauthn.WithExclusions(exclusions))
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	challengeID, err := s.saveChallenge(ctx, &userID, models.WebAuthnCeremonyRegistration, session)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	return creation, challengeID, nil
+}
+
+func (s *webAuthnService) FinishRegistration(ctx context.Context, userID, challengeID uuid.UUID, name string, response []byte) (*models.WebAuthnCredential, error) {
+	challenge, session, err := s.consumeChallenge(ctx, challengeID, models.WebAuthnCeremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.UserID == nil || *challenge.UserID != userID {
+		return nil, ErrChallengeNotFound
+	}
+
+	user, err := s.loadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return nil, ErrPasskeyRejected
+	}
+	credential, err := s.webAuthn.CreateCredential(user, *session, parsed)
+	if err != nil {
+		return nil, ErrPasskeyRejected
+	}
+
+	transports := make([]string, 0, len(credential.Transport))
+	for _, t := range credential.Transport {
+		transports = append(transports, string(t))
+	}
+	if strings.TrimSpace(name) == "" {
+		name = "Passkey"
+	}
+
+	stored := &models.WebAuthnCredential{
+		UserID:          userID,
+		Name:            truncate(name, 100),
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		Transports:      strings.Join(transports, ","),
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+	}
+	if err := s.webAuthnRepo.CreateCredential(ctx, stored); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+func (s *webAuthnService) ListCredentials(ctx context.Context, userID uuid.UUID) ([]models.WebAuthnCredential, error) {
+	return s.webAuthnRepo.ListCredentials(ctx, userID)
+}
+
+func (s *webAuthnService) DeleteCredential(ctx context.Context, userID, credentialID uuid.UUID) error {
+	deleted, err := s.webAuthnRepo.DeleteCredential(ctx, userID, credentialID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrCredentialNotFound
+	}
+	return nil
+}
+
+func (s *webAuthnService) HasCredentials(ctx context.Context, userID uuid.UUID) (bool, error) {
+	credentials, err := s.webAuthnRepo.ListCredentials(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return len(credentials) > 0, nil
+}
+
+// BeginLogin starts a passwordless login. With an email the user's own credentials are offered;
+// otherwise, or if the email is unknown, the browser picks a discoverable passkey. Falling back
+// instead of failing avoids revealing which emails have accounts.
+func (s *webAuthnService) BeginLogin(ctx context.Context, email string) (*protocol.CredentialAssertion, uuid.UUID, error) {
+	if email != "" {
+		if user, err := s.userRepo.GetUserByEmail(ctx, email); err == nil {
+			if wUser, err := s.loadUser(ctx, user.ID); err == nil && len(wUser.credentials) > 0 {
+				assertion, session, err := s.webAuthn.BeginLogin(wUser, webauthn.WithUserVerification(protocol.VerificationRequired))
+				if err != nil {
+					return nil, uuid.Nil, err
+				}
+				challengeID, err := s.saveChallenge(ctx, &user.ID, models.WebAuthnCeremonyLogin, session)
+				return assertion, challengeID, err
+			}
+		}
+	}
+
+	assertion, session, err := s.webAuthn.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	challengeID, err := s.saveChallenge(ctx, nil, models.WebAuthnCeremonyLogin, session)
+	return assertion, challengeID, err
+}
+
+// BeginSecondFactor starts the passkey step of a password login for an already verified user
+func (s *webAuthnService) BeginSecondFactor(ctx context.Context, user *models.User) (*protocol.CredentialAssertion, uuid.UUID, error) {
+	wUser, err := s.loadUser(ctx, user.ID)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	assertion, session, err := s.webAuthn.BeginLogin(wUser)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	challengeID, err := s.saveChallenge(ctx, &user.ID, models.WebAuthnCeremonyMFA, session)
+	return assertion, challengeID, err
+}
+
+// FinishLogin verifies the authenticator's assertion for a login or second-factor challenge
+func (s *webAuthnService) FinishLogin(ctx context.Context, challengeID uuid.UUID, response []byte) (*models.User, error) {
+	challenge, session, err := s.consumeChallenge(ctx, challengeID, models.WebAuthnCeremonyLogin, models.WebAuthnCeremonyMFA)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return nil, ErrPasskeyRejected
+	}
+
+	var (
+		wUser      *webAuthnUser
+		credential *webauthn.Credential
+	)
+	if challenge.UserID != nil {
+		if wUser, err = s.loadUser(ctx, *challenge.UserID); err != nil {
+			return nil, ErrPasskeyRejected
+		}
+		credential, err = s.webAuthn.ValidateLogin(wUser, *session, parsed)
+	} else {
+		handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+			userID, err := uuid.FromBytes(userHandle)
+			if err != nil {
+				return nil, err
+			}
+			wUser, err = s.loadUser(ctx, userID)
+			return wUser, err
+		}
+		_, credential, err = s.webAuthn.ValidatePasskeyLogin(handler, *session, parsed)
+	}
+	if err != nil {
+		return nil, ErrPasskeyRejected
+	}
+
+	// A counter that went backwards means the private key may have been copied
+	if credential.Authenticator.CloneWarning {
+		return nil, ErrPasskeyRejected
+	}
+	if wUser.user.Disabled {
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := s.recordUse(ctx, credential); err != nil {
+		return nil, err
+	}
+	return wUser.user, nil
+}
+
+func (s *webAuthnService) recordUse(ctx context.Context, credential *webauthn.Credential) error {
+	stored, err := s.webAuthnRepo.GetCredentialByCredentialID(ctx, credential.ID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	stored.SignCount = credential.Authenticator.SignCount
+	stored.BackupState = credential.Flags.BackupState
+	stored.LastUsedAt = &now
+	return s.webAuthnRepo.UpdateCredential(ctx, stored)
+}
+
+func (s *webAuthnService) saveChallenge(ctx context.Context, userID *uuid.UUID, ceremony string, session *webauthn.SessionData) (uuid.UUID, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	challenge := &models.WebAuthnChallenge{
+		ID:          uuid.New(),
+		UserID:      userID,
+		Ceremony:    ceremony,
+		SessionData: string(data),
+		ExpiresAt:   time.Now().Add(s.challengeTTL),
+	}
+	if err := s.webAuthnRepo.CreateChallenge(ctx, challenge); err != nil {
+		return uuid.Nil, err
+	}
+	return challenge.ID, nil
+}
+
+func (s *webAuthnService) consumeChallenge(ctx context.Context, id uuid.UUID, ceremonies ...string) (*models.WebAuthnChallenge, *webauthn.SessionData, error) {
+	challenge, err := s.webAuthnRepo.ConsumeChallenge(ctx, id, time.Now())
+	if err != nil {
+		if apperrors.KindOf(err) == apperrors.KindNotFound {
+			return nil, nil, ErrChallengeNotFound
+		}
+		return nil, nil, err
+	}
+
+	valid := false
+	for _, ceremony := range ceremonies {
+		if challenge.Ceremony == ceremony {
+			valid = true
+		}
+	}
+	if !valid {
+		return nil, nil, ErrChallengeNotFound
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal([]byte(challenge.SessionData), &session); err != nil {
+		return nil, nil, err
+	}
+	return challenge, &session, nil
+}
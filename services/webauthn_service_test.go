@@ -0,0 +1,346 @@
+// services/webauthn_service_test.go
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ABDULS21985/test-portal/models"
+	"github.com/ABDULS21985/test-portal/repositories"
+	"github.com/ABDULS21985/test-portal/repositories/memory"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/google/uuid"
+)
+
+const (
+	testRPID   = "localhost"
+	testOrigin = "http://localhost:8080"
+)
+
+// Authenticator data flags (WebAuthn §6.1)
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+)
+
+// softAuthenticator is a software passkey: an ES256 key pair that answers registration and
+// login ceremonies the way a browser and platform authenticator would
+type softAuthenticator struct {
+	t            *testing.T
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentialID := make([]byte, 32)
+	rand.Read(credentialID)
+	return &softAuthenticator{t: t, key: key, credentialID: credentialID}
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func (a *softAuthenticator) clientData(ceremony string, challenge protocol.URLEncodedBase64) []byte {
+	data, err := json.Marshal(map[string]string{
+		"type":      ceremony,
+		"challenge": challenge.String(),
+		"origin":    testOrigin,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return data
+}
+
+func (a *softAuthenticator) authData(flags byte, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	return append(data, attested...)
+}
+
+// register answers navigator.credentials.create() for creation
+func (a *softAuthenticator) register(creation *protocol.CredentialCreation) []byte {
+	a.t.Helper()
+	a.userHandle = creation.Response.User.ID.(protocol.URLEncodedBase64)
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  1, // P-256
+		XCoord: a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	attested := make([]byte, 16) // AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.credentialID)))
+	attested = append(attested, a.credentialID...)
+	attested = append(attested, publicKey...)
+
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authData(flagUserPresent|flagUserVerified|flagAttested, attested),
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return a.marshal(map[string]any{
+		"clientDataJSON":    b64(a.clientData("webauthn.create", creation.Response.Challenge)),
+		"attestationObject": b64(attestation),
+	})
+}
+
+// login answers navigator.credentials.get() for assertion, advancing the signature counter
+func (a *softAuthenticator) login(assertion *protocol.CredentialAssertion) []byte {
+	a.t.Helper()
+	a.signCount++
+	return a.sign(assertion)
+}
+
+// sign answers an assertion with the counter as it stands, as a cloned key would
+func (a *softAuthenticator) sign(assertion *protocol.CredentialAssertion) []byte {
+	a.t.Helper()
+	clientData := a.clientData("webauthn.get", assertion.Response.Challenge)
+	authData := a.authData(flagUserPresent|flagUserVerified, nil)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return a.marshal(map[string]any{
+		"clientDataJSON":    b64(clientData),
+		"authenticatorData": b64(authData),
+		"signature":         b64(signature),
+		"userHandle":        b64(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) marshal(response map[string]any) []byte {
+	data, err := json.Marshal(map[string]any{
+		"id":       b64(a.credentialID),
+		"rawId":    b64(a.credentialID),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return data
+}
+
+type webAuthnFixture struct {
+	service WebAuthnService
+	repos   repositories.Repositories
+	user    *models.User
+}
+
+func newWebAuthnFixture(t *testing.T) *webAuthnFixture {
+	t.Helper()
+	repos := memory.NewStore().Repositories()
+	user := &models.User{Email: "ada@example.com", Name: "Ada", Role: models.RoleUser}
+	if err := repos.Users.CreateUser(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	service, err := NewWebAuthnService(WebAuthnConfig{RPID: testRPID, RPDisplayName: "Test Portal", RPOrigins: []string{testOrigin}}, repos.Users, repos.WebAuthn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &webAuthnFixture{service: service, repos: repos, user: user}
+}
+
+// register runs a whole registration ceremony for a new software authenticator
+func (f *webAuthnFixture) register(t *testing.T) *softAuthenticator {
+	t.Helper()
+	ctx := context.Background()
+	authenticator := newSoftAuthenticator(t)
+	creation, challengeID, err := f.service.BeginRegistration(ctx, f.user.ID)
+	if err != nil {
+		t.Fatalf("BeginRegistration: %v", err)
+	}
+	if _, err := f.service.FinishRegistration(ctx, f.user.ID, challengeID, "Laptop", authenticator.register(creation)); err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+	return authenticator
+}
+
+func TestWebAuthnRegistration(t *testing.T) {
+	ctx := context.Background()
+	f := newWebAuthnFixture(t)
+	authenticator := f.register(t)
+
+	credentials, err := f.service.ListCredentials(ctx, f.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(credentials) != 1 || string(credentials[0].CredentialID) != string(authenticator.credentialID) || credentials[0].Name != "Laptop" {
+		t.Fatalf("credentials = %+v, want the registered passkey", credentials)
+	}
+
+	t.Run("another user's challenge", func(t *testing.T) {
+		other := &models.User{Email: "alan@example.com", Role: models.RoleUser}
+		if err := f.repos.Users.CreateUser(ctx, other); err != nil {
+			t.Fatal(err)
+		}
+		creation, challengeID, err := f.service.BeginRegistration(ctx, other.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = f.service.FinishRegistration(ctx, f.user.ID, challengeID, "", newSoftAuthenticator(t).register(creation))
+		if !errors.Is(err, ErrChallengeNotFound) {
+			t.Fatalf("err = %v, want ErrChallengeNotFound", err)
+		}
+	})
+}
+
+func TestWebAuthnLogin(t *testing.T) {
+	tests := []struct {
+		name  string
+		begin func(f *webAuthnFixture) (*protocol.CredentialAssertion, uuid.UUID, error)
+	}{
+		{name: "with email", begin: func(f *webAuthnFixture) (*protocol.CredentialAssertion, uuid.UUID, error) {
+			return f.service.BeginLogin(context.Background(), f.user.Email)
+		}},
+		{name: "discoverable", begin: func(f *webAuthnFixture) (*protocol.CredentialAssertion, uuid.UUID, error) {
+			return f.service.BeginLogin(context.Background(), "")
+		}},
+		{name: "second factor", begin: func(f *webAuthnFixture) (*protocol.CredentialAssertion, uuid.UUID, error) {
+			return f.service.BeginSecondFactor(context.Background(), f.user)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newWebAuthnFixture(t)
+			authenticator := f.register(t)
+
+			assertion, challengeID, err := tt.begin(f)
+			if err != nil {
+				t.Fatalf("begin: %v", err)
+			}
+			user, err := f.service.FinishLogin(ctx, challengeID, authenticator.login(assertion))
+			if err != nil {
+				t.Fatalf("FinishLogin: %v", err)
+			}
+			if user.ID != f.user.ID {
+				t.Fatalf("logged in as %s, want %s", user.ID, f.user.ID)
+			}
+
+			stored, err := f.repos.WebAuthn.GetCredentialByCredentialID(ctx, authenticator.credentialID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.SignCount != authenticator.signCount || stored.LastUsedAt == nil {
+				t.Fatalf("sign count %d, last used %v; want %d and a time", stored.SignCount, stored.LastUsedAt, authenticator.signCount)
+			}
+		})
+	}
+}
+
+func TestWebAuthnChallengeReplay(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("registration", func(t *testing.T) {
+		f := newWebAuthnFixture(t)
+		authenticator := newSoftAuthenticator(t)
+		creation, challengeID, err := f.service.BeginRegistration(ctx, f.user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response := authenticator.register(creation)
+		if _, err := f.service.FinishRegistration(ctx, f.user.ID, challengeID, "", response); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.service.FinishRegistration(ctx, f.user.ID, challengeID, "", response); !errors.Is(err, ErrChallengeNotFound) {
+			t.Fatalf("replayed registration: err = %v, want ErrChallengeNotFound", err)
+		}
+	})
+
+	t.Run("login", func(t *testing.T) {
+		f := newWebAuthnFixture(t)
+		authenticator := f.register(t)
+		assertion, challengeID, err := f.service.BeginLogin(ctx, f.user.Email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response := authenticator.login(assertion)
+		if _, err := f.service.FinishLogin(ctx, challengeID, response); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.service.FinishLogin(ctx, challengeID, response); !errors.Is(err, ErrChallengeNotFound) {
+			t.Fatalf("replayed login: err = %v, want ErrChallengeNotFound", err)
+		}
+	})
+
+	t.Run("registration challenge used to log in", func(t *testing.T) {
+		f := newWebAuthnFixture(t)
+		authenticator := f.register(t)
+		_, challengeID, err := f.service.BeginRegistration(ctx, f.user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertion, _, err := f.service.BeginLogin(ctx, f.user.Email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.service.FinishLogin(ctx, challengeID, authenticator.login(assertion)); !errors.Is(err, ErrChallengeNotFound) {
+			t.Fatalf("err = %v, want ErrChallengeNotFound", err)
+		}
+	})
+}
+
+func TestWebAuthnCloneCounter(t *testing.T) {
+	ctx := context.Background()
+	f := newWebAuthnFixture(t)
+	authenticator := f.register(t)
+
+	for range 3 {
+		assertion, challengeID, err := f.service.BeginLogin(ctx, f.user.Email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.service.FinishLogin(ctx, challengeID, authenticator.login(assertion)); err != nil {
+			t.Fatalf("FinishLogin: %v", err)
+		}
+	}
+
+	// A copy of the key still at an earlier count
+	clone := *authenticator
+	clone.signCount = 1
+	assertion, challengeID, err := f.service.BeginLogin(ctx, f.user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.service.FinishLogin(ctx, challengeID, clone.sign(assertion)); !errors.Is(err, ErrPasskeyRejected) {
+		t.Fatalf("err = %v, want ErrPasskeyRejected", err)
+	}
+}
@@ -0,0 +1,79 @@
+// tracing/gorm.go
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const spanKey = "tracing:span"
+
+// InstrumentDB creates a client span for every GORM operation, as a child of the span in the
+// statement's context (see db.WithContext). The recorded statement keeps its placeholders; bind
+// values are never attached to spans.
+func InstrumentDB(db *gorm.DB) error {
+	before := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			_, span := tracer().Start(tx.Statement.Context, operation,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					attribute.String("db.system.name", "postgresql"),
+					attribute.String("db.operation.name", operation),
+				),
+			)
+			tx.InstanceSet(spanKey, span)
+		}
+	}
+	after := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			value, ok := tx.InstanceGet(spanKey)
+			if !ok {
+				return
+			}
+			span, ok := value.(trace.Span)
+			if !ok {
+				return
+			}
+			defer span.End()
+
+			// The table is only known once the statement has been built
+			if table := tx.Statement.Table; table != "" {
+				span.SetName(operation + " " + table)
+				span.SetAttributes(attribute.String("db.collection.name", table))
+			}
+			span.SetAttributes(
+				attribute.String("db.query.text", tx.Statement.SQL.String()),
+				attribute.Int64("db.response.returned_rows", tx.Statement.RowsAffected),
+			)
+			if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+				span.RecordError(tx.Error)
+				span.SetStatus(codes.Error, tx.Error.Error())
+			}
+		}
+	}
+
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("gorm:create").Register("tracing:create:before", before("create")),
+		cb.Create().After("gorm:create").Register("tracing:create:after", after("create")),
+		cb.Query().Before("gorm:query").Register("tracing:query:before", before("select")),
+		cb.Query().After("gorm:query").Register("tracing:query:after", after("select")),
+		cb.Update().Before("gorm:update").Register("tracing:update:before", before("update")),
+		cb.Update().After("gorm:update").Register("tracing:update:after", after("update")),
+		cb.Delete().Before("gorm:delete").Register("tracing:delete:before", before("delete")),
+		cb.Delete().After("gorm:delete").Register("tracing:delete:after", after("delete")),
+		cb.Row().Before("gorm:row").Register("tracing:row:before", before("row")),
+		cb.Row().After("gorm:row").Register("tracing:row:after", after("row")),
+		cb.Raw().Before("gorm:raw").Register("tracing:raw:before", before("raw")),
+		cb.Raw().After("gorm:raw").Register("tracing:raw:after", after("raw")),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
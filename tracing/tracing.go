@@ -0,0 +1,84 @@
+// tracing/tracing.go
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ABDULS21985/test-portal/buildinfo"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans created by this service
+const instrumentationName = "github.com/ABDULS21985/test-portal"
+
+// Options configures the tracer provider
+type Options struct {
+	Endpoint    string  // OTLP/HTTP collector URL; empty disables export
+	ServiceName string  // service.name resource attribute
+	SampleRatio float64 // fraction of root traces sampled, 0 to 1
+}
+
+// Setup installs the global tracer provider and the W3C trace context and baggage propagators.
+// Without an endpoint spans are still created, so trace IDs propagate to downstream calls, but
+// nothing is exported. The returned function flushes and stops the provider.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if opts.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(opts.Endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("tracing: creating OTLP exporter: %w", err)
+	}
+	provider, err := NewProvider(exporter, opts)
+	if err != nil {
+		return nil, err
+	}
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// NewProvider builds a tracer provider that batches spans to exporter. Tests pass an in-memory
+// exporter (go.opentelemetry.io/otel/sdk/trace/tracetest) and install the result with
+// otel.SetTracerProvider.
+func NewProvider(exporter sdktrace.SpanExporter, opts Options) (*sdktrace.TracerProvider, error) {
+	if opts.SampleRatio < 0 || opts.SampleRatio > 1 {
+		return nil, fmt.Errorf("tracing: sample ratio %v is not between 0 and 1", opts.SampleRatio)
+	}
+
+	info := buildinfo.Get()
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", opts.ServiceName),
+		attribute.String("service.version", info.Version),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("tracing: building resource: %w", err)
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	), nil
+}
+
+// tracer returns the service tracer from the current global provider, so a provider installed
+// after start-up (e.g. by a test) is picked up
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start begins an internal span, e.g. around a service method, as a child of the span in ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
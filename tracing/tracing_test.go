@@ -0,0 +1,194 @@
+// tracing/tracing_test.go
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// recordSpans installs a provider exporting to memory for the rest of the test. The returned
+// function flushes it and returns the finished spans.
+func recordSpans(t *testing.T) func() tracetest.SpanStubs {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider, err := NewProvider(exporter, Options{ServiceName: "test-portal", SampleRatio: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		provider.Shutdown(context.Background())
+		otel.SetTracerProvider(previous)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	return func() tracetest.SpanStubs {
+		if err := provider.ForceFlush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return exporter.GetSpans()
+	}
+}
+
+func attr(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestNewProviderSampleRatio(t *testing.T) {
+	for _, ratio := range []float64{-0.1, 1.5} {
+		if _, err := NewProvider(tracetest.NewInMemoryExporter(), Options{SampleRatio: ratio}); err == nil {
+			t.Errorf("ratio %v was accepted", ratio)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	const parentTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	tests := []struct {
+		name        string
+		path        string
+		traceparent string
+		status      int
+		wantName    string
+		wantRoute   string
+		wantError   bool
+	}{
+		{name: "route template", path: "/users/42", status: http.StatusOK, wantName: "GET /users/{id}", wantRoute: "/users/{id}"},
+		{name: "server error", path: "/users/42", status: http.StatusBadGateway, wantName: "GET /users/{id}", wantRoute: "/users/{id}", wantError: true},
+		{name: "client error", path: "/users/42", status: http.StatusNotFound, wantName: "GET /users/{id}", wantRoute: "/users/{id}"},
+		{name: "continues the caller's trace", path: "/users/42", status: http.StatusOK, traceparent: "00-" + parentTraceID + "-00f067aa0ba902b7-01", wantName: "GET /users/{id}", wantRoute: "/users/{id}"},
+		{name: "unmatched route", path: "/nowhere", status: http.StatusNotFound, wantName: "GET"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spans := recordSpans(t)
+
+			router := mux.NewRouter()
+			router.Use(Middleware)
+			router.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+				if !trace.SpanContextFromContext(r.Context()).IsValid() {
+					t.Error("the handler's context carries no span")
+				}
+				w.WriteHeader(tt.status)
+			})
+			// Middleware set with Router.Use doesn't run for unmatched requests
+			router.NotFoundHandler = Middleware(http.NotFoundHandler())
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.traceparent != "" {
+				req.Header.Set("traceparent", tt.traceparent)
+			}
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			got := spans()
+			if len(got) != 1 {
+				t.Fatalf("got %d spans, want 1", len(got))
+			}
+			span := got[0]
+			if span.Name != tt.wantName || span.SpanKind != trace.SpanKindServer {
+				t.Errorf("span %q of kind %v, want server span %q", span.Name, span.SpanKind, tt.wantName)
+			}
+			if route := attr(span, "http.route").AsString(); route != tt.wantRoute {
+				t.Errorf("http.route = %q, want %q", route, tt.wantRoute)
+			}
+			if code := attr(span, "http.response.status_code").AsInt64(); code != int64(tt.status) {
+				t.Errorf("http.response.status_code = %d, want %d", code, tt.status)
+			}
+			if isError := span.Status.Code == codes.Error; isError != tt.wantError {
+				t.Errorf("span status = %v, want error %v", span.Status.Code, tt.wantError)
+			}
+			if tt.traceparent != "" {
+				if span.SpanContext.TraceID().String() != parentTraceID || !span.Parent.IsRemote() {
+					t.Errorf("trace %s did not continue the caller's trace", span.SpanContext.TraceID())
+				}
+			}
+		})
+	}
+}
+
+// nopConnPool satisfies gorm.ConnPool for a dry-run session, which never touches it
+type nopConnPool struct{}
+
+var errNoDatabase = errors.New("no database")
+
+func (nopConnPool) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	return nil, errNoDatabase
+}
+
+func (nopConnPool) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	return nil, errNoDatabase
+}
+
+func (nopConnPool) QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error) {
+	return nil, errNoDatabase
+}
+
+func (nopConnPool) QueryRowContext(context.Context, string, ...interface{}) *sql.Row {
+	return nil
+}
+
+type widget struct {
+	ID   int
+	Name string
+}
+
+func TestInstrumentDB(t *testing.T) {
+	spans := recordSpans(t)
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: nopConnPool{}}), &gorm.Config{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := InstrumentDB(db); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+	var found []widget
+	db.WithContext(ctx).Where("name = ?", "secret value").Find(&found)
+	db.WithContext(ctx).Create(&widget{Name: "sprocket"})
+	parent.End()
+
+	byName := map[string]tracetest.SpanStub{}
+	for _, span := range spans() {
+		byName[span.Name] = span
+	}
+	for _, name := range []string{"select widgets", "create widgets"} {
+		span, ok := byName[name]
+		if !ok {
+			t.Fatalf("no %q span among %v", name, byName)
+		}
+		if span.SpanKind != trace.SpanKindClient || span.Parent.SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("%q is not a client span under the request span", name)
+		}
+		if table := attr(span, "db.collection.name").AsString(); table != "widgets" {
+			t.Errorf("%q: db.collection.name = %q", name, table)
+		}
+	}
+	if query := attr(byName["select widgets"], "db.query.text").AsString(); query != `SELECT * FROM "widgets" WHERE name = $1` {
+		t.Errorf("db.query.text = %q, want the statement with its placeholder", query)
+	}
+}
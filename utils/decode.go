@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/ABDULS21985/test-portal/apperrors"
+)
+
+// DefaultMaxBodyBytes is the largest request body DecodeJSON reads
+const DefaultMaxBodyBytes = 1 << 20
+
+// DecodeOptions adjusts how DecodeJSONWithOptions reads a request body
+type DecodeOptions struct {
+	// MaxBytes defaults to DefaultMaxBodyBytes
+	MaxBytes int64
+	// AllowUnknownFields ignores members dst has no field for, for formats such as SCIM whose
+	// clients may send attributes the server doesn't support
+	AllowUnknownFields bool
+}
+
+// DecodeJSON reads a JSON request body into dst and validates it, see DecodeJSONWithOptions
+func DecodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	return DecodeJSONWithOptions(w, r, dst, DecodeOptions{})
+}
+
+// DecodeJSONWithOptions reads a single JSON value from the request body into dst and checks it
+// with Validate. The body must be declared as application/json (or a +json type), stay within
+// the size limit and only contain members dst has fields for. Every failure is a domain error
+// ready for RespondWithAppError: 415, 413, or a 400 naming the invalid fields where it can.
+func DecodeJSONWithOptions(w http.ResponseWriter, r *http.Request, dst any, opts DecodeOptions) error {
+	if !isJSONContentType(r.Header.Get("Content-Type")) {
+		return apperrors.UnsupportedMediaType("Content-Type must be application/json")
+	}
+
+	maxBytes := opts.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBodyBytes
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBytes))
+	if !opts.AllowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+
+	if err := decoder.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+	if err := decoder.Decode(&json.RawMessage{}); !errors.Is(err, io.EOF) {
+		if err != nil {
+			return decodeError(err)
+		}
+		return apperrors.Validation("Request body must contain a single JSON value")
+	}
+	return Validate(dst)
+}
+
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// decodeError turns a decoding failure into the error the client sees
+func decodeError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return apperrors.TooLarge(fmt.Sprintf("Request body must not exceed %d bytes", maxBytesErr.Limit))
+	case errors.Is(err, io.EOF):
+		return apperrors.Validation("Request body is empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return apperrors.Validation("Request body contains malformed JSON").WithCause(err)
+	case errors.As(err, &syntaxErr):
+		return apperrors.Validation(fmt.Sprintf("Request body contains malformed JSON at offset %d", syntaxErr.Offset)).WithCause(err)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return apperrors.InvalidFields("Request validation failed", apperrors.FieldError{
+			Field: typeErr.Field, Code: "type", Message: "must be " + jsonTypeName(typeErr.Type),
+		}).WithCause(err)
+	}
+	// encoding/json has no error type for unknown fields
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return apperrors.InvalidFields("Request validation failed", apperrors.FieldError{
+			Field: strings.Trim(field, `"`), Code: "unknown", Message: "is not a known field",
+		}).WithCause(err)
+	}
+	return apperrors.Validation("Invalid request payload").WithCause(err)
+}
+
+// jsonTypeName describes the JSON a Go type decodes from
+func jsonTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	// Types such as uuid.UUID and time.Time decode from strings
+	if reflect.PointerTo(t).Implements(reflect.TypeFor[encoding.TextUnmarshaler]()) {
+		return "a string"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ABDULS21985/test-portal/apperrors"
+
+	"github.com/google/uuid"
+)
+
+type signup struct {
+	Email  string    `json:"email" validate:"required,email"`
+	Age    int       `json:"age" validate:"min=13"`
+	Tenant uuid.UUID `json:"tenant"`
+}
+
+// decode runs DecodeJSONWithOptions the way a handler does and renders its error, if any
+func decode(contentType, body string, opts DecodeOptions) (*httptest.ResponseRecorder, signup) {
+	req := httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	var dst signup
+	if err := DecodeJSONWithOptions(rec, req, &dst, opts); err != nil {
+		RespondWithAppError(rec, req, err)
+	} else {
+		rec.WriteHeader(http.StatusNoContent)
+	}
+	return rec, dst
+}
+
+func TestDecodeJSON(t *testing.T) {
+	valid := `{"email":"ada@example.com","age":36}`
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		opts        DecodeOptions
+		wantStatus  int
+		wantDetail  string
+		wantErrors  []apperrors.FieldError
+	}{
+		{name: "valid", contentType: "application/json", body: valid, wantStatus: http.StatusNoContent},
+		{name: "charset parameter", contentType: "application/json; charset=utf-8", body: valid, wantStatus: http.StatusNoContent},
+		{name: "+json media type", contentType: "application/merge-patch+json", body: valid, wantStatus: http.StatusNoContent},
+		{name: "no content type", body: valid, wantStatus: http.StatusUnsupportedMediaType, wantDetail: "Content-Type must be application/json"},
+		{name: "form content type", contentType: "application/x-www-form-urlencoded", body: valid, wantStatus: http.StatusUnsupportedMediaType, wantDetail: "Content-Type must be application/json"},
+		{name: "malformed content type", contentType: "application/json; charset", body: valid, wantStatus: http.StatusUnsupportedMediaType, wantDetail: "Content-Type must be application/json"},
+		{name: "too large", contentType: "application/json", body: `{"email":"` + strings.Repeat("a", 64) + `@example.com"}`, opts: DecodeOptions{MaxBytes: 32}, wantStatus: http.StatusRequestEntityTooLarge, wantDetail: "Request body must not exceed 32 bytes"},
+		{name: "within the limit", contentType: "application/json", body: valid, opts: DecodeOptions{MaxBytes: int64(len(valid))}, wantStatus: http.StatusNoContent},
+		{name: "empty body", contentType: "application/json", wantStatus: http.StatusBadRequest, wantDetail: "Request body is empty"},
+		{name: "truncated", contentType: "application/json", body: `{"email":`, wantStatus: http.StatusBadRequest, wantDetail: "Request body contains malformed JSON"},
+		{name: "syntax error", contentType: "application/json", body: `{"email" "ada@example.com"}`, wantStatus: http.StatusBadRequest, wantDetail: "Request body contains malformed JSON at offset 10"},
+		{
+			name: "unknown field", contentType: "application/json", body: `{"email":"ada@example.com","age":36,"role":"admin"}`,
+			wantStatus: http.StatusBadRequest, wantDetail: "Request validation failed",
+			wantErrors: []apperrors.FieldError{{Field: "role", Code: "unknown", Message: "is not a known field"}},
+		},
+		{name: "unknown field allowed", contentType: "application/json", body: `{"email":"ada@example.com","age":36,"role":"admin"}`, opts: DecodeOptions{AllowUnknownFields: true}, wantStatus: http.StatusNoContent},
+		{name: "trailing data", contentType: "application/json", body: valid + ` {"email":"eve@example.com"}`, wantStatus: http.StatusBadRequest, wantDetail: "Request body must contain a single JSON value"},
+		{name: "trailing garbage", contentType: "application/json", body: valid + ` }`, wantStatus: http.StatusBadRequest, wantDetail: "Request body contains malformed JSON at offset 38"},
+		{name: "trailing whitespace", contentType: "application/json", body: valid + "\n\t ", wantStatus: http.StatusNoContent},
+		{
+			name: "wrong type", contentType: "application/json", body: `{"email":"ada@example.com","age":"36"}`,
+			wantStatus: http.StatusBadRequest, wantDetail: "Request validation failed",
+			wantErrors: []apperrors.FieldError{{Field: "age", Code: "type", Message: "must be an integer"}},
+		},
+		{
+			name: "text type", contentType: "application/json", body: `{"email":"ada@example.com","age":36,"tenant":7}`,
+			wantStatus: http.StatusBadRequest, wantDetail: "Request validation failed",
+			wantErrors: []apperrors.FieldError{{Field: "tenant", Code: "type", Message: "must be a string"}},
+		},
+		{name: "not an object", contentType: "application/json", body: `[1,2]`, wantStatus: http.StatusBadRequest, wantDetail: "Invalid request payload"},
+		{name: "validation runs after decoding", contentType: "application/json", body: `{"email":"ada","age":12}`, wantStatus: http.StatusBadRequest, wantDetail: "Request validation failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, _ := decode(tt.contentType, tt.body, tt.opts)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if rec.Code == http.StatusNoContent {
+				return
+			}
+			if got := rec.Header().Get("Content-Type"); got != ProblemContentType {
+				t.Fatalf("Content-Type = %q", got)
+			}
+			var problem Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+				t.Fatal(err)
+			}
+			if problem.Status != tt.wantStatus || problem.Detail != tt.wantDetail {
+				t.Fatalf("problem = %+v, want %d %q", problem, tt.wantStatus, tt.wantDetail)
+			}
+			if tt.wantErrors != nil && !reflect.DeepEqual(problem.Errors, tt.wantErrors) {
+				t.Fatalf("errors = %+v, want %+v", problem.Errors, tt.wantErrors)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONValue(t *testing.T) {
+	tenant := uuid.New()
+	rec, got := decode("application/json", `{"email":"ada@example.com","age":36,"tenant":"`+tenant.String()+`"}`, DecodeOptions{})
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if want := (signup{Email: "ada@example.com", Age: 36, Tenant: tenant}); got != want {
+		t.Fatalf("decoded %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeJSONDefaultLimit(t *testing.T) {
+	body := `{"email":"` + strings.Repeat("a", DefaultMaxBodyBytes) + `@example.com"}`
+	rec, _ := decode("application/json", body, DecodeOptions{})
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413", rec.Code)
+	}
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/ABDULS21985/test-portal/apperrors"
+)
+
+// ProblemContentType is the media type of RFC 7807 error responses
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object. Code carries the apperrors kind so clients can
+// branch on it without parsing Detail; Errors lists the invalid fields of a validation failure.
+type Problem struct {
+	Type     string                 `json:"type"`
+	Title    string                 `json:"title"`
+	Status   int                    `json:"status"`
+	Detail   string                 `json:"detail,omitempty"`
+	Instance string                 `json:"instance,omitempty"`
+	Code     string                 `json:"code,omitempty"`
+	Errors   []apperrors.FieldError `json:"errors,omitempty"`
+}
+
+// NewProblem creates a problem whose type is defined by its HTTP status alone
+func NewProblem(statusCode int, detail string) Problem {
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(statusCode),
+		Status: statusCode,
+		Detail: detail,
+	}
+}
+
+// RespondWithProblem writes p as application/problem+json
+func RespondWithProblem(w http.ResponseWriter, p Problem) {
+	response, err := json.Marshal(p)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	w.Write(response)
+}
+
+// StatusForKind maps a domain error kind to its HTTP status code
+func StatusForKind(kind apperrors.Kind) int {
+	switch kind {
+	case apperrors.KindNotFound:
+		return http.StatusNotFound
+	case apperrors.KindConflict:
+		return http.StatusConflict
+	case apperrors.KindValidation:
+		return http.StatusBadRequest
+	case apperrors.KindUnauthorized:
+		return http.StatusUnauthorized
+	case apperrors.KindForbidden:
+		return http.StatusForbidden
+	case apperrors.KindRateLimited:
+		return http.StatusTooManyRequests
+	case apperrors.KindUnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	case apperrors.KindTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case apperrors.KindNotAcceptable:
+		return http.StatusNotAcceptable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// RespondWithAppError renders err as a problem. Domain errors keep their client message and get
+// the status of their kind; anything else is logged and reported as a bare 500 so driver and
+// GORM messages never reach the client.
+func RespondWithAppError(w http.ResponseWriter, r *http.Request, err error) {
+	if appErr, ok := apperrors.As(err); ok {
+		problem := NewProblem(StatusForKind(appErr.Kind), appErr.Message)
+		problem.Instance = r.URL.Path
+		problem.Code = appErr.Kind.String()
+		problem.Errors = appErr.Fields
+		if appErr.Kind == apperrors.KindRateLimited && appErr.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(appErr.RetryAfter.Seconds()))))
+		}
+		RespondWithProblem(w, problem)
+		return
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		problem := NewProblem(http.StatusGatewayTimeout, "Request timed out")
+		problem.Instance = r.URL.Path
+		RespondWithProblem(w, problem)
+		return
+	}
+
+	slog.ErrorContext(r.Context(), "request failed", slog.Any("error", err))
+	problem := NewProblem(http.StatusInternalServerError, "")
+	problem.Instance = r.URL.Path
+	problem.Code = apperrors.KindInternal.String()
+	RespondWithProblem(w, problem)
+}
@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+type clientIPKey struct{}
+
+// WithClientIP returns r carrying ip as the caller's address; see middleware.ClientIP
+func WithClientIP(r *http.Request, ip string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip))
+}
+
+// ClientIP returns the caller's address as resolved by middleware.ClientIP, or the connection's
+// peer for requests that didn't pass through it. X-Forwarded-For is never read here, since any
+// client can send it.
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return RemoteIP(r)
+}
+
+// RemoteIP returns the address of the connection's peer
+func RemoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -18,9 +18,7 @@ func RespondWithJSON(w http.ResponseWriter, statusCode int, payload interface{})
 	w.Write(response)
 }
 
-// RespondWithError sends a standardized error response
+// RespondWithError sends an RFC 7807 problem with the given status and detail message
 func RespondWithError(w http.ResponseWriter, statusCode int, message string) {
-	RespondWithJSON(w, statusCode, map[string]string{
-		"error": message,
-	})
+	RespondWithProblem(w, NewProblem(statusCode, message))
 }
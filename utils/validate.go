@@ -0,0 +1,208 @@
+package utils
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ABDULS21985/test-portal/apperrors"
+
+	"github.com/google/uuid"
+)
+
+// Validate checks v, a struct or a pointer to one, against the rules in its fields' validate
+// tags, and reports every broken rule as a field error. Nested structs, and structs in slices,
+// are checked too. Rules are comma-separated:
+//
+//	required     the value must not be the zero value
+//	omitempty    skip the other rules when the value is the zero value
+//	min=N, max=N bounds on a number, or on the length of a string (in characters) or slice
+//	email        a bare email address
+//	uuid         a UUID string
+//	oneof=a b c  one of the space-separated values
+//
+// Fields are named by their JSON key. An unknown rule is a programming error and is returned as
+// a plain error.
+func Validate(v any) error {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Pointer && !value.IsNil() {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fields []apperrors.FieldError
+	if err := validateStruct(value, "", &fields); err != nil {
+		return err
+	}
+	if len(fields) > 0 {
+		return apperrors.InvalidFields("Request validation failed", fields...)
+	}
+	return nil
+}
+
+func validateStruct(value reflect.Value, prefix string, fields *[]apperrors.FieldError) error {
+	structType := value.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			if err := validateValue(value.Field(i), prefix, "", fields); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		if err := validateValue(value.Field(i), name, field.Tag.Get("validate"), fields); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateValue applies rules to one value, then descends into it
+func validateValue(value reflect.Value, name, rules string, fields *[]apperrors.FieldError) error {
+	if rules != "" {
+		broken, err := checkRules(value, rules)
+		if err != nil {
+			return fmt.Errorf("utils: validating %s: %w", name, err)
+		}
+		if broken != nil {
+			broken.Field = name
+			*fields = append(*fields, *broken)
+			return nil
+		}
+	}
+
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Struct:
+		return validateStruct(value, name, fields)
+	case reflect.Slice, reflect.Array:
+		elem := value.Type().Elem()
+		for elem.Kind() == reflect.Pointer {
+			elem = elem.Elem()
+		}
+		if elem.Kind() != reflect.Struct {
+			return nil
+		}
+		for i := 0; i < value.Len(); i++ {
+			if err := validateValue(value.Index(i), name+"["+strconv.Itoa(i)+"]", "", fields); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkRules returns the first rule value breaks, without its Field set
+func checkRules(value reflect.Value, rules string) (*apperrors.FieldError, error) {
+	if value.IsZero() {
+		for _, rule := range strings.Split(rules, ",") {
+			switch rule {
+			case "required":
+				return &apperrors.FieldError{Code: "required", Message: "is required"}, nil
+			case "omitempty":
+				return nil, nil
+			}
+		}
+	}
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return nil, nil
+		}
+		value = value.Elem()
+	}
+
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		var broken *apperrors.FieldError
+		var err error
+		switch name {
+		case "required", "omitempty":
+		case "min", "max":
+			broken, err = checkBound(value, name, arg)
+		case "email":
+			if address, parseErr := mail.ParseAddress(value.String()); parseErr != nil || address.Address != value.String() {
+				broken = &apperrors.FieldError{Code: "email", Message: "must be a valid email address"}
+			}
+		case "uuid":
+			if _, parseErr := uuid.Parse(value.String()); parseErr != nil {
+				broken = &apperrors.FieldError{Code: "uuid", Message: "must be a UUID"}
+			}
+		case "oneof":
+			allowed := strings.Fields(arg)
+			if !slices.Contains(allowed, fmt.Sprint(value.Interface())) {
+				broken = &apperrors.FieldError{Code: "oneof", Message: "must be one of " + strings.Join(allowed, ", ")}
+			}
+		default:
+			err = fmt.Errorf("unknown validation rule %q", rule)
+		}
+		if broken != nil || err != nil {
+			return broken, err
+		}
+	}
+	return nil, nil
+}
+
+// checkBound applies a min or max rule to a number or to a length
+func checkBound(value reflect.Value, rule, arg string) (*apperrors.FieldError, error) {
+	bound, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s rule %q", rule, arg)
+	}
+
+	var actual float64
+	var unit string
+	switch {
+	case value.Kind() == reflect.String:
+		actual, unit = float64(utf8.RuneCountInString(value.String())), " characters"
+	case value.Kind() == reflect.Slice, value.Kind() == reflect.Map, value.Kind() == reflect.Array:
+		actual, unit = float64(value.Len()), " items"
+	case value.CanInt():
+		actual = float64(value.Int())
+	case value.CanUint():
+		actual = float64(value.Uint())
+	case value.CanFloat():
+		actual = value.Float()
+	default:
+		return nil, fmt.Errorf("%s rule on unsupported type %s", rule, value.Type())
+	}
+
+	if rule == "min" && actual < bound {
+		message := "must be at least " + arg
+		if unit != "" {
+			message = "must have at least " + arg + unit
+		}
+		return &apperrors.FieldError{Code: "min", Message: message}, nil
+	}
+	if rule == "max" && actual > bound {
+		message := "must be at most " + arg
+		if unit != "" {
+			message = "must have at most " + arg + unit
+		}
+		return &apperrors.FieldError{Code: "max", Message: message}, nil
+	}
+	return nil, nil
+}